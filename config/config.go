@@ -1,29 +1,71 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type (
 	Config struct {
-		Server *Server
-		Db     *Db
-		Email  *Email
-		JWT    *JWT
+		Profile  string `mapstructure:"-"`
+		Server   *Server
+		Db       *Db
+		Email    *Email
+		JWT      *JWT
+		Tracing  *Tracing
+		Media    *Media
+		Payments *Payments
+		// RateLimitStore keeps the token buckets: "memory" for a single
+		// instance or "postgres" to share them between instances.
+		RateLimitStore string `mapstructure:"rate_limit_store"`
+		// Carrier ships parcels and prints their labels. Only "fake", a
+		// local stand-in, is available so far.
+		Carrier string
+
+		// The sections below can be changed at runtime; see Watcher.
+		Log            *Log
+		RateLimits     map[string]*RateLimit `mapstructure:"rate_limits"`
+		Features       map[string]bool
+		EmailTemplates map[string]*EmailTemplate `mapstructure:"email_templates"`
+		Security       *Security
+		Inventory      *Inventory
+		Cart           *Cart
+		Returns        *Returns
 	}
 
 	Server struct {
-		Port int
+		Port            int
+		ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+		WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+		IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
+		ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+		TLS             *TLS
+		// DrainDelay is how long the server keeps serving after readiness
+		// starts failing, so load balancers stop routing to it before it
+		// stops accepting connections.
+		DrainDelay time.Duration `mapstructure:"drain_delay"`
+		// TrustedProxies are the addresses or CIDRs whose X-Forwarded-For
+		// and X-Real-IP headers are believed. With none, the client IP is
+		// the peer address, so clients can't pick their own rate limit
+		// bucket.
+		TrustedProxies []string `mapstructure:"trusted_proxies"`
+	}
+
+	TLS struct {
+		CertFile string `mapstructure:"cert_file"`
+		KeyFile  string `mapstructure:"key_file"`
 	}
 
 	Db struct {
 		Host     string
 		Port     int
 		User     string
-		Password string
+		Password string `secret:"true"`
 		DBName   string
 		SSLMode  string
 		TimeZone string
@@ -32,38 +74,354 @@ type (
 	Email struct {
 		From     string
 		Username string
-		Password string
+		Password string `secret:"true"`
 		Host     string
 		Port     string
 	}
 
+	// JWT holds the signing key set. Tokens are signed with SecretKey and
+	// carry KeyID; PreviousKeys are only accepted for verification so keys
+	// can be rotated without logging everyone out.
 	JWT struct {
-		SecretKey              string
-		ResetPasswordSecretKey string
+		SecretKey              string            `mapstructure:"secret_key" secret:"true"`
+		KeyID                  string            `mapstructure:"key_id"`
+		PreviousKeys           map[string]string `mapstructure:"previous_keys" secret:"true"`
+		ResetPasswordSecretKey string            `mapstructure:"reset_password_secret_key" secret:"true"`
+	}
+
+	// Tracing selects where spans are sent. Exporter is "none", "stdout"
+	// for local runs, or "otlp" to post them to the OTLP/HTTP collector at
+	// Endpoint. SampleRatio applies to traces that start here; incoming
+	// requests keep the sampling decision of their caller.
+	Tracing struct {
+		Exporter    string
+		Endpoint    string
+		ServiceName string  `mapstructure:"service_name"`
+		SampleRatio float64 `mapstructure:"sample_ratio"`
+	}
+
+	// Media configures where uploaded files are kept: "local" stores them
+	// under Dir, "s3" in Bucket of an S3-compatible service such as MinIO.
+	// Files are only served through URLs signed with SigningKey, which
+	// expire after URLTTL.
+	Media struct {
+		Store      string
+		Dir        string
+		S3         *S3
+		SigningKey string        `mapstructure:"signing_key" secret:"true"`
+		URLTTL     time.Duration `mapstructure:"url_ttl"`
+	}
+
+	// S3 locates a bucket. Objects are addressed path-style, as
+	// Endpoint/Bucket/key, which every S3-compatible service supports.
+	S3 struct {
+		Endpoint  string
+		Region    string
+		Bucket    string
+		AccessKey string `mapstructure:"access_key" secret:"true"`
+		SecretKey string `mapstructure:"secret_key" secret:"true"`
+	}
+
+	// Payments selects the payment provider. "simulated" is a local gateway
+	// whose outcome is chosen by the card number; see payment.Simulator.
+	// Webhooks from the provider are signed with WebhookSecret.
+	Payments struct {
+		Provider      string
+		WebhookSecret string `mapstructure:"webhook_secret" secret:"true"`
+		Simulator     *PaymentSimulator
+	}
+
+	// PaymentSimulator configures the simulated provider, which posts its
+	// webhooks to WebhookURL. Payments made with the delayed test card are
+	// only confirmed by a webhook sent after WebhookDelay.
+	PaymentSimulator struct {
+		WebhookURL   string        `mapstructure:"webhook_url"`
+		WebhookDelay time.Duration `mapstructure:"webhook_delay"`
+	}
+
+	Log struct {
+		Level string
+	}
+
+	// RateLimit is a token bucket: Rate tokens per second, up to Burst.
+	// By selects whose requests share a bucket: "ip" (the default), "user"
+	// or "api_key". Requests without that identity fall back to their IP.
+	RateLimit struct {
+		Rate  float64
+		Burst int
+		By    string
 	}
-)
 
-var (
-	once           sync.Once
-	configInstance *Config
+	// Security configures the browser-facing protections. MaxBodyBytes caps
+	// request bodies and MaxUploadBytes multipart uploads; 0 disables the
+	// limit.
+	Security struct {
+		CORS           *CORS
+		Headers        *SecurityHeaders
+		CSRF           *CSRF
+		MaxBodyBytes   int64 `mapstructure:"max_body_bytes"`
+		MaxUploadBytes int64 `mapstructure:"max_upload_bytes"`
+	}
+
+	// CORS lists the origins allowed to call the API from a browser. "*"
+	// allows any origin but cannot be combined with AllowCredentials.
+	CORS struct {
+		AllowedOrigins   []string      `mapstructure:"allowed_origins"`
+		AllowedMethods   []string      `mapstructure:"allowed_methods"`
+		AllowedHeaders   []string      `mapstructure:"allowed_headers"`
+		ExposedHeaders   []string      `mapstructure:"exposed_headers"`
+		AllowCredentials bool          `mapstructure:"allow_credentials"`
+		MaxAge           time.Duration `mapstructure:"max_age"`
+	}
+
+	// SecurityHeaders are added to every response. Empty values and a zero
+	// HSTSMaxAge leave the corresponding header out.
+	SecurityHeaders struct {
+		HSTSMaxAge            time.Duration `mapstructure:"hsts_max_age"`
+		HSTSIncludeSubdomains bool          `mapstructure:"hsts_include_subdomains"`
+		ContentSecurityPolicy string        `mapstructure:"content_security_policy"`
+		FrameOptions          string        `mapstructure:"frame_options"`
+	}
+
+	// CSRF enables double-submit cookie protection for requests that carry
+	// cookies. Clients echo the cookie's value in the HeaderName header.
+	CSRF struct {
+		Enabled    bool
+		CookieName string `mapstructure:"cookie_name"`
+		HeaderName string `mapstructure:"header_name"`
+	}
+
+	// Inventory configures stock reservations, which hold stock for
+	// ReservationTTL while the buyer checks out.
+	Inventory struct {
+		ReservationTTL time.Duration `mapstructure:"reservation_ttl"`
+	}
+
+	// Cart configures shopping carts. Guests' carts expire GuestTTL after
+	// their last change and users' carts UserTTL after it. Amounts are in
+	// the minor unit of the cart's currency, whichever it is.
+	Cart struct {
+		GuestTTL time.Duration `mapstructure:"guest_ttl"`
+		UserTTL  time.Duration `mapstructure:"user_ttl"`
+		// TaxRate is charged on the discounted subtotal, e.g. 0.2 for 20%.
+		TaxRate   float64 `mapstructure:"tax_rate"`
+		Discounts []*CartDiscount
+		Shipping  *Shipping
+	}
+
+	// CartDiscount takes Percent off carts whose subtotal is at least
+	// MinSubtotal. Only the largest discount a cart qualifies for applies.
+	CartDiscount struct {
+		MinSubtotal int64 `mapstructure:"min_subtotal"`
+		Percent     float64
+	}
+
+	// Shipping estimates delivery as FlatRate plus PerKg for every started
+	// kilogram. It is free once the discounted subtotal reaches FreeOver,
+	// unless FreeOver is 0.
+	Shipping struct {
+		FlatRate int64 `mapstructure:"flat_rate"`
+		PerKg    int64 `mapstructure:"per_kg"`
+		FreeOver int64 `mapstructure:"free_over"`
+	}
+
+	// Returns configures returns. Buyers can ask to return items of an
+	// order until Window after it was delivered.
+	Returns struct {
+		Window time.Duration
+	}
+
+	// EmailTemplate is a text/template pair for one transactional email.
+	EmailTemplate struct {
+		Subject string
+		Body    string
+	}
 )
 
-func GetConfig() *Config {
-	once.Do(func() {
-		viper.SetConfigName("config")
-		viper.SetConfigType("yaml")
-		viper.AddConfigPath("./")
-		viper.AutomaticEnv()
-		viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+// FeatureEnabled reports whether the named feature flag is switched on.
+func (c *Config) FeatureEnabled(name string) bool {
+	return c.Features[strings.ToLower(name)]
+}
+
+// ProfileEnv selects the configuration overlay, e.g. APP_ENV=prod merges
+// config.prod.yaml over config.yaml.
+const ProfileEnv = "APP_ENV"
+
+const defaultProfile = "dev"
+
+var defaults = map[string]any{
+	"server.port":                   8080,
+	"server.read_timeout":           "15s",
+	"server.write_timeout":          "15s",
+	"server.idle_timeout":           "60s",
+	"server.shutdown_timeout":       "30s",
+	"server.drain_delay":            "0s",
+	"server.trusted_proxies":        []string{},
+	"server.tls.cert_file":          "",
+	"server.tls.key_file":           "",
+	"db.host":                       "localhost",
+	"db.port":                       5432,
+	"db.user":                       "",
+	"db.password":                   "",
+	"db.dbname":                     "",
+	"db.sslmode":                    "disable",
+	"db.timezone":                   "UTC",
+	"email.from":                    "",
+	"email.username":                "",
+	"email.password":                "",
+	"email.host":                    "localhost",
+	"email.port":                    "25",
+	"jwt.secret_key":                "",
+	"jwt.key_id":                    "primary",
+	"jwt.reset_password_secret_key": "",
+	"tracing.exporter":              "none",
+	"tracing.endpoint":              "http://localhost:4318",
+	"tracing.service_name":          "ecommerce-backend",
+	"tracing.sample_ratio":          1.0,
+	"media.store":                   "local",
+	"media.dir":                     "./uploads",
+	"media.s3.endpoint":             "",
+	"media.s3.region":               "us-east-1",
+	"media.s3.bucket":               "",
+	"media.s3.access_key":           "",
+	"media.s3.secret_key":           "",
+	"media.signing_key":             "",
+	"media.url_ttl":                 "1h",
+	"rate_limit_store":              "memory",
+	"carrier":                       "fake",
+	"log.level":                     "info",
+
+	"payments.provider":                "simulated",
+	"payments.webhook_secret":          "",
+	"payments.simulator.webhook_url":   "http://localhost:8080/api/v2/payments/webhook",
+	"payments.simulator.webhook_delay": "10s",
+
+	"security.max_body_bytes":                  1 << 20,
+	"security.max_upload_bytes":                10 << 20,
+	"security.cors.allowed_origins":            []string{},
+	"security.cors.allowed_methods":            []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+	"security.cors.allowed_headers":            []string{"Authorization", "Content-Type", "X-Request-ID", "X-API-Key", "X-CSRF-Token", "X-Cart-Token", "Idempotency-Key"},
+	"security.cors.exposed_headers":            []string{"X-Request-ID", "X-Cart-Token", "Idempotent-Replayed", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
+	"security.cors.allow_credentials":          false,
+	"security.cors.max_age":                    "10m",
+	"security.headers.hsts_max_age":            "8760h",
+	"security.headers.hsts_include_subdomains": true,
+	"security.headers.content_security_policy": "default-src 'none'; frame-ancestors 'none'",
+	"security.headers.frame_options":           "DENY",
+	"security.csrf.enabled":                    false,
+	"security.csrf.cookie_name":                "csrf_token",
+	"security.csrf.header_name":                "X-CSRF-Token",
+
+	"inventory.reservation_ttl": "15m",
+
+	"cart.guest_ttl":          "168h",
+	"cart.user_ttl":           "720h",
+	"cart.tax_rate":           0.0,
+	"cart.discounts":          []map[string]any{},
+	"cart.shipping.flat_rate": 0,
+	"cart.shipping.per_kg":    0,
+	"cart.shipping.free_over": 0,
+
+	"returns.window": "720h",
+
+	"email_templates.welcome.subject": "Welcome to our Ecommerce Platform",
+	"email_templates.welcome.body": "Dear user,\n\nThank you for registering with our ecommerce platform. " +
+		"We're excited to have you on board!\n\nBest regards,\nThe Ecommerce Team",
+	"email_templates.otp.subject": "Your OTP for login",
+	"email_templates.otp.body": "Dear user,\n\nYour one-time password (OTP) for login is: {{.OTP}}\n\n" +
+		"This OTP will expire in 5 minutes.\n\nBest regards,\nThe Ecommerce Team",
+	"email_templates.reset_password.subject": "Reset your password",
+	"email_templates.reset_password.body": "Dear user,\n\nTo reset your password, please click on the following link:\n\n" +
+		"https://your-app.com/reset-password?token={{.Token}}\n\nThis link will expire in 1 hour.\n\nBest regards,\nThe Ecommerce Team",
+	"email_templates.low_stock.subject": "Low stock: {{.SKU}}",
+	"email_templates.low_stock.body": "Dear seller,\n\nOnly {{.Available}} of {{.ProductName}} ({{.SKU}}) are left in {{.Warehouse}}, " +
+		"below your threshold of {{.Threshold}}.\n\nBest regards,\nThe Ecommerce Team",
+}
+
+// Options controls where Load looks for configuration.
+type Options struct {
+	// Profile selects config.<profile>.yaml. Defaults to $APP_ENV, then "dev".
+	Profile string
+	// Paths are searched for config files. Defaults to the working directory.
+	Paths []string
+}
+
+// Load reads config.yaml, merges the profile overlay, applies environment
+// variables and *_FILE secrets, and validates the result.
+//
+// Every key can be overridden by its upper-cased environment variable, e.g.
+// DB_PASSWORD for db.password. DB_PASSWORD_FILE reads the value from a file
+// instead, which is how container secrets are usually mounted.
+func Load(opts Options) (*Config, error) {
+	profile := opts.Profile
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+	if profile == "" {
+		profile = defaultProfile
+	}
+	paths := opts.Paths
+	if len(paths) == 0 {
+		paths = []string{"./"}
+	}
+
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+	v.SetConfigType("yaml")
+	for _, path := range paths {
+		v.AddConfigPath(path)
+	}
 
-		if err := viper.ReadInConfig(); err != nil {
-			panic(err)
+	v.SetConfigName("config")
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("read config.yaml: %w", err)
 		}
+	}
 
-		if err := viper.Unmarshal(&configInstance); err != nil {
-			panic(err)
+	v.SetConfigName("config." + profile)
+	if err := v.MergeInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("read config.%s.yaml: %w", profile, err)
 		}
-	})
+	}
+
+	v.AutomaticEnv()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	if err := applySecretFiles(v); err != nil {
+		return nil, err
+	}
 
-	return configInstance
+	conf := &Config{}
+	if err := v.Unmarshal(conf); err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	conf.Profile = profile
+
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// applySecretFiles sets every key whose <KEY>_FILE environment variable is
+// present to the trimmed contents of that file.
+func applySecretFiles(v *viper.Viper) error {
+	for _, key := range v.AllKeys() {
+		env := strings.ToUpper(strings.ReplaceAll(key, ".", "_")) + "_FILE"
+		path, ok := os.LookupEnv(env)
+		if !ok || path == "" {
+			continue
+		}
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+		v.Set(key, strings.TrimSpace(string(contents)))
+	}
+	return nil
 }
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const redactedValue = "******"
+
+// Redacted returns the configuration as a nested map keyed like config.yaml,
+// with every field tagged `secret:"true"` masked. It is safe to log or print.
+func (c *Config) Redacted() map[string]any {
+	out := toMap(reflect.ValueOf(c).Elem(), true)
+	out["profile"] = c.Profile
+	return out
+}
+
+func toMap(v reflect.Value, redact bool) map[string]any {
+	out := make(map[string]any)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "-" || !field.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+
+		value := v.Field(i)
+		if redact && field.Tag.Get("secret") == "true" {
+			if !value.IsZero() {
+				out[key] = redactedValue
+			} else {
+				out[key] = ""
+			}
+			continue
+		}
+		out[key] = toValue(value, redact)
+	}
+	return out
+}
+
+func toValue(v reflect.Value, redact bool) any {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return toValue(v.Elem(), redact)
+	case reflect.Struct:
+		return toMap(v, redact)
+	case reflect.Map:
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = toValue(iter.Value(), redact)
+		}
+		return out
+	case reflect.Slice:
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = toValue(v.Index(i), redact)
+		}
+		return out
+	}
+	return v.Interface()
+}
+
+// flatten turns a nested map from toMap into dotted keys and printable values.
+func flatten(prefix string, m map[string]any, out map[string]string) {
+	for key, value := range m {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]any); ok {
+			flatten(path, nested, out)
+			continue
+		}
+		out[path] = fmt.Sprint(value)
+	}
+}
+
+// Diff describes every setting that differs between old and next, e.g.
+// "log.level: info -> debug". Secret values are reported as changed without
+// showing either value.
+func Diff(old, next *Config) []string {
+	oldRaw, nextRaw := map[string]string{}, map[string]string{}
+	flatten("", toMap(reflect.ValueOf(old).Elem(), false), oldRaw)
+	flatten("", toMap(reflect.ValueOf(next).Elem(), false), nextRaw)
+	oldSafe, nextSafe := map[string]string{}, map[string]string{}
+	flatten("", old.Redacted(), oldSafe)
+	flatten("", next.Redacted(), nextSafe)
+
+	keys := make(map[string]bool)
+	for key := range oldRaw {
+		keys[key] = true
+	}
+	for key := range nextRaw {
+		keys[key] = true
+	}
+
+	var changes []string
+	for key := range keys {
+		if oldRaw[key] == nextRaw[key] {
+			continue
+		}
+		if oldSafe[key] == nextSafe[key] {
+			changes = append(changes, key+" changed")
+		} else {
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", key, oldSafe[key], nextSafe[key]))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
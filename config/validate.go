@@ -0,0 +1,350 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const minSecretLength = 16
+
+// ValidationError lists every problem found in a configuration so they can
+// be fixed in one go.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(key, format string, args ...any) {
+	v.problems = append(v.problems, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) required(key, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf(key, "is required")
+	}
+}
+
+func (v *validator) port(key string, port int) {
+	if port < 1 || port > 65535 {
+		v.addf(key, "must be between 1 and 65535, got %d", port)
+	}
+}
+
+func (v *validator) secret(key, value string) {
+	switch {
+	case value == "":
+		v.addf(key, "is required (set %s or %s_FILE)", envName(key), envName(key))
+	case len(value) < minSecretLength:
+		v.addf(key, "must be at least %d characters", minSecretLength)
+	}
+}
+
+// credential checks a secret issued by another service, whose length is
+// not ours to choose.
+func (v *validator) credential(key, value string) {
+	if value == "" {
+		v.addf(key, "is required (set %s or %s_FILE)", envName(key), envName(key))
+	}
+}
+
+func envName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+var logLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+var traceExporters = map[string]bool{"none": true, "stdout": true, "otlp": true}
+
+var rateLimitStores = map[string]bool{"memory": true, "postgres": true}
+
+var rateLimitIdentities = map[string]bool{"": true, "ip": true, "user": true, "api_key": true}
+
+var frameOptions = map[string]bool{"": true, "DENY": true, "SAMEORIGIN": true}
+
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// Validate checks that every section is present and usable.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	if c.Server == nil {
+		v.addf("server", "section is missing")
+	} else {
+		v.port("server.port", c.Server.Port)
+		timeouts := []struct {
+			key string
+			d   time.Duration
+		}{
+			{"server.read_timeout", c.Server.ReadTimeout},
+			{"server.write_timeout", c.Server.WriteTimeout},
+			{"server.idle_timeout", c.Server.IdleTimeout},
+			{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+			{"server.drain_delay", c.Server.DrainDelay},
+		}
+		for _, t := range timeouts {
+			if t.d < 0 {
+				v.addf(t.key, "must not be negative")
+			}
+		}
+		for _, proxy := range c.Server.TrustedProxies {
+			if net.ParseIP(proxy) == nil {
+				if _, _, err := net.ParseCIDR(proxy); err != nil {
+					v.addf("server.trusted_proxies", "%q is not an IP address or CIDR", proxy)
+				}
+			}
+		}
+		if tls := c.Server.TLS; tls != nil && (tls.CertFile != "") != (tls.KeyFile != "") {
+			v.addf("server.tls", "cert_file and key_file must be set together")
+		}
+	}
+
+	if c.Db == nil {
+		v.addf("db", "section is missing")
+	} else {
+		v.required("db.host", c.Db.Host)
+		v.port("db.port", c.Db.Port)
+		v.required("db.user", c.Db.User)
+		v.required("db.dbname", c.Db.DBName)
+		if !sslModes[c.Db.SSLMode] {
+			v.addf("db.sslmode", "unknown mode %q", c.Db.SSLMode)
+		}
+	}
+
+	if c.Email == nil {
+		v.addf("email", "section is missing")
+	} else {
+		if _, err := mail.ParseAddress(c.Email.From); err != nil {
+			v.addf("email.from", "must be a valid email address")
+		}
+		v.required("email.host", c.Email.Host)
+		if port, err := strconv.Atoi(c.Email.Port); err != nil {
+			v.addf("email.port", "must be a number, got %q", c.Email.Port)
+		} else {
+			v.port("email.port", port)
+		}
+	}
+
+	if c.JWT == nil {
+		v.addf("jwt", "section is missing")
+	} else {
+		v.secret("jwt.secret_key", c.JWT.SecretKey)
+		v.secret("jwt.reset_password_secret_key", c.JWT.ResetPasswordSecretKey)
+		if c.JWT.SecretKey != "" && c.JWT.SecretKey == c.JWT.ResetPasswordSecretKey {
+			v.addf("jwt.reset_password_secret_key", "must differ from jwt.secret_key")
+		}
+		v.required("jwt.key_id", c.JWT.KeyID)
+		for id, secret := range c.JWT.PreviousKeys {
+			if id == c.JWT.KeyID {
+				v.addf("jwt.previous_keys."+id, "must not reuse the active jwt.key_id")
+			}
+			if len(secret) < minSecretLength {
+				v.addf("jwt.previous_keys."+id, "must be at least %d characters", minSecretLength)
+			}
+		}
+	}
+
+	if c.Tracing != nil {
+		if !traceExporters[c.Tracing.Exporter] {
+			v.addf("tracing.exporter", "must be one of none, stdout, otlp, got %q", c.Tracing.Exporter)
+		}
+		if c.Tracing.Exporter == "otlp" {
+			if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+				v.addf("tracing.endpoint", "must be a URL such as http://localhost:4318, got %q", c.Tracing.Endpoint)
+			}
+		}
+		v.required("tracing.service_name", c.Tracing.ServiceName)
+		if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+			v.addf("tracing.sample_ratio", "must be between 0 and 1")
+		}
+	}
+
+	if c.Media == nil {
+		v.addf("media", "section is missing")
+	} else {
+		switch c.Media.Store {
+		case "local":
+			v.required("media.dir", c.Media.Dir)
+		case "s3":
+			if c.Media.S3 == nil {
+				v.addf("media.s3", "section is missing")
+				break
+			}
+			if u, err := url.Parse(c.Media.S3.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+				v.addf("media.s3.endpoint", "must be a URL such as http://localhost:9000, got %q", c.Media.S3.Endpoint)
+			}
+			v.required("media.s3.region", c.Media.S3.Region)
+			v.required("media.s3.bucket", c.Media.S3.Bucket)
+			v.credential("media.s3.access_key", c.Media.S3.AccessKey)
+			v.credential("media.s3.secret_key", c.Media.S3.SecretKey)
+		default:
+			v.addf("media.store", "must be local or s3, got %q", c.Media.Store)
+		}
+		v.secret("media.signing_key", c.Media.SigningKey)
+		if c.Media.URLTTL <= 0 {
+			v.addf("media.url_ttl", "must be greater than 0")
+		}
+	}
+
+	if c.Payments == nil {
+		v.addf("payments", "section is missing")
+	} else {
+		switch c.Payments.Provider {
+		case "simulated":
+			if c.Payments.Simulator == nil {
+				v.addf("payments.simulator", "section is missing")
+				break
+			}
+			if u, err := url.Parse(c.Payments.Simulator.WebhookURL); err != nil || u.Scheme == "" || u.Host == "" {
+				v.addf("payments.simulator.webhook_url", "must be a URL such as http://localhost:8080/api/v2/payments/webhook, got %q", c.Payments.Simulator.WebhookURL)
+			}
+			if c.Payments.Simulator.WebhookDelay < 0 {
+				v.addf("payments.simulator.webhook_delay", "must not be negative")
+			}
+		default:
+			v.addf("payments.provider", "must be simulated, got %q", c.Payments.Provider)
+		}
+		v.secret("payments.webhook_secret", c.Payments.WebhookSecret)
+	}
+
+	if c.Inventory == nil {
+		v.addf("inventory", "section is missing")
+	} else if c.Inventory.ReservationTTL <= 0 {
+		v.addf("inventory.reservation_ttl", "must be greater than 0")
+	}
+
+	if c.Cart == nil {
+		v.addf("cart", "section is missing")
+	} else {
+		if c.Cart.GuestTTL <= 0 {
+			v.addf("cart.guest_ttl", "must be greater than 0")
+		}
+		if c.Cart.UserTTL <= 0 {
+			v.addf("cart.user_ttl", "must be greater than 0")
+		}
+		if c.Cart.TaxRate < 0 || c.Cart.TaxRate > 1 {
+			v.addf("cart.tax_rate", "must be between 0 and 1")
+		}
+		for i, d := range c.Cart.Discounts {
+			key := fmt.Sprintf("cart.discounts[%d]", i)
+			if d == nil {
+				v.addf(key, "section is empty")
+				continue
+			}
+			if d.MinSubtotal < 0 {
+				v.addf(key+".min_subtotal", "must not be negative")
+			}
+			if d.Percent <= 0 || d.Percent > 100 {
+				v.addf(key+".percent", "must be greater than 0 and at most 100")
+			}
+		}
+		if s := c.Cart.Shipping; s == nil {
+			v.addf("cart.shipping", "section is missing")
+		} else if s.FlatRate < 0 || s.PerKg < 0 || s.FreeOver < 0 {
+			v.addf("cart.shipping", "rates must not be negative")
+		}
+	}
+
+	if c.Returns == nil {
+		v.addf("returns", "section is missing")
+	} else if c.Returns.Window <= 0 {
+		v.addf("returns.window", "must be greater than 0")
+	}
+
+	if c.Log != nil && !logLevels[strings.ToLower(c.Log.Level)] {
+		v.addf("log.level", "must be one of debug, info, warn, error, got %q", c.Log.Level)
+	}
+
+	for name, limit := range c.RateLimits {
+		if limit == nil {
+			v.addf("rate_limits."+name, "section is empty")
+			continue
+		}
+		if limit.Rate <= 0 {
+			v.addf("rate_limits."+name+".rate", "must be greater than 0")
+		}
+		if limit.Burst < 1 {
+			v.addf("rate_limits."+name+".burst", "must be at least 1")
+		}
+		if !rateLimitIdentities[limit.By] {
+			v.addf("rate_limits."+name+".by", "must be one of ip, user, api_key, got %q", limit.By)
+		}
+	}
+	if !rateLimitStores[c.RateLimitStore] {
+		v.addf("rate_limit_store", "must be memory or postgres, got %q", c.RateLimitStore)
+	}
+	if c.Carrier != "fake" {
+		v.addf("carrier", "must be fake, got %q", c.Carrier)
+	}
+
+	if sec := c.Security; sec != nil {
+		if sec.MaxBodyBytes < 0 {
+			v.addf("security.max_body_bytes", "must not be negative")
+		}
+		if sec.MaxUploadBytes < 0 {
+			v.addf("security.max_upload_bytes", "must not be negative")
+		}
+		if cors := sec.CORS; cors != nil {
+			for _, origin := range cors.AllowedOrigins {
+				if origin == "*" {
+					if cors.AllowCredentials {
+						v.addf("security.cors.allowed_origins", "\"*\" cannot be used with allow_credentials")
+					}
+					continue
+				}
+				if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+					v.addf("security.cors.allowed_origins", "%q is not an origin such as https://shop.example.com", origin)
+				}
+			}
+			if cors.MaxAge < 0 {
+				v.addf("security.cors.max_age", "must not be negative")
+			}
+		}
+		if h := sec.Headers; h != nil {
+			if h.HSTSMaxAge < 0 {
+				v.addf("security.headers.hsts_max_age", "must not be negative")
+			}
+			if !frameOptions[h.FrameOptions] {
+				v.addf("security.headers.frame_options", "must be DENY or SAMEORIGIN, got %q", h.FrameOptions)
+			}
+		}
+		if csrf := sec.CSRF; csrf != nil && csrf.Enabled {
+			v.required("security.csrf.cookie_name", csrf.CookieName)
+			v.required("security.csrf.header_name", csrf.HeaderName)
+		}
+	}
+
+	for name, tmpl := range c.EmailTemplates {
+		if tmpl == nil {
+			v.addf("email_templates."+name, "section is empty")
+			continue
+		}
+		if _, err := template.New(name).Parse(tmpl.Subject); err != nil {
+			v.addf("email_templates."+name+".subject", "%v", err)
+		}
+		if _, err := template.New(name).Parse(tmpl.Body); err != nil {
+			v.addf("email_templates."+name+".body", "%v", err)
+		}
+	}
+
+	if len(v.problems) > 0 {
+		sort.Strings(v.problems)
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// load reads the repository's config.yaml, which is valid as it stands.
+func load(t *testing.T) *Config {
+	t.Helper()
+	conf, err := Load(Options{Profile: "dev", Paths: []string{".."}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []string
+	}{
+		{name: "valid", change: func(*Config) {}},
+		{
+			name:   "missing section",
+			change: func(c *Config) { c.Db = nil },
+			want:   []string{"db: section is missing"},
+		},
+		{
+			name:   "port out of range",
+			change: func(c *Config) { c.Server.Port = 70000 },
+			want:   []string{"server.port: must be between 1 and 65535, got 70000"},
+		},
+		{
+			name:   "negative timeout",
+			change: func(c *Config) { c.Server.ShutdownTimeout = -1 },
+			want:   []string{"server.shutdown_timeout: must not be negative"},
+		},
+		{
+			name:   "trusted proxy",
+			change: func(c *Config) { c.Server.TrustedProxies = []string{"10.0.0.0/8", "proxy"} },
+			want:   []string{`server.trusted_proxies: "proxy" is not an IP address or CIDR`},
+		},
+		{
+			name: "short and shared secrets",
+			change: func(c *Config) {
+				c.JWT.SecretKey = "short"
+				c.JWT.ResetPasswordSecretKey = "short"
+			},
+			want: []string{
+				"jwt.reset_password_secret_key: must be at least 16 characters",
+				"jwt.reset_password_secret_key: must differ from jwt.secret_key",
+				"jwt.secret_key: must be at least 16 characters",
+			},
+		},
+		{
+			name:   "missing secret",
+			change: func(c *Config) { c.Payments.WebhookSecret = "" },
+			want:   []string{"payments.webhook_secret: is required (set PAYMENTS_WEBHOOK_SECRET or PAYMENTS_WEBHOOK_SECRET_FILE)"},
+		},
+		{
+			name:   "reused key ID",
+			change: func(c *Config) { c.JWT.PreviousKeys = map[string]string{c.JWT.KeyID: "0123456789abcdef"} },
+			want:   []string{"jwt.previous_keys." + load(t).JWT.KeyID + ": must not reuse the active jwt.key_id"},
+		},
+		{
+			name:   "unknown ssl mode",
+			change: func(c *Config) { c.Db.SSLMode = "on" },
+			want:   []string{`db.sslmode: unknown mode "on"`},
+		},
+		{
+			name:   "email port",
+			change: func(c *Config) { c.Email.Port = "smtp" },
+			want:   []string{`email.port: must be a number, got "smtp"`},
+		},
+		{
+			name: "rate limit",
+			change: func(c *Config) {
+				c.RateLimits = map[string]*RateLimit{"auth": {Rate: 0, Burst: 0, By: "cookie"}}
+			},
+			want: []string{
+				"rate_limits.auth.burst: must be at least 1",
+				`rate_limits.auth.by: must be one of ip, user, api_key, got "cookie"`,
+				"rate_limits.auth.rate: must be greater than 0",
+			},
+		},
+		{
+			name: "wildcard origin with credentials",
+			change: func(c *Config) {
+				c.Security = &Security{CORS: &CORS{AllowedOrigins: []string{"*", "https://shop.example.com/"}, AllowCredentials: true}}
+			},
+			want: []string{
+				`security.cors.allowed_origins: "*" cannot be used with allow_credentials`,
+				`security.cors.allowed_origins: "https://shop.example.com/" is not an origin such as https://shop.example.com`,
+			},
+		},
+		{
+			name:   "template",
+			change: func(c *Config) { c.EmailTemplates["otp"].Subject = "{{.OTP" },
+			want:   []string{`email_templates.otp.subject: template: otp:1: unclosed action`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := load(t)
+			tt.change(conf)
+			err := conf.Validate()
+			var got []string
+			var verr *ValidationError
+			if errors.As(err, &verr) {
+				got = verr.Problems
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("problems = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSecretFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("from-a-mounted-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PAYMENTS_WEBHOOK_SECRET_FILE", path)
+	if got := load(t).Payments.WebhookSecret; got != "from-a-mounted-file" {
+		t.Errorf("payments.webhook_secret = %q, want the file's trimmed contents", got)
+	}
+}
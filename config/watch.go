@@ -0,0 +1,185 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Provider hands out the current configuration. Consumers should call
+// Current for every use instead of caching the result, so reloaded settings
+// take effect.
+type Provider interface {
+	Current() *Config
+}
+
+type staticProvider struct {
+	conf *Config
+}
+
+// Static returns a Provider that always returns conf.
+func Static(conf *Config) Provider {
+	return staticProvider{conf: conf}
+}
+
+func (p staticProvider) Current() *Config { return p.conf }
+
+// Watcher reloads the configuration when a config file changes or the
+// process receives SIGHUP. The log level, rate limits, feature flags, email
+// templates, security settings and JWT key set are swapped at runtime;
+// changes to the other sections are reported and ignored until the next
+// restart.
+type Watcher struct {
+	opts    Options
+	current atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	listeners []func(old, next *Config)
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	running   sync.WaitGroup
+}
+
+func NewWatcher(initial *Config, opts Options) *Watcher {
+	if opts.Profile == "" {
+		opts.Profile = initial.Profile
+	}
+	w := &Watcher{opts: opts, closing: make(chan struct{})}
+	w.current.Store(initial)
+	return w
+}
+
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// OnChange registers fn to be called after every successful reload.
+func (w *Watcher) OnChange(fn func(old, next *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+// Reload loads and validates the configuration and, if it is valid,
+// publishes the reloadable sections. An invalid configuration is rejected
+// and the current one stays in effect.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	next, err := Load(w.opts)
+	if err != nil {
+		slog.Error("configuration reload rejected", slog.Any("error", err))
+		return err
+	}
+
+	old := w.current.Load()
+	if static := staticChanges(old, next); len(static) > 0 {
+		slog.Warn("configuration changes that need a restart were ignored", slog.Any("changes", static))
+	}
+	next.Server, next.Db, next.Email = old.Server, old.Db, old.Email
+	next.Tracing, next.Media, next.RateLimitStore = old.Tracing, old.Media, old.RateLimitStore
+	next.Payments, next.Carrier = old.Payments, old.Carrier
+
+	changes := Diff(old, next)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	w.current.Store(next)
+	slog.Info("configuration reloaded", slog.Any("changes", changes))
+	for _, fn := range w.listeners {
+		fn(old, next)
+	}
+	return nil
+}
+
+func staticChanges(old, next *Config) []string {
+	return Diff(staticSections(old), staticSections(next))
+}
+
+// staticSections returns the parts of conf that are only read at startup.
+func staticSections(conf *Config) *Config {
+	return &Config{
+		Server:         conf.Server,
+		Db:             conf.Db,
+		Email:          conf.Email,
+		Tracing:        conf.Tracing,
+		Media:          conf.Media,
+		Payments:       conf.Payments,
+		Carrier:        conf.Carrier,
+		RateLimitStore: conf.RateLimitStore,
+	}
+}
+
+func (w *Watcher) Name() string { return "config-watcher" }
+
+// Run watches the config files and SIGHUP until ctx is done or the watcher
+// is closed. It implements worker.Worker.
+func (w *Watcher) Run(ctx context.Context) error {
+	w.running.Add(1)
+	defer w.running.Done()
+
+	files, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer files.Close()
+
+	// Directories are watched rather than the files, so editors that save
+	// by renaming a new file over the old one are noticed too.
+	names := map[string]bool{}
+	paths := w.opts.Paths
+	if len(paths) == 0 {
+		paths = []string{"./"}
+	}
+	for _, dir := range paths {
+		for _, name := range []string{"config.yaml", "config." + w.opts.Profile + ".yaml"} {
+			file := filepath.Join(dir, name)
+			if _, err := os.Stat(file); err != nil {
+				continue
+			}
+			if err := files.Add(filepath.Dir(file)); err != nil {
+				return err
+			}
+			names[filepath.Clean(file)] = true
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.closing:
+			return nil
+		case <-hup:
+			_ = w.Reload()
+		case event := <-files.Events:
+			if names[filepath.Clean(event.Name)] && event.Op.Has(fsnotify.Write|fsnotify.Create) {
+				_ = w.Reload()
+			}
+		case err := <-files.Errors:
+			slog.Warn("watching the configuration files failed", slog.Any("error", err))
+		}
+	}
+}
+
+// Close stops Run, and with it the file watches and the SIGHUP listener,
+// and waits for it to return.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() { close(w.closing) })
+	w.running.Wait()
+	return nil
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"config.yaml", "config.dev.yaml"} {
+		contents, err := os.ReadFile(filepath.Join("..", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), contents, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := Options{Profile: "dev", Paths: []string{dir}}
+	initial, err := Load(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWatcher(initial, opts)
+	changed := make(chan *Config, 1)
+	w.OnChange(func(_, next *Config) { changed <- next })
+
+	done := make(chan error, 1)
+	go func() { done <- w.Run(context.Background()) }()
+
+	// The overlay is rewritten until the watcher, which starts watching
+	// asynchronously, notices.
+	overlay, err := os.ReadFile(filepath.Join(dir, "config.dev.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay = append(overlay, "\nlog:\n  level: debug\n"...)
+	deadline := time.After(5 * time.Second)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+wait:
+	for {
+		if err := os.WriteFile(filepath.Join(dir, "config.dev.yaml"), overlay, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case next := <-changed:
+			if next.Log.Level != "debug" {
+				t.Errorf("log.level = %q, want debug", next.Log.Level)
+			}
+			break wait
+		case <-ticker.C:
+		case <-deadline:
+			t.Fatal("the change was not noticed")
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run = %v, want nil after Close", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run still running after Close")
+	}
+}
+
+func TestWatcherCloseWithoutRun(t *testing.T) {
+	w := NewWatcher(&Config{}, Options{})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("second Close = %v", err)
+	}
+}
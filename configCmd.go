@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"gopkg.in/yaml.v3"
+)
+
+// runConfigCommand implements `config validate`, which loads the
+// configuration for the current profile and prints it with secrets redacted.
+func runConfigCommand(args []string) int {
+	if len(args) != 1 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: ecommerce_backend config validate")
+		return 2
+	}
+
+	conf, err := config.Load(config.Options{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	out, err := yaml.Marshal(conf.Redacted())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("configuration for profile %q is valid\n\n%s", conf.Profile, out)
+	return 0
+}
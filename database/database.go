@@ -5,5 +5,7 @@ import (
 )
 
 type Database interface {
+	Transactor
 	GetDb() *sql.DB
+	Close() error
 }
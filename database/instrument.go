@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// QueryHook is called before every statement run through an instrumented
+// DBTX. The returned function is called with the statement's error once it
+// has completed. The returned context is used to run the statement.
+type QueryHook func(ctx context.Context, repository, statement string) (context.Context, func(err error))
+
+// Instrument wraps db so every statement runs through hook. Statements are
+// named by a leading "-- name: <Name>" comment, as in
+//
+//	-- name: GetUserByEmail
+//	SELECT ... FROM users WHERE email = $1
+//
+// Transactions from WithTx are instrumented too when repositories resolve
+// them with Conn.
+func Instrument(db DBTX, repository string, hook QueryHook) DBTX {
+	return &instrumented{db: db, repository: repository, hook: hook}
+}
+
+// InstrumentTx returns a Transactor for transactions on db whose BEGIN,
+// COMMIT, ROLLBACK and savepoint statements run through hook, under the
+// repository name "tx". The statements run inside are instrumented by the
+// DBTX of the repository that runs them.
+func InstrumentTx(db *sql.DB, hook QueryHook) Transactor {
+	return instrumentedTx{db: db, hook: hook}
+}
+
+type instrumentedTx struct {
+	db   *sql.DB
+	hook QueryHook
+}
+
+func (t instrumentedTx) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	opts := defaultTxOptions
+	opts.Hook = t.hook
+	return WithTxOptions(ctx, t.db, opts, fn)
+}
+
+type instrumented struct {
+	db         DBTX
+	repository string
+	hook       QueryHook
+}
+
+func (i *instrumented) withConn(conn DBTX) DBTX {
+	return &instrumented{db: conn, repository: i.repository, hook: i.hook}
+}
+
+func (i *instrumented) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, done := i.hook(ctx, i.repository, StatementName(query))
+	res, err := i.db.ExecContext(ctx, query, args...)
+	done(err)
+	return res, err
+}
+
+func (i *instrumented) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, done := i.hook(ctx, i.repository, StatementName(query))
+	rows, err := i.db.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+func (i *instrumented) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, done := i.hook(ctx, i.repository, StatementName(query))
+	row := i.db.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+// StatementName returns the name from a leading "-- name: <Name>" comment,
+// or "unnamed".
+func StatementName(query string) string {
+	query = strings.TrimSpace(query)
+	if rest, ok := strings.CutPrefix(query, "-- name:"); ok {
+		name, _, _ := strings.Cut(rest, "\n")
+		if name = strings.TrimSpace(name); name != "" {
+			return name
+		}
+	}
+	return "unnamed"
+}
+
+// ChainHooks runs every hook around a statement, in order.
+func ChainHooks(hooks ...QueryHook) QueryHook {
+	return func(ctx context.Context, repository, statement string) (context.Context, func(error)) {
+		dones := make([]func(error), 0, len(hooks))
+		for _, hook := range hooks {
+			var done func(error)
+			ctx, done = hook(ctx, repository, statement)
+			dones = append(dones, done)
+		}
+		return ctx, func(err error) {
+			for i := len(dones) - 1; i >= 0; i-- {
+				dones[i](err)
+			}
+		}
+	}
+}
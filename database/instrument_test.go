@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// stubDriver accepts every statement and transaction without a database.
+type stubDriver struct{}
+
+func (stubDriver) Open(string) (driver.Conn, error) { return stubConn{}, nil }
+
+type stubConn struct{}
+
+func (stubConn) Prepare(string) (driver.Stmt, error) { return stubStmt{}, nil }
+func (stubConn) Close() error                        { return nil }
+func (stubConn) Begin() (driver.Tx, error)           { return stubConn{}, nil }
+func (stubConn) Commit() error                       { return nil }
+func (stubConn) Rollback() error                     { return nil }
+
+type stubStmt struct{}
+
+func (stubStmt) Close() error  { return nil }
+func (stubStmt) NumInput() int { return -1 }
+func (stubStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+func (stubStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("stub: queries are not supported")
+}
+
+func init() {
+	sql.Register("stub", stubDriver{})
+}
+
+func TestInstrumentTx(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name string
+		// run runs statements with exec, each named after the given name.
+		run  func(ctx context.Context, tx Transactor, exec func(ctx context.Context, name string) error) error
+		want []string
+	}{
+		{
+			name: "committed",
+			run: func(ctx context.Context, tx Transactor, exec func(context.Context, string) error) error {
+				return tx.WithTx(ctx, func(ctx context.Context) error {
+					return exec(ctx, "UpdateUser")
+				})
+			},
+			want: []string{"tx.Begin", "user.UpdateUser", "tx.Commit"},
+		},
+		{
+			name: "rolled back",
+			run: func(ctx context.Context, tx Transactor, exec func(context.Context, string) error) error {
+				err := tx.WithTx(ctx, func(ctx context.Context) error {
+					_ = exec(ctx, "UpdateUser")
+					return errFailed
+				})
+				if !errors.Is(err, errFailed) {
+					return err
+				}
+				return nil
+			},
+			want: []string{"tx.Begin", "user.UpdateUser", "tx.Rollback"},
+		},
+		{
+			name: "savepoints",
+			run: func(ctx context.Context, tx Transactor, exec func(context.Context, string) error) error {
+				return tx.WithTx(ctx, func(ctx context.Context) error {
+					_ = tx.WithTx(ctx, func(ctx context.Context) error {
+						_ = exec(ctx, "DeleteUser")
+						return errFailed
+					})
+					return tx.WithTx(ctx, func(ctx context.Context) error {
+						return exec(ctx, "CreateUser")
+					})
+				})
+			},
+			want: []string{
+				"tx.Begin",
+				"tx.Savepoint", "user.DeleteUser", "tx.RollbackToSavepoint",
+				"tx.Savepoint", "user.CreateUser", "tx.ReleaseSavepoint",
+				"tx.Commit",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := sql.Open("stub", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			var got []string
+			hook := func(ctx context.Context, repository, statement string) (context.Context, func(error)) {
+				got = append(got, repository+"."+statement)
+				return ctx, func(error) {}
+			}
+			users := Instrument(db, "user", hook)
+			exec := func(ctx context.Context, name string) error {
+				_, err := Conn(ctx, users).ExecContext(ctx, "-- name: "+name+"\nUPDATE users SET first_name = ''")
+				return err
+			}
+
+			if err := tt.run(context.Background(), InstrumentTx(db, hook), exec); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("statements = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+CREATE TABLE IF NOT EXISTS warehouses (
+    id SERIAL PRIMARY KEY,
+    seller_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    code TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (seller_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS stock_levels (
+    variant_id INTEGER NOT NULL REFERENCES product_variants (id) ON DELETE CASCADE,
+    warehouse_id INTEGER NOT NULL REFERENCES warehouses (id) ON DELETE CASCADE,
+    on_hand INTEGER NOT NULL DEFAULT 0 CHECK (on_hand >= 0),
+    reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0 AND reserved <= on_hand),
+    low_stock_threshold INTEGER NOT NULL DEFAULT 0 CHECK (low_stock_threshold >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (variant_id, warehouse_id)
+);
+
+CREATE TABLE IF NOT EXISTS reservations (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'committed', 'released', 'expired')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The expiry worker looks for active reservations past their expiry.
+CREATE INDEX IF NOT EXISTS reservations_active_idx ON reservations (expires_at) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS reservation_items (
+    reservation_id INTEGER NOT NULL REFERENCES reservations (id) ON DELETE CASCADE,
+    variant_id INTEGER NOT NULL REFERENCES product_variants (id) ON DELETE CASCADE,
+    warehouse_id INTEGER NOT NULL REFERENCES warehouses (id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (reservation_id, variant_id, warehouse_id)
+);
+
+CREATE TABLE IF NOT EXISTS stock_adjustments (
+    id SERIAL PRIMARY KEY,
+    variant_id INTEGER NOT NULL REFERENCES product_variants (id) ON DELETE CASCADE,
+    warehouse_id INTEGER NOT NULL REFERENCES warehouses (id) ON DELETE CASCADE,
+    reason TEXT NOT NULL CHECK (reason IN ('received', 'correction', 'damaged', 'returned', 'reserved', 'released', 'expired', 'sold')),
+    on_hand_change INTEGER NOT NULL,
+    reserved_change INTEGER NOT NULL,
+    on_hand INTEGER NOT NULL,
+    reserved INTEGER NOT NULL,
+    reservation_id INTEGER REFERENCES reservations (id) ON DELETE SET NULL,
+    actor_id INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS stock_adjustments_variant_id_idx ON stock_adjustments (variant_id, id);
+
+-- Stock moves from variants to warehouses: sellers with stock get a default
+-- warehouse holding it, with the move recorded in the ledger.
+INSERT INTO warehouses (seller_id, name, code)
+SELECT DISTINCT seller_id, 'Default', 'DEFAULT'
+FROM product_variants
+WHERE stock > 0
+ON CONFLICT (seller_id, code) DO NOTHING;
+
+INSERT INTO stock_levels (variant_id, warehouse_id, on_hand)
+SELECT v.id, w.id, v.stock
+FROM product_variants v
+JOIN warehouses w ON w.seller_id = v.seller_id AND w.code = 'DEFAULT'
+WHERE v.stock > 0
+ON CONFLICT (variant_id, warehouse_id) DO NOTHING;
+
+INSERT INTO stock_adjustments (variant_id, warehouse_id, reason, on_hand_change, reserved_change, on_hand, reserved, note)
+SELECT variant_id, warehouse_id, 'correction', on_hand, 0, on_hand, 0, 'Moved from the variant'
+FROM stock_levels;
+
+ALTER TABLE product_variants DROP COLUMN IF EXISTS stock;
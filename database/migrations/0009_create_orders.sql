@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS orders (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    status TEXT NOT NULL DEFAULT 'pending_payment'
+        CHECK (status IN ('pending_payment', 'paid', 'fulfilled', 'delivered', 'cancelled')),
+    currency TEXT NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    subtotal BIGINT NOT NULL CHECK (subtotal >= 0),
+    discount BIGINT NOT NULL CHECK (discount >= 0),
+    tax BIGINT NOT NULL CHECK (tax >= 0),
+    shipping BIGINT NOT NULL CHECK (shipping >= 0),
+    total BIGINT NOT NULL CHECK (total >= 0),
+    shipping_address JSONB NOT NULL,
+    billing_address JSONB NOT NULL,
+    reservation_id INTEGER REFERENCES reservations (id) ON DELETE SET NULL,
+    idempotency_key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    paid_at TIMESTAMP WITH TIME ZONE,
+    fulfilled_at TIMESTAMP WITH TIME ZONE,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    cancelled_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS orders_user_id_idx ON orders (user_id, id);
+
+-- The expiry worker looks for unpaid orders past their expiry.
+CREATE INDEX IF NOT EXISTS orders_pending_idx ON orders (expires_at) WHERE status = 'pending_payment';
+
+-- Items are copies of what was sold, so they don't reference the catalog.
+CREATE TABLE IF NOT EXISTS order_items (
+    order_id INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    variant_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    seller_id INTEGER NOT NULL,
+    product_name TEXT NOT NULL,
+    sku TEXT NOT NULL,
+    options JSONB NOT NULL DEFAULT '{}',
+    unit_price BIGINT NOT NULL CHECK (unit_price >= 0),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    line_total BIGINT NOT NULL CHECK (line_total >= 0),
+    PRIMARY KEY (order_id, variant_id)
+);
@@ -0,0 +1,34 @@
+-- Every attempt to pay for an order is kept. Only the card's brand and last
+-- four digits are stored.
+CREATE TABLE IF NOT EXISTS payments (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    provider TEXT NOT NULL,
+    reference TEXT,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'requires_action', 'authorized', 'captured', 'declined', 'voided', 'refunded')),
+    amount BIGINT NOT NULL CHECK (amount >= 0),
+    captured_amount BIGINT NOT NULL DEFAULT 0 CHECK (captured_amount >= 0 AND captured_amount <= amount),
+    refunded_amount BIGINT NOT NULL DEFAULT 0 CHECK (refunded_amount >= 0 AND refunded_amount <= captured_amount),
+    currency TEXT NOT NULL,
+    card_brand TEXT NOT NULL DEFAULT '',
+    card_last4 TEXT NOT NULL DEFAULT '',
+    decline_code TEXT NOT NULL DEFAULT '',
+    action_url TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, reference)
+);
+
+CREATE INDEX IF NOT EXISTS payments_order_id_idx ON payments (order_id, id);
+
+-- Webhook events already handled, so redelivered ones are ignored.
+CREATE TABLE IF NOT EXISTS payment_events (
+    provider TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    payment_id INTEGER NOT NULL REFERENCES payments (id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, event_id)
+);
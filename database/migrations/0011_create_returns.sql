@@ -0,0 +1,60 @@
+CREATE TABLE IF NOT EXISTS returns (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    seller_id INTEGER NOT NULL REFERENCES users (id),
+    status TEXT NOT NULL DEFAULT 'requested'
+        CHECK (status IN ('requested', 'approved', 'rejected', 'inspected', 'refunded')),
+    label_carrier TEXT NOT NULL DEFAULT '',
+    tracking_number TEXT NOT NULL DEFAULT '',
+    label_key TEXT NOT NULL DEFAULT '',
+    label_created_at TIMESTAMP WITH TIME ZONE,
+    rejection_reason TEXT NOT NULL DEFAULT '',
+    currency TEXT NOT NULL,
+    refund_amount BIGINT NOT NULL DEFAULT 0 CHECK (refund_amount >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS returns_user_id_idx ON returns (user_id, id);
+CREATE INDEX IF NOT EXISTS returns_seller_id_idx ON returns (seller_id, id);
+CREATE INDEX IF NOT EXISTS returns_order_id_idx ON returns (order_id);
+
+-- Like order items, return items are copies and don't reference the catalog.
+CREATE TABLE IF NOT EXISTS return_items (
+    return_id INTEGER NOT NULL REFERENCES returns (id) ON DELETE CASCADE,
+    variant_id INTEGER NOT NULL,
+    product_name TEXT NOT NULL,
+    sku TEXT NOT NULL,
+    unit_price BIGINT NOT NULL CHECK (unit_price >= 0),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    reason TEXT NOT NULL
+        CHECK (reason IN ('damaged', 'defective', 'wrong_item', 'not_as_described', 'no_longer_needed', 'other')),
+    note TEXT NOT NULL DEFAULT '',
+    condition TEXT NOT NULL DEFAULT '' CHECK (condition IN ('', 'resellable', 'damaged', 'missing')),
+    received_quantity INTEGER NOT NULL DEFAULT 0 CHECK (received_quantity >= 0 AND received_quantity <= quantity),
+    PRIMARY KEY (return_id, variant_id)
+);
+
+CREATE TABLE IF NOT EXISTS return_photos (
+    id SERIAL PRIMARY KEY,
+    return_id INTEGER NOT NULL,
+    variant_id INTEGER NOT NULL,
+    key TEXT NOT NULL UNIQUE,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (return_id, variant_id) REFERENCES return_items (return_id, variant_id) ON DELETE CASCADE
+);
+
+-- The audit trail of each return.
+CREATE TABLE IF NOT EXISTS return_events (
+    id SERIAL PRIMARY KEY,
+    return_id INTEGER NOT NULL REFERENCES returns (id) ON DELETE CASCADE,
+    actor_id INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    status TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS return_events_return_id_idx ON return_events (return_id, id);
@@ -0,0 +1,48 @@
+-- A fulfilment is the part of an order one seller ships. Its items are the
+-- order's items with the same seller.
+CREATE TABLE IF NOT EXISTS fulfilments (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    seller_id INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'shipped', 'delivered', 'cancelled')),
+    carrier TEXT NOT NULL DEFAULT '',
+    tracking_number TEXT NOT NULL DEFAULT '',
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    shipped_at TIMESTAMP WITH TIME ZONE,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    cancelled_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (order_id, seller_id)
+);
+
+CREATE INDEX IF NOT EXISTS fulfilments_seller_id_idx ON fulfilments (seller_id, id);
+
+CREATE TABLE IF NOT EXISTS shipment_events (
+    id SERIAL PRIMARY KEY,
+    fulfilment_id INTEGER NOT NULL REFERENCES fulfilments (id) ON DELETE CASCADE,
+    actor_id INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    type TEXT NOT NULL,
+    location TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS shipment_events_fulfilment_id_idx ON shipment_events (fulfilment_id, occurred_at, id);
+
+-- Orders placed before fulfilments existed get one per seller, in the
+-- status their order reached.
+INSERT INTO fulfilments (order_id, seller_id, status, shipped_at, delivered_at, cancelled_at, created_at, updated_at)
+SELECT DISTINCT o.id, i.seller_id,
+    CASE o.status
+        WHEN 'fulfilled' THEN 'shipped'
+        WHEN 'delivered' THEN 'delivered'
+        WHEN 'cancelled' THEN 'cancelled'
+        ELSE 'pending'
+    END,
+    o.fulfilled_at, o.delivered_at, o.cancelled_at, o.created_at, o.updated_at
+FROM orders o
+JOIN order_items i ON i.order_id = o.id
+ON CONFLICT (order_id, seller_id) DO NOTHING;
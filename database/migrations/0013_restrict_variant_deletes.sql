@@ -0,0 +1,12 @@
+-- The ledger and reservations keep the variants they name: a variant with
+-- stock history can't be deleted, so no change goes unexplained. Products
+-- are archived instead.
+ALTER TABLE reservation_items
+    DROP CONSTRAINT IF EXISTS reservation_items_variant_id_fkey,
+    ADD CONSTRAINT reservation_items_variant_id_fkey
+        FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE RESTRICT;
+
+ALTER TABLE stock_adjustments
+    DROP CONSTRAINT IF EXISTS stock_adjustments_variant_id_fkey,
+    ADD CONSTRAINT stock_adjustments_variant_id_fkey
+        FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE RESTRICT;
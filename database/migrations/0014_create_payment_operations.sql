@@ -0,0 +1,23 @@
+-- Captures, voids and refunds asked of the provider. Each is recorded as
+-- pending before the provider is called, and retried with the same
+-- idempotency key until the provider answers, so no call is forgotten or
+-- made twice.
+CREATE TABLE IF NOT EXISTS payment_operations (
+    id SERIAL PRIMARY KEY,
+    payment_id INTEGER NOT NULL REFERENCES payments (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('capture', 'void', 'refund')),
+    amount BIGINT NOT NULL DEFAULT 0 CHECK (amount >= 0),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    key TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A key names one change, e.g. the refund of a return. A failed operation
+-- frees its key for another try.
+CREATE UNIQUE INDEX IF NOT EXISTS payment_operations_key_idx ON payment_operations (key) WHERE status <> 'failed';
+
+CREATE INDEX IF NOT EXISTS payment_operations_payment_id_idx ON payment_operations (payment_id);
+
+CREATE INDEX IF NOT EXISTS payment_operations_pending_idx ON payment_operations (created_at) WHERE status = 'pending';
@@ -1,9 +1,9 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"sync"
 
 	_ "github.com/lib/pq" // PostgreSQL driver
 	"github.com/sudhir512kj/ecommerce_backend/config"
@@ -13,35 +13,36 @@ type postgresDatabase struct {
 	Db *sql.DB
 }
 
-var (
-	once       sync.Once
-	dbInstance *postgresDatabase
-)
-
-func NewPostgresDatabase(conf *config.Config) Database {
-	once.Do(func() {
-		dsn := fmt.Sprintf(
-			"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=%s",
-			conf.Db.Host,
-			conf.Db.User,
-			conf.Db.Password,
-			conf.Db.DBName,
-			conf.Db.Port,
-			conf.Db.SSLMode,
-			conf.Db.TimeZone,
-		)
-
-		db, err := sql.Open("postgres", dsn)
-		if err != nil {
-			panic("failed to connect database")
-		}
-
-		dbInstance = &postgresDatabase{Db: db}
-	})
-
-	return dbInstance
+// NewPostgresDatabase opens a connection pool for conf.Db. Each call returns
+// a separate pool, so callers own it and must Close it.
+func NewPostgresDatabase(conf *config.Config) (Database, error) {
+	dsn := fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=%s",
+		conf.Db.Host,
+		conf.Db.User,
+		conf.Db.Password,
+		conf.Db.DBName,
+		conf.Db.Port,
+		conf.Db.SSLMode,
+		conf.Db.TimeZone,
+	)
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect database: %w", err)
+	}
+
+	return &postgresDatabase{Db: db}, nil
 }
 
 func (p *postgresDatabase) GetDb() *sql.DB {
 	return p.Db
 }
+
+func (p *postgresDatabase) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return WithTx(ctx, p.Db, fn)
+}
+
+func (p *postgresDatabase) Close() error {
+	return p.Db.Close()
+}
@@ -0,0 +1,265 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DBTX is the query surface shared by *sql.DB, *sql.Tx and *Tx, so that
+// repositories work the same way inside and outside of a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Transactor runs a function inside a unit of work. Repositories called with
+// the context passed to fn automatically join the transaction.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TxOptions controls how WithTx starts and retries transactions.
+type TxOptions struct {
+	Isolation  sql.IsolationLevel
+	ReadOnly   bool
+	MaxRetries int
+	// Hook, if set, runs around the statements that begin, commit and roll
+	// back the transaction and its savepoints. See InstrumentTx.
+	Hook QueryHook
+}
+
+var defaultTxOptions = TxOptions{
+	Isolation:  sql.LevelDefault,
+	MaxRetries: 3,
+}
+
+// Tx wraps *sql.Tx and keeps track of the savepoint depth for nested calls.
+type Tx struct {
+	*sql.Tx
+	depth int
+	hook  QueryHook
+}
+
+type txKey struct{}
+
+// TxFromContext returns the transaction bound to ctx, if any.
+func TxFromContext(ctx context.Context) (*Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*Tx)
+	return tx, ok
+}
+
+// Conn returns the transaction bound to ctx, or db when there is none. If db
+// is instrumented, the transaction is instrumented the same way.
+func Conn(ctx context.Context, db DBTX) DBTX {
+	tx, ok := TxFromContext(ctx)
+	if !ok {
+		return db
+	}
+	if i, ok := db.(*instrumented); ok {
+		return i.withConn(tx)
+	}
+	return tx
+}
+
+// WithTx runs fn inside a transaction on db using the default options.
+func WithTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	return WithTxOptions(ctx, db, defaultTxOptions, fn)
+}
+
+// WithTxOptions runs fn inside a transaction on db. If ctx already carries a
+// transaction, fn runs inside a savepoint of it instead, and only that
+// savepoint is rolled back when fn fails. Top-level transactions that fail
+// with a serialization failure or deadlock are retried up to opts.MaxRetries
+// times. Functions added with AfterCommit run once the transaction commits.
+func WithTxOptions(ctx context.Context, db *sql.DB, opts TxOptions, fn func(ctx context.Context) error) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		return withSavepoint(ctx, tx, fn)
+	}
+
+	for attempt := 0; ; attempt++ {
+		hooks, err := runTx(ctx, db, opts, fn)
+		if err == nil {
+			hooks.run(ctx)
+			return nil
+		}
+		if !IsRetryable(err) || attempt >= opts.MaxRetries {
+			return err
+		}
+
+		backoff := time.Duration(10*(1<<attempt)+rand.Intn(10)) * time.Millisecond
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// runTx runs fn in a new transaction and returns the functions fn left to
+// run once it committed.
+func runTx(ctx context.Context, db *sql.DB, opts TxOptions, fn func(ctx context.Context) error) (*afterCommit, error) {
+	var sqlTx *sql.Tx
+	err := control(ctx, opts.Hook, "Begin", func(context.Context) error {
+		var err error
+		// The transaction outlives the hook's context, so it gets ctx.
+		sqlTx, err = db.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	tx := &Tx{Tx: sqlTx, hook: opts.Hook}
+	hooks := &afterCommit{}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(hooks.bind(context.WithValue(ctx, txKey{}, tx))); err != nil {
+		rbErr := control(ctx, opts.Hook, "Rollback", func(context.Context) error { return sqlTx.Rollback() })
+		if rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return nil, errors.Join(err, fmt.Errorf("rollback: %w", rbErr))
+		}
+		return nil, err
+	}
+
+	if err := control(ctx, opts.Hook, "Commit", func(context.Context) error { return sqlTx.Commit() }); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+	return hooks, nil
+}
+
+func withSavepoint(ctx context.Context, parent *Tx, fn func(ctx context.Context) error) (err error) {
+	tx := &Tx{Tx: parent.Tx, depth: parent.depth + 1, hook: parent.hook}
+	name := fmt.Sprintf("sp_%d", tx.depth)
+	hooks := &afterCommit{}
+
+	if err := tx.exec(ctx, "Savepoint", "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("create savepoint: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+	}()
+
+	if err := fn(hooks.bind(context.WithValue(ctx, txKey{}, tx))); err != nil {
+		if rbErr := tx.exec(ctx, "RollbackToSavepoint", "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback to savepoint: %w", rbErr))
+		}
+		return err
+	}
+
+	if err := tx.exec(ctx, "ReleaseSavepoint", "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("release savepoint: %w", err)
+	}
+	hooks.handOver(ctx)
+	return nil
+}
+
+// exec runs a savepoint statement through the transaction's hook.
+func (tx *Tx) exec(ctx context.Context, name, statement string) error {
+	return control(ctx, tx.hook, name, func(ctx context.Context) error {
+		_, err := tx.ExecContext(ctx, statement)
+		return err
+	})
+}
+
+// control runs a statement that begins or ends a transaction or savepoint
+// through hook, if there is one, under the repository name "tx".
+func control(ctx context.Context, hook QueryHook, name string, run func(ctx context.Context) error) error {
+	if hook == nil {
+		return run(ctx)
+	}
+	ctx, done := hook(ctx, "tx", name)
+	err := run(ctx)
+	done(err)
+	return err
+}
+
+// IsRetryable reports whether err is a Postgres serialization failure or
+// deadlock, which are safe to retry from the start of the transaction.
+func IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case "40001", "40P01":
+		return true
+	}
+	return false
+}
+
+// NopTransactor runs fn directly. It is meant for in-memory repositories,
+// which have no transactions to join. Functions added with AfterCommit run
+// when the outermost call succeeds.
+type NopTransactor struct{}
+
+func (NopTransactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	hooks := &afterCommit{}
+	if err := fn(hooks.bind(ctx)); err != nil {
+		return err
+	}
+	if !hooks.handOver(ctx) {
+		hooks.run(ctx)
+	}
+	return nil
+}
+
+// afterCommit lists the functions to run once a transaction commits.
+// Savepoints and nested calls have lists of their own, handed over to the
+// enclosing one when they succeed, so the functions of a rolled back
+// savepoint never run.
+type afterCommit struct {
+	fns []func(ctx context.Context)
+}
+
+type afterCommitKey struct{}
+
+// AfterCommit runs fn once the transaction bound to ctx has committed. It
+// is dropped if the transaction, or the savepoint it was added in, is
+// rolled back, and of a retried transaction only the attempt that commits
+// runs its functions. Without a transaction, fn runs right away. Calls to
+// other services belong here, so they aren't made for changes that are
+// then rolled back, or made again when a transaction is retried.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*afterCommit); ok {
+		hooks.fns = append(hooks.fns, fn)
+		return
+	}
+	fn(ctx)
+}
+
+func (h *afterCommit) bind(ctx context.Context) context.Context {
+	return context.WithValue(ctx, afterCommitKey{}, h)
+}
+
+// handOver adds h's functions to those of the transaction enclosing ctx.
+// It reports false if there is none.
+func (h *afterCommit) handOver(ctx context.Context) bool {
+	parent, ok := ctx.Value(afterCommitKey{}).(*afterCommit)
+	if ok {
+		parent.fns = append(parent.fns, h.fns...)
+	}
+	return ok
+}
+
+// run calls h's functions with ctx, which carries no transaction.
+func (h *afterCommit) run(ctx context.Context) {
+	for _, fn := range h.fns {
+		fn(ctx)
+	}
+}
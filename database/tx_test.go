@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestAfterCommit(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name string
+		// run adds functions with add, each recording its name when it
+		// runs.
+		run     func(ctx context.Context, tx Transactor, add func(ctx context.Context, name string)) error
+		wantErr error
+		want    []string
+	}{
+		{
+			name: "no transaction",
+			run: func(ctx context.Context, _ Transactor, add func(context.Context, string)) error {
+				add(ctx, "now")
+				return nil
+			},
+			want: []string{"now"},
+		},
+		{
+			name: "committed",
+			run: func(ctx context.Context, tx Transactor, add func(context.Context, string)) error {
+				return tx.WithTx(ctx, func(ctx context.Context) error {
+					add(ctx, "first")
+					add(ctx, "second")
+					return nil
+				})
+			},
+			want: []string{"first", "second"},
+		},
+		{
+			name: "rolled back",
+			run: func(ctx context.Context, tx Transactor, add func(context.Context, string)) error {
+				return tx.WithTx(ctx, func(ctx context.Context) error {
+					add(ctx, "dropped")
+					return errFailed
+				})
+			},
+			wantErr: errFailed,
+		},
+		{
+			name: "nested call runs after the outermost commits",
+			run: func(ctx context.Context, tx Transactor, add func(context.Context, string)) error {
+				return tx.WithTx(ctx, func(ctx context.Context) error {
+					err := tx.WithTx(ctx, func(ctx context.Context) error {
+						add(ctx, "inner")
+						return nil
+					})
+					add(ctx, "outer")
+					return err
+				})
+			},
+			want: []string{"inner", "outer"},
+		},
+		{
+			name: "failed nested call is dropped alone",
+			run: func(ctx context.Context, tx Transactor, add func(context.Context, string)) error {
+				return tx.WithTx(ctx, func(ctx context.Context) error {
+					_ = tx.WithTx(ctx, func(ctx context.Context) error {
+						add(ctx, "inner")
+						return errFailed
+					})
+					add(ctx, "outer")
+					return nil
+				})
+			},
+			want: []string{"outer"},
+		},
+		{
+			name: "nested call dropped with the outer one",
+			run: func(ctx context.Context, tx Transactor, add func(context.Context, string)) error {
+				return tx.WithTx(ctx, func(ctx context.Context) error {
+					if err := tx.WithTx(ctx, func(ctx context.Context) error {
+						add(ctx, "inner")
+						return nil
+					}); err != nil {
+						return err
+					}
+					return errFailed
+				})
+			},
+			wantErr: errFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			add := func(ctx context.Context, name string) {
+				AfterCommit(ctx, func(ctx context.Context) {
+					if _, ok := TxFromContext(ctx); ok {
+						t.Errorf("%s ran inside the transaction", name)
+					}
+					calls = append(calls, name)
+				})
+			}
+			err := tt.run(context.Background(), NopTransactor{}, add)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(calls, tt.want) {
+				t.Errorf("calls = %v, want %v", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&pq.Error{Code: "40001"}, true},
+		{&pq.Error{Code: "40P01"}, true},
+		{fmt.Errorf("commit transaction: %w", &pq.Error{Code: "40001"}), true},
+		{&pq.Error{Code: "23505"}, false},
+		{errors.New("connection refused"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Package api mounts the versioned HTTP API. Modules describe their routes
+// per version and register with a Registry, which mounts them under each
+// version's prefix and announces deprecated versions.
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const versionKey = "api_version"
+
+// Version is one mounted API version. Several versions may share a Name,
+// e.g. the unversioned /api prefix is served as v1.
+type Version struct {
+	Name   string
+	Prefix string
+	// Deprecated and Sunset are announced on every response when set, as
+	// are the Successor version's prefix.
+	Deprecated time.Time
+	Sunset     time.Time
+	Successor  string
+}
+
+var (
+	V1 = Version{
+		Name:       "v1",
+		Prefix:     "/api/v1",
+		Deprecated: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		Sunset:     time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC),
+		Successor:  "/api/v2",
+	}
+	V2 = Version{Name: "v2", Prefix: "/api/v2"}
+
+	// Unversioned keeps the original /api/... routes working as v1.
+	Unversioned = Version{
+		Name:       V1.Name,
+		Prefix:     "/api",
+		Deprecated: V1.Deprecated,
+		Sunset:     V1.Sunset,
+		Successor:  V1.Successor,
+	}
+)
+
+// Route is one endpoint of a module. Path is relative to the version prefix
+// and may contain gin parameters such as :id.
+type Route struct {
+	Method  string
+	Path    string
+	Summary string
+	// Auth routes run the registry's authentication handlers first.
+	// OptionalAuth routes run its optional ones, which identify the user
+	// when the request is authenticated and let anonymous requests through.
+	Auth         bool
+	OptionalAuth bool
+	// Permissions restricts an Auth route to users holding any of them.
+	Permissions []string
+	// RateLimit names a limit group the route counts against on top of
+	// its module's, e.g. for routes that are costly or guess credentials.
+	RateLimit string
+	Handler   gin.HandlerFunc
+
+	// Query, Request and Response are zero values of the types the route
+	// binds and returns, used to document it. Query is a struct with form
+	// tags. Request is JSON unless Multipart is set, when it is a struct
+	// with form tags sent as multipart/form-data. Response is sent with
+	// Status, or 200 when Status is zero.
+	Query     any
+	Request   any
+	Multipart bool
+	Response  any
+	Status    int
+}
+
+// Endpoint is a route as mounted under one version.
+type Endpoint struct {
+	Version Version
+	// Path is the full gin path, including the version prefix.
+	Path  string
+	Route Route
+}
+
+// Module is a group of routes, such as the user API.
+type Module interface {
+	// Routes returns the module's routes in the named version, or none if
+	// the module is not part of that version.
+	Routes(version string) []Route
+}
+
+type registration struct {
+	module     Module
+	middleware []gin.HandlerFunc
+}
+
+type Registry struct {
+	versions     []Version
+	auth         []gin.HandlerFunc
+	optionalAuth []gin.HandlerFunc
+	authorize    func(permissions ...string) gin.HandlerFunc
+	limit        func(group string) gin.HandlerFunc
+	modules      []registration
+}
+
+func NewRegistry(versions ...Version) *Registry {
+	return &Registry{versions: versions}
+}
+
+// Authenticate sets the handlers that run before every Auth route.
+func (r *Registry) Authenticate(handlers ...gin.HandlerFunc) {
+	r.auth = handlers
+}
+
+// AuthenticateOptional sets the handlers that run before every OptionalAuth
+// route.
+func (r *Registry) AuthenticateOptional(handlers ...gin.HandlerFunc) {
+	r.optionalAuth = handlers
+}
+
+// Authorize sets how routes with Permissions check them. The returned
+// handler runs after the authentication handlers.
+func (r *Registry) Authorize(fn func(permissions ...string) gin.HandlerFunc) {
+	r.authorize = fn
+}
+
+// Limit sets how routes with a RateLimit group are limited. The returned
+// handler runs after the authentication handlers, so it can limit by user.
+func (r *Registry) Limit(fn func(group string) gin.HandlerFunc) {
+	r.limit = fn
+}
+
+// Register adds m. The middleware runs before every route of m.
+func (r *Registry) Register(m Module, middleware ...gin.HandlerFunc) {
+	r.modules = append(r.modules, registration{module: m, middleware: middleware})
+}
+
+// Mount adds every registered route to router under each version prefix.
+func (r *Registry) Mount(router gin.IRouter) {
+	for _, v := range r.versions {
+		group := router.Group(v.Prefix, versionHeaders(v))
+		for _, reg := range r.modules {
+			for _, route := range reg.module.Routes(v.Name) {
+				handlers := append([]gin.HandlerFunc{}, reg.middleware...)
+				if route.Auth {
+					handlers = append(handlers, r.auth...)
+				} else if route.OptionalAuth {
+					handlers = append(handlers, r.optionalAuth...)
+				}
+				if route.RateLimit != "" {
+					handlers = append(handlers, r.limit(route.RateLimit))
+				}
+				if len(route.Permissions) > 0 {
+					handlers = append(handlers, r.authorize(route.Permissions...))
+				}
+				handlers = append(handlers, route.Handler)
+				group.Handle(route.Method, route.Path, handlers...)
+			}
+		}
+	}
+}
+
+// Endpoints lists every route Mount adds, in the same order.
+func (r *Registry) Endpoints() []Endpoint {
+	var endpoints []Endpoint
+	for _, v := range r.versions {
+		for _, reg := range r.modules {
+			for _, route := range reg.module.Routes(v.Name) {
+				endpoints = append(endpoints, Endpoint{Version: v, Path: v.Prefix + route.Path, Route: route})
+			}
+		}
+	}
+	return endpoints
+}
+
+// CurrentVersion returns the name of the API version serving c, or "" if
+// the route is not versioned.
+func CurrentVersion(c *gin.Context) string {
+	return c.GetString(versionKey)
+}
+
+// versionHeaders records the version on the context and adds the
+// Deprecation (RFC 9745), Sunset (RFC 8594) and successor Link headers.
+func versionHeaders(v Version) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(versionKey, v.Name)
+		if !v.Deprecated.IsZero() {
+			c.Header("Deprecation", "@"+strconv.FormatInt(v.Deprecated.Unix(), 10))
+		}
+		if !v.Sunset.IsZero() {
+			c.Header("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if v.Successor != "" {
+			c.Header("Link", "<"+v.Successor+`>; rel="successor-version"`)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,363 @@
+// Package app is the composition root: it builds every component from the
+// configuration and wires them together explicitly, so several isolated
+// instances can run in one process.
+package app
+
+import (
+	"io"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/cart"
+	"github.com/sudhir512kj/ecommerce_backend/internal/handlers"
+	"github.com/sudhir512kj/ecommerce_backend/internal/health"
+	"github.com/sudhir512kj/ecommerce_backend/internal/inventory"
+	"github.com/sudhir512kj/ecommerce_backend/internal/mailer"
+	"github.com/sudhir512kj/ecommerce_backend/internal/media"
+	"github.com/sudhir512kj/ecommerce_backend/internal/metrics"
+	"github.com/sudhir512kj/ecommerce_backend/internal/order"
+	"github.com/sudhir512kj/ecommerce_backend/internal/payment"
+	"github.com/sudhir512kj/ecommerce_backend/internal/ratelimit"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+	"github.com/sudhir512kj/ecommerce_backend/internal/returns"
+	"github.com/sudhir512kj/ecommerce_backend/internal/shipping"
+	"github.com/sudhir512kj/ecommerce_backend/internal/tracing"
+	"github.com/sudhir512kj/ecommerce_backend/internal/worker"
+	"github.com/sudhir512kj/ecommerce_backend/server"
+)
+
+type App struct {
+	Config config.Provider
+
+	DB          database.Database
+	Tx          database.Transactor
+	Users       repository.UserRepository
+	Products    repository.ProductRepository
+	Categories  repository.CategoryRepository
+	Stock       repository.InventoryRepository
+	Carts       repository.CartRepository
+	Orders      repository.OrderRepository
+	Payments    repository.PaymentRepository
+	Returns     repository.ReturnRepository
+	Fulfilments repository.FulfilmentRepository
+	Mailer      mailer.Mailer
+	Blobs       media.BlobStore
+	Media       *media.Processor
+	Inventory   *inventory.Service
+	Cart        *cart.Service
+	Order       *order.Service
+	Gateway     payment.Provider
+	Carrier     shipping.Carrier
+	Return      *returns.Service
+	Workers     *worker.Group
+	Health      *health.Registry
+	Metrics     *metrics.Metrics
+	Tracing     *tracing.Tracing
+
+	RateLimits  ratelimit.Store
+	RateLimiter *ratelimit.Limiter
+
+	UserHandler       *handlers.UserHandler
+	ProductHandler    *handlers.ProductHandler
+	CategoryHandler   *handlers.CategoryHandler
+	InventoryHandler  *handlers.InventoryHandler
+	CartHandler       *handlers.CartHandler
+	OrderHandler      *handlers.OrderHandler
+	PaymentHandler    *handlers.PaymentHandler
+	FulfilmentHandler *handlers.FulfilmentHandler
+	ReturnHandler     *handlers.ReturnHandler
+	MediaHandler      *handlers.MediaHandler
+	API               *api.Registry
+	Server            server.Server
+
+	closers []io.Closer
+}
+
+// Option overrides a component before the rest of the app is wired.
+type Option func(*App)
+
+// WithDatabase uses db instead of opening a pool from the configuration.
+// The caller keeps ownership of db.
+func WithDatabase(db database.Database) Option {
+	return func(a *App) { a.DB = db }
+}
+
+func WithTransactor(tx database.Transactor) Option {
+	return func(a *App) { a.Tx = tx }
+}
+
+func WithUserRepository(users repository.UserRepository) Option {
+	return func(a *App) { a.Users = users }
+}
+
+func WithProductRepository(products repository.ProductRepository) Option {
+	return func(a *App) { a.Products = products }
+}
+
+func WithCategoryRepository(categories repository.CategoryRepository) Option {
+	return func(a *App) { a.Categories = categories }
+}
+
+func WithInventoryRepository(stock repository.InventoryRepository) Option {
+	return func(a *App) { a.Stock = stock }
+}
+
+func WithCartRepository(carts repository.CartRepository) Option {
+	return func(a *App) { a.Carts = carts }
+}
+
+func WithOrderRepository(orders repository.OrderRepository) Option {
+	return func(a *App) { a.Orders = orders }
+}
+
+func WithPaymentRepository(payments repository.PaymentRepository) Option {
+	return func(a *App) { a.Payments = payments }
+}
+
+func WithPaymentGateway(gateway payment.Provider) Option {
+	return func(a *App) { a.Gateway = gateway }
+}
+
+func WithFulfilmentRepository(fulfilments repository.FulfilmentRepository) Option {
+	return func(a *App) { a.Fulfilments = fulfilments }
+}
+
+func WithReturnRepository(returns repository.ReturnRepository) Option {
+	return func(a *App) { a.Returns = returns }
+}
+
+func WithCarrier(carrier shipping.Carrier) Option {
+	return func(a *App) { a.Carrier = carrier }
+}
+
+func WithMailer(m mailer.Mailer) Option {
+	return func(a *App) { a.Mailer = m }
+}
+
+func WithBlobStore(blobs media.BlobStore) Option {
+	return func(a *App) { a.Blobs = blobs }
+}
+
+func WithRateLimitStore(store ratelimit.Store) Option {
+	return func(a *App) { a.RateLimits = store }
+}
+
+// InMemory replaces every database-backed component, the mailer and the
+// blob store with in-memory fakes, so no external services are needed.
+func InMemory() Option {
+	return func(a *App) {
+		a.Tx = database.NopTransactor{}
+		a.Users = repository.NewMemoryUserRepository()
+		a.Products = repository.NewMemoryProductRepository()
+		a.Categories = repository.NewMemoryCategoryRepository()
+		a.Stock = repository.NewMemoryInventoryRepository()
+		a.Carts = repository.NewMemoryCartRepository()
+		a.Orders = repository.NewMemoryOrderRepository()
+		a.Payments = repository.NewMemoryPaymentRepository()
+		a.Fulfilments = repository.NewMemoryFulfilmentRepository()
+		a.Returns = repository.NewMemoryReturnRepository()
+		a.Mailer = mailer.NewMemory()
+		a.Blobs = media.NewMemoryStore()
+		a.RateLimits = ratelimit.NewMemoryStore()
+	}
+}
+
+// New builds an App from the configuration in provider. Components not
+// supplied through opts are created from the configuration; a database pool
+// is only opened if some component still needs one. If provider is a
+// *config.Watcher it is run as a background worker so reloads take effect,
+// and closed on shutdown.
+func New(provider config.Provider, opts ...Option) (*App, error) {
+	conf := provider.Current()
+	a := &App{
+		Config:  provider,
+		Workers: worker.NewGroup(),
+		Health:  health.NewRegistry(),
+		Metrics: metrics.New(),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	tracer, err := tracing.New(conf)
+	if err != nil {
+		return nil, err
+	}
+	a.Tracing = tracer
+
+	needsDB := a.Tx == nil || a.Users == nil || a.Products == nil || a.Categories == nil || a.Stock == nil ||
+		a.Carts == nil || a.Orders == nil || a.Payments == nil || a.Fulfilments == nil || a.Returns == nil ||
+		(a.RateLimits == nil && conf.RateLimitStore == "postgres")
+	if a.DB == nil && needsDB {
+		db, err := database.NewPostgresDatabase(conf)
+		if err != nil {
+			return nil, err
+		}
+		a.DB = db
+		a.closers = append(a.closers, db)
+	}
+	if a.DB != nil {
+		a.Metrics.RegisterDB(a.DB.GetDb(), conf.Db.DBName)
+	}
+
+	if a.Tx == nil {
+		a.Tx = database.InstrumentTx(a.DB.GetDb(), a.queryHook())
+	}
+	if a.Users == nil {
+		a.Users = repository.NewUserRepository(a.instrument("user"))
+	}
+	if a.Products == nil {
+		a.Products = repository.NewProductRepository(a.instrument("product"))
+	}
+	if a.Categories == nil {
+		a.Categories = repository.NewCategoryRepository(a.instrument("category"))
+	}
+	if a.Stock == nil {
+		a.Stock = repository.NewInventoryRepository(a.instrument("inventory"))
+	}
+	if a.Carts == nil {
+		a.Carts = repository.NewCartRepository(a.instrument("cart"))
+	}
+	if a.Orders == nil {
+		a.Orders = repository.NewOrderRepository(a.instrument("order"))
+	}
+	if a.Payments == nil {
+		a.Payments = repository.NewPaymentRepository(a.instrument("payment"))
+	}
+	if a.Fulfilments == nil {
+		a.Fulfilments = repository.NewFulfilmentRepository(a.instrument("fulfilment"))
+	}
+	if a.Returns == nil {
+		a.Returns = repository.NewReturnRepository(a.instrument("return"))
+	}
+	if a.Mailer == nil {
+		a.Mailer = mailer.NewSMTPMailer(conf.Email)
+	}
+	if a.Blobs == nil {
+		blobs, err := media.NewBlobStore(conf.Media)
+		if err != nil {
+			return nil, err
+		}
+		a.Blobs = blobs
+	}
+	if a.Gateway == nil {
+		provider, err := payment.NewProvider(conf.Payments, api.V2.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		a.Gateway = provider
+	}
+	if a.Carrier == nil {
+		carrier, err := shipping.NewCarrier(conf.Carrier)
+		if err != nil {
+			return nil, err
+		}
+		a.Carrier = carrier
+	}
+	if a.RateLimits == nil {
+		if conf.RateLimitStore == "postgres" {
+			a.RateLimits = ratelimit.NewPostgresStore(a.instrument("ratelimit"), a.Tx)
+		} else {
+			a.RateLimits = ratelimit.NewMemoryStore()
+		}
+	}
+	a.RateLimiter = ratelimit.New(provider, a.RateLimits)
+
+	if watcher, ok := provider.(*config.Watcher); ok {
+		a.Workers.Add(watcher)
+		a.closers = append(a.closers, watcher)
+	}
+	if sweeper, ok := a.RateLimits.(worker.Worker); ok {
+		a.Workers.Add(sweeper)
+	}
+	a.Media = media.NewProcessor(a.Products, a.Blobs)
+	a.Workers.Add(a.Media)
+	a.registerHealthChecks()
+	a.Mailer = metrics.InstrumentMailer(tracing.InstrumentMailer(a.Mailer, a.Tracing), a.Metrics)
+	// Spans are flushed last so those of in-flight work are not lost.
+	a.closers = append(a.closers, a.Tracing)
+
+	a.Inventory = inventory.NewService(provider, a.Stock, a.Products, a.Users, a.Tx, a.Mailer)
+	a.Workers.Add(a.Inventory)
+	a.Cart = cart.NewService(provider, a.Carts, a.Products, a.Stock, a.Tx)
+	a.Workers.Add(a.Cart)
+	a.Order = order.NewService(a.Orders, a.Payments, a.Fulfilments, a.Users, a.Cart, a.Inventory, a.Gateway, a.Tx)
+	a.Workers.Add(a.Order)
+	a.Workers.Add(a.Order.OperationsWorker())
+	if simulator, ok := a.Gateway.(*payment.Simulator); ok {
+		a.Workers.Add(simulator.Worker())
+	}
+	a.Return = returns.NewService(provider, a.Returns, a.Orders, a.Users, a.Order, a.Inventory, a.Carrier, a.Blobs, a.Tx)
+
+	a.UserHandler = handlers.NewUserHandler(provider, a.Users, a.Cart, a.Tx, a.Mailer, a.Metrics)
+	signer := media.NewSigner(conf.Media, api.V2.Prefix+handlers.MediaPath)
+	a.ProductHandler = handlers.NewProductHandler(a.Products, a.Categories, a.Stock, a.Tx, a.Blobs, signer, a.Media)
+	a.CategoryHandler = handlers.NewCategoryHandler(a.Categories, a.Products, a.Tx)
+	a.InventoryHandler = handlers.NewInventoryHandler(a.Inventory, a.Stock, a.Products)
+	a.CartHandler = handlers.NewCartHandler(a.Cart)
+	a.OrderHandler = handlers.NewOrderHandler(a.Order, a.Orders)
+	a.PaymentHandler = handlers.NewPaymentHandler(a.Order, a.Gateway)
+	a.FulfilmentHandler = handlers.NewFulfilmentHandler(a.Order, a.Fulfilments)
+	a.ReturnHandler = handlers.NewReturnHandler(a.Return, a.Returns, signer)
+	a.MediaHandler = handlers.NewMediaHandler(a.Blobs, signer)
+
+	a.API = api.NewRegistry(api.V1, api.V2, api.Unversioned)
+	// Authenticated routes are also limited per user, so the account limit
+	// runs after AuthMiddleware.
+	a.API.Authenticate(a.UserHandler.AuthMiddleware, a.RateLimiter.Limit("account"))
+	a.API.AuthenticateOptional(a.UserHandler.OptionalAuthMiddleware)
+	a.API.Authorize(a.UserHandler.RequirePermission)
+	a.API.Limit(a.RateLimiter.Limit)
+	a.API.Register(a.UserHandler, a.RateLimiter.Limit("users"))
+	a.API.Register(a.ProductHandler, a.RateLimiter.Limit("catalog"))
+	a.API.Register(a.CategoryHandler, a.RateLimiter.Limit("catalog"))
+	a.API.Register(a.InventoryHandler, a.RateLimiter.Limit("catalog"))
+	a.API.Register(a.CartHandler, a.RateLimiter.Limit("shopping"))
+	a.API.Register(a.OrderHandler, a.RateLimiter.Limit("shopping"))
+	a.API.Register(a.FulfilmentHandler, a.RateLimiter.Limit("shopping"))
+	a.API.Register(a.ReturnHandler, a.RateLimiter.Limit("shopping"))
+	// Webhooks come from the provider's few addresses, so they aren't
+	// limited per IP; their signatures keep others out.
+	a.API.Register(a.PaymentHandler)
+	a.API.Register(a.MediaHandler, a.RateLimiter.Limit("catalog"))
+
+	a.Server, err = server.NewEchoServer(conf, server.Dependencies{
+		Config:  provider,
+		API:     a.API,
+		Health:  a.Health,
+		Workers: a.Workers,
+		Metrics: a.Metrics,
+		Tracing: a.Tracing,
+		Closers: a.closers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// instrument returns the database handle for the named repository, with
+// every statement traced and measured, in transactions too.
+func (a *App) instrument(repository string) database.DBTX {
+	return database.Instrument(a.DB.GetDb(), repository, a.queryHook())
+}
+
+// queryHook traces and measures a database statement.
+func (a *App) queryHook() database.QueryHook {
+	return database.ChainHooks(a.Tracing.QueryHook, a.Metrics.QueryHook)
+}
+
+func (a *App) registerHealthChecks() {
+	if a.DB != nil {
+		a.Health.Register(health.Postgres(a.DB.GetDb()))
+		a.Health.Register(health.Migrations(a.DB.GetDb()))
+	}
+	if checker, ok := a.Mailer.(health.Checker); ok {
+		a.Health.RegisterOptional(checker)
+	}
+	if checker, ok := a.Blobs.(health.Checker); ok {
+		a.Health.RegisterOptional(checker)
+	}
+	a.Health.RegisterOptional(health.Workers(a.Workers))
+}
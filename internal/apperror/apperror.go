@@ -0,0 +1,131 @@
+// Package apperror defines the domain errors shared by repositories,
+// handlers and the HTTP error middleware.
+package apperror
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Sentinel kinds. Every *Error wraps exactly one of these so callers can use
+// errors.Is without caring about the specific code.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrTooLarge     = errors.New("request too large")
+	ErrUnsupported  = errors.New("unsupported media type")
+)
+
+// Error is a domain error with a stable machine-readable code and a message
+// that is safe to show to clients. The underlying cause is kept for logging
+// and is never exposed over HTTP.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	// Fields lists the individual problems of a validation error.
+	Fields []FieldError
+	Err    error
+}
+
+// FieldError is one invalid field of a request. Field is the JSON path of
+// the field, e.g. "items[0].sku", and Code names the rule it broke.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// Wrap returns a copy of e with err recorded as its cause.
+func (e *Error) Wrap(err error) *Error {
+	cp := *e
+	cp.Err = err
+	return &cp
+}
+
+func NotFound(code, message string) *Error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+func Conflict(code, message string) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+func Validation(code, message string) *Error {
+	return &Error{Kind: ErrValidation, Code: code, Message: message}
+}
+
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: message}
+}
+
+func Unauthorized(code, message string) *Error {
+	return &Error{Kind: ErrUnauthorized, Code: code, Message: message}
+}
+
+func RateLimited(code, message string) *Error {
+	return &Error{Kind: ErrRateLimited, Code: code, Message: message}
+}
+
+func TooLarge(code, message string) *Error {
+	return &Error{Kind: ErrTooLarge, Code: code, Message: message}
+}
+
+func Unsupported(code, message string) *Error {
+	return &Error{Kind: ErrUnsupported, Code: code, Message: message}
+}
+
+// InvalidRequest wraps a request binding error. The binder's message only
+// describes the client's own input, so it is passed through. Bodies cut off
+// by http.MaxBytesReader are reported as too large instead.
+func InvalidRequest(err error) *Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return ErrBodyTooLarge.Wrap(err)
+	}
+	return &Error{Kind: ErrValidation, Code: "invalid_request", Message: err.Error(), Err: err}
+}
+
+// ErrBodyTooLarge is returned for request bodies over the configured limit.
+var ErrBodyTooLarge = TooLarge("body_too_large", "The request body is too large")
+
+// HTTPStatus maps err to the status code the API responds with.
+func HTTPStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnsupported):
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusInternalServerError
+}
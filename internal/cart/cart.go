@@ -0,0 +1,475 @@
+// Package cart keeps shopping carts. Guests' carts are identified by a
+// token the client sends in TokenHeader, users' carts by their ID. Changes
+// are checked against the catalog and the stock available, and carts are
+// priced from the catalog whenever they are read.
+package cart
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+)
+
+// TokenHeader carries a guest's cart token, in requests and in the response
+// that creates the cart.
+const TokenHeader = "X-Cart-Token"
+
+var (
+	errVariantNotForSale = apperror.Validation("invalid_variant", "The variant does not exist or is not for sale")
+	errTooMany           = apperror.Validation("invalid_quantity", "An item's quantity can't exceed 1000")
+	errInsufficientStock = apperror.Conflict("insufficient_stock", "Not enough stock is available")
+	errCurrencyMismatch  = apperror.Conflict("currency_mismatch", "Every item in a cart must be priced in the same currency")
+	errCartFull          = apperror.Conflict("cart_full", "A cart can hold at most 100 items")
+	errItemNotFound      = apperror.NotFound("cart_item_not_found", "The item is not in the cart")
+)
+
+const (
+	maxItems    = 100
+	maxQuantity = 1000
+
+	// expiryInterval is how often expired carts are deleted.
+	expiryInterval = time.Minute
+	expiryBatch    = 500
+)
+
+// Owner identifies a cart: the user's if UserID is set, otherwise the guest
+// cart with Token, if any.
+type Owner struct {
+	UserID int
+	Token  string
+}
+
+// Service changes and prices carts. It is also the background worker that
+// deletes expired carts.
+type Service struct {
+	conf     config.Provider
+	carts    repository.CartRepository
+	products repository.ProductRepository
+	stock    repository.InventoryRepository
+	tx       database.Transactor
+	now      func() time.Time
+}
+
+func NewService(
+	conf config.Provider,
+	carts repository.CartRepository,
+	products repository.ProductRepository,
+	stock repository.InventoryRepository,
+	tx database.Transactor,
+) *Service {
+	return &Service{conf: conf, carts: carts, products: products, stock: stock, tx: tx, now: time.Now}
+}
+
+// Get returns the owner's cart, or an empty one that isn't saved yet.
+func (s *Service) Get(ctx context.Context, owner Owner) (*models.Cart, error) {
+	cart, err := s.find(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if cart == nil || s.expired(cart) {
+		cart = &models.Cart{Items: []models.CartItem{}}
+	}
+	return cart, s.price(ctx, cart)
+}
+
+// AddItem adds quantity units of a published variant to the owner's cart,
+// creating the cart if there is none. A guest's new cart comes with its
+// Token.
+func (s *Service) AddItem(ctx context.Context, owner Owner, variantID, quantity int) (*models.Cart, error) {
+	return s.change(ctx, owner, true, func(ctx context.Context, cart *models.Cart) error {
+		variant, product, err := s.variant(ctx, variantID, nil)
+		if err != nil {
+			return err
+		}
+		if variant == nil || product.Status != models.ProductPublished {
+			return errVariantNotForSale
+		}
+		if len(cart.Items) == 0 {
+			cart.Currency = product.Currency
+		} else if product.Currency != cart.Currency {
+			return errCurrencyMismatch
+		}
+
+		item := models.CartItem{VariantID: variantID, Quantity: quantity}
+		if i := index(cart, variantID); i >= 0 {
+			item.Quantity += cart.Items[i].Quantity
+		} else if len(cart.Items) >= maxItems {
+			return errCartFull
+		}
+		return s.save(ctx, cart, variant, item)
+	})
+}
+
+// SetQuantity changes how many units of an item are in the owner's cart.
+// The item is repriced at the variant's current price.
+func (s *Service) SetQuantity(ctx context.Context, owner Owner, variantID, quantity int) (*models.Cart, error) {
+	return s.change(ctx, owner, false, func(ctx context.Context, cart *models.Cart) error {
+		if index(cart, variantID) < 0 {
+			return errItemNotFound
+		}
+		variant, product, err := s.variant(ctx, variantID, nil)
+		if err != nil {
+			return err
+		}
+		if variant == nil || product.Status != models.ProductPublished || product.Currency != cart.Currency {
+			return errVariantNotForSale
+		}
+		return s.save(ctx, cart, variant, models.CartItem{VariantID: variantID, Quantity: quantity})
+	})
+}
+
+// RemoveItem takes an item out of the owner's cart.
+func (s *Service) RemoveItem(ctx context.Context, owner Owner, variantID int) (*models.Cart, error) {
+	return s.change(ctx, owner, false, func(ctx context.Context, cart *models.Cart) error {
+		i := index(cart, variantID)
+		if i < 0 {
+			return errItemNotFound
+		}
+		if err := s.carts.DeleteItem(ctx, cart.ID, variantID); err != nil {
+			return err
+		}
+		cart.Items = slices.Delete(cart.Items, i, i+1)
+		return nil
+	})
+}
+
+// Clear deletes the owner's cart and returns an empty one.
+func (s *Service) Clear(ctx context.Context, owner Owner) (*models.Cart, error) {
+	cart, err := s.find(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if cart != nil {
+		if err := s.carts.DeleteCart(ctx, cart.ID); err != nil {
+			return nil, err
+		}
+	}
+	return s.Get(ctx, Owner{})
+}
+
+// Checkout passes the user's priced cart to place, which orders it, and
+// deletes the cart once place succeeds. The cart is locked meanwhile, and
+// both happen in one transaction. A user without a cart has an empty one.
+func (s *Service) Checkout(ctx context.Context, userID int, place func(ctx context.Context, cart *models.Cart) error) error {
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		cart, err := s.lock(ctx, Owner{UserID: userID})
+		if err != nil {
+			return err
+		}
+		if cart == nil {
+			cart = &models.Cart{Items: []models.CartItem{}}
+		}
+		if err := s.price(ctx, cart); err != nil {
+			return err
+		}
+		if err := place(ctx, cart); err != nil {
+			return err
+		}
+		if cart.ID == 0 {
+			return nil
+		}
+		return s.carts.DeleteCart(ctx, cart.ID)
+	})
+}
+
+// Merge moves the guest cart with token into the user's cart when they log
+// in. Items already in the user's cart have the guest's quantities added,
+// up to the stock available; items that can't be added are dropped with
+// the guest cart. A user without a cart takes the guest's over.
+func (s *Service) Merge(ctx context.Context, token string, userID int) error {
+	if token == "" {
+		return nil
+	}
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		guest, err := s.lock(ctx, Owner{Token: token})
+		if err != nil || guest == nil || guest.UserID != nil {
+			return err
+		}
+		cart, err := s.lock(ctx, Owner{UserID: userID})
+		if err != nil {
+			return err
+		}
+		if cart == nil {
+			guest.UserID = &userID
+			guest.TokenHash = ""
+			return s.touch(ctx, guest)
+		}
+
+		products := map[int]*models.Product{}
+		for _, item := range guest.Items {
+			if err := s.mergeItem(ctx, cart, item, products); err != nil {
+				return err
+			}
+		}
+		if err := s.carts.DeleteCart(ctx, guest.ID); err != nil {
+			return err
+		}
+		return s.touch(ctx, cart)
+	})
+}
+
+func (s *Service) mergeItem(ctx context.Context, cart *models.Cart, item models.CartItem, products map[int]*models.Product) error {
+	variant, product, err := s.variant(ctx, item.VariantID, products)
+	if err != nil {
+		return err
+	}
+	if variant == nil || product.Status != models.ProductPublished {
+		return nil
+	}
+	if len(cart.Items) > 0 && product.Currency != cart.Currency {
+		return nil
+	}
+
+	quantity := item.Quantity
+	i := index(cart, item.VariantID)
+	if i >= 0 {
+		item.AddedPrice = cart.Items[i].AddedPrice
+		quantity += cart.Items[i].Quantity
+	} else if len(cart.Items) >= maxItems {
+		return nil
+	}
+	available, err := s.available(ctx, item.VariantID)
+	if err != nil {
+		return err
+	}
+	quantity = min(quantity, available, maxQuantity)
+	if quantity <= 0 || (i >= 0 && quantity <= cart.Items[i].Quantity) {
+		return nil
+	}
+
+	cart.Currency = product.Currency
+	item.Quantity = quantity
+	if err := s.carts.SaveItem(ctx, cart.ID, &item); err != nil {
+		return err
+	}
+	setItem(cart, item)
+	return nil
+}
+
+// change runs fn on the owner's locked cart and saves it with a new
+// expiry. Without a cart, one is created if create is set; otherwise the
+// change fails as if the item wasn't found.
+func (s *Service) change(ctx context.Context, owner Owner, create bool, fn func(ctx context.Context, cart *models.Cart) error) (*models.Cart, error) {
+	var cart *models.Cart
+	var token string
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		token = ""
+		cart, err = s.lock(ctx, owner)
+		if err != nil {
+			return err
+		}
+		if cart == nil {
+			if !create {
+				return errItemNotFound
+			}
+			if cart, token, err = s.create(ctx, owner); err != nil {
+				return err
+			}
+		}
+		if err := fn(ctx, cart); err != nil {
+			return err
+		}
+		if len(cart.Items) == 0 {
+			cart.Currency = ""
+		}
+		return s.touch(ctx, cart)
+	})
+	if err != nil {
+		return nil, err
+	}
+	cart.Token = token
+	return cart, s.price(ctx, cart)
+}
+
+// save checks the item's quantity against the stock available and saves it
+// at the variant's current price.
+func (s *Service) save(ctx context.Context, cart *models.Cart, variant *models.ProductVariant, item models.CartItem) error {
+	if item.Quantity > maxQuantity {
+		return errTooMany
+	}
+	available, err := s.available(ctx, item.VariantID)
+	if err != nil {
+		return err
+	}
+	if item.Quantity > available {
+		return errInsufficientStock
+	}
+	item.AddedPrice = variant.Price
+	if err := s.carts.SaveItem(ctx, cart.ID, &item); err != nil {
+		return err
+	}
+	setItem(cart, item)
+	return nil
+}
+
+func (s *Service) create(ctx context.Context, owner Owner) (*models.Cart, string, error) {
+	cart := &models.Cart{Items: []models.CartItem{}}
+	var token string
+	if owner.UserID != 0 {
+		cart.UserID = &owner.UserID
+	} else {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, "", err
+		}
+		token = base64.RawURLEncoding.EncodeToString(buf)
+		cart.TokenHash = hashToken(token)
+	}
+	cart.ExpiresAt = s.expiry(cart)
+	if err := s.carts.CreateCart(ctx, cart); err != nil {
+		return nil, "", err
+	}
+	return cart, token, nil
+}
+
+// touch saves the cart with a new expiry.
+func (s *Service) touch(ctx context.Context, cart *models.Cart) error {
+	cart.ExpiresAt = s.expiry(cart)
+	return s.carts.UpdateCart(ctx, cart)
+}
+
+func (s *Service) expiry(cart *models.Cart) time.Time {
+	conf := s.conf.Current().Cart
+	if cart.UserID != nil {
+		return s.now().Add(conf.UserTTL)
+	}
+	return s.now().Add(conf.GuestTTL)
+}
+
+func (s *Service) expired(cart *models.Cart) bool {
+	return cart.ExpiresAt.Before(s.now())
+}
+
+// find returns the owner's cart, or nil if there is none.
+func (s *Service) find(ctx context.Context, owner Owner) (*models.Cart, error) {
+	var cart *models.Cart
+	var err error
+	switch {
+	case owner.UserID != 0:
+		cart, err = s.carts.GetCartByUserID(ctx, owner.UserID)
+	case owner.Token != "":
+		cart, err = s.carts.GetCartByTokenHash(ctx, hashToken(owner.Token))
+	default:
+		return nil, nil
+	}
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil, nil
+	}
+	return cart, err
+}
+
+// lock returns the owner's cart locked, or nil if there is none. An expired
+// cart is deleted, so a new one can take its place.
+func (s *Service) lock(ctx context.Context, owner Owner) (*models.Cart, error) {
+	cart, err := s.find(ctx, owner)
+	if err != nil || cart == nil {
+		return nil, err
+	}
+	if cart, err = s.carts.LockCart(ctx, cart.ID); err != nil {
+		return nil, err
+	}
+	if s.expired(cart) {
+		return nil, s.carts.DeleteCart(ctx, cart.ID)
+	}
+	return cart, nil
+}
+
+// variant returns a variant and its product, or nils if the variant no
+// longer exists. Products are cached in products when it isn't nil.
+func (s *Service) variant(ctx context.Context, id int, products map[int]*models.Product) (*models.ProductVariant, *models.Product, error) {
+	variant, err := s.products.GetVariantByID(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	product, ok := products[variant.ProductID]
+	if !ok {
+		product, err = s.products.GetProductByID(ctx, variant.ProductID)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if products != nil {
+			products[variant.ProductID] = product
+		}
+	}
+	return variant, product, nil
+}
+
+// available returns the stock of a variant available across warehouses.
+func (s *Service) available(ctx context.Context, variantID int) (int, error) {
+	levels, err := s.stock.ListStock(ctx, []int{variantID})
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, level := range levels {
+		total += level.Available
+	}
+	return total, nil
+}
+
+func index(cart *models.Cart, variantID int) int {
+	return slices.IndexFunc(cart.Items, func(item models.CartItem) bool { return item.VariantID == variantID })
+}
+
+// setItem adds or replaces the item in the cart's items.
+func setItem(cart *models.Cart, item models.CartItem) {
+	if i := index(cart, item.VariantID); i >= 0 {
+		cart.Items[i] = item
+		return
+	}
+	cart.Items = append(cart.Items, item)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Service) Name() string { return "cart-expiry" }
+
+// Run deletes expired carts until ctx is done. It implements worker.Worker.
+func (s *Service) Run(ctx context.Context) error {
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for {
+		s.expire(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) expire(ctx context.Context) {
+	for {
+		n, err := s.carts.DeleteExpiredCarts(ctx, s.now(), expiryBatch)
+		if err != nil {
+			slog.Error("delete expired carts", slog.Any("error", err))
+			return
+		}
+		if n < expiryBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package cart
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+	"github.com/sudhir512kj/ecommerce_backend/internal/testutil"
+)
+
+// userID is the signed-in buyer and sellerID sells every product.
+const (
+	userID   = 1
+	sellerID = 99
+)
+
+// fixture is a catalog of published variants, by SKU, each with the stock
+// given to newFixture.
+type fixture struct {
+	s        *Service
+	products repository.ProductRepository
+	variants map[string]*models.ProductVariant
+}
+
+type stocked struct {
+	sku       string
+	currency  string
+	available int
+}
+
+func newFixture(t *testing.T, catalog []stocked) *fixture {
+	t.Helper()
+	c := testutil.Catalog{
+		Products:  repository.NewMemoryProductRepository(),
+		Inventory: repository.NewMemoryInventoryRepository(),
+	}
+	f := &fixture{products: c.Products, variants: map[string]*models.ProductVariant{}}
+	f.s = NewService(testutil.Config(t), repository.NewMemoryCartRepository(), c.Products, c.Inventory, database.NopTransactor{})
+	warehouse := c.Warehouse(t, sellerID, "W-1")
+	for _, item := range catalog {
+		variant := c.Variant(t, sellerID, item.sku, 500, item.currency)
+		c.Stock(t, variant.ID, warehouse.ID, item.available)
+		f.variants[item.sku] = variant
+	}
+	return f
+}
+
+// add puts items, by SKU, in the owner's cart and returns the cart's token.
+func (f *fixture) add(t *testing.T, owner Owner, items map[string]int) string {
+	t.Helper()
+	for sku, quantity := range items {
+		cart, err := f.s.AddItem(context.Background(), owner, f.variants[sku].ID, quantity)
+		if err != nil {
+			t.Fatalf("add %s: %v", sku, err)
+		}
+		if cart.Token != "" {
+			owner.Token = cart.Token
+		}
+	}
+	return owner.Token
+}
+
+// quantities returns the items of the owner's cart by SKU.
+func (f *fixture) quantities(t *testing.T, owner Owner) map[string]int {
+	t.Helper()
+	cart, err := f.s.Get(context.Background(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int{}
+	for _, item := range cart.Items {
+		for sku, variant := range f.variants {
+			if variant.ID == item.VariantID {
+				got[sku] = item.Quantity
+			}
+		}
+	}
+	return got
+}
+
+func TestMerge(t *testing.T) {
+	catalog := []stocked{
+		{sku: "LAMP", currency: "USD", available: 10},
+		{sku: "DESK", currency: "USD", available: 3},
+		{sku: "SOFA", currency: "EUR", available: 10},
+	}
+	tests := []struct {
+		name  string
+		guest map[string]int
+		user  map[string]int
+		// archive is a product taken off sale after it was added.
+		archive string
+		want    map[string]int
+	}{
+		{
+			name:  "user without a cart takes the guest's",
+			guest: map[string]int{"LAMP": 2, "DESK": 1},
+			want:  map[string]int{"LAMP": 2, "DESK": 1},
+		},
+		{
+			name:  "quantities add up",
+			guest: map[string]int{"LAMP": 2},
+			user:  map[string]int{"LAMP": 3, "DESK": 1},
+			want:  map[string]int{"LAMP": 5, "DESK": 1},
+		},
+		{
+			name:  "capped at the stock available",
+			guest: map[string]int{"DESK": 2},
+			user:  map[string]int{"DESK": 2},
+			want:  map[string]int{"DESK": 3},
+		},
+		{
+			name:  "other currency dropped",
+			guest: map[string]int{"SOFA": 1},
+			user:  map[string]int{"LAMP": 1},
+			want:  map[string]int{"LAMP": 1},
+		},
+		{
+			name:    "items no longer for sale dropped",
+			guest:   map[string]int{"LAMP": 1, "DESK": 1},
+			user:    map[string]int{"DESK": 1},
+			archive: "LAMP",
+			want:    map[string]int{"DESK": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t, catalog)
+			token := f.add(t, Owner{}, tt.guest)
+			f.add(t, Owner{UserID: userID}, tt.user)
+			if tt.archive != "" {
+				product, err := f.products.GetProductByID(ctx, f.variants[tt.archive].ProductID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				product.Status = models.ProductArchived
+				if err := f.products.UpdateProduct(ctx, product); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := f.s.Merge(ctx, token, userID); err != nil {
+				t.Fatalf("merge: %v", err)
+			}
+			if got := f.quantities(t, Owner{UserID: userID}); !maps.Equal(got, tt.want) {
+				t.Errorf("user's cart = %v, want %v", got, tt.want)
+			}
+			if got := f.quantities(t, Owner{Token: token}); len(got) != 0 {
+				t.Errorf("guest's cart = %v, want it gone", got)
+			}
+			// Merging again, e.g. on a second login, changes nothing.
+			if err := f.s.Merge(ctx, token, userID); err != nil {
+				t.Fatalf("merge again: %v", err)
+			}
+			if got := f.quantities(t, Owner{UserID: userID}); !maps.Equal(got, tt.want) {
+				t.Errorf("user's cart after merging again = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeLeavesOtherUsersCarts(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, []stocked{{sku: "LAMP", currency: "USD", available: 10}})
+	token := f.add(t, Owner{}, map[string]int{"LAMP": 1})
+	if err := f.s.Merge(ctx, token, userID); err != nil {
+		t.Fatal(err)
+	}
+	// The token now names the first user's cart, which no one else can
+	// take over.
+	if err := f.s.Merge(ctx, token, userID+1); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.quantities(t, Owner{UserID: userID + 1}); len(got) != 0 {
+		t.Errorf("other user's cart = %v, want it empty", got)
+	}
+	if got := f.quantities(t, Owner{UserID: userID}); got["LAMP"] != 1 {
+		t.Errorf("user's cart = %v, want one LAMP", got)
+	}
+}
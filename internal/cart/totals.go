@@ -0,0 +1,97 @@
+package cart
+
+import (
+	"context"
+	"math"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+// price fills in the cart's items from the catalog and stock, and works out
+// its totals. Items that are no longer for sale are left out of the totals.
+func (s *Service) price(ctx context.Context, cart *models.Cart) error {
+	variantIDs := make([]int, len(cart.Items))
+	for i, item := range cart.Items {
+		variantIDs[i] = item.VariantID
+	}
+	available := make(map[int]int)
+	if len(variantIDs) > 0 {
+		levels, err := s.stock.ListStock(ctx, variantIDs)
+		if err != nil {
+			return err
+		}
+		for _, level := range levels {
+			available[level.VariantID] += level.Available
+		}
+	}
+
+	var t models.Totals
+	grams := 0
+	products := map[int]*models.Product{}
+	for i := range cart.Items {
+		item := &cart.Items[i]
+		item.Available = available[item.VariantID]
+		item.Status = models.CartItemUnavailable
+		variant, product, err := s.variant(ctx, item.VariantID, products)
+		if err != nil {
+			return err
+		}
+		if variant == nil {
+			continue
+		}
+		item.ProductID = product.ID
+		item.SellerID = product.SellerID
+		item.ProductName = product.Name
+		item.SKU = variant.SKU
+		item.Options = variant.Options
+		item.UnitPrice = variant.Price
+		item.WeightGrams = variant.WeightGrams
+		item.LineTotal = variant.Price * int64(item.Quantity)
+		if product.Status != models.ProductPublished || product.Currency != cart.Currency {
+			continue
+		}
+
+		switch {
+		case item.Quantity > item.Available:
+			item.Status = models.CartItemInsufficientStock
+		case item.UnitPrice != item.AddedPrice:
+			item.Status = models.CartItemPriceChanged
+		default:
+			item.Status = models.CartItemAvailable
+		}
+		t.Quantity += item.Quantity
+		t.Subtotal += item.LineTotal
+		grams += item.WeightGrams * item.Quantity
+	}
+	cart.Totals = totals(s.conf.Current().Cart, t, grams)
+	return nil
+}
+
+// totals adds the discount, tax and shipping estimate to t, which holds the
+// quantity and subtotal of items weighing grams.
+func totals(conf *config.Cart, t models.Totals, grams int) models.Totals {
+	percent := 0.0
+	for _, d := range conf.Discounts {
+		if t.Subtotal >= d.MinSubtotal && d.Percent > percent {
+			percent = d.Percent
+		}
+	}
+	t.Discount = round(float64(t.Subtotal) * percent / 100)
+	discounted := t.Subtotal - t.Discount
+	t.Tax = round(float64(discounted) * conf.TaxRate)
+
+	shipping := conf.Shipping
+	free := shipping.FreeOver > 0 && discounted >= shipping.FreeOver
+	if t.Quantity > 0 && !free {
+		kg := int64((grams + 999) / 1000)
+		t.Shipping = shipping.FlatRate + shipping.PerKg*kg
+	}
+	t.Total = discounted + t.Tax + t.Shipping
+	return t
+}
+
+// round rounds half away from zero to a whole minor unit.
+func round(amount float64) int64 {
+	return int64(math.Round(amount))
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+// permissionsKey holds the permissions of the user, set by
+// RequirePermission.
+const permissionsKey = "permissions"
+
+// hasPermission reports whether the authenticated user holds permission.
+// It is only known on routes guarded by RequirePermission.
+func hasPermission(c *gin.Context, permission models.Permission) bool {
+	held, _ := c.Get(permissionsKey)
+	permissions, _ := held.([]models.Permission)
+	return slices.Contains(permissions, permission)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/cart"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/validation"
+)
+
+// CartHandler serves the cart of the signed-in user or, for guests, the
+// cart whose token is sent in the X-Cart-Token header. Every response
+// holds the whole cart with its totals.
+type CartHandler struct {
+	carts *cart.Service
+}
+
+func NewCartHandler(carts *cart.Service) *CartHandler {
+	return &CartHandler{carts: carts}
+}
+
+// Routes implements api.Module. Carts are only part of v2.
+func (h *CartHandler) Routes(version string) []api.Route {
+	if version != api.V2.Name {
+		return nil
+	}
+	resp := models.Data[models.Cart]{}
+
+	return []api.Route{
+		{
+			Method: http.MethodGet, Path: "/cart", Handler: h.GetCart, OptionalAuth: true,
+			Summary:  "Get your cart, repriced, with its totals",
+			Response: resp,
+		},
+		{
+			Method: http.MethodDelete, Path: "/cart", Handler: h.ClearCart, OptionalAuth: true,
+			Summary:  "Empty your cart",
+			Response: resp,
+		},
+		{
+			Method: http.MethodPost, Path: "/cart/items", Handler: h.AddItem, OptionalAuth: true,
+			Summary: "Add a variant to your cart; a guest's new cart's token is returned in X-Cart-Token",
+			Request: models.CartItemRequest{}, Response: resp,
+		},
+		{
+			Method: http.MethodPut, Path: "/cart/items/:variant_id", Handler: h.SetQuantity, OptionalAuth: true,
+			Summary: "Change the quantity of an item in your cart",
+			Query:   models.CartItemPath{}, Request: models.CartQuantityRequest{}, Response: resp,
+		},
+		{
+			Method: http.MethodDelete, Path: "/cart/items/:variant_id", Handler: h.RemoveItem, OptionalAuth: true,
+			Summary:  "Remove an item from your cart",
+			Query:    models.CartItemPath{},
+			Response: resp,
+		},
+	}
+}
+
+func (h *CartHandler) GetCart(c *gin.Context) {
+	result, err := h.carts.Get(c.Request.Context(), owner(c))
+	h.respond(c, result, err)
+}
+
+func (h *CartHandler) ClearCart(c *gin.Context) {
+	result, err := h.carts.Clear(c.Request.Context(), owner(c))
+	h.respond(c, result, err)
+}
+
+func (h *CartHandler) AddItem(c *gin.Context) {
+	var req models.CartItemRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	result, err := h.carts.AddItem(c.Request.Context(), owner(c), req.VariantID, req.Quantity)
+	h.respond(c, result, err)
+}
+
+func (h *CartHandler) SetQuantity(c *gin.Context) {
+	var req models.CartQuantityRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var path models.CartItemPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	result, err := h.carts.SetQuantity(c.Request.Context(), owner(c), path.VariantID, req.Quantity)
+	h.respond(c, result, err)
+}
+
+func (h *CartHandler) RemoveItem(c *gin.Context) {
+	var path models.CartItemPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	result, err := h.carts.RemoveItem(c.Request.Context(), owner(c), path.VariantID)
+	h.respond(c, result, err)
+}
+
+// respond writes the cart, with the token of a guest's new cart also in
+// the X-Cart-Token header.
+func (h *CartHandler) respond(c *gin.Context, result *models.Cart, err error) {
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if result.Token != "" {
+		c.Header(cart.TokenHeader, result.Token)
+	}
+	c.JSON(http.StatusOK, models.Data[models.Cart]{Data: *result})
+}
+
+// owner identifies the cart of the request: the signed-in user's, or the
+// guest's with the token sent.
+func owner(c *gin.Context) cart.Owner {
+	return cart.Owner{UserID: c.GetInt("user_id"), Token: c.GetHeader(cart.TokenHeader)}
+}
@@ -0,0 +1,461 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+	"github.com/sudhir512kj/ecommerce_backend/internal/validation"
+)
+
+var (
+	errCategoryNotFound    = apperror.NotFound("category_not_found", "category not found")
+	errInvalidParent       = apperror.Validation("invalid_parent", "The parent category does not exist")
+	errCategoryCycle       = apperror.Validation("invalid_parent", "A category cannot be moved under itself or its subcategories")
+	errCategoryHasChildren = apperror.Conflict("category_has_children", "Delete or move the category's subcategories first")
+	errCategoryHasProducts = apperror.Conflict("category_has_products", "Products are assigned to the category; move them to another category first")
+	errInvalidCategory     = apperror.Validation("invalid_category", "The category does not exist")
+	errCategoryNotLeaf     = apperror.Validation("category_not_leaf", "Products can only be assigned to categories without subcategories")
+)
+
+// CategoryHandler serves the category tree. Admins manage it; everyone can
+// browse it.
+type CategoryHandler struct {
+	categories repository.CategoryRepository
+	products   repository.ProductRepository
+	tx         database.Transactor
+}
+
+func NewCategoryHandler(categories repository.CategoryRepository, products repository.ProductRepository, tx database.Transactor) *CategoryHandler {
+	return &CategoryHandler{categories: categories, products: products, tx: tx}
+}
+
+// Routes implements api.Module. Categories are part of the v2 catalog.
+func (h *CategoryHandler) Routes(version string) []api.Route {
+	if version != api.V2.Name {
+		return nil
+	}
+	admin := []string{string(models.PermissionAdmin)}
+	detail := models.Data[models.CategoryDetail]{}
+
+	return []api.Route{
+		{
+			Method: http.MethodGet, Path: "/categories", Handler: h.Tree,
+			Summary:  "Get the category tree",
+			Response: models.Data[[]models.CategoryNode]{},
+		},
+		{
+			Method: http.MethodGet, Path: "/categories/:id", Handler: h.Get,
+			Summary: "Get a category with its breadcrumbs, subcategories and attributes",
+			Query:   models.CategoryPath{}, Response: detail,
+		},
+		{
+			Method: http.MethodPost, Path: "/categories", Handler: h.Create, Auth: true, Permissions: admin,
+			Summary: "Create a category",
+			Request: models.CategoryRequest{}, Response: detail, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPut, Path: "/categories/:id", Handler: h.Update, Auth: true, Permissions: admin,
+			Summary: "Rename, move or reorder a category",
+			Query:   models.CategoryPath{}, Request: models.CategoryRequest{}, Response: detail,
+		},
+		{
+			Method: http.MethodPut, Path: "/categories/:id/attributes", Handler: h.SetAttributes, Auth: true, Permissions: admin,
+			Summary: "Replace the attributes a category defines for its products",
+			Query:   models.CategoryPath{}, Request: models.CategoryAttributesRequest{}, Response: detail,
+		},
+		{
+			Method: http.MethodDelete, Path: "/categories/:id", Handler: h.Delete, Auth: true, Permissions: admin,
+			Summary: "Delete a category without subcategories or products",
+			Query:   models.CategoryPath{}, Status: http.StatusNoContent,
+		},
+	}
+}
+
+func (h *CategoryHandler) Tree(c *gin.Context) {
+	tree, err := loadCategoryTree(c.Request.Context(), h.categories)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[[]models.CategoryNode]{Data: tree.nodes(0)})
+}
+
+func (h *CategoryHandler) Get(c *gin.Context) {
+	var path models.CategoryPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.respond(c, http.StatusOK, path.ID)
+}
+
+func (h *CategoryHandler) Create(c *gin.Context) {
+	var req models.CategoryRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	tree, err := loadCategoryTree(ctx, h.categories)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	category := &models.Category{}
+	if err := h.apply(ctx, tree, category, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := h.categories.CreateCategory(ctx, category); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.respond(c, http.StatusCreated, category.ID)
+}
+
+func (h *CategoryHandler) Update(c *gin.Context) {
+	var req models.CategoryRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var path models.CategoryPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	tree, err := loadCategoryTree(ctx, h.categories)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	category, ok := tree.byID[path.ID]
+	if !ok {
+		_ = c.Error(errCategoryNotFound)
+		return
+	}
+
+	if req.ParentID != 0 && slices.Contains(tree.subtree(category.ID), req.ParentID) {
+		_ = c.Error(errCategoryCycle)
+		return
+	}
+	if err := h.apply(ctx, tree, category, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := h.categories.UpdateCategory(ctx, category); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.respond(c, http.StatusOK, category.ID)
+}
+
+// apply copies the request onto category. A new parent must exist and
+// have no products, since products only belong to leaves.
+func (h *CategoryHandler) apply(ctx context.Context, tree *categoryTree, category *models.Category, req models.CategoryRequest) error {
+	slug := req.Slug
+	if slug == "" {
+		slug = slugify(req.Name)
+		if slug == "" {
+			return errInvalidSlug
+		}
+	}
+
+	var parentID *int
+	if req.ParentID != 0 {
+		if _, ok := tree.byID[req.ParentID]; !ok {
+			return errInvalidParent
+		}
+		if category.ParentID == nil || *category.ParentID != req.ParentID {
+			hasProducts, err := h.hasProducts(ctx, req.ParentID)
+			if err != nil {
+				return err
+			}
+			if hasProducts {
+				return errCategoryHasProducts
+			}
+		}
+		parentID = &req.ParentID
+	}
+
+	category.ParentID = parentID
+	category.Name = req.Name
+	category.Slug = slug
+	category.Position = req.Position
+	return nil
+}
+
+// SetAttributes replaces the category's own attributes. Options only apply
+// to enum attributes and are dropped from the others.
+func (h *CategoryHandler) SetAttributes(c *gin.Context) {
+	var req models.CategoryAttributesRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var path models.CategoryPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	if _, err := h.categories.GetCategoryByID(ctx, path.ID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	attributes := make([]models.CategoryAttribute, len(req.Attributes))
+	for i, a := range req.Attributes {
+		attributes[i] = models.CategoryAttribute{
+			CategoryID: path.ID,
+			Name:       a.Name,
+			Label:      a.Label,
+			Type:       a.Type,
+			Unit:       a.Unit,
+			Required:   a.Required,
+		}
+		if a.Type == models.AttributeEnum {
+			attributes[i].Options = a.Options
+		}
+	}
+	err := h.tx.WithTx(ctx, func(ctx context.Context) error {
+		return h.categories.ReplaceAttributes(ctx, path.ID, attributes)
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.respond(c, http.StatusOK, path.ID)
+}
+
+func (h *CategoryHandler) Delete(c *gin.Context) {
+	var path models.CategoryPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	tree, err := loadCategoryTree(ctx, h.categories)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if _, ok := tree.byID[path.ID]; !ok {
+		_ = c.Error(errCategoryNotFound)
+		return
+	}
+	if len(tree.children[path.ID]) > 0 {
+		_ = c.Error(errCategoryHasChildren)
+		return
+	}
+	hasProducts, err := h.hasProducts(ctx, path.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if hasProducts {
+		_ = c.Error(errCategoryHasProducts)
+		return
+	}
+
+	if err := h.categories.DeleteCategory(ctx, path.ID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// respond writes the detail of category id with status.
+func (h *CategoryHandler) respond(c *gin.Context, status, id int) {
+	ctx := c.Request.Context()
+	tree, err := loadCategoryTree(ctx, h.categories)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	category, ok := tree.byID[id]
+	if !ok {
+		_ = c.Error(errCategoryNotFound)
+		return
+	}
+
+	path := tree.path(id)
+	attributes, err := effectiveAttributes(ctx, h.categories, path)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	detail := models.CategoryDetail{
+		Category:    *category,
+		Breadcrumbs: categoryRefs(path[:len(path)-1]),
+		Children:    categoryRefs(tree.children[id]),
+		Attributes:  attributes,
+	}
+	c.JSON(status, models.Data[models.CategoryDetail]{Data: detail})
+}
+
+// hasProducts reports whether any product, in any status, is assigned to
+// the category itself.
+func (h *CategoryHandler) hasProducts(ctx context.Context, id int) (bool, error) {
+	_, total, err := h.products.ListProducts(ctx, repository.ProductFilter{CategoryIDs: []int{id}, Limit: 1})
+	return total > 0, err
+}
+
+// categoryTree indexes the flattened tree from ListCategories.
+type categoryTree struct {
+	byID map[int]*models.Category
+	// children lists each category's subcategories in order; roots are
+	// under 0.
+	children map[int][]*models.Category
+}
+
+func loadCategoryTree(ctx context.Context, categories repository.CategoryRepository) (*categoryTree, error) {
+	list, err := categories.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tree := &categoryTree{byID: make(map[int]*models.Category), children: make(map[int][]*models.Category)}
+	for _, category := range list {
+		parent := 0
+		if category.ParentID != nil {
+			parent = *category.ParentID
+		}
+		tree.byID[category.ID] = category
+		tree.children[parent] = append(tree.children[parent], category)
+	}
+	return tree, nil
+}
+
+// subtree returns id and the IDs of all its descendants.
+func (t *categoryTree) subtree(id int) []int {
+	ids := []int{id}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range t.children[ids[i]] {
+			ids = append(ids, child.ID)
+		}
+	}
+	return ids
+}
+
+// path returns the category's ancestors from the root down, followed by
+// the category itself.
+func (t *categoryTree) path(id int) []*models.Category {
+	var path []*models.Category
+	category := t.byID[id]
+	for category != nil {
+		path = append(path, category)
+		if category.ParentID == nil {
+			break
+		}
+		category = t.byID[*category.ParentID]
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// nodes returns the subtrees of the parent's children, in order.
+func (t *categoryTree) nodes(parent int) []models.CategoryNode {
+	nodes := []models.CategoryNode{}
+	for _, child := range t.children[parent] {
+		nodes = append(nodes, models.CategoryNode{Category: *child, Children: t.nodes(child.ID)})
+	}
+	return nodes
+}
+
+func categoryRefs(categories []*models.Category) []models.CategoryRef {
+	refs := make([]models.CategoryRef, len(categories))
+	for i, c := range categories {
+		refs[i] = models.CategoryRef{ID: c.ID, Name: c.Name, Slug: c.Slug}
+	}
+	return refs
+}
+
+// effectiveAttributes merges the attributes defined along path, from the
+// root down. A category that redefines an ancestor's attribute replaces it
+// in place.
+func effectiveAttributes(ctx context.Context, categories repository.CategoryRepository, path []*models.Category) ([]models.CategoryAttribute, error) {
+	attributes := []models.CategoryAttribute{}
+	for _, category := range path {
+		own, err := categories.ListAttributes(ctx, category.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range own {
+			i := slices.IndexFunc(attributes, func(b models.CategoryAttribute) bool { return b.Name == a.Name })
+			if i < 0 {
+				attributes = append(attributes, a)
+			} else {
+				attributes[i] = a
+			}
+		}
+	}
+	return attributes, nil
+}
+
+// checkAttributes reports every attribute value that doesn't fit schema:
+// missing required values, values of the wrong type, enum values that
+// aren't offered and names the schema doesn't define. Null values count as
+// missing and are removed.
+func checkAttributes(c *gin.Context, schema []models.CategoryAttribute, values map[string]any) error {
+	var problems []validation.Problem
+	for _, a := range schema {
+		field := "attributes." + a.Name
+		value, ok := values[a.Name]
+		if !ok || value == nil {
+			delete(values, a.Name)
+			if a.Required {
+				problems = append(problems, validation.Problem{Field: field, Code: "required"})
+			}
+			continue
+		}
+
+		var valid bool
+		switch a.Type {
+		case models.AttributeNumber:
+			_, valid = value.(float64)
+		case models.AttributeBoolean:
+			_, valid = value.(bool)
+		default:
+			_, valid = value.(string)
+		}
+		switch {
+		case !valid:
+			problems = append(problems, validation.Problem{Field: field, Code: "invalid_type", Param: attributeJSONType(a.Type)})
+		case a.Type == models.AttributeEnum && !slices.Contains(a.Options, value.(string)):
+			problems = append(problems, validation.Problem{Field: field, Code: "oneof", Param: strings.Join(a.Options, " ")})
+		}
+	}
+
+	var unknown []string
+	for name := range values {
+		if !slices.ContainsFunc(schema, func(a models.CategoryAttribute) bool { return a.Name == name }) {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, validation.Problem{Field: "attributes." + name, Code: "unknown_field"})
+	}
+	return validation.Fields(c, problems...)
+}
+
+func attributeJSONType(t models.AttributeType) string {
+	switch t {
+	case models.AttributeNumber:
+		return "number"
+	case models.AttributeBoolean:
+		return "boolean"
+	}
+	return "string"
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/order"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+	"github.com/sudhir512kj/ecommerce_backend/internal/validation"
+)
+
+var errNotFulfilmentOwner = apperror.Forbidden("not_fulfilment_owner", "Only the fulfilment's seller or an admin can handle it")
+
+// FulfilmentHandler is the sellers' side of orders: the fulfilments of
+// their items, which they accept, ship and track.
+type FulfilmentHandler struct {
+	orders         *order.Service
+	fulfilmentRepo repository.FulfilmentRepository
+}
+
+func NewFulfilmentHandler(orders *order.Service, fulfilmentRepo repository.FulfilmentRepository) *FulfilmentHandler {
+	return &FulfilmentHandler{orders: orders, fulfilmentRepo: fulfilmentRepo}
+}
+
+// Routes implements api.Module. Fulfilments are only part of v2.
+func (h *FulfilmentHandler) Routes(version string) []api.Route {
+	if version != api.V2.Name {
+		return nil
+	}
+	seller := []string{string(models.PermissionSeller)}
+	manager := []string{string(models.PermissionSeller), string(models.PermissionAdmin)}
+	resp := models.Data[models.Fulfilment]{}
+
+	return []api.Route{
+		{
+			Method: http.MethodGet, Path: "/seller/fulfilments", Handler: h.ListOwn, Auth: true, Permissions: seller,
+			Summary: "List the fulfilments of the signed-in seller's items, newest first",
+			Query:   models.FulfilmentQuery{}, Response: models.Page[models.Fulfilment]{},
+		},
+		{
+			Method: http.MethodGet, Path: "/seller/fulfilments/summary", Handler: h.Summary, Auth: true, Permissions: seller,
+			Summary:  "Count the signed-in seller's fulfilments in each status",
+			Response: models.Data[models.FulfilmentSummary]{},
+		},
+		{
+			Method: http.MethodGet, Path: "/seller/fulfilments/:id", Handler: h.GetOwn, Auth: true, Permissions: manager,
+			Summary: "Get a fulfilment of your items with its shipment history",
+			Query:   models.FulfilmentPath{}, Response: resp,
+		},
+		{
+			Method: http.MethodPost, Path: "/fulfilments/:id/accept", Handler: h.Accept, Auth: true, Permissions: manager,
+			Summary: "Accept a fulfilment of a paid order",
+			Query:   models.FulfilmentPath{}, Response: resp,
+		},
+		{
+			Method: http.MethodPost, Path: "/fulfilments/:id/ship", Handler: h.Ship, Auth: true, Permissions: manager,
+			Summary: "Mark an accepted fulfilment shipped; the order is fulfilled once all its fulfilments are",
+			Query:   models.FulfilmentPath{}, Request: models.ShipRequest{}, Response: resp,
+		},
+		{
+			Method: http.MethodPost, Path: "/fulfilments/:id/events", Handler: h.Track, Auth: true, Permissions: manager,
+			Summary: "Add a shipment event to a shipped fulfilment; a delivered event delivers it",
+			Query:   models.FulfilmentPath{}, Request: models.ShipmentEventRequest{}, Response: resp,
+			Status: http.StatusCreated,
+		},
+	}
+}
+
+func (h *FulfilmentHandler) ListOwn(c *gin.Context) {
+	var query models.FulfilmentQuery
+	if err := validation.BindQuery(c, &query); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultPageSize
+	}
+	fulfilments, total, err := h.fulfilmentRepo.ListFulfilments(c.Request.Context(), repository.FulfilmentFilter{
+		SellerID: c.GetInt("user_id"),
+		Status:   query.Status,
+		Limit:    query.Limit,
+		Offset:   query.Offset,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	resp := models.Page[models.Fulfilment]{Data: []models.Fulfilment{}, Total: total, Limit: query.Limit, Offset: query.Offset}
+	for _, f := range fulfilments {
+		resp.Data = append(resp.Data, *f)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *FulfilmentHandler) Summary(c *gin.Context) {
+	summary, err := h.fulfilmentRepo.SummarizeFulfilments(c.Request.Context(), c.GetInt("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.FulfilmentSummary]{Data: *summary})
+}
+
+func (h *FulfilmentHandler) GetOwn(c *gin.Context) {
+	f, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.Fulfilment]{Data: *f})
+}
+
+func (h *FulfilmentHandler) Accept(c *gin.Context) {
+	f, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	f, err = h.orders.Accept(c.Request.Context(), f.ID, c.GetInt("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.Fulfilment]{Data: *f})
+}
+
+func (h *FulfilmentHandler) Ship(c *gin.Context) {
+	var req models.ShipRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	f, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	f, err = h.orders.Ship(c.Request.Context(), f.ID, c.GetInt("user_id"), req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.Fulfilment]{Data: *f})
+}
+
+func (h *FulfilmentHandler) Track(c *gin.Context) {
+	var req models.ShipmentEventRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	f, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	f, err = h.orders.Track(c.Request.Context(), f.ID, c.GetInt("user_id"), req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.Data[models.Fulfilment]{Data: *f})
+}
+
+// loadManaged returns the fulfilment named in the path if the signed-in
+// user is its seller or an admin.
+func (h *FulfilmentHandler) loadManaged(c *gin.Context) (*models.Fulfilment, error) {
+	var path models.FulfilmentPath
+	if err := validation.BindURI(c, &path); err != nil {
+		return nil, err
+	}
+	f, err := h.fulfilmentRepo.GetFulfilmentByID(c.Request.Context(), path.ID)
+	if err != nil {
+		return nil, err
+	}
+	if f.SellerID != c.GetInt("user_id") && !hasPermission(c, models.PermissionAdmin) {
+		return nil, errNotFulfilmentOwner
+	}
+	return f, nil
+}
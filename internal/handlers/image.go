@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/media"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/validation"
+)
+
+// maxImages caps how many images a product may have.
+const maxImages = 20
+
+var (
+	errTooManyImages = apperror.Conflict("too_many_images", "A product can have at most "+strconv.Itoa(maxImages)+" images")
+	errImageNotFound = apperror.NotFound("image_not_found", "image not found")
+)
+
+// UploadImage stores an image after the product's others. Its type is
+// sniffed from the contents; renditions are made in the background, so it
+// starts out processing.
+func (h *ProductHandler) UploadImage(c *gin.Context) {
+	var req models.ImageUploadRequest
+	if err := validation.BindMultipart(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	product, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	images, err := h.products.ListImages(ctx, product.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if len(images) >= maxImages {
+		_ = c.Error(errTooManyImages)
+		return
+	}
+
+	file, err := req.File.Open()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	defer file.Close()
+	info, err := media.Inspect(file)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	image := &models.ProductImage{
+		ProductID:   product.ID,
+		Key:         media.NewKey("products/"+strconv.Itoa(product.ID), info.Ext),
+		ContentType: info.ContentType,
+		Size:        req.File.Size,
+		Width:       info.Width,
+		Height:      info.Height,
+		Position:    len(images),
+		Status:      models.ImageProcessing,
+	}
+	if err := h.blobs.Put(ctx, image.Key, file, image.Size, image.ContentType); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := h.products.CreateImage(ctx, image); err != nil {
+		media.DeleteImageBlobs(ctx, h.blobs, image)
+		_ = c.Error(err)
+		return
+	}
+	h.processor.Enqueue(image.ID)
+
+	h.signImage(image)
+	c.JSON(http.StatusCreated, models.Data[models.ProductImage]{Data: *image})
+}
+
+func (h *ProductHandler) DeleteImage(c *gin.Context) {
+	var path models.ImagePath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	product, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	image, err := h.products.GetImageByID(ctx, path.ImageID)
+	if err == nil && image.ProductID != product.ID {
+		err = errImageNotFound
+	}
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := h.products.DeleteImage(ctx, image.ID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	media.DeleteImageBlobs(ctx, h.blobs, image)
+	c.Status(http.StatusNoContent)
+}
+
+// signImage fills in the image's URLs.
+func (h *ProductHandler) signImage(image *models.ProductImage) {
+	image.URL = h.signer.URL(image.Key)
+	image.RenditionURLs = make(map[string]string, len(image.Renditions))
+	for name, key := range image.Renditions {
+		image.RenditionURLs[name] = h.signer.URL(key)
+	}
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/inventory"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+	"github.com/sudhir512kj/ecommerce_backend/internal/validation"
+)
+
+var (
+	errNotWarehouseOwner   = apperror.Forbidden("not_warehouse_owner", "You can only manage your own warehouses")
+	errWarehouseNotFound   = apperror.NotFound("warehouse_not_found", "warehouse not found")
+	errInvalidWarehouse    = apperror.Validation("invalid_warehouse", "The warehouse does not exist or isn't the seller's")
+	errReservationNotFound = apperror.NotFound("reservation_not_found", "reservation not found")
+)
+
+// InventoryHandler serves sellers' warehouses and stock, and the
+// reservations buyers hold while checking out.
+type InventoryHandler struct {
+	inventory *inventory.Service
+	stock     repository.InventoryRepository
+	products  repository.ProductRepository
+}
+
+func NewInventoryHandler(inventory *inventory.Service, stock repository.InventoryRepository, products repository.ProductRepository) *InventoryHandler {
+	return &InventoryHandler{inventory: inventory, stock: stock, products: products}
+}
+
+// Routes implements api.Module. Inventory is part of the v2 catalog.
+func (h *InventoryHandler) Routes(version string) []api.Route {
+	if version != api.V2.Name {
+		return nil
+	}
+	seller := []string{string(models.PermissionSeller)}
+	manager := []string{string(models.PermissionSeller), string(models.PermissionAdmin)}
+	warehouse := models.Data[models.Warehouse]{}
+	reservation := models.Data[models.Reservation]{}
+
+	return []api.Route{
+		{
+			Method: http.MethodGet, Path: "/warehouses", Handler: h.ListWarehouses, Auth: true, Permissions: seller,
+			Summary:  "List the signed-in seller's warehouses",
+			Response: models.Data[[]models.Warehouse]{},
+		},
+		{
+			Method: http.MethodPost, Path: "/warehouses", Handler: h.CreateWarehouse, Auth: true, Permissions: seller,
+			Summary: "Create a warehouse",
+			Request: models.WarehouseRequest{}, Response: warehouse, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPut, Path: "/warehouses/:id", Handler: h.UpdateWarehouse, Auth: true, Permissions: manager,
+			Summary: "Rename a warehouse or change its code",
+			Query:   models.WarehousePath{}, Request: models.WarehouseRequest{}, Response: warehouse,
+		},
+		{
+			Method: http.MethodGet, Path: "/products/:id/inventory", Handler: h.ListStock, Auth: true, Permissions: manager,
+			Summary: "Get the stock of a product's variants in every warehouse",
+			Query:   models.ProductPath{}, Response: models.Data[[]models.StockLevel]{},
+		},
+		{
+			Method: http.MethodPut, Path: "/products/:id/variants/:variant_id/inventory/:warehouse_id", Handler: h.SetThreshold, Auth: true, Permissions: manager,
+			Summary: "Set when the seller is told a variant is running low in a warehouse",
+			Query:   models.StockPath{}, Request: models.StockThresholdRequest{}, Response: models.Data[models.StockLevel]{},
+		},
+		{
+			Method: http.MethodPost, Path: "/products/:id/variants/:variant_id/inventory/adjustments", Handler: h.Adjust, Auth: true, Permissions: manager,
+			Summary: "Change the stock of a variant on hand in a warehouse",
+			Query:   models.VariantPath{}, Request: models.StockAdjustmentRequest{},
+			Response: models.Data[models.StockAdjustment]{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/products/:id/variants/:variant_id/inventory/adjustments", Handler: h.ListAdjustments, Auth: true, Permissions: manager,
+			Summary: "List the changes to a variant's stock, newest first",
+			Query:   models.AdjustmentQuery{}, Response: models.Page[models.StockAdjustment]{},
+		},
+		{
+			Method: http.MethodPost, Path: "/reservations", Handler: h.Reserve, Auth: true,
+			Summary: "Hold stock while checking out",
+			Request: models.ReservationRequest{}, Response: reservation, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/reservations/:id", Handler: h.GetReservation, Auth: true,
+			Summary: "Get one of your reservations",
+			Query:   models.ReservationPath{}, Response: reservation,
+		},
+		{
+			Method: http.MethodDelete, Path: "/reservations/:id", Handler: h.Release, Auth: true,
+			Summary: "Release a reservation's stock",
+			Query:   models.ReservationPath{}, Status: http.StatusNoContent,
+		},
+	}
+}
+
+func (h *InventoryHandler) ListWarehouses(c *gin.Context) {
+	warehouses, err := h.stock.ListWarehouses(c.Request.Context(), c.GetInt("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	resp := models.Data[[]models.Warehouse]{Data: []models.Warehouse{}}
+	for _, w := range warehouses {
+		resp.Data = append(resp.Data, *w)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *InventoryHandler) CreateWarehouse(c *gin.Context) {
+	var req models.WarehouseRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	warehouse := &models.Warehouse{SellerID: c.GetInt("user_id"), Name: req.Name, Code: req.Code}
+	if err := h.stock.CreateWarehouse(c.Request.Context(), warehouse); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.Data[models.Warehouse]{Data: *warehouse})
+}
+
+func (h *InventoryHandler) UpdateWarehouse(c *gin.Context) {
+	var req models.WarehouseRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var path models.WarehousePath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	warehouse, err := h.stock.GetWarehouseByID(c.Request.Context(), path.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if warehouse.SellerID != c.GetInt("user_id") && !hasPermission(c, models.PermissionAdmin) {
+		_ = c.Error(errNotWarehouseOwner)
+		return
+	}
+
+	warehouse.Name = req.Name
+	warehouse.Code = req.Code
+	if err := h.stock.UpdateWarehouse(c.Request.Context(), warehouse); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.Warehouse]{Data: *warehouse})
+}
+
+func (h *InventoryHandler) ListStock(c *gin.Context) {
+	var path models.ProductPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if _, err := h.loadManaged(c, path.ID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	variants, err := h.products.ListVariants(ctx, path.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ids := make([]int, len(variants))
+	for i, v := range variants {
+		ids[i] = v.ID
+	}
+	levels, err := h.stock.ListStock(ctx, ids)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resp := models.Data[[]models.StockLevel]{Data: []models.StockLevel{}}
+	for _, level := range levels {
+		resp.Data = append(resp.Data, *level)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *InventoryHandler) SetThreshold(c *gin.Context) {
+	var req models.StockThresholdRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var path models.StockPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	product, err := h.loadVariant(c, path.ID, path.VariantID)
+	if err == nil {
+		err = h.checkWarehouse(c, path.WarehouseID, product.SellerID, errWarehouseNotFound)
+	}
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	level, err := h.inventory.SetThreshold(c.Request.Context(), path.VariantID, path.WarehouseID, req.LowStockThreshold)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.StockLevel]{Data: *level})
+}
+
+func (h *InventoryHandler) Adjust(c *gin.Context) {
+	var req models.StockAdjustmentRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var path models.VariantPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	product, err := h.loadVariant(c, path.ID, path.VariantID)
+	if err == nil {
+		err = h.checkWarehouse(c, req.WarehouseID, product.SellerID, errInvalidWarehouse)
+	}
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	adjustment := &models.StockAdjustment{Reason: req.Reason, OnHandChange: req.Change, Note: req.Note}
+	err = h.inventory.Adjust(c.Request.Context(), c.GetInt("user_id"), path.VariantID, req.WarehouseID, adjustment)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.Data[models.StockAdjustment]{Data: *adjustment})
+}
+
+func (h *InventoryHandler) ListAdjustments(c *gin.Context) {
+	var query models.AdjustmentQuery
+	if err := validation.BindURI(c, &query); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := validation.BindQuery(c, &query); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultPageSize
+	}
+	if _, err := h.loadVariant(c, query.ID, query.VariantID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	adjustments, total, err := h.stock.ListAdjustments(c.Request.Context(), query.VariantID, query.Limit, query.Offset)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	page := models.Page[models.StockAdjustment]{Data: []models.StockAdjustment{}, Total: total, Limit: query.Limit, Offset: query.Offset}
+	for _, a := range adjustments {
+		page.Data = append(page.Data, *a)
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *InventoryHandler) Reserve(c *gin.Context) {
+	var req models.ReservationRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	wanted := make([]models.ReservationItem, len(req.Items))
+	for i, item := range req.Items {
+		wanted[i] = models.ReservationItem{VariantID: item.VariantID, Quantity: item.Quantity}
+	}
+
+	reservation, err := h.inventory.Reserve(c.Request.Context(), c.GetInt("user_id"), wanted)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.Data[models.Reservation]{Data: *reservation})
+}
+
+func (h *InventoryHandler) GetReservation(c *gin.Context) {
+	reservation, err := h.loadReservation(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.Reservation]{Data: *reservation})
+}
+
+func (h *InventoryHandler) Release(c *gin.Context) {
+	reservation, err := h.loadReservation(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if _, err := h.inventory.Release(c.Request.Context(), reservation.ID, c.GetInt("user_id")); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// loadReservation loads the reservation in the path if it is the user's.
+// Other users' reservations are reported as missing.
+func (h *InventoryHandler) loadReservation(c *gin.Context) (*models.Reservation, error) {
+	var path models.ReservationPath
+	if err := validation.BindURI(c, &path); err != nil {
+		return nil, err
+	}
+	reservation, err := h.stock.GetReservationByID(c.Request.Context(), path.ID)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.UserID != c.GetInt("user_id") {
+		return nil, errReservationNotFound
+	}
+	return reservation, nil
+}
+
+// loadManaged loads the product if the user may manage its stock: its
+// seller or an admin.
+func (h *InventoryHandler) loadManaged(c *gin.Context, productID int) (*models.Product, error) {
+	product, err := h.products.GetProductByID(c.Request.Context(), productID)
+	if err != nil {
+		return nil, err
+	}
+	if product.SellerID != c.GetInt("user_id") && !hasPermission(c, models.PermissionAdmin) {
+		return nil, errNotProductOwner
+	}
+	return product, nil
+}
+
+// loadVariant is loadManaged that also checks that the variant is one of
+// the product's.
+func (h *InventoryHandler) loadVariant(c *gin.Context, productID, variantID int) (*models.Product, error) {
+	product, err := h.loadManaged(c, productID)
+	if err != nil {
+		return nil, err
+	}
+	variant, err := h.products.GetVariantByID(c.Request.Context(), variantID)
+	if err == nil && variant.ProductID != product.ID {
+		err = errVariantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// checkWarehouse returns notFound unless the warehouse belongs to the
+// seller, whose stock is kept only in their own warehouses.
+func (h *InventoryHandler) checkWarehouse(c *gin.Context, warehouseID, sellerID int, notFound error) error {
+	warehouse, err := h.stock.GetWarehouseByID(c.Request.Context(), warehouseID)
+	if errors.Is(err, apperror.ErrNotFound) || err == nil && warehouse.SellerID != sellerID {
+		return notFound
+	}
+	return err
+}
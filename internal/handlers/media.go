@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/media"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/validation"
+)
+
+// MediaPath is where files are served from, relative to the version
+// prefix; the signer must use the same path.
+const MediaPath = "/media"
+
+// MediaHandler serves stored files to holders of a signed URL.
+type MediaHandler struct {
+	blobs  media.BlobStore
+	signer *media.Signer
+}
+
+func NewMediaHandler(blobs media.BlobStore, signer *media.Signer) *MediaHandler {
+	return &MediaHandler{blobs: blobs, signer: signer}
+}
+
+// Routes implements api.Module. Files are served under v2 only.
+func (h *MediaHandler) Routes(version string) []api.Route {
+	if version != api.V2.Name {
+		return nil
+	}
+	return []api.Route{
+		{
+			Method: http.MethodGet, Path: MediaPath + "/*key", Handler: h.Serve,
+			Summary: "Download a file through a signed URL",
+			Query:   models.MediaQuery{},
+		},
+	}
+}
+
+// Serve streams the file if the URL's signature is valid. Browsers may
+// cache it until the URL expires.
+func (h *MediaHandler) Serve(c *gin.Context) {
+	var query models.MediaQuery
+	if err := validation.BindQuery(c, &query); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	ttl, err := h.signer.Verify(key, query.Expires, query.Signature)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	blob, err := h.blobs.Get(c.Request.Context(), key)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	defer blob.Close()
+
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(int(ttl.Seconds())))
+	c.DataFromReader(http.StatusOK, blob.Size, blob.ContentType, blob, nil)
+}
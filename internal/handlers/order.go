@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/order"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+	"github.com/sudhir512kj/ecommerce_backend/internal/validation"
+)
+
+// IdempotencyKeyHeader makes checkouts safe to retry: a checkout with a key
+// the user has used before returns the order it placed.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// ReplayedHeader is set on checkout responses that return an order placed
+// by an earlier request with the same idempotency key.
+const ReplayedHeader = "Idempotent-Replayed"
+
+const maxIdempotencyKeyLength = 255
+
+var (
+	errMissingIdempotencyKey = apperror.Validation("missing_idempotency_key", "The Idempotency-Key header is required")
+	errInvalidIdempotencyKey = apperror.Validation("invalid_idempotency_key", "The Idempotency-Key header can't be longer than 255 characters")
+	errOrderNotFound         = apperror.NotFound("order_not_found", "order not found")
+)
+
+// OrderHandler serves checkout and the signed-in user's orders, and lets
+// admins move orders through their statuses.
+type OrderHandler struct {
+	orders    *order.Service
+	orderRepo repository.OrderRepository
+}
+
+func NewOrderHandler(orders *order.Service, orderRepo repository.OrderRepository) *OrderHandler {
+	return &OrderHandler{orders: orders, orderRepo: orderRepo}
+}
+
+// Routes implements api.Module. Orders are only part of v2.
+func (h *OrderHandler) Routes(version string) []api.Route {
+	if version != api.V2.Name {
+		return nil
+	}
+	admin := []string{string(models.PermissionAdmin)}
+	resp := models.Data[models.Order]{}
+
+	return []api.Route{
+		{
+			Method: http.MethodPost, Path: "/orders", Handler: h.PlaceOrder, Auth: true, RateLimit: "checkout",
+			Summary: "Order your cart; a retry with the same Idempotency-Key header returns the same order with Idempotent-Replayed: true",
+			Request: models.CheckoutRequest{}, Response: resp, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/orders", Handler: h.ListOrders, Auth: true,
+			Summary: "List your orders, newest first",
+			Query:   models.OrderQuery{}, Response: models.Page[models.Order]{},
+		},
+		{
+			Method: http.MethodGet, Path: "/orders/:id", Handler: h.GetOrder, Auth: true,
+			Summary: "Get one of your orders with its fulfilments, one per seller, and their shipment history",
+			Query:   models.OrderPath{}, Response: resp,
+		},
+		{
+			Method: http.MethodPost, Path: "/orders/:id/cancel", Handler: h.CancelOrder, Auth: true,
+			Summary: "Cancel one of your orders that isn't paid yet",
+			Query:   models.OrderPath{}, Response: resp,
+		},
+		{
+			Method: http.MethodPost, Path: "/orders/:id/payments", Handler: h.Pay, Auth: true, RateLimit: "payments",
+			Summary: "Pay for one of your orders by card; the payment may need a challenge completed at its action_url",
+			Query:   models.OrderPath{}, Request: models.PaymentRequest{}, Response: models.Data[models.Payment]{},
+			Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/orders/:id/payments", Handler: h.ListPayments, Auth: true,
+			Summary: "List the payments of one of your orders, oldest first",
+			Query:   models.OrderPath{}, Response: models.Data[[]models.Payment]{},
+		},
+		{
+			Method: http.MethodPut, Path: "/orders/:id/status", Handler: h.SetStatus, Auth: true, Permissions: admin,
+			Summary: "Move an order on to its next status",
+			Query:   models.OrderPath{}, Request: models.OrderStatusRequest{}, Response: resp,
+		},
+	}
+}
+
+func (h *OrderHandler) PlaceOrder(c *gin.Context) {
+	var req models.CheckoutRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key == "" {
+		_ = c.Error(errMissingIdempotencyKey)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		_ = c.Error(errInvalidIdempotencyKey)
+		return
+	}
+
+	placed, created, err := h.orders.Place(c.Request.Context(), c.GetInt("user_id"), key, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	status := http.StatusCreated
+	if !created {
+		c.Header(ReplayedHeader, "true")
+		status = http.StatusOK
+	}
+	c.JSON(status, models.Data[models.Order]{Data: *placed})
+}
+
+func (h *OrderHandler) ListOrders(c *gin.Context) {
+	var query models.OrderQuery
+	if err := validation.BindQuery(c, &query); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultPageSize
+	}
+	orders, total, err := h.orderRepo.ListOrders(c.Request.Context(), repository.OrderFilter{
+		UserID: c.GetInt("user_id"),
+		Status: query.Status,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	resp := models.Page[models.Order]{Data: []models.Order{}, Total: total, Limit: query.Limit, Offset: query.Offset}
+	for _, o := range orders {
+		resp.Data = append(resp.Data, *o)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	var path models.OrderPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	o, err := h.orderRepo.GetOrderByID(c.Request.Context(), path.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	// Other users' orders are reported as missing so their IDs don't leak.
+	if o.UserID != c.GetInt("user_id") {
+		_ = c.Error(errOrderNotFound)
+		return
+	}
+	o.Fulfilments, err = h.orders.Fulfilments(c.Request.Context(), o.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.Order]{Data: *o})
+}
+
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	var path models.OrderPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	o, err := h.orders.Cancel(c.Request.Context(), path.ID, c.GetInt("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.Order]{Data: *o})
+}
+
+func (h *OrderHandler) SetStatus(c *gin.Context) {
+	var req models.OrderStatusRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var path models.OrderPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	o, err := h.orders.Transition(c.Request.Context(), path.ID, req.Status, c.GetInt("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.Order]{Data: *o})
+}
+
+func (h *OrderHandler) Pay(c *gin.Context) {
+	var req models.PaymentRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var path models.OrderPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	p, err := h.orders.Pay(c.Request.Context(), path.ID, c.GetInt("user_id"), req.Card)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.Data[models.Payment]{Data: *p})
+}
+
+func (h *OrderHandler) ListPayments(c *gin.Context) {
+	var path models.OrderPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	payments, err := h.orders.Payments(c.Request.Context(), path.ID, c.GetInt("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	resp := models.Data[[]models.Payment]{Data: []models.Payment{}}
+	for _, p := range payments {
+		resp.Data = append(resp.Data, *p)
+	}
+	c.JSON(http.StatusOK, resp)
+}
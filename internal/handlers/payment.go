@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/order"
+	"github.com/sudhir512kj/ecommerce_backend/internal/payment"
+	"github.com/sudhir512kj/ecommerce_backend/internal/validation"
+)
+
+// PaymentHandler receives the payment provider's webhooks. With the
+// simulated provider it also serves the challenge page buyers are sent to.
+type PaymentHandler struct {
+	orders    *order.Service
+	simulator *payment.Simulator
+}
+
+// NewPaymentHandler serves webhooks for provider, and the challenge route if
+// provider is the simulator.
+func NewPaymentHandler(orders *order.Service, provider payment.Provider) *PaymentHandler {
+	simulator, _ := provider.(*payment.Simulator)
+	return &PaymentHandler{orders: orders, simulator: simulator}
+}
+
+// Routes implements api.Module. Payments are only part of v2.
+func (h *PaymentHandler) Routes(version string) []api.Route {
+	if version != api.V2.Name {
+		return nil
+	}
+	routes := []api.Route{
+		{
+			Method: http.MethodPost, Path: "/payments/webhook", Handler: h.Webhook,
+			Summary: "Receive a signed webhook from the payment provider",
+			Status:  http.StatusNoContent,
+		},
+	}
+	if h.simulator != nil {
+		routes = append(routes, api.Route{
+			Method: http.MethodPost, Path: payment.ChallengePath, Handler: h.CompleteChallenge,
+			Summary: "Approve or fail the challenge of a simulated payment, as the buyer's bank would",
+			Query:   models.PaymentReferencePath{}, Request: models.SimulatorChallengeRequest{},
+			Status: http.StatusNoContent,
+		})
+	}
+	return routes
+}
+
+func (h *PaymentHandler) Webhook(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		_ = c.Error(apperror.InvalidRequest(err))
+		return
+	}
+	if err := h.orders.HandleWebhook(c.Request.Context(), c.Request.Header, body); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PaymentHandler) CompleteChallenge(c *gin.Context) {
+	var req models.SimulatorChallengeRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var path models.PaymentReferencePath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := h.simulator.Complete(path.Reference, req.Result == "approve"); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/media"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+	"github.com/sudhir512kj/ecommerce_backend/internal/validation"
+)
+
+const defaultPageSize = 20
+
+var (
+	errProductNotFound         = apperror.NotFound("product_not_found", "product not found")
+	errNotProductOwner         = apperror.Forbidden("not_product_owner", "Only the product's seller or an admin can change it")
+	errProductNotDraft         = apperror.Conflict("product_not_draft", "Only draft products can be deleted; archive it instead")
+	errProductHasStockHistory  = apperror.Conflict("product_has_stock_history", "Products whose variants have stock history can't be deleted; archive it instead")
+	errInvalidStatusTransition = apperror.Conflict("invalid_status_transition", "The product cannot move to that status")
+	errInvalidSlug             = apperror.Validation("invalid_slug", "A slug cannot be derived from the name; set one explicitly")
+)
+
+// productTransitions lists the statuses each status may move to. Archived
+// products go back through draft before they are published again.
+var productTransitions = map[models.ProductStatus][]models.ProductStatus{
+	models.ProductDraft:     {models.ProductPublished, models.ProductArchived},
+	models.ProductPublished: {models.ProductDraft, models.ProductArchived},
+	models.ProductArchived:  {models.ProductDraft},
+}
+
+type ProductHandler struct {
+	products   repository.ProductRepository
+	categories repository.CategoryRepository
+	stock      repository.InventoryRepository
+	tx         database.Transactor
+	blobs      media.BlobStore
+	signer     *media.Signer
+	processor  *media.Processor
+}
+
+func NewProductHandler(
+	products repository.ProductRepository,
+	categories repository.CategoryRepository,
+	stock repository.InventoryRepository,
+	tx database.Transactor,
+	blobs media.BlobStore,
+	signer *media.Signer,
+	processor *media.Processor,
+) *ProductHandler {
+	return &ProductHandler{
+		products:   products,
+		categories: categories,
+		stock:      stock,
+		tx:         tx,
+		blobs:      blobs,
+		signer:     signer,
+		processor:  processor,
+	}
+}
+
+// Routes implements api.Module. The catalog is only part of v2.
+func (h *ProductHandler) Routes(version string) []api.Route {
+	if version != api.V2.Name {
+		return nil
+	}
+	seller := []string{string(models.PermissionSeller)}
+	manager := []string{string(models.PermissionSeller), string(models.PermissionAdmin)}
+	product := models.Data[models.Product]{}
+	page := models.Page[models.Product]{}
+
+	return []api.Route{
+		{
+			Method: http.MethodGet, Path: "/products", Handler: h.ListPublished,
+			Summary: "List published products",
+			Query:   models.ProductQuery{}, Response: page,
+		},
+		{
+			Method: http.MethodGet, Path: "/products/:id", Handler: h.GetPublished,
+			Summary: "Get a published product",
+			Query:   models.ProductPath{}, Response: product,
+		},
+		{
+			Method: http.MethodPost, Path: "/products", Handler: h.Create, Auth: true, Permissions: seller,
+			Summary: "Create a draft product with a single variant",
+			Request: models.ProductRequest{}, Response: product, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPut, Path: "/products/:id", Handler: h.Update, Auth: true, Permissions: manager,
+			Summary: "Replace a product's details",
+			Query:   models.ProductPath{}, Request: models.ProductRequest{}, Response: product,
+		},
+		{
+			Method: http.MethodPost, Path: "/products/:id/status", Handler: h.SetStatus, Auth: true, Permissions: manager,
+			Summary: "Publish, unpublish or archive a product",
+			Query:   models.ProductPath{}, Request: models.ProductStatusRequest{}, Response: product,
+		},
+		{
+			Method: http.MethodPut, Path: "/products/:id/options", Handler: h.SetOptions, Auth: true, Permissions: manager,
+			Summary: "Replace a product's options and regenerate its variants",
+			Query:   models.ProductPath{}, Request: models.ProductOptionsRequest{}, Response: product,
+		},
+		{
+			Method: http.MethodPut, Path: "/products/:id/variants/:variant_id", Handler: h.UpdateVariant, Auth: true, Permissions: manager,
+			Summary: "Replace a variant's SKU, price, weight, barcode and images",
+			Query:   models.VariantPath{}, Request: models.VariantRequest{}, Response: models.Data[models.ProductVariant]{},
+		},
+		{
+			Method: http.MethodPost, Path: "/products/:id/images", Handler: h.UploadImage, Auth: true, Permissions: manager,
+			Summary: "Upload a JPEG, PNG or GIF image of a product",
+			Query:   models.ProductPath{}, Request: models.ImageUploadRequest{}, Multipart: true,
+			Response: models.Data[models.ProductImage]{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodDelete, Path: "/products/:id/images/:image_id", Handler: h.DeleteImage, Auth: true, Permissions: manager,
+			Summary: "Delete a product image",
+			Query:   models.ImagePath{}, Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodDelete, Path: "/products/:id", Handler: h.Delete, Auth: true, Permissions: manager,
+			Summary: "Delete a draft product",
+			Query:   models.ProductPath{}, Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodGet, Path: "/seller/products", Handler: h.ListOwn, Auth: true, Permissions: seller,
+			Summary: "List the signed-in seller's products in any status",
+			Query:   models.SellerProductQuery{}, Response: page,
+		},
+		{
+			Method: http.MethodGet, Path: "/seller/products/:id", Handler: h.GetOwn, Auth: true, Permissions: manager,
+			Summary: "Get a product in any status",
+			Query:   models.ProductPath{}, Response: product,
+		},
+	}
+}
+
+func (h *ProductHandler) ListPublished(c *gin.Context) {
+	var query models.ProductQuery
+	if err := validation.BindQuery(c, &query); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.list(c, query.CategoryID, repository.ProductFilter{
+		SellerID: query.SellerID,
+		Status:   models.ProductPublished,
+		Limit:    query.Limit,
+		Offset:   query.Offset,
+	})
+}
+
+func (h *ProductHandler) ListOwn(c *gin.Context) {
+	var query models.SellerProductQuery
+	if err := validation.BindQuery(c, &query); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.list(c, query.CategoryID, repository.ProductFilter{
+		SellerID: c.GetInt("user_id"),
+		Status:   query.Status,
+		Limit:    query.Limit,
+		Offset:   query.Offset,
+	})
+}
+
+// list pages through the products matching filter. A category narrows it
+// to products in the category's subtree.
+func (h *ProductHandler) list(c *gin.Context, categoryID int, filter repository.ProductFilter) {
+	if filter.Limit == 0 {
+		filter.Limit = defaultPageSize
+	}
+	if categoryID != 0 {
+		tree, err := loadCategoryTree(c.Request.Context(), h.categories)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		filter.CategoryIDs = tree.subtree(categoryID)
+	}
+	products, total, err := h.products.ListProducts(c.Request.Context(), filter)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	page := models.Page[models.Product]{Data: []models.Product{}, Total: total, Limit: filter.Limit, Offset: filter.Offset}
+	for _, p := range products {
+		page.Data = append(page.Data, *p)
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// GetPublished hides products that aren't published, even from their
+// seller, who uses GetOwn instead.
+func (h *ProductHandler) GetPublished(c *gin.Context) {
+	product, err := h.load(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if product.Status != models.ProductPublished {
+		_ = c.Error(errProductNotFound)
+		return
+	}
+	if err := h.loadDetails(c.Request.Context(), product); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.Product]{Data: *product})
+}
+
+func (h *ProductHandler) GetOwn(c *gin.Context) {
+	product, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := h.loadDetails(c.Request.Context(), product); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.Product]{Data: *product})
+}
+
+func (h *ProductHandler) Create(c *gin.Context) {
+	var req models.ProductRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	product := &models.Product{SellerID: c.GetInt("user_id"), Status: models.ProductDraft}
+	if err := h.apply(c, product, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	err := h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.products.CreateProduct(ctx, product); err != nil {
+			return err
+		}
+		return h.createVariant(ctx, product, map[string]string{}, nil)
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := h.loadDetails(c.Request.Context(), product); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.Data[models.Product]{Data: *product})
+}
+
+func (h *ProductHandler) Update(c *gin.Context) {
+	var req models.ProductRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	product, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := h.apply(c, product, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := h.products.UpdateProduct(c.Request.Context(), product); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.Product]{Data: *product})
+}
+
+func (h *ProductHandler) SetStatus(c *gin.Context) {
+	var req models.ProductStatusRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	product, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if req.Status != product.Status {
+		if !canTransition(product.Status, req.Status) {
+			_ = c.Error(errInvalidStatusTransition)
+			return
+		}
+		product.Status = req.Status
+		if req.Status == models.ProductPublished {
+			now := time.Now()
+			product.PublishedAt = &now
+		}
+		if err := h.products.UpdateProduct(c.Request.Context(), product); err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, models.Data[models.Product]{Data: *product})
+}
+
+func (h *ProductHandler) Delete(c *gin.Context) {
+	product, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if product.Status != models.ProductDraft {
+		_ = c.Error(errProductNotDraft)
+		return
+	}
+	ctx := c.Request.Context()
+	variants, err := h.products.ListVariants(ctx, product.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	for _, v := range variants {
+		hasHistory, err := h.hasStockHistory(ctx, v.ID)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		if hasHistory {
+			_ = c.Error(errProductHasStockHistory)
+			return
+		}
+	}
+	images, err := h.products.ListImages(ctx, product.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := h.products.DeleteProduct(ctx, product.ID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	for _, image := range images {
+		media.DeleteImageBlobs(ctx, h.blobs, image)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ProductHandler) load(c *gin.Context) (*models.Product, error) {
+	var path models.ProductPath
+	if err := validation.BindURI(c, &path); err != nil {
+		return nil, err
+	}
+	return h.products.GetProductByID(c.Request.Context(), path.ID)
+}
+
+// loadManaged loads the product in the path if the user may change it: its
+// seller or an admin.
+func (h *ProductHandler) loadManaged(c *gin.Context) (*models.Product, error) {
+	product, err := h.load(c)
+	if err != nil {
+		return nil, err
+	}
+	if product.SellerID != c.GetInt("user_id") && !hasPermission(c, models.PermissionAdmin) {
+		return nil, errNotProductOwner
+	}
+	return product, nil
+}
+
+// apply copies the request onto product. The category must be a leaf and
+// the attributes must fit the schema it inherits; without a category a
+// product has no attributes.
+func (h *ProductHandler) apply(c *gin.Context, product *models.Product, req models.ProductRequest) error {
+	var schema []models.CategoryAttribute
+	if req.CategoryID != 0 {
+		tree, err := loadCategoryTree(c.Request.Context(), h.categories)
+		if err != nil {
+			return err
+		}
+		if _, ok := tree.byID[req.CategoryID]; !ok {
+			return errInvalidCategory
+		}
+		if len(tree.children[req.CategoryID]) > 0 {
+			return errCategoryNotLeaf
+		}
+		schema, err = effectiveAttributes(c.Request.Context(), h.categories, tree.path(req.CategoryID))
+		if err != nil {
+			return err
+		}
+	}
+	attributes := req.Attributes
+	if attributes == nil {
+		attributes = map[string]any{}
+	}
+	if err := checkAttributes(c, schema, attributes); err != nil {
+		return err
+	}
+
+	slug := req.Slug
+	if slug == "" {
+		slug = slugify(req.Name)
+		if slug == "" {
+			return errInvalidSlug
+		}
+	}
+	product.Name = req.Name
+	product.Slug = slug
+	product.Description = req.Description
+	product.Price = req.Price
+	product.Currency = req.Currency
+	product.CategoryID = nil
+	if req.CategoryID != 0 {
+		product.CategoryID = &req.CategoryID
+	}
+	product.Attributes = attributes
+	return nil
+}
+
+func canTransition(from, to models.ProductStatus) bool {
+	return slices.Contains(productTransitions[from], to)
+}
+
+// slugify lower-cases name and joins its runs of ASCII letters and digits
+// with hyphens, e.g. "Red Shoes (42)" becomes "red-shoes-42".
+func slugify(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+	slug := strings.Join(words, "-")
+	if len(slug) > 200 {
+		slug = strings.TrimRight(slug[:200], "-")
+	}
+	return slug
+}
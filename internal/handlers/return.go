@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/media"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+	"github.com/sudhir512kj/ecommerce_backend/internal/returns"
+	"github.com/sudhir512kj/ecommerce_backend/internal/validation"
+)
+
+var (
+	errReturnNotFound = apperror.NotFound("return_not_found", "return not found")
+	errNotReturnOwner = apperror.Forbidden("not_return_owner", "Only the return's seller or an admin can handle it")
+)
+
+// ReturnHandler lets buyers return items of delivered orders and sellers
+// review, inspect and refund the returns of their items.
+type ReturnHandler struct {
+	returns    *returns.Service
+	returnRepo repository.ReturnRepository
+	signer     *media.Signer
+}
+
+func NewReturnHandler(returns *returns.Service, returnRepo repository.ReturnRepository, signer *media.Signer) *ReturnHandler {
+	return &ReturnHandler{returns: returns, returnRepo: returnRepo, signer: signer}
+}
+
+// Routes implements api.Module. Returns are only part of v2.
+func (h *ReturnHandler) Routes(version string) []api.Route {
+	if version != api.V2.Name {
+		return nil
+	}
+	seller := []string{string(models.PermissionSeller)}
+	manager := []string{string(models.PermissionSeller), string(models.PermissionAdmin)}
+	resp := models.Data[models.Return]{}
+
+	return []api.Route{
+		{
+			Method: http.MethodPost, Path: "/orders/:id/returns", Handler: h.RequestReturn, Auth: true,
+			Summary: "Ask to return items of one of your delivered orders, all sold by the same seller",
+			Query:   models.OrderPath{}, Request: models.ReturnCreateRequest{}, Response: resp,
+			Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/returns", Handler: h.ListReturns, Auth: true,
+			Summary: "List your returns, newest first",
+			Query:   models.ReturnQuery{}, Response: models.Page[models.Return]{},
+		},
+		{
+			Method: http.MethodGet, Path: "/returns/:id", Handler: h.GetReturn, Auth: true,
+			Summary: "Get one of your returns with its history",
+			Query:   models.ReturnPath{}, Response: resp,
+		},
+		{
+			Method: http.MethodPost, Path: "/returns/:id/items/:variant_id/photos", Handler: h.AddPhoto, Auth: true,
+			Summary: "Upload a JPEG, PNG or GIF photo of an item of one of your returns",
+			Query:   models.ReturnItemPath{}, Request: models.ImageUploadRequest{}, Multipart: true,
+			Response: models.Data[models.ReturnPhoto]{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/seller/returns", Handler: h.ListOwn, Auth: true, Permissions: seller,
+			Summary: "List the returns of the signed-in seller's items, newest first",
+			Query:   models.ReturnQuery{}, Response: models.Page[models.Return]{},
+		},
+		{
+			Method: http.MethodGet, Path: "/seller/returns/:id", Handler: h.GetOwn, Auth: true, Permissions: manager,
+			Summary: "Get a return of your items with its history",
+			Query:   models.ReturnPath{}, Response: resp,
+		},
+		{
+			Method: http.MethodPost, Path: "/returns/:id/approve", Handler: h.Approve, Auth: true, Permissions: manager,
+			Summary: "Approve a requested return; the buyer gets a label to send the items back with",
+			Query:   models.ReturnPath{}, Response: resp,
+		},
+		{
+			Method: http.MethodPost, Path: "/returns/:id/reject", Handler: h.Reject, Auth: true, Permissions: manager,
+			Summary: "Reject a requested return",
+			Query:   models.ReturnPath{}, Request: models.ReturnRejectRequest{}, Response: resp,
+		},
+		{
+			Method: http.MethodPost, Path: "/returns/:id/inspection", Handler: h.Inspect, Auth: true, Permissions: manager,
+			Summary: "Record what arrived of an approved return; resellable items are restocked",
+			Query:   models.ReturnPath{}, Request: models.ReturnInspectionRequest{}, Response: resp,
+		},
+		{
+			Method: http.MethodPost, Path: "/returns/:id/refund", Handler: h.Refund, Auth: true, Permissions: manager,
+			Summary: "Refund the buyer for an inspected return, by default the price of the items received",
+			Query:   models.ReturnPath{}, Request: models.ReturnRefundRequest{}, Response: resp,
+		},
+	}
+}
+
+func (h *ReturnHandler) RequestReturn(c *gin.Context) {
+	var req models.ReturnCreateRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var path models.OrderPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ret, err := h.returns.Request(c.Request.Context(), path.ID, c.GetInt("user_id"), req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.sign(ret)
+	c.JSON(http.StatusCreated, models.Data[models.Return]{Data: *ret})
+}
+
+func (h *ReturnHandler) ListReturns(c *gin.Context) {
+	var query models.ReturnQuery
+	if err := validation.BindQuery(c, &query); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.list(c, repository.ReturnFilter{
+		UserID: c.GetInt("user_id"),
+		Status: query.Status,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	})
+}
+
+func (h *ReturnHandler) ListOwn(c *gin.Context) {
+	var query models.ReturnQuery
+	if err := validation.BindQuery(c, &query); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.list(c, repository.ReturnFilter{
+		SellerID: c.GetInt("user_id"),
+		Status:   query.Status,
+		Limit:    query.Limit,
+		Offset:   query.Offset,
+	})
+}
+
+func (h *ReturnHandler) list(c *gin.Context, filter repository.ReturnFilter) {
+	if filter.Limit == 0 {
+		filter.Limit = defaultPageSize
+	}
+	rets, total, err := h.returnRepo.ListReturns(c.Request.Context(), filter)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	resp := models.Page[models.Return]{Data: []models.Return{}, Total: total, Limit: filter.Limit, Offset: filter.Offset}
+	for _, ret := range rets {
+		h.sign(ret)
+		resp.Data = append(resp.Data, *ret)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *ReturnHandler) GetReturn(c *gin.Context) {
+	ret, err := h.load(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	// Other users' returns are reported as missing so their IDs don't leak.
+	if ret.UserID != c.GetInt("user_id") {
+		_ = c.Error(errReturnNotFound)
+		return
+	}
+	h.sign(ret)
+	c.JSON(http.StatusOK, models.Data[models.Return]{Data: *ret})
+}
+
+func (h *ReturnHandler) GetOwn(c *gin.Context) {
+	ret, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.sign(ret)
+	c.JSON(http.StatusOK, models.Data[models.Return]{Data: *ret})
+}
+
+func (h *ReturnHandler) AddPhoto(c *gin.Context) {
+	var req models.ImageUploadRequest
+	if err := validation.BindMultipart(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var path models.ReturnItemPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	file, err := req.File.Open()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	defer file.Close()
+
+	photo, err := h.returns.AddPhoto(c.Request.Context(), path.ID, path.VariantID, c.GetInt("user_id"), file, req.File.Size)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	photo.URL = h.signer.URL(photo.Key)
+	c.JSON(http.StatusCreated, models.Data[models.ReturnPhoto]{Data: *photo})
+}
+
+func (h *ReturnHandler) Approve(c *gin.Context) {
+	ret, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ret, err = h.returns.Approve(c.Request.Context(), ret.ID, c.GetInt("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.sign(ret)
+	c.JSON(http.StatusOK, models.Data[models.Return]{Data: *ret})
+}
+
+func (h *ReturnHandler) Reject(c *gin.Context) {
+	var req models.ReturnRejectRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ret, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ret, err = h.returns.Reject(c.Request.Context(), ret.ID, c.GetInt("user_id"), req.Reason)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.sign(ret)
+	c.JSON(http.StatusOK, models.Data[models.Return]{Data: *ret})
+}
+
+func (h *ReturnHandler) Inspect(c *gin.Context) {
+	var req models.ReturnInspectionRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ret, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ret, err = h.returns.Inspect(c.Request.Context(), ret.ID, c.GetInt("user_id"), req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.sign(ret)
+	c.JSON(http.StatusOK, models.Data[models.Return]{Data: *ret})
+}
+
+func (h *ReturnHandler) Refund(c *gin.Context) {
+	var req models.ReturnRefundRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ret, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ret, err = h.returns.Refund(c.Request.Context(), ret.ID, c.GetInt("user_id"), req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.sign(ret)
+	c.JSON(http.StatusOK, models.Data[models.Return]{Data: *ret})
+}
+
+// load returns the return named in the path.
+func (h *ReturnHandler) load(c *gin.Context) (*models.Return, error) {
+	var path models.ReturnPath
+	if err := validation.BindURI(c, &path); err != nil {
+		return nil, err
+	}
+	return h.returnRepo.GetReturnByID(c.Request.Context(), path.ID)
+}
+
+// loadManaged is load for routes that only the return's seller or an admin
+// may use.
+func (h *ReturnHandler) loadManaged(c *gin.Context) (*models.Return, error) {
+	ret, err := h.load(c)
+	if err != nil {
+		return nil, err
+	}
+	if ret.SellerID != c.GetInt("user_id") && !hasPermission(c, models.PermissionAdmin) {
+		return nil, errNotReturnOwner
+	}
+	return ret, nil
+}
+
+// sign sets the expiring URLs of the return's label and photos.
+func (h *ReturnHandler) sign(ret *models.Return) {
+	if ret.Label != nil {
+		ret.Label.URL = h.signer.URL(ret.Label.Key)
+	}
+	for i := range ret.Items {
+		for j := range ret.Items[i].Photos {
+			photo := &ret.Items[i].Photos[j]
+			photo.URL = h.signer.URL(photo.Key)
+		}
+	}
+}
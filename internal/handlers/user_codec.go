@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/validation"
+	"github.com/sudhir512kj/ecommerce_backend/pkg/jwt"
+)
+
+// userCodec is the part of the user API that differs between versions:
+// how some requests are read and responses written. The handlers hold the
+// business logic and are shared by every version.
+type userCodec interface {
+	bindPasswordChange(c *gin.Context) (passwordChange, error)
+	// writeLogin answers a login whose credentials were accepted and whose
+	// OTP has been sent.
+	writeLogin(c *gin.Context, user *models.User)
+	writeUser(c *gin.Context, status int, user *models.User)
+}
+
+type passwordChange struct {
+	UserID      int
+	OldPassword string
+	NewPassword string
+}
+
+func userResponse(user *models.User) models.UserResponse {
+	return models.UserResponse{
+		ID:          user.ID,
+		FirstName:   user.FirstName,
+		LastName:    user.LastName,
+		Email:       user.Email,
+		Permissions: user.Permissions,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+	}
+}
+
+// userCodecV1 is the original, deprecated format.
+type userCodecV1 struct {
+	conf config.Provider
+}
+
+// bindPasswordChange takes the user from the request body, as v1 always has.
+func (userCodecV1) bindPasswordChange(c *gin.Context) (passwordChange, error) {
+	var req models.ChangePasswordRequestV1
+	if err := validation.BindJSON(c, &req); err != nil {
+		return passwordChange{}, err
+	}
+	return passwordChange{UserID: req.UserID, OldPassword: req.OldPassword, NewPassword: req.NewPassword}, nil
+}
+
+// writeLogin also issues a token straight away, without waiting for the OTP.
+func (v userCodecV1) writeLogin(c *gin.Context, user *models.User) {
+	c.JSON(http.StatusOK, models.MessageResponse{
+		Message: "OTP sent to your email",
+	})
+
+	token, err := jwt.GenerateToken(v.conf.Current(), user.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TokenResponse{
+		Message: "Login successful",
+		Token:   token,
+	})
+}
+
+func (userCodecV1) writeUser(c *gin.Context, status int, user *models.User) {
+	c.JSON(status, userResponse(user))
+}
+
+// userCodecV2 only issues tokens once the OTP is verified, changes the
+// password of the authenticated user, and wraps resources in "data".
+type userCodecV2 struct{}
+
+func (userCodecV2) bindPasswordChange(c *gin.Context) (passwordChange, error) {
+	var req models.ChangePasswordRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		return passwordChange{}, err
+	}
+	return passwordChange{UserID: c.GetInt("user_id"), OldPassword: req.OldPassword, NewPassword: req.NewPassword}, nil
+}
+
+func (userCodecV2) writeLogin(c *gin.Context, _ *models.User) {
+	c.JSON(http.StatusOK, models.MessageResponse{
+		Message: "OTP sent to your email",
+	})
+}
+
+func (userCodecV2) writeUser(c *gin.Context, status int, user *models.User) {
+	c.JSON(status, models.Data[models.UserResponse]{Data: userResponse(user)})
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/validation"
+)
+
+// maxVariants caps how many combinations a product's options may produce.
+const maxVariants = 100
+
+var (
+	errTooManyVariants = apperror.Validation("too_many_variants", "The options produce more than "+strconv.Itoa(maxVariants)+" variants")
+	errVariantNotFound = apperror.NotFound("variant_not_found", "variant not found")
+)
+
+// SetOptions replaces the product's options and regenerates its variants.
+// Variants whose option values are still offered keep their SKU, price and
+// stock; new combinations start at the product's price with no stock. The
+// options can't drop variants with stock history.
+func (h *ProductHandler) SetOptions(c *gin.Context) {
+	var req models.ProductOptionsRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	options := make([]models.ProductOption, len(req.Options))
+	count := 1
+	for i, o := range req.Options {
+		options[i] = models.ProductOption{Name: o.Name, Values: o.Values}
+		count *= len(o.Values)
+	}
+	if count > maxVariants {
+		_ = c.Error(errTooManyVariants)
+		return
+	}
+
+	product, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	err = h.tx.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.products.ReplaceOptions(ctx, product.ID, options); err != nil {
+			return err
+		}
+		return h.syncVariants(ctx, product, options)
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := h.loadDetails(c.Request.Context(), product); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.Product]{Data: *product})
+}
+
+// syncVariants makes the product's variants match every combination of
+// options. Stale variants are deleted before new ones are created so their
+// SKUs can be reused, unless their stock has ever changed: deleting them
+// would lose their stock, reservations and ledger.
+func (h *ProductHandler) syncVariants(ctx context.Context, product *models.Product, options []models.ProductOption) error {
+	existing, err := h.products.ListVariants(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+	kept := map[string]bool{}
+	for _, v := range existing {
+		key, ok := variantKey(v.Options, options)
+		if ok && !kept[key] {
+			kept[key] = true
+			continue
+		}
+		hasHistory, err := h.hasStockHistory(ctx, v.ID)
+		if err != nil {
+			return err
+		}
+		if hasHistory {
+			return apperror.Conflict("variant_has_stock_history",
+				fmt.Sprintf("Variant %s has stock history, so the options must keep offering it", v.SKU))
+		}
+		if err := h.products.DeleteVariant(ctx, v.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, combination := range combinations(options) {
+		key, _ := variantKey(combination, options)
+		if kept[key] {
+			continue
+		}
+		if err := h.createVariant(ctx, product, combination, options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasStockHistory reports whether the variant's stock has ever changed.
+// The ledger keeps such variants, so they can't be deleted.
+func (h *ProductHandler) hasStockHistory(ctx context.Context, variantID int) (bool, error) {
+	_, total, err := h.stock.ListAdjustments(ctx, variantID, 1, 0)
+	return total > 0, err
+}
+
+// createVariant adds a variant for combination with a SKU derived from the
+// product ID and option values, e.g. P12-RED-M.
+func (h *ProductHandler) createVariant(ctx context.Context, product *models.Product, combination map[string]string, options []models.ProductOption) error {
+	parts := []string{"P" + strconv.Itoa(product.ID)}
+	for _, o := range options {
+		if part := skuPart(combination[o.Name]); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return h.products.CreateVariant(ctx, &models.ProductVariant{
+		ProductID: product.ID,
+		SKU:       strings.Join(parts, "-"),
+		Options:   combination,
+		Price:     product.Price,
+		Images:    []string{},
+	})
+}
+
+func (h *ProductHandler) UpdateVariant(c *gin.Context) {
+	var req models.VariantRequest
+	if err := validation.BindJSON(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var path models.VariantPath
+	if err := validation.BindURI(c, &path); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	product, err := h.loadManaged(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	variant, err := h.products.GetVariantByID(c.Request.Context(), path.VariantID)
+	if err == nil && variant.ProductID != product.ID {
+		err = errVariantNotFound
+	}
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	variant.SKU = req.SKU
+	variant.Price = req.Price
+	variant.WeightGrams = req.WeightGrams
+	variant.Barcode = req.Barcode
+	variant.Images = req.Images
+	if variant.Images == nil {
+		variant.Images = []string{}
+	}
+	if err := h.products.UpdateVariant(c.Request.Context(), variant); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := h.loadStock(c.Request.Context(), []*models.ProductVariant{variant}); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Data[models.ProductVariant]{Data: *variant})
+}
+
+// loadDetails fills in the product's options, variants and images.
+func (h *ProductHandler) loadDetails(ctx context.Context, product *models.Product) error {
+	options, err := h.products.ListOptions(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+	variants, err := h.products.ListVariants(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+	if err := h.loadStock(ctx, variants); err != nil {
+		return err
+	}
+	images, err := h.products.ListImages(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+	product.Options = options
+	product.Variants = make([]models.ProductVariant, len(variants))
+	for i, v := range variants {
+		product.Variants[i] = *v
+	}
+	product.Images = make([]models.ProductImage, len(images))
+	for i, image := range images {
+		h.signImage(image)
+		product.Images[i] = *image
+	}
+	return nil
+}
+
+// loadStock fills in how many of each variant are available across
+// warehouses.
+func (h *ProductHandler) loadStock(ctx context.Context, variants []*models.ProductVariant) error {
+	ids := make([]int, len(variants))
+	for i, v := range variants {
+		ids[i] = v.ID
+	}
+	levels, err := h.stock.ListStock(ctx, ids)
+	if err != nil {
+		return err
+	}
+	available := map[int]int{}
+	for _, level := range levels {
+		available[level.VariantID] += level.Available
+	}
+	for _, v := range variants {
+		v.Stock = available[v.ID]
+	}
+	return nil
+}
+
+// combinations returns every combination of one value per option, in the
+// order the options and values are listed. No options give one empty
+// combination: the product's only variant.
+func combinations(options []models.ProductOption) []map[string]string {
+	result := []map[string]string{{}}
+	for _, o := range options {
+		var next []map[string]string
+		for _, partial := range result {
+			for _, value := range o.Values {
+				combination := maps.Clone(partial)
+				combination[o.Name] = value
+				next = append(next, combination)
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+// variantKey identifies a combination of option values. It reports false
+// when the combination doesn't fit options: it names other options or uses
+// a value that is no longer offered.
+func variantKey(combination map[string]string, options []models.ProductOption) (string, bool) {
+	if len(combination) != len(options) {
+		return "", false
+	}
+	values := make([]string, len(options))
+	for i, o := range options {
+		value, ok := combination[o.Name]
+		if !ok || !slices.Contains(o.Values, value) {
+			return "", false
+		}
+		values[i] = value
+	}
+	return strings.Join(values, "\x00"), true
+}
+
+// skuPart upper-cases an option value and joins its runs of ASCII letters
+// and digits with hyphens so it fits the sku rule.
+func skuPart(value string) string {
+	words := strings.FieldsFunc(strings.ToUpper(value), func(r rune) bool {
+		return r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+	return strings.Join(words, "-")
+}
@@ -0,0 +1,52 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/worker"
+)
+
+// Postgres checks that a connection can be taken from the pool.
+func Postgres(db *sql.DB) Checker {
+	return CheckFunc{CheckName: "postgres", Fn: db.PingContext}
+}
+
+// Migrations checks that the database schema is at the version this binary
+// was built for.
+func Migrations(db *sql.DB) Checker {
+	return CheckFunc{CheckName: "migrations", Fn: func(ctx context.Context) error {
+		current, err := database.SchemaVersion(ctx, db)
+		if err != nil {
+			return fmt.Errorf("read schema version: %w", err)
+		}
+		if expected := database.ExpectedSchemaVersion(); current != expected {
+			return fmt.Errorf("schema version %d, expected %d", current, expected)
+		}
+		return nil
+	}}
+}
+
+// Workers checks that every registered background worker is still running.
+func Workers(group *worker.Group) Checker {
+	return CheckFunc{CheckName: "workers", Fn: func(ctx context.Context) error {
+		var stopped []string
+		for _, status := range group.Statuses() {
+			if status.Running {
+				continue
+			}
+			if status.Err != nil {
+				stopped = append(stopped, fmt.Sprintf("%s (%v)", status.Name, status.Err))
+			} else {
+				stopped = append(stopped, status.Name)
+			}
+		}
+		if len(stopped) > 0 {
+			return fmt.Errorf("not running: %s", strings.Join(stopped, ", "))
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,165 @@
+// Package health implements the liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultCheckTimeout = 2 * time.Second
+
+// Checker reports whether one dependency is usable.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// CheckFunc adapts a function to the Checker interface.
+type CheckFunc struct {
+	CheckName string
+	Fn        func(ctx context.Context) error
+}
+
+func (f CheckFunc) Name() string { return f.CheckName }
+
+func (f CheckFunc) Check(ctx context.Context) error { return f.Fn(ctx) }
+
+type registeredCheck struct {
+	Checker
+	critical bool
+}
+
+// Registry holds the readiness checks. A failing critical check makes the
+// service unavailable; a failing optional check only marks it degraded.
+type Registry struct {
+	mu       sync.RWMutex
+	checks   []registeredCheck
+	draining atomic.Bool
+	timeout  time.Duration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{timeout: defaultCheckTimeout}
+}
+
+// Register adds a check that must pass for the service to be ready.
+func (r *Registry) Register(c Checker) {
+	r.add(c, true)
+}
+
+// RegisterOptional adds a check whose failure degrades the service without
+// taking it out of rotation.
+func (r *Registry) RegisterOptional(c Checker) {
+	r.add(c, false)
+}
+
+func (r *Registry) add(c Checker, critical bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, registeredCheck{Checker: c, critical: critical})
+}
+
+// SetDraining makes readiness fail so load balancers stop routing new
+// traffic while in-flight requests finish.
+func (r *Registry) SetDraining() {
+	r.draining.Store(true)
+}
+
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+type ComponentStatus struct {
+	Status     string `json:"status"`
+	Critical   bool   `json:"critical"`
+	DurationMS int64  `json:"duration_ms"`
+	// Error is logged, not served: probes are unauthenticated and the
+	// errors of dependencies can name hosts and paths.
+	Error string `json:"-"`
+}
+
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// Run executes every check concurrently, each bounded by the check timeout.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := append([]registeredCheck(nil), r.checks...)
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Components: make(map[string]ComponentStatus, len(checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check registeredCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(checkCtx)
+			status := ComponentStatus{
+				Status:     "up",
+				Critical:   check.critical,
+				DurationMS: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				status.Status = "down"
+				status.Error = err.Error()
+				slog.WarnContext(ctx, "health check failed",
+					slog.String("component", check.Name()),
+					slog.Bool("critical", check.critical),
+					slog.Any("error", err))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[check.Name()] = status
+			switch {
+			case err == nil:
+			case check.critical:
+				report.Status = StatusUnavailable
+			case report.Status == StatusOK:
+				report.Status = StatusDegraded
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	if r.draining.Load() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+// Liveness reports that the process is up and serving requests. It checks no
+// dependencies so a database outage doesn't get the process restarted.
+func (r *Registry) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": StatusOK})
+}
+
+// Readiness reports whether the service can take traffic.
+func (r *Registry) Readiness(c *gin.Context) {
+	report := r.Run(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status == StatusUnavailable || report.Status == StatusDraining {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,456 @@
+// Package inventory keeps sellers' stock per warehouse. Reservations hold
+// stock while buyers check out and orders sell it; sellers receive and
+// correct it. Every change is made under a row lock and recorded in the
+// ledger.
+package inventory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/mailer"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+)
+
+var (
+	// ErrReservationNotActive is returned when ending a reservation that
+	// has already ended.
+	ErrReservationNotActive = apperror.Conflict("reservation_not_active", "The reservation has already ended")
+	// ErrReservationExpired is returned when committing a reservation past
+	// its expiry that the expiry worker hasn't released yet.
+	ErrReservationExpired = apperror.Conflict("reservation_expired", "The reservation has expired")
+)
+
+var (
+	errVariantNotForSale  = apperror.Validation("invalid_variant", "The variant does not exist or is not for sale")
+	errReservationNotSold = apperror.Conflict("reservation_not_committed", "Only stock that was sold can be restocked")
+	errStockReserved      = apperror.Conflict("stock_reserved", "Stock on hand can't drop below the units reserved")
+)
+
+const (
+	// expiryInterval is how often expired reservations are released.
+	expiryInterval = 30 * time.Second
+	expiryBatch    = 100
+)
+
+// Service changes stock. It is also the background worker that releases
+// expired reservations.
+type Service struct {
+	conf     config.Provider
+	stock    repository.InventoryRepository
+	products repository.ProductRepository
+	users    repository.UserRepository
+	tx       database.Transactor
+	mailer   mailer.Mailer
+	now      func() time.Time
+}
+
+func NewService(
+	conf config.Provider,
+	stock repository.InventoryRepository,
+	products repository.ProductRepository,
+	users repository.UserRepository,
+	tx database.Transactor,
+	m mailer.Mailer,
+) *Service {
+	return &Service{conf: conf, stock: stock, products: products, users: users, tx: tx, mailer: m, now: time.Now}
+}
+
+// Reserve holds stock of published variants for the user until the
+// reservation expires. Each wanted item's WarehouseID is ignored: stock is
+// taken from the warehouses with the most available first, so a variant
+// may be spread over several items. Nothing is reserved unless all of it
+// is available.
+func (s *Service) Reserve(ctx context.Context, userID int, wanted []models.ReservationItem) (*models.Reservation, error) {
+	wanted = merge(wanted)
+	variantIDs := make([]int, len(wanted))
+	for i, w := range wanted {
+		if err := s.checkForSale(ctx, w.VariantID); err != nil {
+			return nil, err
+		}
+		variantIDs[i] = w.VariantID
+	}
+
+	var (
+		reservation *models.Reservation
+		low         []models.StockLevel
+	)
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		low = nil
+		levels, err := s.stock.LockStock(ctx, variantIDs)
+		if err != nil {
+			return err
+		}
+		reservation = &models.Reservation{
+			UserID:    userID,
+			Status:    models.ReservationActive,
+			ExpiresAt: s.now().Add(s.conf.Current().Inventory.ReservationTTL),
+		}
+		for _, w := range wanted {
+			items, err := allocate(levels, w)
+			if err != nil {
+				return err
+			}
+			reservation.Items = append(reservation.Items, items...)
+		}
+		if err := s.stock.CreateReservation(ctx, reservation); err != nil {
+			return err
+		}
+
+		for _, item := range reservation.Items {
+			level := find(levels, item)
+			before := level.Available
+			err := s.apply(ctx, level, &models.StockAdjustment{
+				Reason:         models.AdjustmentReserved,
+				ReservedChange: item.Quantity,
+				ReservationID:  &reservation.ID,
+				ActorID:        &userID,
+			})
+			if err != nil {
+				return err
+			}
+			if crossedThreshold(level, before) {
+				low = append(low, *level)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notifyLowStock(ctx, low)
+	return reservation, nil
+}
+
+// merge adds up the quantities wanted of each variant, keeping the order in
+// which variants were first listed.
+func merge(wanted []models.ReservationItem) []models.ReservationItem {
+	var merged []models.ReservationItem
+	index := map[int]int{}
+	for _, w := range wanted {
+		if i, ok := index[w.VariantID]; ok {
+			merged[i].Quantity += w.Quantity
+			continue
+		}
+		index[w.VariantID] = len(merged)
+		merged = append(merged, models.ReservationItem{VariantID: w.VariantID, Quantity: w.Quantity})
+	}
+	return merged
+}
+
+// allocate takes the wanted quantity from the variant's levels, most
+// available first.
+func allocate(levels []*models.StockLevel, wanted models.ReservationItem) ([]models.ReservationItem, error) {
+	var candidates []*models.StockLevel
+	available := 0
+	for _, level := range levels {
+		if level.VariantID == wanted.VariantID && level.Available > 0 {
+			candidates = append(candidates, level)
+			available += level.Available
+		}
+	}
+	if available < wanted.Quantity {
+		return nil, apperror.Conflict("insufficient_stock",
+			fmt.Sprintf("Only %d of variant %d are available", available, wanted.VariantID))
+	}
+	slices.SortStableFunc(candidates, func(a, b *models.StockLevel) int {
+		return cmp.Compare(b.Available, a.Available)
+	})
+
+	var items []models.ReservationItem
+	remaining := wanted.Quantity
+	for _, level := range candidates {
+		if remaining == 0 {
+			break
+		}
+		n := min(remaining, level.Available)
+		items = append(items, models.ReservationItem{VariantID: level.VariantID, WarehouseID: level.WarehouseID, Quantity: n})
+		remaining -= n
+	}
+	return items, nil
+}
+
+// Release ends an active reservation and makes its stock available again.
+func (s *Service) Release(ctx context.Context, reservationID, actorID int) (*models.Reservation, error) {
+	return s.end(ctx, reservationID, models.ReservationReleased, &actorID)
+}
+
+// Commit sells the reserved stock when the order is confirmed: it leaves
+// the warehouses for good. The reservation must not have expired.
+func (s *Service) Commit(ctx context.Context, reservationID int) (*models.Reservation, error) {
+	return s.end(ctx, reservationID, models.ReservationCommitted, nil)
+}
+
+// end moves an active reservation to status and updates its stock
+// accordingly.
+func (s *Service) end(ctx context.Context, reservationID int, status models.ReservationStatus, actorID *int) (*models.Reservation, error) {
+	reason := map[models.ReservationStatus]models.AdjustmentReason{
+		models.ReservationReleased:  models.AdjustmentReleased,
+		models.ReservationExpired:   models.AdjustmentExpired,
+		models.ReservationCommitted: models.AdjustmentSold,
+	}[status]
+
+	var reservation *models.Reservation
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		reservation, err = s.stock.LockReservation(ctx, reservationID)
+		if err != nil {
+			return err
+		}
+		if reservation.Status != models.ReservationActive {
+			return ErrReservationNotActive
+		}
+		if status == models.ReservationCommitted && !s.now().Before(reservation.ExpiresAt) {
+			return ErrReservationExpired
+		}
+
+		variantIDs := make([]int, len(reservation.Items))
+		for i, item := range reservation.Items {
+			variantIDs[i] = item.VariantID
+		}
+		levels, err := s.stock.LockStock(ctx, variantIDs)
+		if err != nil {
+			return err
+		}
+		for _, item := range reservation.Items {
+			adjustment := &models.StockAdjustment{
+				Reason:         reason,
+				ReservedChange: -item.Quantity,
+				ReservationID:  &reservation.ID,
+				ActorID:        actorID,
+			}
+			if status == models.ReservationCommitted {
+				adjustment.OnHandChange = -item.Quantity
+			}
+			level := find(levels, item)
+			if level == nil {
+				// No stock of the variant is held in the warehouse.
+				continue
+			}
+			if err := s.apply(ctx, level, adjustment); err != nil {
+				return err
+			}
+		}
+
+		reservation.Status = status
+		return s.stock.UpdateReservationStatus(ctx, reservation)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// Restock puts the stock sold through a committed reservation back on hand,
+// e.g. when a paid order is cancelled before it ships. If returned is not
+// nil only those quantities of its variants are restocked, e.g. for items
+// sent back, into the warehouses they were sold from.
+func (s *Service) Restock(ctx context.Context, reservationID int, returned []models.ReservationItem, actorID *int, note string) error {
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		reservation, err := s.stock.LockReservation(ctx, reservationID)
+		if err != nil {
+			return err
+		}
+		if reservation.Status != models.ReservationCommitted {
+			return errReservationNotSold
+		}
+
+		variantIDs := make([]int, len(reservation.Items))
+		for i, item := range reservation.Items {
+			variantIDs[i] = item.VariantID
+		}
+		levels, err := s.stock.LockStock(ctx, variantIDs)
+		if err != nil {
+			return err
+		}
+		var left map[int]int
+		if returned != nil {
+			left = make(map[int]int)
+			for _, item := range merge(returned) {
+				left[item.VariantID] = item.Quantity
+			}
+		}
+		for _, item := range reservation.Items {
+			quantity := item.Quantity
+			if left != nil {
+				quantity = min(quantity, left[item.VariantID])
+				left[item.VariantID] -= quantity
+			}
+			level := find(levels, item)
+			if level == nil || quantity == 0 {
+				continue
+			}
+			err := s.apply(ctx, level, &models.StockAdjustment{
+				Reason:        models.AdjustmentReturned,
+				OnHandChange:  quantity,
+				ReservationID: &reservation.ID,
+				ActorID:       actorID,
+				Note:          note,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Adjust changes the units of a variant on hand in a warehouse, e.g. when
+// stock is received or found damaged. The adjustment's Reason, OnHandChange
+// and Note come from the seller.
+func (s *Service) Adjust(ctx context.Context, actorID, variantID, warehouseID int, adjustment *models.StockAdjustment) error {
+	var low []models.StockLevel
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		low = nil
+		level, err := s.lockLevel(ctx, variantID, warehouseID)
+		if err != nil {
+			return err
+		}
+		if level.OnHand+adjustment.OnHandChange < level.Reserved {
+			return errStockReserved
+		}
+		before := level.Available
+		adjustment.ReservedChange = 0
+		adjustment.ReservationID = nil
+		adjustment.ActorID = &actorID
+		if err := s.apply(ctx, level, adjustment); err != nil {
+			return err
+		}
+		if crossedThreshold(level, before) {
+			low = append(low, *level)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.notifyLowStock(ctx, low)
+	return nil
+}
+
+// SetThreshold sets the level below which the seller is told that the
+// variant is running low in the warehouse. It isn't a change to the stock,
+// so the ledger doesn't record it.
+func (s *Service) SetThreshold(ctx context.Context, variantID, warehouseID, threshold int) (*models.StockLevel, error) {
+	var level *models.StockLevel
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		level, err = s.lockLevel(ctx, variantID, warehouseID)
+		if err != nil {
+			return err
+		}
+		level.LowStockThreshold = threshold
+		return s.stock.UpdateStock(ctx, level)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return level, nil
+}
+
+// lockLevel locks a variant's stock in a warehouse, creating it if the
+// variant has never been stocked there.
+func (s *Service) lockLevel(ctx context.Context, variantID, warehouseID int) (*models.StockLevel, error) {
+	if err := s.stock.CreateStock(ctx, variantID, warehouseID); err != nil {
+		return nil, err
+	}
+	levels, err := s.stock.LockStock(ctx, []int{variantID})
+	if err != nil {
+		return nil, err
+	}
+	level := find(levels, models.ReservationItem{VariantID: variantID, WarehouseID: warehouseID})
+	if level == nil {
+		return nil, fmt.Errorf("stock of variant %d in warehouse %d vanished", variantID, warehouseID)
+	}
+	return level, nil
+}
+
+// apply makes the adjustment's changes to a locked level and records them
+// in the ledger.
+func (s *Service) apply(ctx context.Context, level *models.StockLevel, adjustment *models.StockAdjustment) error {
+	level.OnHand += adjustment.OnHandChange
+	level.Reserved += adjustment.ReservedChange
+	if err := s.stock.UpdateStock(ctx, level); err != nil {
+		return err
+	}
+	adjustment.VariantID, adjustment.WarehouseID = level.VariantID, level.WarehouseID
+	adjustment.OnHand, adjustment.Reserved = level.OnHand, level.Reserved
+	return s.stock.CreateAdjustment(ctx, adjustment)
+}
+
+// checkForSale reports an error unless the variant belongs to a published
+// product.
+func (s *Service) checkForSale(ctx context.Context, variantID int) error {
+	variant, err := s.products.GetVariantByID(ctx, variantID)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return errVariantNotForSale
+	}
+	if err != nil {
+		return err
+	}
+	product, err := s.products.GetProductByID(ctx, variant.ProductID)
+	if err != nil {
+		return err
+	}
+	if product.Status != models.ProductPublished {
+		return errVariantNotForSale
+	}
+	return nil
+}
+
+func find(levels []*models.StockLevel, item models.ReservationItem) *models.StockLevel {
+	for _, level := range levels {
+		if level.VariantID == item.VariantID && level.WarehouseID == item.WarehouseID {
+			return level
+		}
+	}
+	return nil
+}
+
+// crossedThreshold reports whether the level's available stock has just
+// dropped below its threshold from before.
+func crossedThreshold(level *models.StockLevel, before int) bool {
+	return level.LowStockThreshold > 0 && before >= level.LowStockThreshold && level.Available < level.LowStockThreshold
+}
+
+func (s *Service) Name() string { return "reservation-expiry" }
+
+// Run releases expired reservations until ctx is done. It implements
+// worker.Worker.
+func (s *Service) Run(ctx context.Context) error {
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for {
+		s.expire(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) expire(ctx context.Context) {
+	reservations, err := s.stock.ListExpiredReservations(ctx, s.now(), expiryBatch)
+	if err != nil {
+		slog.Error("list expired reservations", slog.Any("error", err))
+		return
+	}
+	for _, reservation := range reservations {
+		_, err := s.end(ctx, reservation.ID, models.ReservationExpired, nil)
+		// The order may have been confirmed in the meantime.
+		if err != nil && !errors.Is(err, ErrReservationNotActive) {
+			slog.Error("expire reservation", slog.Int("reservation_id", reservation.ID), slog.Any("error", err))
+		}
+	}
+}
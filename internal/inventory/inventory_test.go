@@ -0,0 +1,244 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/mailer"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+	"github.com/sudhir512kj/ecommerce_backend/internal/testutil"
+)
+
+// fixture is a seller with one variant stocked in two warehouses, and the
+// service to change its stock.
+type fixture struct {
+	s          *Service
+	stock      repository.InventoryRepository
+	sellerID   int
+	variantID  int
+	warehouses [2]int
+}
+
+func newFixture(t *testing.T, onHand [2]int) *fixture {
+	t.Helper()
+	c := testutil.Catalog{
+		Users:     repository.NewMemoryUserRepository(),
+		Products:  repository.NewMemoryProductRepository(),
+		Inventory: repository.NewMemoryInventoryRepository(),
+	}
+	f := &fixture{stock: c.Inventory}
+	f.s = NewService(testutil.Config(t), c.Inventory, c.Products, c.Users, database.NopTransactor{}, mailer.NewMemory())
+	f.sellerID = c.User(t, "seller@example.com", models.PermissionSeller).ID
+	f.variantID = c.Variant(t, f.sellerID, "LAMP-1", 1000, "USD").ID
+	for i, code := range []string{"W-1", "W-2"} {
+		f.warehouses[i] = c.Warehouse(t, f.sellerID, code).ID
+		c.Stock(t, f.variantID, f.warehouses[i], onHand[i])
+	}
+	return f
+}
+
+// levels returns the variant's stock in each warehouse.
+func (f *fixture) levels(t *testing.T) [2]models.StockLevel {
+	t.Helper()
+	levels, err := f.stock.ListStock(context.Background(), []int{f.variantID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [2]models.StockLevel
+	for _, level := range levels {
+		got[slices.Index(f.warehouses[:], level.WarehouseID)] = *level
+	}
+	return got
+}
+
+func TestAllocate(t *testing.T) {
+	levels := []*models.StockLevel{
+		{VariantID: 1, WarehouseID: 1, Available: 2},
+		{VariantID: 1, WarehouseID: 2, Available: 5},
+		{VariantID: 1, WarehouseID: 3, Available: 0},
+		{VariantID: 2, WarehouseID: 1, Available: 9},
+	}
+	tests := []struct {
+		name    string
+		wanted  int
+		want    []models.ReservationItem
+		wantErr bool
+	}{
+		{
+			name:   "most available first",
+			wanted: 4,
+			want:   []models.ReservationItem{{VariantID: 1, WarehouseID: 2, Quantity: 4}},
+		},
+		{
+			name:   "spread over warehouses",
+			wanted: 7,
+			want: []models.ReservationItem{
+				{VariantID: 1, WarehouseID: 2, Quantity: 5},
+				{VariantID: 1, WarehouseID: 1, Quantity: 2},
+			},
+		},
+		{name: "more than available", wanted: 8, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := allocate(levels, models.ReservationItem{VariantID: 1, Quantity: tt.wanted})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("items = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	got := merge([]models.ReservationItem{
+		{VariantID: 2, Quantity: 1},
+		{VariantID: 1, WarehouseID: 7, Quantity: 2},
+		{VariantID: 2, Quantity: 3},
+	})
+	want := []models.ReservationItem{{VariantID: 2, Quantity: 4}, {VariantID: 1, Quantity: 2}}
+	if !slices.Equal(got, want) {
+		t.Errorf("merge = %v, want %v", got, want)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    int
+		wantErr string
+		// held is what is left reserved in each warehouse.
+		held [2]int
+	}{
+		{name: "one warehouse", want: 4, held: [2]int{4, 0}},
+		{name: "both warehouses", want: 7, held: [2]int{5, 2}},
+		{name: "everything", want: 8, held: [2]int{5, 3}},
+		{name: "too many", want: 9, wantErr: "insufficient_stock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, [2]int{5, 3})
+			_, err := f.s.Reserve(context.Background(), 99, []models.ReservationItem{{VariantID: f.variantID, Quantity: tt.want}})
+			if testutil.Code(err) != tt.wantErr {
+				t.Fatalf("err = %v, want %s", err, tt.wantErr)
+			}
+			levels := f.levels(t)
+			for i, level := range levels {
+				if level.Reserved != tt.held[i] || level.OnHand != [2]int{5, 3}[i] {
+					t.Errorf("warehouse %d: on hand %d, reserved %d; want 5/3 on hand, %d reserved", i+1, level.OnHand, level.Reserved, tt.held[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReserveUnknownVariant(t *testing.T) {
+	f := newFixture(t, [2]int{5, 0})
+	_, err := f.s.Reserve(context.Background(), 99, []models.ReservationItem{{VariantID: f.variantID + 100, Quantity: 1}})
+	if !errors.Is(err, errVariantNotForSale) {
+		t.Errorf("err = %v, want %v", err, errVariantNotForSale)
+	}
+}
+
+func TestEndReservation(t *testing.T) {
+	tests := []struct {
+		name string
+		// later moves the clock on before the reservation ends.
+		later   time.Duration
+		end     func(s *Service, id int) error
+		wantErr error
+		// onHand and reserved are the stock of the warehouse afterwards.
+		onHand   int
+		reserved int
+	}{
+		{
+			name:   "released",
+			end:    func(s *Service, id int) error { _, err := s.Release(context.Background(), id, 99); return err },
+			onHand: 5,
+		},
+		{
+			name:   "committed",
+			end:    func(s *Service, id int) error { _, err := s.Commit(context.Background(), id); return err },
+			onHand: 3,
+		},
+		{
+			name:     "committed after expiry",
+			later:    time.Hour,
+			end:      func(s *Service, id int) error { _, err := s.Commit(context.Background(), id); return err },
+			wantErr:  ErrReservationExpired,
+			onHand:   5,
+			reserved: 2,
+		},
+		{
+			name: "released twice",
+			end: func(s *Service, id int) error {
+				if _, err := s.Release(context.Background(), id, 99); err != nil {
+					return err
+				}
+				_, err := s.Release(context.Background(), id, 99)
+				return err
+			},
+			wantErr: ErrReservationNotActive,
+			onHand:  5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, [2]int{5, 0})
+			reservation, err := f.s.Reserve(context.Background(), 99, []models.ReservationItem{{VariantID: f.variantID, Quantity: 2}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Now().Add(tt.later)
+			f.s.now = func() time.Time { return now }
+			if err := tt.end(f.s, reservation.ID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			level := f.levels(t)[0]
+			if level.OnHand != tt.onHand || level.Reserved != tt.reserved {
+				t.Errorf("on hand %d, reserved %d; want %d, %d", level.OnHand, level.Reserved, tt.onHand, tt.reserved)
+			}
+		})
+	}
+}
+
+func TestRestock(t *testing.T) {
+	tests := []struct {
+		name     string
+		returned []models.ReservationItem
+		onHand   int
+	}{
+		{name: "whole order", onHand: 5},
+		{name: "some items", returned: []models.ReservationItem{{Quantity: 1}}, onHand: 3},
+		{name: "nothing of the variant", returned: []models.ReservationItem{}, onHand: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t, [2]int{5, 0})
+			reservation, err := f.s.Reserve(ctx, 99, []models.ReservationItem{{VariantID: f.variantID, Quantity: 3}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.s.Commit(ctx, reservation.ID); err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.returned {
+				tt.returned[i].VariantID = f.variantID
+			}
+			if err := f.s.Restock(ctx, reservation.ID, tt.returned, &f.sellerID, "returned"); err != nil {
+				t.Fatal(err)
+			}
+			if level := f.levels(t)[0]; level.OnHand != tt.onHand {
+				t.Errorf("on hand = %d, want %d", level.OnHand, tt.onHand)
+			}
+		})
+	}
+}
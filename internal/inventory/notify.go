@@ -0,0 +1,52 @@
+package inventory
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/mailer"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+// notifyLowStock emails the sellers of stock that dropped below its
+// threshold. It runs once the change is committed; failures are only
+// logged since the change stands either way.
+func (s *Service) notifyLowStock(ctx context.Context, levels []models.StockLevel) {
+	for _, level := range levels {
+		if err := s.sendLowStock(ctx, level); err != nil {
+			slog.Warn("send low stock notification",
+				slog.Int("variant_id", level.VariantID), slog.Int("warehouse_id", level.WarehouseID), slog.Any("error", err))
+		}
+	}
+}
+
+func (s *Service) sendLowStock(ctx context.Context, level models.StockLevel) error {
+	variant, err := s.products.GetVariantByID(ctx, level.VariantID)
+	if err != nil {
+		return err
+	}
+	product, err := s.products.GetProductByID(ctx, variant.ProductID)
+	if err != nil {
+		return err
+	}
+	seller, err := s.users.GetUserByID(ctx, product.SellerID)
+	if err != nil {
+		return err
+	}
+	warehouse, err := s.stock.GetWarehouseByID(ctx, level.WarehouseID)
+	if err != nil {
+		return err
+	}
+
+	msg, err := mailer.Render(s.conf.Current(), "low_stock", []string{seller.Email}, map[string]any{
+		"SKU":         variant.SKU,
+		"ProductName": product.Name,
+		"Warehouse":   warehouse.Name,
+		"Available":   level.Available,
+		"Threshold":   level.LowStockThreshold,
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, msg)
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestMaskEmails(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "no address here", want: "no address here"},
+		{in: "jane.doe@example.com", want: "j***@example.com"},
+		{in: "sent to a@b.co and bob+shop@mail.example.org", want: "sent to a***@b.co and b***@mail.example.org"},
+		{in: "user@localhost", want: "user@localhost"},
+	}
+	for _, tt := range tests {
+		if got := MaskEmails(tt.in); got != tt.want {
+			t.Errorf("MaskEmails(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		attr slog.Attr
+		want any
+	}{
+		{name: "password", attr: slog.String("password", "hunter2"), want: redacted},
+		{name: "key in capitals", attr: slog.String("Authorization", "Bearer abc"), want: redacted},
+		{name: "sensitive number", attr: slog.Int("otp", 123456), want: redacted},
+		{name: "email", attr: slog.String("email", "jane@example.com"), want: "j***@example.com"},
+		{name: "email in an error", attr: slog.Any("error", errors.New("no user jane@example.com")), want: "no user j***@example.com"},
+		{name: "other", attr: slog.Int("count", 3), want: 3.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			New(&buf, slog.LevelInfo).LogAttrs(context.Background(), slog.LevelInfo, "msg", tt.attr)
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatal(err)
+			}
+			if got := record[tt.attr.Key]; got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.attr.Key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAddsContext(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := WithUserID(WithRequestID(context.Background(), "req-1"), 42)
+	New(&buf, slog.LevelInfo).With("component", "test").InfoContext(ctx, "msg")
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["request_id"] != "req-1" || record["user_id"] != 42.0 {
+		t.Errorf("request_id = %v, user_id = %v, want req-1 and 42", record["request_id"], record["user_id"])
+	}
+}
@@ -0,0 +1,134 @@
+// Package mailer sends transactional email.
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+)
+
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+	// Template names the email template the message was rendered from.
+	Template string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type smtpMailer struct {
+	conf *config.Email
+}
+
+// NewSMTPMailer returns a Mailer that delivers through the SMTP server in
+// conf. It also implements health.Checker.
+func NewSMTPMailer(conf *config.Email) Mailer {
+	return &smtpMailer{conf: conf}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if m.conf.Password != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			auth := smtp.PlainAuth("", m.conf.Username, m.conf.Password, m.conf.Host)
+			if err := client.Auth(auth); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := client.Mail(m.conf.From); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	body := "From: " + m.conf.From + "\r\n" +
+		"To: " + strings.Join(msg.To, ",") + "\r\n" +
+		"Subject: " + msg.Subject + "\r\n\r\n" +
+		msg.Body
+	if _, err := w.Write([]byte(body)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (m *smtpMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.conf.Host, m.conf.Port))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.conf.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func (m *smtpMailer) Name() string { return "mail" }
+
+// Check verifies that the SMTP server accepts connections and answers NOOP.
+func (m *smtpMailer) Check(ctx context.Context) error {
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Noop(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Memory is an in-memory Mailer that records every message it is given.
+type Memory struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Send(_ context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Messages returns a copy of the messages sent so far.
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.sent...)
+}
@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+)
+
+// Render builds a message from the named entry of conf.EmailTemplates,
+// executing its subject and body with data.
+func Render(conf *config.Config, name string, to []string, data any) (Message, error) {
+	tmpl, ok := conf.EmailTemplates[name]
+	if !ok || tmpl == nil {
+		return Message{}, fmt.Errorf("email template %q is not configured", name)
+	}
+
+	subject, err := execute(name+".subject", tmpl.Subject, data)
+	if err != nil {
+		return Message{}, err
+	}
+	body, err := execute(name+".body", tmpl.Body, data)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: subject, Body: body, Template: name}, nil
+}
+
+func execute(name, text string, data any) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
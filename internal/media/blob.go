@@ -0,0 +1,81 @@
+// Package media stores uploaded files, serves them through signed,
+// expiring URLs and resizes product images in the background.
+package media
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+)
+
+// ErrBlobNotFound is returned by Get for keys without a blob.
+var ErrBlobNotFound = apperror.NotFound("blob_not_found", "file not found")
+
+var errInvalidKey = apperror.Validation("invalid_key", "invalid file key")
+
+// BlobStore keeps files under slash-separated keys such as
+// products/12/3f9c.jpg.
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any blob
+	// already there.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the blob at key. The caller must close it.
+	Get(ctx context.Context, key string) (*Blob, error)
+	// Delete removes the blob at key. Missing blobs are not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Blob is an open stored file.
+type Blob struct {
+	io.ReadCloser
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// NewBlobStore returns the store selected by conf.
+func NewBlobStore(conf *config.Media) (BlobStore, error) {
+	switch conf.Store {
+	case "local":
+		return NewLocalStore(conf.Dir)
+	case "s3":
+		return NewS3Store(conf.S3)
+	}
+	return nil, fmt.Errorf("unknown media store %q", conf.Store)
+}
+
+// NewKey returns an unguessable key under prefix with the extension ext,
+// e.g. NewKey("products/12", ".jpg") gives products/12/3f9c….jpg.
+func NewKey(prefix, ext string) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return prefix + "/" + hex.EncodeToString(b) + ext
+}
+
+// checkKey rejects keys that could escape the store's root, such as
+// ../secrets or /etc/passwd.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return errInvalidKey
+	}
+	return nil
+}
+
+// contentTypeOf guesses a blob's type from its key's extension.
+func contentTypeOf(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,109 @@
+package media
+
+import (
+	"image"
+	"image/color"
+	_ "image/gif" // registers the GIF decoder with image.Decode
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+)
+
+// maxPixels bounds the images accepted, so a small file can't expand to
+// gigabytes once decoded.
+const maxPixels = 40_000_000
+
+var (
+	ErrUnsupportedImage = apperror.Unsupported("unsupported_media_type", "Only JPEG, PNG and GIF images are accepted")
+	errInvalidImage     = apperror.Validation("invalid_image", "The image could not be read")
+	errImageTooLarge    = apperror.TooLarge("image_too_large", "The image has too many pixels")
+)
+
+// imageTypes lists the accepted content types and the extension their
+// blobs get.
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// ImageInfo describes an uploaded image.
+type ImageInfo struct {
+	ContentType string
+	// Ext is the extension for the image's key, e.g. ".jpg".
+	Ext    string
+	Width  int
+	Height int
+}
+
+// Inspect identifies the image in r from its contents, not its file name or
+// declared type, and reads its dimensions. r is left at the start.
+func Inspect(r io.ReadSeeker) (*ImageInfo, error) {
+	mt, err := mimetype.DetectReader(r)
+	if err != nil {
+		return nil, err
+	}
+	ext, ok := imageTypes[mt.String()]
+	if !ok {
+		return nil, ErrUnsupportedImage
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, errInvalidImage.Wrap(err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, errImageTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &ImageInfo{ContentType: mt.String(), Ext: ext, Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+// encode writes img as a JPEG, or as a PNG if it has transparent pixels,
+// and returns the content type and extension used.
+func encode(w io.Writer, img image.Image) (string, string, error) {
+	if o, ok := img.(interface{ Opaque() bool }); ok && !o.Opaque() {
+		return "image/png", ".png", png.Encode(w, img)
+	}
+	return "image/jpeg", ".jpg", jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+}
+
+// resize scales src down to fit within size×size, keeping its aspect
+// ratio. Each output pixel averages the source pixels it covers. Images
+// that already fit keep their size.
+func resize(src image.Image, size int) *image.NRGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	switch {
+	case w <= size && h <= size:
+	case w >= h:
+		dw, dh = size, max(1, h*size/w)
+	default:
+		dw, dh = max(1, w*size/h), size
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+max((x+1)*w/dw, x*w/dw+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
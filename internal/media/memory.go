@@ -0,0 +1,64 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+type memoryBlob struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+// memoryStore is an in-memory BlobStore for tests and local runs.
+type memoryStore struct {
+	mu    sync.Mutex
+	blobs map[string]memoryBlob
+}
+
+func NewMemoryStore() BlobStore {
+	return &memoryStore{blobs: make(map[string]memoryBlob)}
+}
+
+func (s *memoryStore) Put(_ context.Context, key string, r io.Reader, _ int64, contentType string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = memoryBlob{data: data, contentType: contentType, modTime: time.Now()}
+	return nil
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) (*Blob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.blobs[key]
+	if !ok {
+		return nil, ErrBlobNotFound
+	}
+	return &Blob{
+		ReadCloser:  io.NopCloser(bytes.NewReader(b.data)),
+		ContentType: b.contentType,
+		Size:        int64(len(b.data)),
+		ModTime:     b.modTime,
+	}, nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.blobs, key)
+	return nil
+}
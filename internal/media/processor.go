@@ -0,0 +1,162 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+)
+
+// Renditions are the resized copies made of every product image, by name,
+// with the size of the square each must fit in.
+var Renditions = map[string]int{
+	"thumbnail": 200,
+	"medium":    800,
+}
+
+const (
+	queueSize = 256
+	// sweepInterval is how often images missed by the queue, e.g. after a
+	// restart or on another instance, are picked up.
+	sweepInterval = time.Minute
+	sweepBatch    = 20
+)
+
+// Processor is a background worker that makes the renditions of uploaded
+// images and marks them ready, or failed if they can't be decoded.
+type Processor struct {
+	images repository.ProductRepository
+	blobs  BlobStore
+	queue  chan int
+}
+
+func NewProcessor(images repository.ProductRepository, blobs BlobStore) *Processor {
+	return &Processor{images: images, blobs: blobs, queue: make(chan int, queueSize)}
+}
+
+func (p *Processor) Name() string { return "media" }
+
+// Enqueue asks for the image to be processed soon. It never blocks: when
+// the queue is full the image waits for the next sweep instead.
+func (p *Processor) Enqueue(imageID int) {
+	select {
+	case p.queue <- imageID:
+	default:
+	}
+}
+
+// Run processes queued images one at a time until ctx is cancelled.
+func (p *Processor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	p.sweep(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case id := <-p.queue:
+			p.process(ctx, id)
+		case <-ticker.C:
+			p.sweep(ctx)
+		}
+	}
+}
+
+func (p *Processor) sweep(ctx context.Context) {
+	images, err := p.images.ListPendingImages(ctx, sweepBatch)
+	if err != nil {
+		slog.Error("list pending images", slog.Any("error", err))
+		return
+	}
+	for _, image := range images {
+		p.process(ctx, image.ID)
+	}
+}
+
+// process makes the image's renditions. Images already processed, or
+// deleted since they were queued, are skipped.
+func (p *Processor) process(ctx context.Context, id int) {
+	img, err := p.images.GetImageByID(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		slog.Error("load image", slog.Int("image_id", id), slog.Any("error", err))
+		return
+	}
+	if img.Status != models.ImageProcessing {
+		return
+	}
+
+	img.Renditions, err = p.render(ctx, img.Key)
+	img.Status = models.ImageReady
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("process image", slog.Int("image_id", id), slog.Any("error", err))
+		img.Status = models.ImageFailed
+	}
+	err = p.images.UpdateImage(ctx, img)
+	if errors.Is(err, apperror.ErrNotFound) {
+		// Deleted while processing; its renditions would be orphaned.
+		DeleteImageBlobs(ctx, p.blobs, img)
+		return
+	}
+	if err != nil {
+		slog.Error("save image", slog.Int("image_id", id), slog.Any("error", err))
+	}
+}
+
+// DeleteImageBlobs removes an image's file and renditions. Failures are
+// only logged: the image is already gone and the files are unreachable.
+func DeleteImageBlobs(ctx context.Context, blobs BlobStore, img *models.ProductImage) {
+	keys := []string{img.Key}
+	for _, key := range img.Renditions {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		if err := blobs.Delete(ctx, key); err != nil {
+			slog.Warn("delete blob", slog.String("key", key), slog.Any("error", err))
+		}
+	}
+}
+
+// render stores every rendition of the image at key next to it, e.g.
+// products/12/3f9c_thumbnail.jpg, and returns their keys.
+func (p *Processor) render(ctx context.Context, key string) (map[string]string, error) {
+	blob, err := p.blobs.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(blob)
+	blob.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimSuffix(key, path.Ext(key))
+	keys := make(map[string]string, len(Renditions))
+	for name, size := range Renditions {
+		var buf bytes.Buffer
+		contentType, ext, err := encode(&buf, resize(src, size))
+		if err != nil {
+			return nil, err
+		}
+		renditionKey := base + "_" + name + ext
+		if err := p.blobs.Put(ctx, renditionKey, &buf, int64(buf.Len()), contentType); err != nil {
+			return nil, err
+		}
+		keys[name] = renditionKey
+	}
+	return keys, nil
+}
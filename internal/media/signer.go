@@ -0,0 +1,64 @@
+package media
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+)
+
+var (
+	errInvalidSignature = apperror.Forbidden("invalid_signature", "The link is invalid")
+	errLinkExpired      = apperror.Forbidden("link_expired", "The link has expired")
+)
+
+// Signer makes and checks the expiring URLs files are served from. Anyone
+// holding a URL can fetch the file until it expires, so URLs are only
+// handed to users who may see it.
+type Signer struct {
+	key    []byte
+	ttl    time.Duration
+	prefix string
+	now    func() time.Time
+}
+
+// NewSigner signs URLs for the route serving files at prefix, e.g.
+// /api/v2/media, which receives the key as the rest of the path.
+func NewSigner(conf *config.Media, prefix string) *Signer {
+	return &Signer{key: []byte(conf.SigningKey), ttl: conf.URLTTL, prefix: prefix, now: time.Now}
+}
+
+// URL returns a URL for key that is valid for at least the TTL. The expiry
+// is rounded up to a multiple of the TTL, so URLs signed in the same window
+// are identical and browsers can cache the file.
+func (s *Signer) URL(key string) string {
+	expires := s.now().Add(s.ttl).Truncate(s.ttl).Add(s.ttl).Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", s.signature(key, expires))
+	return s.prefix + "/" + key + "?" + q.Encode()
+}
+
+// Verify checks a URL's signature and expiry and returns how long it stays
+// valid.
+func (s *Signer) Verify(key string, expires int64, signature string) (time.Duration, error) {
+	if !hmac.Equal([]byte(signature), []byte(s.signature(key, expires))) {
+		return 0, errInvalidSignature
+	}
+	left := time.Unix(expires, 0).Sub(s.now())
+	if left <= 0 {
+		return 0, errLinkExpired
+	}
+	return left, nil
+}
+
+func (s *Signer) signature(key string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/mailer"
+)
+
+type instrumentedMailer struct {
+	next    mailer.Mailer
+	metrics *Metrics
+}
+
+// InstrumentMailer counts the successes and failures of every send.
+func InstrumentMailer(next mailer.Mailer, m *Metrics) mailer.Mailer {
+	return &instrumentedMailer{next: next, metrics: m}
+}
+
+func (i *instrumentedMailer) Send(ctx context.Context, msg mailer.Message) error {
+	err := i.next.Send(ctx, msg)
+	template := msg.Template
+	if template == "" {
+		template = "none"
+	}
+	i.metrics.EmailSent(template, err)
+	return err
+}
@@ -0,0 +1,139 @@
+// Package metrics defines the Prometheus metrics exposed at /metrics.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ecommerce"
+
+// Metrics owns a registry, so each App instance reports independently.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpDuration  *prometheus.HistogramVec
+	queryDuration *prometheus.HistogramVec
+	emails        *prometheus.CounterVec
+	otps          *prometheus.CounterVec
+	logins        *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route template, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Database statement latency by repository and statement name.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"repository", "statement", "outcome"}),
+		emails: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "emails_sent_total",
+			Help:      "Emails handed to the mail backend, by template and result.",
+		}, []string{"template", "result"}),
+		otps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "otp_events_total",
+			Help:      "One-time passwords issued, verified and rejected.",
+		}, []string{"event"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Login attempts by result.",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpDuration,
+		m.queryDuration,
+		m.emails,
+		m.otps,
+		m.logins,
+	)
+	return m
+}
+
+// Registerer lets other components add their own collectors.
+func (m *Metrics) Registerer() prometheus.Registerer {
+	return m.registry
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware records the latency of every request. Requests that match no
+// route are grouped under "unmatched" to keep label cardinality bounded.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.httpDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// QueryHook is a database.QueryHook that records statement durations. The
+// statements that begin and end transactions come under the repository
+// "tx". A query that finds no rows has answered, so it isn't an error.
+func (m *Metrics) QueryHook(ctx context.Context, repository, statement string) (context.Context, func(error)) {
+	start := time.Now()
+	return ctx, func(err error) {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+		m.queryDuration.
+			WithLabelValues(repository, statement, outcome(err)).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// EmailSent counts one send attempt of template.
+func (m *Metrics) EmailSent(template string, err error) {
+	m.emails.WithLabelValues(template, outcome(err)).Inc()
+}
+
+func (m *Metrics) OTPIssued()   { m.otps.WithLabelValues("issued").Inc() }
+func (m *Metrics) OTPVerified() { m.otps.WithLabelValues("verified").Inc() }
+func (m *Metrics) OTPFailed()   { m.otps.WithLabelValues("failed").Inc() }
+
+func (m *Metrics) LoginSucceeded() { m.logins.WithLabelValues("success").Inc() }
+func (m *Metrics) LoginFailed()    { m.logins.WithLabelValues("failure").Inc() }
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
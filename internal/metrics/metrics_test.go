@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestQueryHook(t *testing.T) {
+	m := New()
+	statements := []struct {
+		repository, statement string
+		err                   error
+	}{
+		{"user", "GetUserByEmail", nil},
+		{"user", "GetUserByEmail", sql.ErrNoRows},
+		{"tx", "Begin", nil},
+		{"tx", "Commit", errors.New("could not serialize access")},
+	}
+	for _, s := range statements {
+		_, done := m.QueryHook(context.Background(), s.repository, s.statement)
+		done(s.err)
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{
+		`ecommerce_db_query_duration_seconds_count{outcome="success",repository="user",statement="GetUserByEmail"} 2`,
+		`ecommerce_db_query_duration_seconds_count{outcome="success",repository="tx",statement="Begin"} 1`,
+		`ecommerce_db_query_duration_seconds_count{outcome="error",repository="tx",statement="Commit"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics are missing %s", want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+)
+
+// Problem is an RFC 7807 problem details body, extended with a stable code.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Errors lists the invalid fields of a validation_failed problem.
+	Errors []apperror.FieldError `json:"errors,omitempty"`
+}
+
+const problemContentType = "application/problem+json"
+
+// ErrorHandler renders the last error recorded with c.Error as
+// application/problem+json. Errors that are not *apperror.Error are treated
+// as internal and their message is logged instead of returned.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		WriteProblem(c, err)
+	}
+}
+
+// WriteProblem writes err as a problem+json response and aborts the chain.
+func WriteProblem(c *gin.Context, err error) {
+	status := apperror.HTTPStatus(err)
+	problem := Problem{
+		Status:   status,
+		Title:    http.StatusText(status),
+		Instance: c.Request.URL.Path,
+	}
+
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) && status != http.StatusInternalServerError {
+		problem.Code = appErr.Code
+		problem.Detail = appErr.Message
+		problem.Errors = appErr.Fields
+	} else {
+		slog.ErrorContext(c.Request.Context(), "internal error",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Any("error", err),
+		)
+		problem.Code = "internal_error"
+		problem.Detail = "An unexpected error occurred"
+	}
+	problem.Type = "/problems/" + problem.Code
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(status, problem)
+}
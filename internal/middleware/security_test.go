@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+)
+
+// newRouter serves every method at / behind ErrorHandler and mw. The handler
+// reads the whole body, as binding does, and reports a failed read the way
+// the handlers do.
+func newRouter(mw gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler(), mw)
+	r.Any("/", func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			_ = c.Error(apperror.InvalidRequest(err))
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers *config.SecurityHeaders
+		want    map[string]string
+	}{
+		{
+			name: "unconfigured",
+			want: map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"Referrer-Policy":           "no-referrer",
+				"Strict-Transport-Security": "",
+				"X-Frame-Options":           "",
+			},
+		},
+		{
+			name: "configured",
+			headers: &config.SecurityHeaders{
+				HSTSMaxAge:            24 * time.Hour,
+				HSTSIncludeSubdomains: true,
+				ContentSecurityPolicy: "default-src 'none'",
+				FrameOptions:          "DENY",
+			},
+			want: map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"Strict-Transport-Security": "max-age=86400; includeSubDomains",
+				"Content-Security-Policy":   "default-src 'none'",
+				"X-Frame-Options":           "DENY",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &config.Config{Security: &config.Security{Headers: tt.headers}}
+			rec := httptest.NewRecorder()
+			newRouter(SecurityHeaders(config.Static(conf))).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			for header, want := range tt.want {
+				if got := rec.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	conf := &config.Config{Security: &config.Security{CORS: &config.CORS{
+		AllowedOrigins:   []string{"https://shop.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}}}
+	r := newRouter(CORS(config.Static(conf)))
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		preflight  bool
+		wantStatus int
+		want       map[string]string
+	}{
+		{
+			name:       "same origin",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			want:       map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""},
+		},
+		{
+			name:       "allowed origin",
+			method:     http.MethodGet,
+			origin:     "https://shop.example.com",
+			wantStatus: http.StatusOK,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "https://shop.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-ID",
+				"Vary":                             "Origin",
+			},
+		},
+		{
+			name:       "other origin",
+			method:     http.MethodGet,
+			origin:     "https://evil.example.com",
+			wantStatus: http.StatusOK,
+			want:       map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"},
+		},
+		{
+			name:       "preflight",
+			method:     http.MethodOptions,
+			origin:     "https://shop.example.com",
+			preflight:  true,
+			wantStatus: http.StatusNoContent,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "https://shop.example.com",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Content-Type",
+				"Access-Control-Max-Age":       "3600",
+			},
+		},
+		{
+			name:       "preflight from another origin",
+			method:     http.MethodOptions,
+			origin:     "https://evil.example.com",
+			preflight:  true,
+			wantStatus: http.StatusNoContent,
+			want:       map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			for header, want := range tt.want {
+				if got := rec.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	conf := &config.Config{Security: &config.Security{MaxBodyBytes: 10, MaxUploadBytes: 20}}
+	r := newRouter(BodyLimit(config.Static(conf)))
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		// chunked hides the length, so only reading the body can catch it.
+		chunked bool
+		want    int
+	}{
+		{name: "within the limit", contentType: "application/json", body: "0123456789", want: http.StatusOK},
+		{name: "declared too large", contentType: "application/json", body: "0123456789a", want: http.StatusRequestEntityTooLarge},
+		{name: "read too large", contentType: "application/json", body: "0123456789a", chunked: true, want: http.StatusRequestEntityTooLarge},
+		{name: "upload within its limit", contentType: "multipart/form-data; boundary=x", body: "0123456789a", want: http.StatusOK},
+		{name: "upload too large", contentType: "multipart/form-data; boundary=x", body: strings.Repeat("0", 21), want: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	conf := &config.Config{Security: &config.Security{CSRF: &config.CSRF{
+		Enabled: true, CookieName: "csrf_token", HeaderName: "X-CSRF-Token",
+	}}}
+	r := newRouter(CSRF(config.Static(conf)))
+	tests := []struct {
+		name       string
+		method     string
+		cookie     string
+		header     string
+		want       int
+		wantCookie bool
+	}{
+		{name: "safe method gets a token", method: http.MethodGet, want: http.StatusOK, wantCookie: true},
+		{name: "no cookies", method: http.MethodPost, want: http.StatusOK, wantCookie: true},
+		{name: "matching header", method: http.MethodPost, cookie: "token", header: "token", want: http.StatusOK},
+		{name: "missing header", method: http.MethodPost, cookie: "token", want: http.StatusForbidden},
+		{name: "wrong header", method: http.MethodDelete, cookie: "token", header: "other", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set("X-CSRF-Token", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := strings.HasPrefix(rec.Header().Get("Set-Cookie"), "csrf_token="); got != tt.wantCookie {
+				t.Errorf("Set-Cookie = %q", rec.Header().Get("Set-Cookie"))
+			}
+		})
+	}
+}
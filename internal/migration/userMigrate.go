@@ -1,82 +1,51 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/google/uuid"
-	_ "github.com/lib/pq"
 	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/logging"
 	"github.com/sudhir512kj/ecommerce_backend/internal/models"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type postgresDatabase struct {
-	Db *sql.DB
-}
-
-var once sync.Once
-var conf *config.Config
-
-var db *postgresDatabase
-
 func main() {
+	slog.SetDefault(logging.New(os.Stdout, slog.LevelInfo))
 
-	once.Do(func() {
-		dsn := fmt.Sprintf(
-			"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=%s",
-			conf.Db.Host,
-			conf.Db.User,
-			conf.Db.Password,
-			conf.Db.DBName,
-			conf.Db.Port,
-			conf.Db.SSLMode,
-			conf.Db.TimeZone,
-		)
-
-		dbI, err := sql.Open("postgres", dsn)
-		if err != nil {
-			panic("failed to connect database")
-		}
-
-		db = &postgresDatabase{Db: dbI}
-	})
-	defer db.Db.Close()
+	conf, err := config.Load(config.Options{})
+	if err != nil {
+		fatal("Error loading configuration", err)
+	}
+	db, err := database.NewPostgresDatabase(conf)
+	if err != nil {
+		fatal("Error connecting to database", err)
+	}
+	defer db.Close()
 
-	// Create the users table
-	createUsersTable(db.Db)
+	// Apply the versioned schema migrations
+	if err := database.Migrate(context.Background(), db.GetDb()); err != nil {
+		fatal("Error applying migrations", err)
+	}
 
 	// Insert 10 demo users
-	insertDemoUsers(db.Db)
+	insertDemoUsers(db.GetDb())
 
 	// Insert demo addresses for some users
-	insertDemoAddresses(db.Db)
+	insertDemoAddresses(db.GetDb())
 
-	fmt.Println("User migration completed successfully.")
+	slog.Info("User migration completed successfully.")
 }
 
-func createUsersTable(db *sql.DB) {
-	query := `
-        CREATE TABLE IF NOT EXISTS users (
-            id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-            email TEXT NOT NULL UNIQUE,
-            password TEXT NOT NULL,
-            first_name TEXT NOT NULL,
-            last_name TEXT NOT NULL,
-            permissions TEXT[] DEFAULT '{buyer}',
-            created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
-            updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
-        );
-    `
-
-	_, err := db.Exec(query)
-	if err != nil {
-		log.Fatalf("Error creating users table: %v", err)
-	}
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
 
 func insertDemoUsers(db *sql.DB) {
@@ -88,7 +57,7 @@ func insertDemoUsers(db *sql.DB) {
 
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 		if err != nil {
-			log.Fatalf("Error hashing password: %v", err)
+			fatal("Error hashing password", err)
 		}
 
 		permissions := []models.Permission{models.PermissionBuyer}
@@ -100,11 +69,11 @@ func insertDemoUsers(db *sql.DB) {
 		}
 
 		_, err = db.Exec(
-			"INSERT INTO users (id, email, password, first_name, last_name, permissions, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
-			uuid.New(), email, string(hashedPassword), firstName, lastName, fmt.Sprintf("{%s}", formatPermissions(permissions)), time.Now(), time.Now(),
+			"INSERT INTO users (email, password, first_name, last_name, permissions, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (email) DO NOTHING",
+			email, string(hashedPassword), firstName, lastName, fmt.Sprintf("{%s}", formatPermissions(permissions)), time.Now(), time.Now(),
 		)
 		if err != nil {
-			log.Fatalf("Error inserting demo user: %v", err)
+			fatal("Error inserting demo user", err)
 		}
 	}
 }
@@ -126,7 +95,7 @@ func insertDemoAddresses(db *sql.DB) {
 			address.UserID, address.Street, address.City, address.State, address.Country, address.Zipcode, time.Now(), time.Now(),
 		)
 		if err != nil {
-			log.Fatalf("Error inserting demo address: %v", err)
+			fatal("Error inserting demo address", err)
 		}
 	}
 }
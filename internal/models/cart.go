@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// Cart holds what a buyer intends to order. Guests' carts are identified by
+// a random token and users' carts by their ID; a guest's cart is merged
+// into theirs when they log in. Carts expire some time after their last
+// change.
+//
+// All items share the cart's Currency, set by the first item added. Prices
+// and totals are recalculated from the catalog whenever the cart is read.
+// A cart that isn't saved yet has ID 0 and zero times.
+type Cart struct {
+	ID     int  `json:"id"`
+	UserID *int `json:"user_id"`
+	// Token is only returned when a guest cart is created. Only its hash
+	// is stored.
+	Token     string     `json:"token,omitempty"`
+	TokenHash string     `json:"-"`
+	Currency  string     `json:"currency"`
+	Items     []CartItem `json:"items"`
+	Totals    Totals     `json:"totals"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type CartItemStatus string
+
+const (
+	CartItemAvailable CartItemStatus = "available"
+	// CartItemPriceChanged items cost something else than when they were
+	// last added or changed.
+	CartItemPriceChanged CartItemStatus = "price_changed"
+	// CartItemInsufficientStock items want more than is available.
+	CartItemInsufficientStock CartItemStatus = "insufficient_stock"
+	// CartItemUnavailable items are no longer for sale and are left out of
+	// the totals.
+	CartItemUnavailable CartItemStatus = "unavailable"
+)
+
+// CartItem is a quantity of one variant. AddedPrice is the unit price when
+// the item was last added or changed and is all that is stored with the
+// quantity; the rest is looked up from the catalog.
+type CartItem struct {
+	VariantID   int               `json:"variant_id"`
+	ProductID   int               `json:"product_id"`
+	SellerID    int               `json:"seller_id"`
+	ProductName string            `json:"product_name"`
+	SKU         string            `json:"sku"`
+	Options     map[string]string `json:"options"`
+	Quantity    int               `json:"quantity"`
+	AddedPrice  int64             `json:"added_price"`
+	UnitPrice   int64             `json:"unit_price"`
+	LineTotal   int64             `json:"line_total"`
+	WeightGrams int               `json:"weight_grams"`
+	// Available is the stock available across the seller's warehouses.
+	Available int            `json:"available"`
+	Status    CartItemStatus `json:"status"`
+	AddedAt   time.Time      `json:"added_at"`
+}
+
+// Totals are in the minor unit of a cart's or order's currency. Discount
+// is taken off Subtotal before Tax is added. A cart's Shipping is an
+// estimate; an order's is what the buyer is charged.
+type Totals struct {
+	Quantity int   `json:"quantity"`
+	Subtotal int64 `json:"subtotal"`
+	Discount int64 `json:"discount"`
+	Tax      int64 `json:"tax"`
+	Shipping int64 `json:"shipping"`
+	Total    int64 `json:"total"`
+}
+
+// CartItemRequest adds a quantity of a variant to the cart, on top of any
+// already in it.
+type CartItemRequest struct {
+	VariantID int `json:"variant_id" binding:"required,min=1"`
+	Quantity  int `json:"quantity" binding:"required,min=1,max=1000"`
+}
+
+// CartQuantityRequest sets an item's quantity.
+type CartQuantityRequest struct {
+	Quantity int `json:"quantity" binding:"required,min=1,max=1000"`
+}
+
+type CartItemPath struct {
+	VariantID int `uri:"variant_id" binding:"required,min=1"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import "time"
+
+// Category is a node of the catalog's category tree. Siblings are ordered
+// by Position, then ID. Products are only assigned to leaf categories.
+type Category struct {
+	ID        int       `json:"id"`
+	ParentID  *int      `json:"parent_id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CategoryNode is a category with its subtree.
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+// CategoryRef names a category in breadcrumbs.
+type CategoryRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// CategoryDetail is a category with its ancestors, from the root down, and
+// the attributes its products have, including those inherited from its
+// ancestors.
+type CategoryDetail struct {
+	Category
+	Breadcrumbs []CategoryRef       `json:"breadcrumbs"`
+	Children    []CategoryRef       `json:"children"`
+	Attributes  []CategoryAttribute `json:"attributes"`
+}
+
+type AttributeType string
+
+const (
+	AttributeText    AttributeType = "text"
+	AttributeNumber  AttributeType = "number"
+	AttributeBoolean AttributeType = "boolean"
+	AttributeEnum    AttributeType = "enum"
+)
+
+// CategoryAttribute is one entry of a category's attribute schema, e.g. a
+// TV's screen size in inches. Name is the key in Product.Attributes. A
+// category inherits its ancestors' attributes and may redefine them.
+type CategoryAttribute struct {
+	CategoryID int           `json:"category_id"`
+	Name       string        `json:"name"`
+	Label      string        `json:"label"`
+	Type       AttributeType `json:"type"`
+	Unit       string        `json:"unit,omitempty"`
+	Required   bool          `json:"required"`
+	// Options are the allowed values of an enum attribute.
+	Options []string `json:"options,omitempty"`
+}
+
+// CategoryRequest creates or replaces a category. Without a parent it is a
+// root; the slug is derived from the name when left empty.
+type CategoryRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Slug     string `json:"slug" binding:"omitempty,slug,max=100"`
+	ParentID int    `json:"parent_id" binding:"omitempty,min=1"`
+	Position int    `json:"position" binding:"min=0"`
+}
+
+// CategoryAttributesRequest replaces the attributes a category defines.
+// Existing products are not checked against the new schema until they are
+// next updated.
+type CategoryAttributesRequest struct {
+	Attributes []CategoryAttributeRequest `json:"attributes" binding:"max=50,unique=Name,dive"`
+}
+
+type CategoryAttributeRequest struct {
+	Name     string        `json:"name" binding:"required,slug,max=50"`
+	Label    string        `json:"label" binding:"required,max=100"`
+	Type     AttributeType `json:"type" binding:"required,oneof=text number boolean enum"`
+	Unit     string        `json:"unit" binding:"max=20"`
+	Required bool          `json:"required"`
+	Options  []string      `json:"options" binding:"required_if=Type enum,max=100,unique,dive,required,max=100"`
+}
+
+type CategoryPath struct {
+	ID int `uri:"id" binding:"required,min=1"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+type FulfilmentStatus string
+
+// A fulfilment waits until its order is paid and the seller accepts it,
+// is shipped with a tracking number and is delivered. It is cancelled with
+// its order.
+const (
+	FulfilmentPending   FulfilmentStatus = "pending"
+	FulfilmentAccepted  FulfilmentStatus = "accepted"
+	FulfilmentShipped   FulfilmentStatus = "shipped"
+	FulfilmentDelivered FulfilmentStatus = "delivered"
+	FulfilmentCancelled FulfilmentStatus = "cancelled"
+)
+
+type ShipmentEventType string
+
+// Accepted, shipped and cancelled events record the fulfilment's status
+// changes; the others are reported by the seller or carrier while the
+// parcel travels.
+const (
+	ShipmentAccepted       ShipmentEventType = "accepted"
+	ShipmentShipped        ShipmentEventType = "shipped"
+	ShipmentInTransit      ShipmentEventType = "in_transit"
+	ShipmentOutForDelivery ShipmentEventType = "out_for_delivery"
+	ShipmentException      ShipmentEventType = "exception"
+	ShipmentDelivered      ShipmentEventType = "delivered"
+	ShipmentCancelled      ShipmentEventType = "cancelled"
+)
+
+// Fulfilment is the part of an order one seller ships: the order's items
+// sold by that seller. Every order has one fulfilment per seller. Events,
+// the shipment's history, are only loaded for single fulfilments and for
+// the fulfilments of a single order.
+type Fulfilment struct {
+	ID              int              `json:"id"`
+	OrderID         int              `json:"order_id"`
+	SellerID        int              `json:"seller_id"`
+	Status          FulfilmentStatus `json:"status"`
+	Items           []OrderItem      `json:"items"`
+	ShippingAddress OrderAddress     `json:"shipping_address"`
+	Carrier         string           `json:"carrier"`
+	TrackingNumber  string           `json:"tracking_number"`
+	Events          []ShipmentEvent  `json:"events,omitempty"`
+	AcceptedAt      *time.Time       `json:"accepted_at"`
+	ShippedAt       *time.Time       `json:"shipped_at"`
+	DeliveredAt     *time.Time       `json:"delivered_at"`
+	CancelledAt     *time.Time       `json:"cancelled_at"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+}
+
+// ShipmentEvent is a step in a fulfilment's history. A nil ActorID is the
+// system.
+type ShipmentEvent struct {
+	ID           int               `json:"id"`
+	FulfilmentID int               `json:"fulfilment_id"`
+	ActorID      *int              `json:"actor_id"`
+	Type         ShipmentEventType `json:"type"`
+	Location     string            `json:"location"`
+	Description  string            `json:"description"`
+	OccurredAt   time.Time         `json:"occurred_at"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+// FulfilmentSummary counts a seller's fulfilments in each status.
+type FulfilmentSummary struct {
+	Pending   int `json:"pending"`
+	Accepted  int `json:"accepted"`
+	Shipped   int `json:"shipped"`
+	Delivered int `json:"delivered"`
+	Cancelled int `json:"cancelled"`
+}
+
+// ShipRequest records that a fulfilment was handed to a carrier.
+type ShipRequest struct {
+	Carrier        string `json:"carrier" binding:"required,max=100"`
+	TrackingNumber string `json:"tracking_number" binding:"required,max=100"`
+}
+
+// ShipmentEventRequest reports where a shipped parcel is. A delivered
+// event delivers the fulfilment. OccurredAt defaults to now.
+type ShipmentEventRequest struct {
+	Type        ShipmentEventType `json:"type" binding:"required,oneof=in_transit out_for_delivery exception delivered"`
+	Location    string            `json:"location" binding:"max=200"`
+	Description string            `json:"description" binding:"max=1000"`
+	OccurredAt  *time.Time        `json:"occurred_at"`
+}
+
+type FulfilmentPath struct {
+	ID int `uri:"id" binding:"required,min=1"`
+}
+
+// FulfilmentQuery pages the signed-in seller's fulfilments, newest first.
+type FulfilmentQuery struct {
+	Status FulfilmentStatus `form:"status" binding:"omitempty,oneof=pending accepted shipped delivered cancelled"`
+	Limit  int              `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int              `form:"offset" binding:"omitempty,min=0"`
+}
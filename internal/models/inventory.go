@@ -0,0 +1,145 @@
+package models
+
+import "time"
+
+// Warehouse is a place a seller keeps stock. Codes are unique among a
+// seller's warehouses.
+type Warehouse struct {
+	ID        int       `json:"id"`
+	SellerID  int       `json:"seller_id"`
+	Name      string    `json:"name"`
+	Code      string    `json:"code"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StockLevel is the stock of a variant in one warehouse. Reserved units are
+// held for checkouts in progress; the rest are Available. The seller is
+// notified when Available drops below a non-zero LowStockThreshold.
+type StockLevel struct {
+	VariantID         int       `json:"variant_id"`
+	WarehouseID       int       `json:"warehouse_id"`
+	OnHand            int       `json:"on_hand"`
+	Reserved          int       `json:"reserved"`
+	Available         int       `json:"available"`
+	LowStockThreshold int       `json:"low_stock_threshold"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// AdjustmentReason says why stock changed. Sellers record the first four;
+// the rest are written by reservations and orders.
+type AdjustmentReason string
+
+const (
+	AdjustmentReceived   AdjustmentReason = "received"
+	AdjustmentCorrection AdjustmentReason = "correction"
+	AdjustmentDamaged    AdjustmentReason = "damaged"
+	AdjustmentReturned   AdjustmentReason = "returned"
+	AdjustmentReserved   AdjustmentReason = "reserved"
+	AdjustmentReleased   AdjustmentReason = "released"
+	AdjustmentExpired    AdjustmentReason = "expired"
+	AdjustmentSold       AdjustmentReason = "sold"
+)
+
+// StockAdjustment is an entry of the inventory ledger, which records every
+// change to a stock level: the changes to its on-hand and reserved units
+// and their balances afterwards. ActorID is the user who made the change,
+// if it wasn't made by the system.
+type StockAdjustment struct {
+	ID             int              `json:"id"`
+	VariantID      int              `json:"variant_id"`
+	WarehouseID    int              `json:"warehouse_id"`
+	Reason         AdjustmentReason `json:"reason"`
+	OnHandChange   int              `json:"on_hand_change"`
+	ReservedChange int              `json:"reserved_change"`
+	OnHand         int              `json:"on_hand"`
+	Reserved       int              `json:"reserved"`
+	ReservationID  *int             `json:"reservation_id,omitempty"`
+	ActorID        *int             `json:"actor_id,omitempty"`
+	Note           string           `json:"note,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "active"
+	ReservationCommitted ReservationStatus = "committed"
+	ReservationReleased  ReservationStatus = "released"
+	ReservationExpired   ReservationStatus = "expired"
+)
+
+// Reservation holds stock for a buyer during checkout. It ends when the
+// order is confirmed and the stock is sold, when the buyer releases it, or
+// when it expires.
+type Reservation struct {
+	ID        int               `json:"id"`
+	UserID    int               `json:"user_id"`
+	Status    ReservationStatus `json:"status"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Items     []ReservationItem `json:"items"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// ReservationItem is stock held in one warehouse. A variant may be spread
+// over several items when no single warehouse has enough.
+type ReservationItem struct {
+	VariantID   int `json:"variant_id"`
+	WarehouseID int `json:"warehouse_id"`
+	Quantity    int `json:"quantity"`
+}
+
+// WarehouseRequest creates or replaces a warehouse.
+type WarehouseRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Code string `json:"code" binding:"required,sku,max=32"`
+}
+
+type WarehousePath struct {
+	ID int `uri:"id" binding:"required,min=1"`
+}
+
+// StockAdjustmentRequest changes the units on hand in a warehouse. Stock
+// can't drop below what is reserved.
+type StockAdjustmentRequest struct {
+	WarehouseID int              `json:"warehouse_id" binding:"required,min=1"`
+	Change      int              `json:"change" binding:"required,min=-1000000,max=1000000"`
+	Reason      AdjustmentReason `json:"reason" binding:"required,oneof=received correction damaged returned"`
+	Note        string           `json:"note" binding:"max=500"`
+}
+
+// StockThresholdRequest sets when the seller is told a variant is running
+// low in a warehouse; 0 turns the notification off.
+type StockThresholdRequest struct {
+	LowStockThreshold int `json:"low_stock_threshold" binding:"min=0,max=1000000"`
+}
+
+type StockPath struct {
+	ID          int `uri:"id" binding:"required,min=1"`
+	VariantID   int `uri:"variant_id" binding:"required,min=1"`
+	WarehouseID int `uri:"warehouse_id" binding:"required,min=1"`
+}
+
+// AdjustmentQuery pages a variant's ledger, newest first.
+type AdjustmentQuery struct {
+	ID        int `uri:"id" binding:"required,min=1"`
+	VariantID int `uri:"variant_id" binding:"required,min=1"`
+	Limit     int `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset    int `form:"offset" binding:"omitempty,min=0"`
+}
+
+// ReservationRequest reserves stock of published variants. Each variant is
+// taken from the warehouses with the most available first.
+type ReservationRequest struct {
+	Items []ReservationItemRequest `json:"items" binding:"required,min=1,max=50,unique=VariantID,dive"`
+}
+
+type ReservationItemRequest struct {
+	VariantID int `json:"variant_id" binding:"required,min=1"`
+	Quantity  int `json:"quantity" binding:"required,min=1,max=1000"`
+}
+
+type ReservationPath struct {
+	ID int `uri:"id" binding:"required,min=1"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"mime/multipart"
+	"time"
+)
+
+type ImageStatus string
+
+const (
+	// ImageProcessing images are stored but their renditions are still
+	// being generated.
+	ImageProcessing ImageStatus = "processing"
+	ImageReady      ImageStatus = "ready"
+	ImageFailed     ImageStatus = "failed"
+)
+
+// ProductImage is a picture of a product, shown in Position order. Files
+// are private: URL and RenditionURLs are signed and expire, so clients
+// should fetch the product again rather than store them.
+type ProductImage struct {
+	ID          int         `json:"id"`
+	ProductID   int         `json:"product_id"`
+	Key         string      `json:"-"`
+	ContentType string      `json:"content_type"`
+	Size        int64       `json:"size"`
+	Width       int         `json:"width"`
+	Height      int         `json:"height"`
+	Position    int         `json:"position"`
+	Status      ImageStatus `json:"status"`
+	// Renditions maps the names of the resized copies, e.g. "thumbnail",
+	// to their keys.
+	Renditions    map[string]string `json:"-"`
+	URL           string            `json:"url"`
+	RenditionURLs map[string]string `json:"renditions"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// ImageUploadRequest is a multipart/form-data upload of one image.
+type ImageUploadRequest struct {
+	File *multipart.FileHeader `form:"file" binding:"required"`
+}
+
+type ImagePath struct {
+	ID      int `uri:"id" binding:"required,min=1"`
+	ImageID int `uri:"image_id" binding:"required,min=1"`
+}
+
+// MediaQuery is the signature of a file URL, as made by media.Signer.
+type MediaQuery struct {
+	Expires   int64  `form:"expires" binding:"required"`
+	Signature string `form:"signature" binding:"required"`
+}
@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+type OrderStatus string
+
+// Orders move from pending_payment to paid, fulfilled and delivered, and
+// can be cancelled until they are fulfilled. A paid order is fulfilled
+// once every seller has shipped their fulfilment, and delivered once every
+// fulfilment is.
+const (
+	OrderPendingPayment OrderStatus = "pending_payment"
+	OrderPaid           OrderStatus = "paid"
+	OrderFulfilled      OrderStatus = "fulfilled"
+	OrderDelivered      OrderStatus = "delivered"
+	OrderCancelled      OrderStatus = "cancelled"
+)
+
+// Order is a checked-out cart. Its items, addresses and totals are copies
+// taken at checkout and never change. The stock is held by the reservation
+// until the order is paid; unpaid orders are cancelled at ExpiresAt, when
+// the reservation runs out.
+type Order struct {
+	ID              int          `json:"id"`
+	UserID          int          `json:"user_id"`
+	Status          OrderStatus  `json:"status"`
+	Currency        string       `json:"currency"`
+	Items           []OrderItem  `json:"items"`
+	Totals          Totals       `json:"totals"`
+	ShippingAddress OrderAddress `json:"shipping_address"`
+	BillingAddress  OrderAddress `json:"billing_address"`
+	ReservationID   *int         `json:"reservation_id"`
+	// Fulfilments split the order by seller. They are only loaded for
+	// single orders.
+	Fulfilments []Fulfilment `json:"fulfilments,omitempty"`
+	// IdempotencyKey is unique per user. RequestHash identifies the
+	// checkout request it was first used for.
+	IdempotencyKey string     `json:"-"`
+	RequestHash    string     `json:"-"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	PaidAt         *time.Time `json:"paid_at"`
+	FulfilledAt    *time.Time `json:"fulfilled_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CancelledAt    *time.Time `json:"cancelled_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// OrderItem is a variant as it was sold. The variant and product may have
+// changed or been deleted since.
+type OrderItem struct {
+	VariantID   int               `json:"variant_id"`
+	ProductID   int               `json:"product_id"`
+	SellerID    int               `json:"seller_id"`
+	ProductName string            `json:"product_name"`
+	SKU         string            `json:"sku"`
+	Options     map[string]string `json:"options"`
+	UnitPrice   int64             `json:"unit_price"`
+	Quantity    int               `json:"quantity"`
+	LineTotal   int64             `json:"line_total"`
+}
+
+// OrderAddress is a copy of one of the buyer's addresses.
+type OrderAddress struct {
+	Street  string `json:"street"`
+	City    string `json:"city"`
+	State   string `json:"state"`
+	Country string `json:"country"`
+	Zipcode string `json:"zipcode"`
+}
+
+// CheckoutRequest orders the signed-in user's cart. The billing address
+// defaults to the shipping address.
+type CheckoutRequest struct {
+	ShippingAddressID int `json:"shipping_address_id" binding:"required,min=1"`
+	BillingAddressID  int `json:"billing_address_id" binding:"omitempty,min=1"`
+}
+
+// OrderStatusRequest moves an order on to its next status.
+type OrderStatusRequest struct {
+	Status OrderStatus `json:"status" binding:"required,oneof=paid fulfilled delivered cancelled"`
+}
+
+type OrderPath struct {
+	ID int `uri:"id" binding:"required,min=1"`
+}
+
+// OrderQuery pages the signed-in user's orders, newest first.
+type OrderQuery struct {
+	Status OrderStatus `form:"status" binding:"omitempty,oneof=pending_payment paid fulfilled delivered cancelled"`
+	Limit  int         `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int         `form:"offset" binding:"omitempty,min=0"`
+}
@@ -0,0 +1,103 @@
+package models
+
+import "time"
+
+type PaymentStatus string
+
+// A payment starts pending, may need the buyer to complete a challenge and
+// is then authorized or declined. Authorized payments are captured when the
+// order is fulfilled or voided when it is cancelled; captured payments can
+// be refunded, in parts.
+const (
+	PaymentPending        PaymentStatus = "pending"
+	PaymentRequiresAction PaymentStatus = "requires_action"
+	PaymentAuthorized     PaymentStatus = "authorized"
+	PaymentCaptured       PaymentStatus = "captured"
+	PaymentDeclined       PaymentStatus = "declined"
+	PaymentVoided         PaymentStatus = "voided"
+	PaymentRefunded       PaymentStatus = "refunded"
+)
+
+// Payment is one attempt to pay for an order. Failed attempts are kept, so
+// an order can have several payments but only one that isn't declined or
+// voided.
+type Payment struct {
+	ID       int    `json:"id"`
+	OrderID  int    `json:"order_id"`
+	UserID   int    `json:"user_id"`
+	Provider string `json:"provider"`
+	// Reference is the provider's ID for the payment; it is empty until the
+	// provider has seen it.
+	Reference      string        `json:"reference"`
+	Status         PaymentStatus `json:"status"`
+	Amount         int64         `json:"amount"`
+	CapturedAmount int64         `json:"captured_amount"`
+	RefundedAmount int64         `json:"refunded_amount"`
+	Currency       string        `json:"currency"`
+	CardBrand      string        `json:"card_brand"`
+	CardLast4      string        `json:"card_last4"`
+	// DeclineCode says why a declined payment was declined.
+	DeclineCode string `json:"decline_code,omitempty"`
+	// ActionURL is where the buyer completes the challenge of a payment
+	// that requires action.
+	ActionURL string    `json:"action_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PaymentOperationKind string
+
+const (
+	OperationCapture PaymentOperationKind = "capture"
+	OperationVoid    PaymentOperationKind = "void"
+	OperationRefund  PaymentOperationKind = "refund"
+)
+
+type PaymentOperationStatus string
+
+const (
+	OperationPending   PaymentOperationStatus = "pending"
+	OperationSucceeded PaymentOperationStatus = "succeeded"
+	OperationFailed    PaymentOperationStatus = "failed"
+)
+
+// PaymentOperation is a capture, void or refund asked of the provider. It
+// is recorded as pending before the provider is called, so a call whose
+// answer was lost is made again with the same idempotency key rather than
+// forgotten or repeated. Key names the change, e.g. the refund of a return;
+// only one operation that hasn't failed can have it.
+type PaymentOperation struct {
+	ID        int                    `json:"id"`
+	PaymentID int                    `json:"payment_id"`
+	Kind      PaymentOperationKind   `json:"kind"`
+	Amount    int64                  `json:"amount"`
+	Status    PaymentOperationStatus `json:"status"`
+	Key       string                 `json:"key"`
+	// Error is what the provider said when it refused a failed operation.
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PaymentRequest pays for an order by card. The card details are passed to
+// the provider and never stored, apart from the brand and last four digits.
+type PaymentRequest struct {
+	Card PaymentCard `json:"card" binding:"required"`
+}
+
+type PaymentCard struct {
+	Number   string `json:"number" binding:"required,credit_card"`
+	ExpMonth int    `json:"exp_month" binding:"required,min=1,max=12"`
+	ExpYear  int    `json:"exp_year" binding:"required,min=2000,max=2100"`
+	CVC      string `json:"cvc" binding:"required,numeric,min=3,max=4"`
+}
+
+// SimulatorChallengeRequest completes the challenge of a payment made with
+// the simulated provider, as the buyer's bank would.
+type SimulatorChallengeRequest struct {
+	Result string `json:"result" binding:"required,oneof=approve fail"`
+}
+
+type PaymentReferencePath struct {
+	Reference string `uri:"reference" binding:"required,max=64"`
+}
@@ -0,0 +1,130 @@
+package models
+
+import "time"
+
+type ProductStatus string
+
+const (
+	ProductDraft     ProductStatus = "draft"
+	ProductPublished ProductStatus = "published"
+	ProductArchived  ProductStatus = "archived"
+)
+
+// Product is an item a seller lists. Only published products are visible
+// to the public; drafts and archived products are seen by their seller and
+// admins. Price is in the minor unit of Currency, e.g. cents, and is the
+// default for new variants.
+//
+// Options, Variants and Images are only loaded for single products.
+type Product struct {
+	ID          int           `json:"id"`
+	SellerID    int           `json:"seller_id"`
+	Name        string        `json:"name"`
+	Slug        string        `json:"slug"`
+	Description string        `json:"description"`
+	Price       int64         `json:"price"`
+	Currency    string        `json:"currency"`
+	Status      ProductStatus `json:"status"`
+	CategoryID  *int          `json:"category_id"`
+	// Attributes hold the values of the category's attributes, e.g.
+	// {"screen-size": 55}.
+	Attributes  map[string]any `json:"attributes"`
+	PublishedAt *time.Time     `json:"published_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+
+	Options  []ProductOption  `json:"options,omitempty"`
+	Variants []ProductVariant `json:"variants,omitempty"`
+	Images   []ProductImage   `json:"images,omitempty"`
+}
+
+// ProductOption is a dimension a product varies in, such as size, with the
+// values it comes in. Options are kept in the order they were defined.
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// ProductVariant is one purchasable combination of option values, e.g.
+// {"size": "M", "colour": "red"}. A product without options has a single
+// variant with no option values. SKUs are unique among a seller's variants.
+// Stock is the number available across the seller's warehouses; it is
+// managed through the inventory.
+type ProductVariant struct {
+	ID          int               `json:"id"`
+	ProductID   int               `json:"product_id"`
+	SKU         string            `json:"sku"`
+	Options     map[string]string `json:"options"`
+	Price       int64             `json:"price"`
+	WeightGrams int               `json:"weight_grams"`
+	Barcode     string            `json:"barcode,omitempty"`
+	Stock       int               `json:"stock"`
+	Images      []string          `json:"images"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// ProductRequest creates or replaces a product. The slug is derived from
+// the name when left empty. The category must be a leaf, and the
+// attributes must fit its schema.
+type ProductRequest struct {
+	Name        string         `json:"name" binding:"required,max=200"`
+	Slug        string         `json:"slug" binding:"omitempty,slug,max=200"`
+	Description string         `json:"description" binding:"max=10000"`
+	Price       int64          `json:"price" binding:"min=0"`
+	Currency    string         `json:"currency" binding:"required,currency"`
+	CategoryID  int            `json:"category_id" binding:"omitempty,min=1"`
+	Attributes  map[string]any `json:"attributes"`
+}
+
+// ProductOptionsRequest replaces a product's options. Variants are
+// regenerated to match: combinations that already exist are kept, new ones
+// are added at the product's price and the rest are deleted.
+type ProductOptionsRequest struct {
+	Options []ProductOptionRequest `json:"options" binding:"max=3,unique=Name,dive"`
+}
+
+type ProductOptionRequest struct {
+	Name   string   `json:"name" binding:"required,max=50"`
+	Values []string `json:"values" binding:"required,min=1,max=50,unique,dive,required,max=50"`
+}
+
+// VariantRequest replaces a variant's details. Its option values are set
+// through the product's options and its stock through the inventory.
+type VariantRequest struct {
+	SKU         string   `json:"sku" binding:"required,sku,max=64"`
+	Price       int64    `json:"price" binding:"min=0"`
+	WeightGrams int      `json:"weight_grams" binding:"min=0"`
+	Barcode     string   `json:"barcode" binding:"omitempty,numeric,min=8,max=14"`
+	Images      []string `json:"images" binding:"max=10,dive,url"`
+}
+
+type ProductStatusRequest struct {
+	Status ProductStatus `json:"status" binding:"required,oneof=draft published archived"`
+}
+
+type ProductPath struct {
+	ID int `uri:"id" binding:"required,min=1"`
+}
+
+type VariantPath struct {
+	ID        int `uri:"id" binding:"required,min=1"`
+	VariantID int `uri:"variant_id" binding:"required,min=1"`
+}
+
+// ProductQuery filters and pages the public list of published products.
+// A category includes its whole subtree.
+type ProductQuery struct {
+	SellerID   int `form:"seller_id" binding:"omitempty,min=1"`
+	CategoryID int `form:"category_id" binding:"omitempty,min=1"`
+	Limit      int `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset     int `form:"offset" binding:"omitempty,min=0"`
+}
+
+// SellerProductQuery filters and pages a seller's own products.
+type SellerProductQuery struct {
+	Status     ProductStatus `form:"status" binding:"omitempty,oneof=draft published archived"`
+	CategoryID int           `form:"category_id" binding:"omitempty,min=1"`
+	Limit      int           `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset     int           `form:"offset" binding:"omitempty,min=0"`
+}
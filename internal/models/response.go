@@ -0,0 +1,25 @@
+package models
+
+// MessageResponse acknowledges an action that returns no resource.
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// TokenResponse carries an access token for the Authorization header.
+type TokenResponse struct {
+	Message string `json:"message"`
+	Token   string `json:"token"`
+}
+
+// Data is the envelope v2 wraps single resources in.
+type Data[T any] struct {
+	Data T `json:"data"`
+}
+
+// Page is one page of a list. Total counts every matching item.
+type Page[T any] struct {
+	Data   []T `json:"data"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
@@ -0,0 +1,156 @@
+package models
+
+import "time"
+
+type ReturnStatus string
+
+// Returns are requested by the buyer and approved or rejected by the
+// seller. Approved returns get a shipping label; once the parcel arrives
+// the seller inspects it and refunds the buyer.
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnApproved  ReturnStatus = "approved"
+	ReturnRejected  ReturnStatus = "rejected"
+	ReturnInspected ReturnStatus = "inspected"
+	ReturnRefunded  ReturnStatus = "refunded"
+)
+
+type ReturnReason string
+
+const (
+	ReturnDamaged        ReturnReason = "damaged"
+	ReturnDefective      ReturnReason = "defective"
+	ReturnWrongItem      ReturnReason = "wrong_item"
+	ReturnNotAsDescribed ReturnReason = "not_as_described"
+	ReturnNotNeeded      ReturnReason = "no_longer_needed"
+	ReturnOther          ReturnReason = "other"
+)
+
+// ReturnCondition is what the seller found an item in on inspection.
+// Only resellable items are restocked.
+type ReturnCondition string
+
+const (
+	ConditionResellable ReturnCondition = "resellable"
+	ConditionDamaged    ReturnCondition = "damaged"
+	ConditionMissing    ReturnCondition = "missing"
+)
+
+// Return is a buyer's request to send back items of one order, all sold by
+// the same seller. Events, the return's audit trail, are only loaded for
+// single returns.
+type Return struct {
+	ID       int          `json:"id"`
+	OrderID  int          `json:"order_id"`
+	UserID   int          `json:"user_id"`
+	SellerID int          `json:"seller_id"`
+	Status   ReturnStatus `json:"status"`
+	Items    []ReturnItem `json:"items"`
+	// Label is set once the return is approved.
+	Label           *ReturnLabel  `json:"label"`
+	RejectionReason string        `json:"rejection_reason,omitempty"`
+	Currency        string        `json:"currency"`
+	RefundAmount    int64         `json:"refund_amount"`
+	Events          []ReturnEvent `json:"events,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// ReturnItem is a quantity of one order item to return. Condition and
+// ReceivedQuantity are set on inspection.
+type ReturnItem struct {
+	VariantID        int             `json:"variant_id"`
+	ProductName      string          `json:"product_name"`
+	SKU              string          `json:"sku"`
+	UnitPrice        int64           `json:"unit_price"`
+	Quantity         int             `json:"quantity"`
+	Reason           ReturnReason    `json:"reason"`
+	Note             string          `json:"note"`
+	Photos           []ReturnPhoto   `json:"photos"`
+	Condition        ReturnCondition `json:"condition,omitempty"`
+	ReceivedQuantity int             `json:"received_quantity"`
+}
+
+// ReturnPhoto shows the state of a returned item. URL is signed and
+// expires.
+type ReturnPhoto struct {
+	ID          int       `json:"id"`
+	Key         string    `json:"-"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ReturnLabel is the shipping label the buyer sends the items back with.
+// URL is signed and expires.
+type ReturnLabel struct {
+	Carrier        string    `json:"carrier"`
+	TrackingNumber string    `json:"tracking_number"`
+	Key            string    `json:"-"`
+	URL            string    `json:"url"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ReturnEvent records who moved a return to a status, and why.
+type ReturnEvent struct {
+	ID        int          `json:"id"`
+	ReturnID  int          `json:"return_id"`
+	ActorID   *int         `json:"actor_id"`
+	Status    ReturnStatus `json:"status"`
+	Note      string       `json:"note"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// ReturnCreateRequest asks to return items of a delivered order. Photos
+// are uploaded to the items afterwards.
+type ReturnCreateRequest struct {
+	Items []ReturnItemRequest `json:"items" binding:"required,min=1,max=50,unique=VariantID,dive"`
+}
+
+type ReturnItemRequest struct {
+	VariantID int          `json:"variant_id" binding:"required,min=1"`
+	Quantity  int          `json:"quantity" binding:"required,min=1"`
+	Reason    ReturnReason `json:"reason" binding:"required,oneof=damaged defective wrong_item not_as_described no_longer_needed other"`
+	Note      string       `json:"note" binding:"max=1000"`
+}
+
+type ReturnRejectRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
+
+// ReturnInspectionRequest records what arrived of each item of the return.
+type ReturnInspectionRequest struct {
+	Items []ReturnInspectionItem `json:"items" binding:"required,min=1,max=50,unique=VariantID,dive"`
+	Note  string                 `json:"note" binding:"max=1000"`
+}
+
+type ReturnInspectionItem struct {
+	VariantID        int             `json:"variant_id" binding:"required,min=1"`
+	ReceivedQuantity int             `json:"received_quantity" binding:"min=0"`
+	Condition        ReturnCondition `json:"condition" binding:"required,oneof=resellable damaged missing"`
+}
+
+// ReturnRefundRequest refunds an inspected return. Amount defaults to the
+// price of the items received; less is a partial refund. More, up to the
+// price of the items returned, needs a Note saying why.
+type ReturnRefundRequest struct {
+	Amount int64  `json:"amount" binding:"omitempty,min=1"`
+	Note   string `json:"note" binding:"max=1000"`
+}
+
+type ReturnPath struct {
+	ID int `uri:"id" binding:"required,min=1"`
+}
+
+type ReturnItemPath struct {
+	ID        int `uri:"id" binding:"required,min=1"`
+	VariantID int `uri:"variant_id" binding:"required,min=1"`
+}
+
+// ReturnQuery pages returns, newest first.
+type ReturnQuery struct {
+	Status ReturnStatus `form:"status" binding:"omitempty,oneof=requested approved rejected inspected refunded"`
+	Limit  int          `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int          `form:"offset" binding:"omitempty,min=0"`
+}
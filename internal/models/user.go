@@ -21,6 +21,18 @@ type User struct {
 	UpdatedAt   time.Time    `json:"updated_at"`
 }
 
+// HasPermission reports whether the user holds any of permissions.
+func (u *User) HasPermission(permissions ...Permission) bool {
+	for _, held := range u.Permissions {
+		for _, p := range permissions {
+			if held == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type UserCreateRequest struct {
 	FirstName string `json:"first_name" binding:"required"`
 	LastName  string `json:"last_name"`
@@ -28,11 +40,47 @@ type UserCreateRequest struct {
 	Password  string `json:"password" binding:"required"`
 }
 
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type VerifyOTPRequest struct {
+	OTP    string `json:"otp" binding:"required"`
+	UserID int    `json:"user_id" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ChangePasswordRequestV1 names the user in the body; v2 uses the
+// authenticated user and takes ChangePasswordRequest.
+type ChangePasswordRequestV1 struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+	UserID      int    `json:"user_id" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
 type UserUpdateRequest struct {
-	FirstName   string       `json:"first_name"`
-	LastName    string       `json:"last_name"`
-	Email       string       `json:"email"`
-	Permissions []Permission `json:"permissions"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+}
+
+// UserPermissionsRequest replaces a user's permissions. Only admins can
+// send it.
+type UserPermissionsRequest struct {
+	Permissions []Permission `json:"permissions" binding:"required,min=1,unique,dive,oneof=buyer seller admin"`
+}
+
+type UserPath struct {
+	ID int `uri:"id" binding:"required,min=1"`
 }
 
 type UserResponse struct {
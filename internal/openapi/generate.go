@@ -0,0 +1,196 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/middleware"
+)
+
+const (
+	jsonContentType    = "application/json"
+	formContentType    = "multipart/form-data"
+	problemContentType = "application/problem+json"
+	securityScheme     = "token"
+)
+
+var defaultInfo = Info{
+	Title:   "Ecommerce API",
+	Version: "1.0.0",
+	Description: "Versions are served under /api/v1 and /api/v2. v1, also served " +
+		"under the unversioned /api prefix, is deprecated; see the Deprecation and Sunset headers.",
+}
+
+// FromRegistry documents every route mounted by reg.
+func FromRegistry(reg *api.Registry) *Document {
+	return Generate(reg.Endpoints(), defaultInfo)
+}
+
+// Generate documents every endpoint. Operations of deprecated versions are
+// marked deprecated, Auth routes require the token security scheme and
+// mention the permissions they need, OptionalAuth routes accept it, and
+// every operation shares the problem+json error response.
+func Generate(endpoints []api.Endpoint, info Info) *Document {
+	b := newSchemas()
+	problem := b.of(reflect.TypeOf(middleware.Problem{}))
+
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			SecuritySchemes: map[string]*SecurityScheme{
+				securityScheme: {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "Authorization",
+					Description: "The token returned by verify-otp, sent as is without a Bearer prefix.",
+				},
+			},
+		},
+	}
+
+	tagged := map[string]bool{}
+	for _, e := range endpoints {
+		route := e.Route
+		path, pathParams := convertPath(e.Path)
+
+		if !tagged[e.Version.Name] {
+			tagged[e.Version.Name] = true
+			doc.Tags = append(doc.Tags, Tag{Name: e.Version.Name})
+		}
+
+		op := &Operation{
+			OperationID: operationID(route.Method, e.Path),
+			Summary:     route.Summary,
+			Tags:        []string{e.Version.Name},
+			Deprecated:  !e.Version.Deprecated.IsZero(),
+			Responses: map[string]*Response{
+				"default": {
+					Description: "Error",
+					Content:     map[string]MediaType{problemContentType: {Schema: problem}},
+				},
+			},
+		}
+		op.Parameters = parameters(b, route.Query, pathParams)
+		if route.Request != nil {
+			contentType := jsonContentType
+			if route.Multipart {
+				contentType = formContentType
+			}
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{contentType: {Schema: b.of(reflect.TypeOf(route.Request))}},
+			}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		resp := &Response{Description: http.StatusText(status)}
+		if route.Response != nil {
+			resp.Content = map[string]MediaType{jsonContentType: {Schema: b.of(reflect.TypeOf(route.Response))}}
+		}
+		op.Responses[strconv.Itoa(status)] = resp
+
+		if route.Auth {
+			op.Security = []map[string][]string{{securityScheme: {}}}
+		} else if route.OptionalAuth {
+			// The empty requirement allows anonymous requests.
+			op.Security = []map[string][]string{{}, {securityScheme: {}}}
+		}
+		if len(route.Permissions) > 0 {
+			op.Description = "Requires the " + strings.Join(route.Permissions, " or ") + " permission."
+		}
+
+		item := doc.Paths[path]
+		if item == nil {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(route.Method)] = op
+	}
+
+	doc.Components.Schemas = b.components
+	return doc
+}
+
+// convertPath turns gin parameters (:id, *path) into OpenAPI templates and
+// returns their names.
+func convertPath(ginPath string) (string, []string) {
+	var params []string
+	segments := strings.Split(ginPath, "/")
+	for i, seg := range segments {
+		if seg != "" && (seg[0] == ':' || seg[0] == '*') {
+			params = append(params, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives a stable camel-case ID from the method and path, e.g.
+// postApiV1UsersLogin or getApiV2ProductsById.
+func operationID(method, ginPath string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(ginPath, "/") {
+		if seg == "" {
+			continue
+		}
+		if seg[0] == ':' || seg[0] == '*' {
+			sb.WriteString("By")
+			seg = seg[1:]
+		}
+		for _, word := range strings.FieldsFunc(seg, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			sb.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return sb.String()
+}
+
+// parameters documents the path parameters and the fields of query, a
+// struct whose fields are tagged form:"name" for query parameters or
+// uri:"name" to describe a path parameter.
+func parameters(b *schemas, query any, pathParams []string) []*Parameter {
+	pathSchemas := map[string]*Schema{}
+	var params []*Parameter
+
+	if query != nil {
+		t := reflect.TypeOf(query)
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			schema := b.of(field.Type)
+			required := applyBinding(schema, field.Tag.Get("binding"))
+			if name, _, _ := strings.Cut(field.Tag.Get("uri"), ","); name != "" {
+				pathSchemas[name] = schema
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("form"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			params = append(params, &Parameter{Name: name, In: "query", Required: required, Schema: schema})
+		}
+	}
+
+	var path []*Parameter
+	for _, name := range pathParams {
+		schema := pathSchemas[name]
+		if schema == nil {
+			schema = &Schema{Type: "string"}
+		}
+		path = append(path, &Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return append(path, params...)
+}
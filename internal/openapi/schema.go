@@ -0,0 +1,296 @@
+package openapi
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+)
+
+// RuleFunc applies a custom validator's binding rule, e.g. "phone", to the
+// schema of the field it is declared on. param is the text after "=".
+type RuleFunc func(s *Schema, param string)
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]RuleFunc{}
+)
+
+// RegisterRule documents a custom binding rule. Rules the generator doesn't
+// know are left out of the schema.
+func RegisterRule(name string, fn RuleFunc) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = fn
+}
+
+func customRule(name string) (RuleFunc, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	fn, ok := rules[name]
+	return fn, ok
+}
+
+// schemas turns Go types into schemas, collecting named structs as
+// reusable components.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+func (b *schemas) of(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	case fileHeaderType:
+		return &Schema{Type: "string", ContentMediaType: "application/octet-stream"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: ptr(0.0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.of(t.Elem())}
+	case reflect.Struct:
+		return b.ref(t)
+	}
+	return &Schema{}
+}
+
+// ref returns a reference to the component for a named struct, adding it on
+// first use. Anonymous structs are inlined.
+func (b *schemas) ref(t reflect.Type) *Schema {
+	name := b.name(t)
+	if name == "" {
+		return b.object(t)
+	}
+	if _, ok := b.components[name]; !ok {
+		// Reserve the name first so self-referencing types terminate.
+		s := &Schema{}
+		b.components[name] = s
+		*s = *b.object(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// name returns the component name of t. Instances of generic types are
+// named after their arguments, e.g. Data[models.UserResponse] becomes
+// UserResponseData. Types whose names clash get their package as a prefix.
+func (b *schemas) name(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if name == "" {
+		return ""
+	}
+	if base, args, ok := strings.Cut(name, "["); ok {
+		name = ""
+		for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+			arg = arg[strings.LastIndex(arg, ".")+1:]
+			name += strings.TrimLeft(arg, "*[]")
+		}
+		name += base
+	}
+	for _, taken := range b.names {
+		if taken == name {
+			pkg := path.Base(t.PkgPath())
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+			break
+		}
+	}
+	b.names[t] = name
+	return name
+}
+
+func (b *schemas) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	b.fields(t, s)
+	return s
+}
+
+// fields adds the JSON fields of struct t to s, flattening embedded structs
+// the way encoding/json does. Multipart forms, which have no JSON tags, are
+// named by their form tags.
+func (b *schemas) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("json")
+		if !ok {
+			tag = field.Tag.Get("form")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.fields(ft, s)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := b.of(field.Type)
+		required := applyBinding(prop, field.Tag.Get("binding"))
+		if required && !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// applyBinding translates go-playground/validator rules into schema
+// constraints and reports whether the field is required. Rules after "dive"
+// apply to the elements of a slice or map.
+func applyBinding(s *Schema, tag string) (required bool) {
+	if tag == "" {
+		return false
+	}
+	target := s
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = required || target == s
+		case "dive":
+			switch {
+			case target.Items != nil:
+				target = target.Items
+			case target.AdditionalProperties != nil:
+				target = target.AdditionalProperties
+			}
+		case "email":
+			target.Format = "email"
+		case "url", "uri", "http_url":
+			target.Format = "uri"
+		case "uuid", "uuid4":
+			target.Format = "uuid"
+		case "alphanum":
+			target.Pattern = "^[a-zA-Z0-9]+$"
+		case "numeric":
+			target.Pattern = `^[-+]?[0-9]+(\.[0-9]+)?$`
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				target.Enum = append(target.Enum, enumValue(target, v))
+			}
+		case "unique":
+			// unique=Field compares one field of each element, which JSON
+			// Schema can't express.
+			if param == "" && target.Type == "array" {
+				target.UniqueItems = true
+			}
+		case "len":
+			bound(target, param, true, true)
+		case "min", "gte":
+			bound(target, param, true, false)
+		case "max", "lte":
+			bound(target, param, false, true)
+		case "gt":
+			if n, err := strconv.ParseFloat(param, 64); err == nil && numeric(target) {
+				target.ExclusiveMinimum = &n
+			}
+		case "lt":
+			if n, err := strconv.ParseFloat(param, 64); err == nil && numeric(target) {
+				target.ExclusiveMaximum = &n
+			}
+		default:
+			if fn, ok := customRule(name); ok {
+				fn(target, param)
+			}
+		}
+	}
+	return required
+}
+
+// bound sets a lower and/or upper limit: a length for strings, a count for
+// arrays and a value for numbers.
+func bound(s *Schema, param string, lower, upper bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch {
+	case s.Type == "string":
+		if lower {
+			s.MinLength = ptr(int(n))
+		}
+		if upper {
+			s.MaxLength = ptr(int(n))
+		}
+	case s.Type == "array":
+		if lower {
+			s.MinItems = ptr(int(n))
+		}
+		if upper {
+			s.MaxItems = ptr(int(n))
+		}
+	case numeric(s):
+		if lower {
+			s.Minimum = &n
+		}
+		if upper {
+			s.Maximum = &n
+		}
+	}
+}
+
+func numeric(s *Schema) bool {
+	return s.Type == "integer" || s.Type == "number"
+}
+
+func enumValue(s *Schema, v string) any {
+	if numeric(s) {
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
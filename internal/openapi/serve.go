@@ -0,0 +1,57 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// initializer points the bundled Swagger UI at /openapi.json instead of the
+// petstore example.
+//
+//go:embed swagger-initializer.js
+var initializer []byte
+
+// uiPolicy relaxes the API's default Content-Security-Policy just enough for
+// Swagger UI's own scripts and inline styles.
+const uiPolicy = "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'; frame-ancestors 'none'"
+
+// Marshal encodes doc the way it is served and committed: indented, with a
+// trailing newline.
+func Marshal(doc *Document) ([]byte, error) {
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// Handler serves doc, encoded once up front.
+func Handler(doc *Document) gin.HandlerFunc {
+	body, err := Marshal(doc)
+	if err != nil {
+		// Documents only hold strings, numbers, maps and slices.
+		panic("openapi: " + err.Error())
+	}
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", body)
+	}
+}
+
+// UI serves Swagger UI from the files embedded in the binary. It must be
+// mounted at prefix + "/*filepath".
+func UI(prefix string) gin.HandlerFunc {
+	files := http.StripPrefix(prefix, http.FileServer(http.FS(swaggerFiles.FS)))
+	return func(c *gin.Context) {
+		c.Header("Content-Security-Policy", uiPolicy)
+		if strings.TrimPrefix(c.Param("filepath"), "/") == "swagger-initializer.js" {
+			c.Data(http.StatusOK, "text/javascript; charset=utf-8", initializer)
+			return
+		}
+		files.ServeHTTP(c.Writer, c.Request)
+	}
+}
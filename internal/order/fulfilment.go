@@ -0,0 +1,269 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+var (
+	errOrderNotPaid  = apperror.Conflict("order_not_paid", "Fulfilments can only be accepted once their order is paid")
+	errNotShipped    = apperror.Conflict("fulfilment_not_shipped", "Only shipped fulfilments can be tracked")
+	errPartlyShipped = apperror.Conflict("order_partly_shipped", "Orders can't be cancelled once part of them has shipped")
+)
+
+// fulfilmentMoves lists the statuses a fulfilment can move on to from each
+// status.
+var fulfilmentMoves = map[models.FulfilmentStatus][]models.FulfilmentStatus{
+	models.FulfilmentPending:  {models.FulfilmentAccepted, models.FulfilmentCancelled},
+	models.FulfilmentAccepted: {models.FulfilmentShipped, models.FulfilmentCancelled},
+	models.FulfilmentShipped:  {models.FulfilmentDelivered},
+}
+
+// fulfilmentEvents names the event recording each status change.
+var fulfilmentEvents = map[models.FulfilmentStatus]models.ShipmentEventType{
+	models.FulfilmentAccepted:  models.ShipmentAccepted,
+	models.FulfilmentShipped:   models.ShipmentShipped,
+	models.FulfilmentDelivered: models.ShipmentDelivered,
+	models.FulfilmentCancelled: models.ShipmentCancelled,
+}
+
+// split creates one fulfilment per seller of the order's items.
+func (s *Service) split(ctx context.Context, order *models.Order) error {
+	bySeller := make(map[int]*models.Fulfilment)
+	var sellers []int
+	for _, item := range order.Items {
+		f, ok := bySeller[item.SellerID]
+		if !ok {
+			f = &models.Fulfilment{
+				OrderID:         order.ID,
+				SellerID:        item.SellerID,
+				Status:          models.FulfilmentPending,
+				ShippingAddress: order.ShippingAddress,
+			}
+			bySeller[item.SellerID] = f
+			sellers = append(sellers, item.SellerID)
+		}
+		f.Items = append(f.Items, item)
+	}
+	for _, sellerID := range sellers {
+		if err := s.fulfilments.CreateFulfilment(ctx, bySeller[sellerID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fulfilments returns the fulfilments of an order with their events,
+// oldest first.
+func (s *Service) Fulfilments(ctx context.Context, orderID int) ([]models.Fulfilment, error) {
+	listed, err := s.fulfilments.ListFulfilmentsByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	fulfilments := make([]models.Fulfilment, len(listed))
+	for i, f := range listed {
+		fulfilments[i] = *f
+	}
+	return fulfilments, nil
+}
+
+// Accept lets the seller take on a fulfilment of a paid order.
+func (s *Service) Accept(ctx context.Context, id, actorID int) (*models.Fulfilment, error) {
+	return s.fulfil(ctx, id, actorID, func(f *models.Fulfilment, order *models.Order) (*models.ShipmentEvent, error) {
+		if order.Status != models.OrderPaid {
+			return nil, errOrderNotPaid
+		}
+		if err := s.moveFulfilment(f, models.FulfilmentAccepted); err != nil {
+			return nil, err
+		}
+		return &models.ShipmentEvent{Type: models.ShipmentAccepted}, nil
+	})
+}
+
+// Ship records that an accepted fulfilment was handed to a carrier. Once
+// every fulfilment of the order has shipped the order is fulfilled, which
+// captures its payment.
+func (s *Service) Ship(ctx context.Context, id, actorID int, req models.ShipRequest) (*models.Fulfilment, error) {
+	return s.fulfil(ctx, id, actorID, func(f *models.Fulfilment, _ *models.Order) (*models.ShipmentEvent, error) {
+		if err := s.moveFulfilment(f, models.FulfilmentShipped); err != nil {
+			return nil, err
+		}
+		f.Carrier = req.Carrier
+		f.TrackingNumber = req.TrackingNumber
+		return &models.ShipmentEvent{
+			Type:        models.ShipmentShipped,
+			Description: fmt.Sprintf("Shipped with %s, tracking number %s", req.Carrier, req.TrackingNumber),
+		}, nil
+	})
+}
+
+// Track adds an event to the history of a shipped fulfilment. A delivered
+// event delivers it; once every fulfilment of the order is delivered so is
+// the order.
+func (s *Service) Track(ctx context.Context, id, actorID int, req models.ShipmentEventRequest) (*models.Fulfilment, error) {
+	return s.fulfil(ctx, id, actorID, func(f *models.Fulfilment, _ *models.Order) (*models.ShipmentEvent, error) {
+		if f.Status != models.FulfilmentShipped {
+			return nil, errNotShipped
+		}
+		if req.Type == models.ShipmentDelivered {
+			if err := s.moveFulfilment(f, models.FulfilmentDelivered); err != nil {
+				return nil, err
+			}
+		}
+		event := &models.ShipmentEvent{Type: req.Type, Location: req.Location, Description: req.Description}
+		if req.OccurredAt != nil {
+			event.OccurredAt = *req.OccurredAt
+		}
+		return event, nil
+	})
+}
+
+// fulfil lets change update the locked fulfilment id, given its locked
+// order, and saves it with the event change returns. The order then moves
+// on if all its fulfilments have.
+func (s *Service) fulfil(ctx context.Context, id, actorID int, change func(*models.Fulfilment, *models.Order) (*models.ShipmentEvent, error)) (*models.Fulfilment, error) {
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		f, err := s.fulfilments.GetFulfilmentByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		// The order is locked before the fulfilment, as in transition, so
+		// the two can't deadlock.
+		order, err := s.orders.LockOrder(ctx, f.OrderID)
+		if err != nil {
+			return err
+		}
+		f, err = s.fulfilments.LockFulfilment(ctx, id)
+		if err != nil {
+			return err
+		}
+		event, err := change(f, order)
+		if err != nil {
+			return err
+		}
+		if err := s.fulfilments.UpdateFulfilment(ctx, f); err != nil {
+			return err
+		}
+		event.FulfilmentID = f.ID
+		event.ActorID = &actorID
+		if event.OccurredAt.IsZero() {
+			event.OccurredAt = s.now()
+		}
+		if err := s.fulfilments.CreateShipmentEvent(ctx, event); err != nil {
+			return err
+		}
+		return s.aggregate(ctx, order, actorID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.fulfilments.GetFulfilmentByID(ctx, id)
+}
+
+// aggregate fulfils the locked order once all its fulfilments that aren't
+// cancelled have shipped, and delivers it once they are all delivered.
+func (s *Service) aggregate(ctx context.Context, order *models.Order, actorID int) error {
+	fulfilments, err := s.fulfilments.ListFulfilmentsByOrderID(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	shipped, delivered := true, true
+	for _, f := range fulfilments {
+		if f.Status == models.FulfilmentCancelled {
+			continue
+		}
+		shipped = shipped && (f.Status == models.FulfilmentShipped || f.Status == models.FulfilmentDelivered)
+		delivered = delivered && f.Status == models.FulfilmentDelivered
+	}
+	if shipped && order.Status == models.OrderPaid {
+		if order, err = s.transition(ctx, order.ID, models.OrderFulfilled, &actorID, nil); err != nil {
+			return err
+		}
+	}
+	if delivered && order.Status == models.OrderFulfilled {
+		_, err = s.transition(ctx, order.ID, models.OrderDelivered, &actorID, nil)
+	}
+	return err
+}
+
+// moveFulfilment changes f's status to to, if its current status allows
+// it.
+func (s *Service) moveFulfilment(f *models.Fulfilment, to models.FulfilmentStatus) error {
+	if !slices.Contains(fulfilmentMoves[f.Status], to) {
+		return apperror.Conflict("invalid_fulfilment_transition",
+			fmt.Sprintf("A fulfilment that is %s can't become %s", f.Status, to))
+	}
+	s.stamp(f, to)
+	return nil
+}
+
+// stamp sets f's status to to and records when it changed.
+func (s *Service) stamp(f *models.Fulfilment, to models.FulfilmentStatus) {
+	now := s.now()
+	switch to {
+	case models.FulfilmentAccepted:
+		f.AcceptedAt = &now
+	case models.FulfilmentShipped:
+		f.ShippedAt = &now
+	case models.FulfilmentDelivered:
+		f.DeliveredAt = &now
+	case models.FulfilmentCancelled:
+		f.CancelledAt = &now
+	}
+	f.Status = to
+}
+
+// syncFulfilments brings the order's fulfilments along when the order
+// itself moves to: cancelling it cancels them, and fulfilling or
+// delivering it by hand ships or delivers those that aren't there yet.
+// Orders with shipped fulfilments can't be cancelled.
+func (s *Service) syncFulfilments(ctx context.Context, order *models.Order, to models.OrderStatus, actorID *int) error {
+	var target models.FulfilmentStatus
+	switch to {
+	case models.OrderFulfilled:
+		target = models.FulfilmentShipped
+	case models.OrderDelivered:
+		target = models.FulfilmentDelivered
+	case models.OrderCancelled:
+		target = models.FulfilmentCancelled
+	default:
+		return nil
+	}
+	fulfilments, err := s.fulfilments.ListFulfilmentsByOrderID(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	for _, listed := range fulfilments {
+		if listed.Status == target || listed.Status == models.FulfilmentCancelled ||
+			(target == models.FulfilmentShipped && listed.Status == models.FulfilmentDelivered) {
+			continue
+		}
+		if target == models.FulfilmentCancelled &&
+			(listed.Status == models.FulfilmentShipped || listed.Status == models.FulfilmentDelivered) {
+			return errPartlyShipped
+		}
+		f, err := s.fulfilments.LockFulfilment(ctx, listed.ID)
+		if err != nil {
+			return err
+		}
+		s.stamp(f, target)
+		if err := s.fulfilments.UpdateFulfilment(ctx, f); err != nil {
+			return err
+		}
+		err = s.fulfilments.CreateShipmentEvent(ctx, &models.ShipmentEvent{
+			FulfilmentID: f.ID,
+			ActorID:      actorID,
+			Type:         fulfilmentEvents[target],
+			Description:  fmt.Sprintf("Order %d became %s", order.ID, to),
+			OccurredAt:   s.now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
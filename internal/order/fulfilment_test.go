@@ -0,0 +1,91 @@
+package order_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/payment"
+	"github.com/sudhir512kj/ecommerce_backend/internal/testutil"
+)
+
+func TestFulfilmentsMoveTheOrder(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	o := f.place(t, 1, 1)
+	f.pay(t, o, payment.CardSuccess)
+	fulfilments, err := f.Order.Fulfilments(ctx, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fulfilments) != 2 {
+		t.Fatalf("got %d fulfilments, want one per seller", len(fulfilments))
+	}
+
+	accept := func(id int) error { _, err := f.Order.Accept(ctx, id, f.admin.ID); return err }
+	ship := func(id int) error {
+		_, err := f.Order.Ship(ctx, id, f.admin.ID, models.ShipRequest{Carrier: "UPS", TrackingNumber: "1Z"})
+		return err
+	}
+	track := func(typ models.ShipmentEventType) func(int) error {
+		return func(id int) error {
+			_, err := f.Order.Track(ctx, id, f.admin.ID, models.ShipmentEventRequest{Type: typ})
+			return err
+		}
+	}
+	tests := []struct {
+		name string
+		// fulfilment is the index of the fulfilment the step changes.
+		fulfilment int
+		step       func(id int) error
+		wantCode   string
+		want       models.OrderStatus
+		wantPay    models.PaymentStatus
+	}{
+		{name: "first accepted", fulfilment: 0, step: accept, want: models.OrderPaid, wantPay: models.PaymentAuthorized},
+		{name: "tracked before shipping", fulfilment: 0, step: track(models.ShipmentInTransit), wantCode: "fulfilment_not_shipped", want: models.OrderPaid, wantPay: models.PaymentAuthorized},
+		{name: "first shipped", fulfilment: 0, step: ship, want: models.OrderPaid, wantPay: models.PaymentAuthorized},
+		{name: "second shipped before accepted", fulfilment: 1, step: ship, wantCode: "invalid_fulfilment_transition", want: models.OrderPaid, wantPay: models.PaymentAuthorized},
+		{name: "second accepted", fulfilment: 1, step: accept, want: models.OrderPaid, wantPay: models.PaymentAuthorized},
+		{name: "second shipped", fulfilment: 1, step: ship, want: models.OrderFulfilled, wantPay: models.PaymentCaptured},
+		{name: "first in transit", fulfilment: 0, step: track(models.ShipmentInTransit), want: models.OrderFulfilled, wantPay: models.PaymentCaptured},
+		{name: "first delivered", fulfilment: 0, step: track(models.ShipmentDelivered), want: models.OrderFulfilled, wantPay: models.PaymentCaptured},
+		{name: "second delivered", fulfilment: 1, step: track(models.ShipmentDelivered), want: models.OrderDelivered, wantPay: models.PaymentCaptured},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.step(fulfilments[tt.fulfilment].ID); testutil.Code(err) != tt.wantCode {
+				t.Fatalf("err = %v, want %s", err, tt.wantCode)
+			}
+			if got := f.order(t, o.ID).Status; got != tt.want {
+				t.Errorf("order = %s, want %s", got, tt.want)
+			}
+			if got := f.payment(t, o.ID).Status; got != tt.wantPay {
+				t.Errorf("payment = %s, want %s", got, tt.wantPay)
+			}
+		})
+	}
+}
+
+func TestCancelAfterShippingStarted(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	o := f.place(t, 1, 1)
+	f.pay(t, o, payment.CardSuccess)
+	fulfilments, err := f.Order.Fulfilments(ctx, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Order.Accept(ctx, fulfilments[0].ID, f.admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Order.Ship(ctx, fulfilments[0].ID, f.admin.ID, models.ShipRequest{Carrier: "UPS", TrackingNumber: "1Z"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Order.Transition(ctx, o.ID, models.OrderCancelled, f.admin.ID); testutil.Code(err) != "order_partly_shipped" {
+		t.Errorf("err = %v, want order_partly_shipped", err)
+	}
+	if got := f.payment(t, o.ID).Status; got != models.PaymentAuthorized {
+		t.Errorf("payment = %s, want authorized", got)
+	}
+}
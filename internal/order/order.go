@@ -0,0 +1,362 @@
+// Package order turns carts into orders, takes payment for them and moves
+// them through their statuses, holding, selling and restocking their stock
+// on the way.
+package order
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/cart"
+	"github.com/sudhir512kj/ecommerce_backend/internal/inventory"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/payment"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+)
+
+var (
+	errCartEmpty       = apperror.Conflict("cart_empty", "The cart is empty")
+	errCartUnavailable = apperror.Conflict("cart_items_unavailable", "Remove the items that are no longer for sale from the cart")
+	errInvalidAddress  = apperror.Validation("invalid_address", "The address does not exist or isn't yours")
+	errKeyReused       = apperror.Conflict("idempotency_key_reused", "The idempotency key was already used for a different checkout")
+	errOrderNotFound   = apperror.NotFound("order_not_found", "order not found")
+	errNotCancellable  = apperror.Conflict("order_not_cancellable", "Only orders that aren't paid yet can be cancelled")
+	errNoLongerPending = errors.New("order is no longer pending payment")
+
+	// errKeyTaken means a concurrent checkout with the same idempotency key
+	// placed its order first.
+	errKeyTaken = errors.New("idempotency key taken")
+)
+
+const (
+	// expiryInterval is how often unpaid orders past their expiry are
+	// cancelled.
+	expiryInterval = 30 * time.Second
+	expiryBatch    = 100
+)
+
+// transitions lists the statuses an order can move on to from each status.
+var transitions = map[models.OrderStatus][]models.OrderStatus{
+	models.OrderPendingPayment: {models.OrderPaid, models.OrderCancelled},
+	models.OrderPaid:           {models.OrderFulfilled, models.OrderCancelled},
+	models.OrderFulfilled:      {models.OrderDelivered},
+}
+
+// CanTransition reports whether an order can move from one status to
+// another.
+func CanTransition(from, to models.OrderStatus) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// Service places orders, splits them into fulfilments per seller and
+// changes their status. It is also the background worker that cancels
+// unpaid orders once their stock is no longer held.
+type Service struct {
+	orders      repository.OrderRepository
+	payments    repository.PaymentRepository
+	fulfilments repository.FulfilmentRepository
+	users       repository.UserRepository
+	carts       *cart.Service
+	inventory   *inventory.Service
+	provider    payment.Provider
+	tx          database.Transactor
+	now         func() time.Time
+}
+
+func NewService(
+	orders repository.OrderRepository,
+	payments repository.PaymentRepository,
+	fulfilments repository.FulfilmentRepository,
+	users repository.UserRepository,
+	carts *cart.Service,
+	inventory *inventory.Service,
+	provider payment.Provider,
+	tx database.Transactor,
+) *Service {
+	return &Service{
+		orders:      orders,
+		payments:    payments,
+		fulfilments: fulfilments,
+		users:       users,
+		carts:       carts,
+		inventory:   inventory,
+		provider:    provider,
+		tx:          tx,
+		now:         time.Now,
+	}
+}
+
+// Place orders the user's cart at its current prices and reserves the
+// stock until the order is paid. The cart is emptied. key makes retries
+// safe: if the user already placed an order with it, that order is returned
+// and created is false, even when the retry came in while the first
+// request was still placing it.
+func (s *Service) Place(ctx context.Context, userID int, key string, req models.CheckoutRequest) (order *models.Order, created bool, err error) {
+	if req.BillingAddressID == 0 {
+		req.BillingAddressID = req.ShippingAddressID
+	}
+	hash := requestHash(req)
+	if order, err := s.replay(ctx, userID, key, hash); order != nil || err != nil {
+		return order, false, err
+	}
+
+	shipping, billing, err := s.addresses(ctx, userID, req)
+	if err != nil {
+		return nil, false, err
+	}
+	err = s.carts.Checkout(ctx, userID, func(ctx context.Context, c *models.Cart) error {
+		order = &models.Order{
+			UserID:          userID,
+			Status:          models.OrderPendingPayment,
+			Currency:        c.Currency,
+			Totals:          c.Totals,
+			ShippingAddress: shipping,
+			BillingAddress:  billing,
+			IdempotencyKey:  key,
+			RequestHash:     hash,
+		}
+		return s.create(ctx, order, c)
+	})
+	if errors.Is(err, errKeyTaken) || errors.Is(err, errCartEmpty) {
+		// A concurrent checkout with the same key may have placed its order,
+		// and emptied the cart, while this one waited for the cart's lock.
+		replayed, replayErr := s.replay(ctx, userID, key, hash)
+		if replayed != nil || replayErr != nil {
+			return replayed, false, replayErr
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return order, true, nil
+}
+
+// replay returns the user's order placed with key, or nil if there is none.
+func (s *Service) replay(ctx context.Context, userID int, key, hash string) (*models.Order, error) {
+	order, err := s.orders.GetOrderByIdempotencyKey(ctx, userID, key)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if order.RequestHash != hash {
+		return nil, errKeyReused
+	}
+	return order, nil
+}
+
+// create copies the cart's items into the order, reserves their stock and
+// saves the order with a fulfilment per seller.
+func (s *Service) create(ctx context.Context, order *models.Order, c *models.Cart) error {
+	if len(c.Items) == 0 {
+		return errCartEmpty
+	}
+	wanted := make([]models.ReservationItem, len(c.Items))
+	order.Items = make([]models.OrderItem, len(c.Items))
+	for i, item := range c.Items {
+		if item.Status == models.CartItemUnavailable {
+			return errCartUnavailable
+		}
+		wanted[i] = models.ReservationItem{VariantID: item.VariantID, Quantity: item.Quantity}
+		order.Items[i] = models.OrderItem{
+			VariantID:   item.VariantID,
+			ProductID:   item.ProductID,
+			SellerID:    item.SellerID,
+			ProductName: item.ProductName,
+			SKU:         item.SKU,
+			Options:     item.Options,
+			UnitPrice:   item.UnitPrice,
+			Quantity:    item.Quantity,
+			LineTotal:   item.LineTotal,
+		}
+	}
+
+	reservation, err := s.inventory.Reserve(ctx, order.UserID, wanted)
+	if err != nil {
+		return err
+	}
+	order.ReservationID = &reservation.ID
+	order.ExpiresAt = reservation.ExpiresAt
+	err = s.orders.CreateOrder(ctx, order)
+	if errors.Is(err, apperror.ErrConflict) {
+		return errKeyTaken
+	}
+	if err != nil {
+		return err
+	}
+	return s.split(ctx, order)
+}
+
+// addresses copies the user's shipping and billing addresses.
+func (s *Service) addresses(ctx context.Context, userID int, req models.CheckoutRequest) (shipping, billing models.OrderAddress, err error) {
+	addresses, err := s.users.GetAddressesByUserID(ctx, userID)
+	if err != nil {
+		return shipping, billing, err
+	}
+	find := func(id int) (models.OrderAddress, bool) {
+		for _, a := range addresses {
+			if a.ID == id {
+				return models.OrderAddress{Street: a.Street, City: a.City, State: a.State, Country: a.Country, Zipcode: a.Zipcode}, true
+			}
+		}
+		return models.OrderAddress{}, false
+	}
+	shipping, ok := find(req.ShippingAddressID)
+	if !ok {
+		return shipping, billing, errInvalidAddress
+	}
+	billing, ok = find(req.BillingAddressID)
+	if !ok {
+		return shipping, billing, errInvalidAddress
+	}
+	return shipping, billing, nil
+}
+
+// requestHash identifies a checkout request, so a reused idempotency key
+// can be told apart from a retry.
+func requestHash(req models.CheckoutRequest) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d:%d", req.ShippingAddressID, req.BillingAddressID))
+	return hex.EncodeToString(sum[:])
+}
+
+// Transition moves an order on to the status to, if its current status
+// allows it. Paying sells the reserved stock; cancelling releases it, or
+// restocks it if the order was paid. Fulfilling captures the order's
+// payment and cancelling voids it. The order's fulfilments follow it.
+func (s *Service) Transition(ctx context.Context, id int, to models.OrderStatus, actorID int) (*models.Order, error) {
+	return s.transition(ctx, id, to, &actorID, nil)
+}
+
+// Cancel cancels one of the user's orders that isn't paid yet.
+func (s *Service) Cancel(ctx context.Context, id, userID int) (*models.Order, error) {
+	return s.transition(ctx, id, models.OrderCancelled, &userID, func(order *models.Order) error {
+		if order.UserID != userID {
+			return errOrderNotFound
+		}
+		if order.Status != models.OrderPendingPayment {
+			return errNotCancellable
+		}
+		return nil
+	})
+}
+
+// transition changes the locked order's status once check, if any, lets it.
+// A nil actor is the system.
+func (s *Service) transition(ctx context.Context, id int, to models.OrderStatus, actorID *int, check func(*models.Order) error) (*models.Order, error) {
+	var order *models.Order
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		order, err = s.orders.LockOrder(ctx, id)
+		if err != nil {
+			return err
+		}
+		if check != nil {
+			if err := check(order); err != nil {
+				return err
+			}
+		}
+		if !CanTransition(order.Status, to) {
+			return apperror.Conflict("invalid_order_transition",
+				fmt.Sprintf("An order that is %s can't become %s", order.Status, to))
+		}
+
+		if err := s.syncFulfilments(ctx, order, to, actorID); err != nil {
+			return err
+		}
+		if err := s.settle(ctx, order, to); err != nil {
+			return err
+		}
+		if err := s.moveStock(ctx, order, to, actorID); err != nil {
+			return err
+		}
+		now := s.now()
+		switch to {
+		case models.OrderPaid:
+			order.PaidAt = &now
+		case models.OrderFulfilled:
+			order.FulfilledAt = &now
+		case models.OrderDelivered:
+			order.DeliveredAt = &now
+		case models.OrderCancelled:
+			order.CancelledAt = &now
+		}
+		order.Status = to
+		return s.orders.UpdateOrderStatus(ctx, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// moveStock sells the order's reserved stock when it is paid, and gives it
+// back when it is cancelled.
+func (s *Service) moveStock(ctx context.Context, order *models.Order, to models.OrderStatus, actorID *int) error {
+	if order.ReservationID == nil {
+		return nil
+	}
+	reservationID := *order.ReservationID
+	switch {
+	case to == models.OrderPaid:
+		_, err := s.inventory.Commit(ctx, reservationID)
+		return err
+	case to == models.OrderCancelled && order.Status == models.OrderPaid:
+		return s.inventory.Restock(ctx, reservationID, nil, actorID, fmt.Sprintf("Order %d was cancelled", order.ID))
+	case to == models.OrderCancelled && actorID != nil:
+		_, err := s.inventory.Release(ctx, reservationID, *actorID)
+		if errors.Is(err, inventory.ErrReservationNotActive) {
+			return nil
+		}
+		return err
+	}
+	// Orders cancelled by the system have expired along with their
+	// reservation, which the inventory releases itself.
+	return nil
+}
+
+func (s *Service) Name() string { return "order-expiry" }
+
+// Run cancels unpaid orders past their expiry until ctx is done. It
+// implements worker.Worker.
+func (s *Service) Run(ctx context.Context) error {
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for {
+		s.expire(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) expire(ctx context.Context) {
+	orders, err := s.orders.ListExpiredOrders(ctx, s.now(), expiryBatch)
+	if err != nil {
+		slog.Error("list expired orders", slog.Any("error", err))
+		return
+	}
+	for _, order := range orders {
+		_, err := s.transition(ctx, order.ID, models.OrderCancelled, nil, func(order *models.Order) error {
+			// The order may have been paid in the meantime.
+			if order.Status != models.OrderPendingPayment {
+				return errNoLongerPending
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errNoLongerPending) {
+			slog.Error("cancel expired order", slog.Int("order_id", order.ID), slog.Any("error", err))
+		}
+	}
+}
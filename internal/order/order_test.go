@@ -0,0 +1,243 @@
+package order_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/app"
+	"github.com/sudhir512kj/ecommerce_backend/internal/cart"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/order"
+	"github.com/sudhir512kj/ecommerce_backend/internal/payment"
+	"github.com/sudhir512kj/ecommerce_backend/internal/testutil"
+)
+
+// fixture is an in-memory app with a buyer and two sellers, each selling
+// one variant with 10 units in stock.
+type fixture struct {
+	*app.App
+	buyer    *models.User
+	address  *models.Address
+	admin    *models.User
+	sellers  [2]*models.User
+	variants [2]*models.ProductVariant
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	a, err := app.New(testutil.Config(t), app.InMemory())
+	if err != nil {
+		t.Fatalf("new app: %v", err)
+	}
+	f := &fixture{App: a}
+	c := testutil.Catalog{Users: a.Users, Products: a.Products, Inventory: a.Stock}
+	f.buyer = c.User(t, "buyer@example.com", models.PermissionBuyer)
+	f.address = c.Address(t, f.buyer.ID)
+	f.admin = c.User(t, "admin@example.com", models.PermissionAdmin)
+	for i, price := range []int64{1000, 2500} {
+		f.sellers[i] = c.User(t, fmt.Sprintf("seller%d@example.com", i+1), models.PermissionSeller)
+		f.variants[i] = c.Variant(t, f.sellers[i].ID, fmt.Sprintf("SKU-%d", i+1), price, "USD")
+		warehouse := c.Warehouse(t, f.sellers[i].ID, fmt.Sprintf("W-%d", i+1))
+		c.Stock(t, f.variants[i].ID, warehouse.ID, 10)
+	}
+	return f
+}
+
+// fill puts quantities[i] units of the i-th seller's variant in the
+// buyer's cart.
+func (f *fixture) fill(t *testing.T, quantities ...int) {
+	t.Helper()
+	for i, quantity := range quantities {
+		if quantity == 0 {
+			continue
+		}
+		if _, err := f.Cart.AddItem(context.Background(), cart.Owner{UserID: f.buyer.ID}, f.variants[i].ID, quantity); err != nil {
+			t.Fatalf("add to cart: %v", err)
+		}
+	}
+}
+
+// place checks out a cart with quantities of the sellers' variants.
+func (f *fixture) place(t *testing.T, quantities ...int) *models.Order {
+	t.Helper()
+	f.fill(t, quantities...)
+	o, _, err := f.Order.Place(context.Background(), f.buyer.ID, fmt.Sprintf("key-%v", quantities), models.CheckoutRequest{ShippingAddressID: f.address.ID})
+	if err != nil {
+		t.Fatalf("place: %v", err)
+	}
+	return o
+}
+
+// pay pays for the order with the simulator's card number.
+func (f *fixture) pay(t *testing.T, o *models.Order, number string) *models.Payment {
+	t.Helper()
+	card := models.PaymentCard{Number: number, ExpMonth: 12, ExpYear: 2099, CVC: "123"}
+	p, err := f.Order.Pay(context.Background(), o.ID, f.buyer.ID, card)
+	if err != nil {
+		t.Fatalf("pay: %v", err)
+	}
+	return p
+}
+
+func (f *fixture) order(t *testing.T, id int) *models.Order {
+	t.Helper()
+	o, err := f.Orders.GetOrderByID(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// payment returns the order's latest payment.
+func (f *fixture) payment(t *testing.T, orderID int) *models.Payment {
+	t.Helper()
+	payments, err := f.Payments.ListPaymentsByOrderID(context.Background(), orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payments) == 0 {
+		t.Fatal("the order has no payment")
+	}
+	return payments[len(payments)-1]
+}
+
+// available returns the units of the i-th seller's variant available.
+func (f *fixture) available(t *testing.T, i int) int {
+	t.Helper()
+	levels, err := f.Stock.ListStock(context.Background(), []int{f.variants[i].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	available := 0
+	for _, level := range levels {
+		available += level.Available
+	}
+	return available
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to models.OrderStatus
+		want     bool
+	}{
+		{models.OrderPendingPayment, models.OrderPaid, true},
+		{models.OrderPendingPayment, models.OrderCancelled, true},
+		{models.OrderPendingPayment, models.OrderFulfilled, false},
+		{models.OrderPaid, models.OrderFulfilled, true},
+		{models.OrderPaid, models.OrderCancelled, true},
+		{models.OrderPaid, models.OrderDelivered, false},
+		{models.OrderFulfilled, models.OrderDelivered, true},
+		{models.OrderFulfilled, models.OrderCancelled, false},
+		{models.OrderDelivered, models.OrderCancelled, false},
+		{models.OrderCancelled, models.OrderPaid, false},
+	}
+	for _, tt := range tests {
+		if got := order.CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestPlaceIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	f.fill(t, 2, 1)
+	req := models.CheckoutRequest{ShippingAddressID: f.address.ID}
+	first, created, err := f.Order.Place(ctx, f.buyer.ID, "checkout-1", req)
+	if err != nil || !created {
+		t.Fatalf("place: created %v, err %v", created, err)
+	}
+	if first.Totals.Subtotal != 2*1000+2500 {
+		t.Errorf("subtotal = %d, want %d", first.Totals.Subtotal, 2*1000+2500)
+	}
+
+	other := &models.Address{UserID: f.buyer.ID, Street: "2 Side St", City: "Springfield", State: "IL", Country: "US", Zipcode: "62701"}
+	if err := f.Users.CreateAddress(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		key      string
+		req      models.CheckoutRequest
+		wantCode string
+	}{
+		{name: "retry", key: "checkout-1", req: req},
+		{name: "retry with the billing address given", key: "checkout-1", req: models.CheckoutRequest{ShippingAddressID: f.address.ID, BillingAddressID: f.address.ID}},
+		{name: "key reused for another checkout", key: "checkout-1", req: models.CheckoutRequest{ShippingAddressID: other.ID}, wantCode: "idempotency_key_reused"},
+		{name: "new key with the cart emptied", key: "checkout-2", req: req, wantCode: "cart_empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, created, err := f.Order.Place(ctx, f.buyer.ID, tt.key, tt.req)
+			if testutil.Code(err) != tt.wantCode {
+				t.Fatalf("err = %v, want %s", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if created || o.ID != first.ID {
+				t.Errorf("got order %d, created %v; want order %d again", o.ID, created, first.ID)
+			}
+		})
+	}
+	if got := f.available(t, 0); got != 8 {
+		t.Errorf("available = %d, want 8: the retries reserved stock again", got)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		name string
+		// paid pays for the order by hand first.
+		paid     bool
+		to       models.OrderStatus
+		wantCode string
+		// available is the stock of the first variant afterwards.
+		available int
+	}{
+		{name: "paid by hand", to: models.OrderPaid, available: 7},
+		{name: "cancelled before payment", to: models.OrderCancelled, available: 10},
+		{name: "cancelled after payment", paid: true, to: models.OrderCancelled, available: 10},
+		{name: "fulfilled by hand", paid: true, to: models.OrderFulfilled, available: 7},
+		{name: "fulfilled before payment", to: models.OrderFulfilled, wantCode: "invalid_order_transition", available: 7},
+		{name: "delivered before fulfilment", paid: true, to: models.OrderDelivered, wantCode: "invalid_order_transition", available: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t)
+			o := f.place(t, 3)
+			if tt.paid {
+				if _, err := f.Order.Transition(ctx, o.ID, models.OrderPaid, f.admin.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := f.Order.Transition(ctx, o.ID, tt.to, f.admin.ID)
+			if testutil.Code(err) != tt.wantCode {
+				t.Fatalf("err = %v, want %s", err, tt.wantCode)
+			}
+			if got := f.order(t, o.ID).Status; err == nil && got != tt.to {
+				t.Errorf("status = %s, want %s", got, tt.to)
+			}
+			if got := f.available(t, 0); got != tt.available {
+				t.Errorf("available = %d, want %d", got, tt.available)
+			}
+		})
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	o := f.place(t, 1)
+	if _, err := f.Order.Cancel(ctx, o.ID, f.admin.ID); testutil.Code(err) != "order_not_found" {
+		t.Errorf("cancel by someone else: err = %v, want order_not_found", err)
+	}
+	f.pay(t, o, payment.CardSuccess)
+	if _, err := f.Order.Cancel(ctx, o.ID, f.buyer.ID); testutil.Code(err) != "order_not_cancellable" {
+		t.Errorf("cancel after payment: err = %v, want order_not_cancellable", err)
+	}
+}
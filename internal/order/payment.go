@@ -0,0 +1,462 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/inventory"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/payment"
+	"github.com/sudhir512kj/ecommerce_backend/internal/worker"
+)
+
+var (
+	errNotPayable        = apperror.Conflict("order_not_payable", "Only orders that aren't paid yet can be paid")
+	errOrderExpired      = apperror.Conflict("order_expired", "The order's stock is no longer held; check out again")
+	errPaymentInProgress = apperror.Conflict("payment_in_progress", "The order already has a payment that isn't declined")
+	errNotRefundable     = apperror.Conflict("no_refundable_payment", "The order has no captured payment that covers the refund")
+	errRefundKeyReused   = apperror.Conflict("refund_key_reused", "A different refund was already made with the same key")
+	errOperationFailed   = apperror.Conflict("payment_operation_failed", "The payment provider refused the change")
+)
+
+const (
+	// operationInterval is how often pending payment operations are
+	// retried, once they are operationRetryAfter old.
+	operationInterval   = 30 * time.Second
+	operationRetryAfter = time.Minute
+	operationBatch      = 100
+)
+
+// paymentMoves lists the statuses a payment can move on to from each
+// status. Provider answers and webhooks that would move a payment anywhere
+// else are stale or repeated and are ignored.
+var paymentMoves = map[models.PaymentStatus][]models.PaymentStatus{
+	models.PaymentPending:        {models.PaymentRequiresAction, models.PaymentAuthorized, models.PaymentDeclined},
+	models.PaymentRequiresAction: {models.PaymentAuthorized, models.PaymentDeclined},
+	models.PaymentAuthorized:     {models.PaymentCaptured, models.PaymentVoided},
+	models.PaymentCaptured:       {models.PaymentRefunded},
+}
+
+// Pay authorizes a card payment of one of the user's unpaid orders. The
+// order is paid once the payment is authorized, which may be right away or
+// later, through a webhook. Declined payments are returned like any other;
+// the buyer can then pay with another card. Orders past their expiry can't
+// be paid, even before the expiry worker cancels them.
+func (s *Service) Pay(ctx context.Context, orderID, userID int, card models.PaymentCard) (*models.Payment, error) {
+	var p *models.Payment
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		order, err := s.orders.LockOrder(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		if order.UserID != userID {
+			return errOrderNotFound
+		}
+		if order.Status != models.OrderPendingPayment {
+			return errNotPayable
+		}
+		if order.ReservationID != nil && !s.now().Before(order.ExpiresAt) {
+			return errOrderExpired
+		}
+		payments, err := s.payments.ListPaymentsByOrderID(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		for _, existing := range payments {
+			if existing.Status != models.PaymentDeclined && existing.Status != models.PaymentVoided {
+				return errPaymentInProgress
+			}
+		}
+		p = &models.Payment{
+			OrderID:   order.ID,
+			UserID:    userID,
+			Provider:  s.provider.Name(),
+			Status:    models.PaymentPending,
+			Amount:    order.Totals.Total,
+			Currency:  order.Currency,
+			CardBrand: payment.Brand(card.Number),
+			CardLast4: payment.Last4(card.Number),
+		}
+		return s.payments.CreatePayment(ctx, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The provider is called outside a transaction, so the order isn't
+	// locked while it answers.
+	result, err := s.provider.Authorize(ctx, payment.AuthorizeRequest{
+		PaymentID: p.ID,
+		Amount:    p.Amount,
+		Currency:  p.Currency,
+		Card:      card,
+	})
+	if err != nil {
+		// Declined so the buyer can try again.
+		s.apply(ctx, p.ID, &payment.Result{Status: models.PaymentDeclined, DeclineCode: "provider_error"})
+		return nil, err
+	}
+	return s.apply(ctx, p.ID, result)
+}
+
+// Payments returns the payments of one of the user's orders, oldest first.
+func (s *Service) Payments(ctx context.Context, orderID, userID int) ([]*models.Payment, error) {
+	order, err := s.orders.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != userID {
+		return nil, errOrderNotFound
+	}
+	return s.payments.ListPaymentsByOrderID(ctx, orderID)
+}
+
+// Refund refunds amount of an order's captured payment. key names the
+// refund, e.g. the return it is for: a refund whose key was used before is
+// made at most once, and retrying it finishes it if its answer was lost.
+// Once the provider has refused a refund its key is free again, for
+// another try of any amount. The provider is called after the refund is
+// recorded, so Refund must not be called in a transaction.
+func (s *Service) Refund(ctx context.Context, orderID int, amount int64, key string) (*models.Payment, error) {
+	var op *models.PaymentOperation
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		op, err = s.payments.GetOperationByKey(ctx, key)
+		if err == nil {
+			if op.Kind != models.OperationRefund || op.Amount != amount {
+				return errRefundKeyReused
+			}
+			return nil
+		}
+		if !errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+
+		payments, err := s.payments.ListPaymentsByOrderID(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		for _, listed := range payments {
+			if listed.Status != models.PaymentCaptured {
+				continue
+			}
+			p, err := s.payments.LockPayment(ctx, listed.ID)
+			if err != nil {
+				return err
+			}
+			refundable, err := s.refundable(ctx, p)
+			if err != nil {
+				return err
+			}
+			if refundable < amount {
+				continue
+			}
+			op, err = s.operate(ctx, p, models.OperationRefund, amount, key)
+			return err
+		}
+		return errNotRefundable
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.perform(ctx, op.ID)
+}
+
+// refundable returns how much of the locked payment p is left to refund,
+// leaving out the refunds still waiting for the provider.
+func (s *Service) refundable(ctx context.Context, p *models.Payment) (int64, error) {
+	if p.Status != models.PaymentCaptured {
+		return 0, nil
+	}
+	ops, err := s.payments.ListOperationsByPaymentID(ctx, p.ID)
+	if err != nil {
+		return 0, err
+	}
+	left := p.CapturedAmount - p.RefundedAmount
+	for _, op := range ops {
+		if op.Kind == models.OperationRefund && op.Status == models.OperationPending {
+			left -= op.Amount
+		}
+	}
+	return left, nil
+}
+
+// HandleWebhook verifies a webhook from the payment provider and applies
+// the change it announces. Events are handled once; redelivered ones are
+// ignored.
+func (s *Service) HandleWebhook(ctx context.Context, header http.Header, body []byte) error {
+	event, err := s.provider.VerifyWebhook(header, body)
+	if err != nil {
+		return err
+	}
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		// Webhooks can overtake the answer to Authorize, when the reference
+		// isn't saved yet. The not found error makes the provider retry.
+		p, err := s.payments.LockPaymentByReference(ctx, s.provider.Name(), event.Result.Reference)
+		if err != nil {
+			return err
+		}
+		fresh, err := s.payments.RecordEvent(ctx, s.provider.Name(), event.ID, p.ID, event.Type)
+		if err != nil || !fresh {
+			return err
+		}
+		return s.update(ctx, p, &event.Result)
+	})
+}
+
+// apply saves a provider's answer about a payment. Failures are logged, as
+// the payment is caught up by the provider's webhook.
+func (s *Service) apply(ctx context.Context, paymentID int, result *payment.Result) (*models.Payment, error) {
+	var p *models.Payment
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		p, err = s.payments.LockPayment(ctx, paymentID)
+		if err != nil {
+			return err
+		}
+		return s.update(ctx, p, result)
+	})
+	if err != nil {
+		slog.Error("save payment result", slog.Int("payment_id", paymentID), slog.Any("error", err))
+		return nil, err
+	}
+	return p, nil
+}
+
+// update moves the locked payment p to the state in result, unless result
+// is stale. A newly authorized payment pays its order, or is voided if the
+// order can no longer be paid: it was cancelled, or its stock is no longer
+// held.
+func (s *Service) update(ctx context.Context, p *models.Payment, result *payment.Result) error {
+	if !newer(p, result) {
+		return nil
+	}
+	if result.Reference != "" {
+		p.Reference = result.Reference
+	}
+	p.Status = result.Status
+	p.CapturedAmount = result.CapturedAmount
+	p.RefundedAmount = result.RefundedAmount
+	p.DeclineCode = result.DeclineCode
+	p.ActionURL = result.ActionURL
+	if err := s.payments.UpdatePayment(ctx, p); err != nil {
+		return err
+	}
+	if p.Status != models.PaymentAuthorized {
+		return nil
+	}
+
+	_, err := s.transition(ctx, p.OrderID, models.OrderPaid, nil, func(order *models.Order) error {
+		if order.Status != models.OrderPendingPayment {
+			return errNoLongerPending
+		}
+		return nil
+	})
+	if !errors.Is(err, errNoLongerPending) && !errors.Is(err, inventory.ErrReservationNotActive) &&
+		!errors.Is(err, inventory.ErrReservationExpired) {
+		return err
+	}
+	// The order expired or was cancelled while the buyer paid. An expired
+	// reservation the expiry worker hasn't released yet can't be sold
+	// either.
+	return s.settleLater(ctx, p, models.OperationVoid, 0)
+}
+
+// newer reports whether result is a later state of p than p's own.
+func newer(p *models.Payment, result *payment.Result) bool {
+	// The first answer carries the reference, even if the payment is
+	// still pending.
+	if p.Reference == "" {
+		return true
+	}
+	if p.Status == models.PaymentCaptured && result.Status == models.PaymentCaptured {
+		return result.RefundedAmount > p.RefundedAmount
+	}
+	return slices.Contains(paymentMoves[p.Status], result.Status)
+}
+
+// settle captures the order's authorized payment when it is fulfilled and
+// voids it when the order is cancelled. Orders marked paid by hand have no
+// payment to settle.
+func (s *Service) settle(ctx context.Context, order *models.Order, to models.OrderStatus) error {
+	if to != models.OrderFulfilled && to != models.OrderCancelled {
+		return nil
+	}
+	payments, err := s.payments.ListPaymentsByOrderID(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	for _, listed := range payments {
+		if listed.Status != models.PaymentAuthorized {
+			continue
+		}
+		p, err := s.payments.LockPayment(ctx, listed.ID)
+		if err != nil {
+			return err
+		}
+		if to == models.OrderFulfilled {
+			err = s.settleLater(ctx, p, models.OperationCapture, p.Amount)
+		} else {
+			err = s.settleLater(ctx, p, models.OperationVoid, 0)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settleLater records a capture or void of the locked payment p, which the
+// provider is asked for once the transaction commits. A payment is
+// captured or voided once, whichever comes first.
+func (s *Service) settleLater(ctx context.Context, p *models.Payment, kind models.PaymentOperationKind, amount int64) error {
+	key := fmt.Sprintf("settle-%d", p.ID)
+	_, err := s.payments.GetOperationByKey(ctx, key)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, apperror.ErrNotFound) {
+		return err
+	}
+	op, err := s.operate(ctx, p, kind, amount, key)
+	if err != nil {
+		return err
+	}
+	database.AfterCommit(ctx, func(ctx context.Context) {
+		// Failures are left to the operations worker.
+		_, _ = s.perform(ctx, op.ID)
+	})
+	return nil
+}
+
+// operate records a pending operation of the locked payment p.
+func (s *Service) operate(ctx context.Context, p *models.Payment, kind models.PaymentOperationKind, amount int64, key string) (*models.PaymentOperation, error) {
+	op := &models.PaymentOperation{
+		PaymentID: p.ID,
+		Kind:      kind,
+		Amount:    amount,
+		Status:    models.OperationPending,
+		Key:       key,
+	}
+	if err := s.payments.CreateOperation(ctx, op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// perform asks the provider to carry out a pending operation and saves the
+// answer. The idempotency key is the payment's ID and the operation's, so
+// asking again after a lost answer doesn't repeat the operation. Errors
+// the provider returns fail the operation; any other error, such as a
+// timeout, leaves it pending for the operations worker to retry. It must
+// not be called in a transaction.
+func (s *Service) perform(ctx context.Context, id int) (*models.Payment, error) {
+	var op *models.PaymentOperation
+	var p *models.Payment
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if op, err = s.payments.LockOperation(ctx, id); err != nil {
+			return err
+		}
+		p, err = s.payments.LockPayment(ctx, op.PaymentID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch op.Status {
+	case models.OperationSucceeded:
+		return p, nil
+	case models.OperationFailed:
+		return nil, errOperationFailed
+	}
+
+	key := fmt.Sprintf("%d-%d", p.ID, op.ID)
+	var result *payment.Result
+	var callErr error
+	switch op.Kind {
+	case models.OperationCapture:
+		result, callErr = s.provider.Capture(ctx, p.Reference, op.Amount, key)
+	case models.OperationVoid:
+		result, callErr = s.provider.Void(ctx, p.Reference, key)
+	case models.OperationRefund:
+		result, callErr = s.provider.Refund(ctx, p.Reference, op.Amount, key)
+	}
+	var refused *apperror.Error
+	if callErr != nil && !errors.As(callErr, &refused) {
+		slog.Warn("payment operation", slog.Int("operation_id", op.ID), slog.Any("error", callErr))
+		return nil, callErr
+	}
+
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if op, err = s.payments.LockOperation(ctx, id); err != nil {
+			return err
+		}
+		if p, err = s.payments.LockPayment(ctx, op.PaymentID); err != nil {
+			return err
+		}
+		// A retry of the operation may have saved the answer first.
+		if op.Status != models.OperationPending {
+			return nil
+		}
+		op.Status = models.OperationSucceeded
+		if callErr != nil {
+			op.Status = models.OperationFailed
+			op.Error = callErr.Error()
+		}
+		if err := s.payments.UpdateOperation(ctx, op); err != nil || callErr != nil {
+			return err
+		}
+		return s.update(ctx, p, result)
+	})
+	if err != nil {
+		slog.Error("save payment operation", slog.Int("operation_id", id), slog.Any("error", err))
+		return nil, err
+	}
+	if op.Status == models.OperationFailed {
+		return nil, callErr
+	}
+	return p, nil
+}
+
+// OperationsWorker returns the worker that retries the operations whose
+// provider call failed or whose answer was lost.
+func (s *Service) OperationsWorker() worker.Worker {
+	return worker.Func{WorkerName: "payment-operations", Fn: s.runOperations}
+}
+
+func (s *Service) runOperations(ctx context.Context) error {
+	ticker := time.NewTicker(operationInterval)
+	defer ticker.Stop()
+
+	for {
+		s.retryOperations(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// retryOperations performs the operations pending for longer than
+// operationRetryAfter, which leaves the fresh ones to the requests that
+// recorded them.
+func (s *Service) retryOperations(ctx context.Context) {
+	ops, err := s.payments.ListPendingOperations(ctx, s.now().Add(-operationRetryAfter), operationBatch)
+	if err != nil {
+		slog.Error("list pending payment operations", slog.Any("error", err))
+		return
+	}
+	for _, op := range ops {
+		_, _ = s.perform(ctx, op.ID)
+	}
+}
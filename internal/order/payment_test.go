@@ -0,0 +1,266 @@
+package order_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/payment"
+	"github.com/sudhir512kj/ecommerce_backend/internal/testutil"
+)
+
+// webhook signs event as the simulator does.
+func (f *fixture) webhook(t *testing.T, event payment.Event) (http.Header, []byte) {
+	t.Helper()
+	body, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(f.Config.Current().Payments.WebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	header := http.Header{payment.SignatureHeader: {"t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))}}
+	return header, body
+}
+
+func TestPay(t *testing.T) {
+	tests := []struct {
+		card      string
+		want      models.PaymentStatus
+		wantOrder models.OrderStatus
+	}{
+		{payment.CardSuccess, models.PaymentAuthorized, models.OrderPaid},
+		{payment.CardDeclined, models.PaymentDeclined, models.OrderPendingPayment},
+		{payment.CardChallenge, models.PaymentRequiresAction, models.OrderPendingPayment},
+		{payment.CardDelayed, models.PaymentPending, models.OrderPendingPayment},
+	}
+	for _, tt := range tests {
+		t.Run(tt.card, func(t *testing.T) {
+			f := newFixture(t)
+			o := f.place(t, 1)
+			if p := f.pay(t, o, tt.card); p.Status != tt.want {
+				t.Errorf("payment = %s, want %s", p.Status, tt.want)
+			}
+			if got := f.order(t, o.ID).Status; got != tt.wantOrder {
+				t.Errorf("order = %s, want %s", got, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestPayAgainOnlyAfterDecline(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	o := f.place(t, 1)
+	f.pay(t, o, payment.CardDeclined)
+	f.pay(t, o, payment.CardDelayed)
+	card := models.PaymentCard{Number: payment.CardSuccess, ExpMonth: 12, ExpYear: 2099, CVC: "123"}
+	if _, err := f.Order.Pay(ctx, o.ID, f.buyer.ID, card); testutil.Code(err) != "payment_in_progress" {
+		t.Errorf("err = %v, want payment_in_progress", err)
+	}
+}
+
+// expireSoon makes the orders placed from now on expire after d.
+func (f *fixture) expireSoon(d time.Duration) {
+	f.Config.Current().Inventory.ReservationTTL = d
+}
+
+func TestPayExpiredOrder(t *testing.T) {
+	f := newFixture(t)
+	f.expireSoon(time.Millisecond)
+	o := f.place(t, 1)
+	time.Sleep(5 * time.Millisecond)
+	// The expiry worker hasn't cancelled the order yet.
+	card := models.PaymentCard{Number: payment.CardSuccess, ExpMonth: 12, ExpYear: 2099, CVC: "123"}
+	if _, err := f.Order.Pay(context.Background(), o.ID, f.buyer.ID, card); testutil.Code(err) != "order_expired" {
+		t.Errorf("err = %v, want order_expired", err)
+	}
+}
+
+func TestAuthorizedAfterExpiryIsVoided(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	f.expireSoon(100 * time.Millisecond)
+	o := f.place(t, 1)
+	p := f.pay(t, o, payment.CardChallenge)
+	time.Sleep(150 * time.Millisecond)
+	// The buyer completes the challenge once the reservation has expired,
+	// before the expiry worker releases it.
+	if err := f.Gateway.(*payment.Simulator).Complete(p.Reference, true); err != nil {
+		t.Fatal(err)
+	}
+	header, body := f.webhook(t, payment.Event{ID: "evt_1", Type: "payment.authorized", Result: payment.Result{
+		Reference: p.Reference,
+		Status:    models.PaymentAuthorized,
+		Amount:    p.Amount,
+	}})
+	if err := f.Order.HandleWebhook(ctx, header, body); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.payment(t, o.ID).Status; got != models.PaymentVoided {
+		t.Errorf("payment = %s, want voided", got)
+	}
+	if got := f.order(t, o.ID).Status; got != models.OrderPendingPayment {
+		t.Errorf("order = %s, want it left to the expiry worker", got)
+	}
+}
+
+func TestSettle(t *testing.T) {
+	tests := []struct {
+		name        string
+		to          models.OrderStatus
+		want        models.PaymentStatus
+		wantCapture int64
+	}{
+		{name: "fulfilled", to: models.OrderFulfilled, want: models.PaymentCaptured, wantCapture: 2 * 1000},
+		{name: "cancelled", to: models.OrderCancelled, want: models.PaymentVoided},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t)
+			o := f.place(t, 2)
+			f.pay(t, o, payment.CardSuccess)
+			if _, err := f.Order.Transition(ctx, o.ID, tt.to, f.admin.ID); err != nil {
+				t.Fatal(err)
+			}
+			p := f.payment(t, o.ID)
+			if p.Status != tt.want || p.CapturedAmount != tt.wantCapture {
+				t.Errorf("payment = %s, captured %d; want %s, captured %d", p.Status, p.CapturedAmount, tt.want, tt.wantCapture)
+			}
+			ops, err := f.Payments.ListOperationsByPaymentID(ctx, p.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ops) != 1 || ops[0].Status != models.OperationSucceeded {
+				t.Errorf("operations = %+v, want one that succeeded", ops)
+			}
+		})
+	}
+}
+
+func TestRefund(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	o := f.place(t, 3)
+	f.pay(t, o, payment.CardSuccess)
+	if _, err := f.Order.Transition(ctx, o.ID, models.OrderFulfilled, f.admin.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		key          string
+		amount       int64
+		wantCode     string
+		wantRefunded int64
+	}{
+		{name: "partial", key: "return-1", amount: 1000, wantRefunded: 1000},
+		{name: "retried", key: "return-1", amount: 1000, wantRefunded: 1000},
+		{name: "key reused for another amount", key: "return-1", amount: 500, wantCode: "refund_key_reused", wantRefunded: 1000},
+		{name: "more than is left", key: "return-2", amount: 2500, wantCode: "no_refundable_payment", wantRefunded: 1000},
+		{name: "the rest", key: "return-2", amount: 2000, wantRefunded: 3000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.Order.Refund(ctx, o.ID, tt.amount, tt.key)
+			if testutil.Code(err) != tt.wantCode {
+				t.Fatalf("err = %v, want %s", err, tt.wantCode)
+			}
+			if got := f.payment(t, o.ID).RefundedAmount; got != tt.wantRefunded {
+				t.Errorf("refunded = %d, want %d", got, tt.wantRefunded)
+			}
+		})
+	}
+	if got := f.payment(t, o.ID).Status; got != models.PaymentRefunded {
+		t.Errorf("payment = %s, want refunded", got)
+	}
+}
+
+func TestHandleWebhook(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	o := f.place(t, 1)
+	p := f.pay(t, o, payment.CardDelayed)
+
+	authorized := payment.Event{ID: "evt_1", Type: "payment.authorized", Result: payment.Result{
+		Reference: p.Reference,
+		Status:    models.PaymentAuthorized,
+		Amount:    p.Amount,
+	}}
+	stale := payment.Event{ID: "evt_0", Type: "payment.declined", Result: payment.Result{
+		Reference:   p.Reference,
+		Status:      models.PaymentDeclined,
+		Amount:      p.Amount,
+		DeclineCode: "card_declined",
+	}}
+	unknown := payment.Event{ID: "evt_2", Type: "payment.authorized", Result: payment.Result{Reference: "sim_unknown"}}
+
+	tests := []struct {
+		name      string
+		event     payment.Event
+		tamper    bool
+		wantCode  string
+		want      models.PaymentStatus
+		wantOrder models.OrderStatus
+	}{
+		{name: "forged", event: authorized, tamper: true, wantCode: "invalid_webhook_signature", want: models.PaymentPending, wantOrder: models.OrderPendingPayment},
+		{name: "unknown payment", event: unknown, wantCode: "payment_not_found", want: models.PaymentPending, wantOrder: models.OrderPendingPayment},
+		{name: "authorized", event: authorized, want: models.PaymentAuthorized, wantOrder: models.OrderPaid},
+		{name: "redelivered", event: authorized, want: models.PaymentAuthorized, wantOrder: models.OrderPaid},
+		{name: "out of order", event: stale, want: models.PaymentAuthorized, wantOrder: models.OrderPaid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, body := f.webhook(t, tt.event)
+			if tt.tamper {
+				body = append(body, ' ')
+			}
+			if err := f.Order.HandleWebhook(ctx, header, body); testutil.Code(err) != tt.wantCode {
+				t.Fatalf("err = %v, want %s", err, tt.wantCode)
+			}
+			if got := f.payment(t, o.ID).Status; got != tt.want {
+				t.Errorf("payment = %s, want %s", got, tt.want)
+			}
+			if got := f.order(t, o.ID).Status; got != tt.wantOrder {
+				t.Errorf("order = %s, want %s", got, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestLateAuthorizationIsVoided(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	o := f.place(t, 1)
+	p := f.pay(t, o, payment.CardChallenge)
+	if _, err := f.Order.Transition(ctx, o.ID, models.OrderCancelled, f.admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	// The buyer completes the challenge after the order was cancelled.
+	if err := f.Gateway.(*payment.Simulator).Complete(p.Reference, true); err != nil {
+		t.Fatal(err)
+	}
+	header, body := f.webhook(t, payment.Event{ID: "evt_1", Type: "payment.authorized", Result: payment.Result{
+		Reference: p.Reference,
+		Status:    models.PaymentAuthorized,
+		Amount:    p.Amount,
+	}})
+	if err := f.Order.HandleWebhook(ctx, header, body); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.payment(t, o.ID).Status; got != models.PaymentVoided {
+		t.Errorf("payment = %s, want voided", got)
+	}
+	if got := f.order(t, o.ID).Status; got != models.OrderCancelled {
+		t.Errorf("order = %s, want cancelled", got)
+	}
+}
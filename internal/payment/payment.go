@@ -0,0 +1,112 @@
+// Package payment talks to payment providers: it authorizes, captures,
+// voids and refunds card payments and verifies the webhooks providers send
+// when a payment changes.
+package payment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+var (
+	// ErrUnknownPayment is returned for references the provider doesn't know.
+	ErrUnknownPayment = apperror.NotFound("payment_not_found", "The payment provider doesn't know the payment")
+	// ErrInvalidAmount is returned when capturing or refunding more than is
+	// left of a payment.
+	ErrInvalidAmount = apperror.Validation("invalid_payment_amount", "The amount is more than is left of the payment")
+	// ErrInvalidState is returned when a payment can't be captured, voided or
+	// refunded in its current state.
+	ErrInvalidState = apperror.Conflict("invalid_payment_state", "The payment can't be changed in its current state")
+)
+
+// Provider is a payment service provider. Calls return the payment's state
+// at the provider; changes that happen later, such as a challenge being
+// completed, are announced through webhooks, which may arrive more than
+// once and out of order.
+//
+// Capture, Void and Refund take an idempotency key. A call that repeats the
+// key of an earlier successful one changes nothing and gets the earlier
+// answer, so calls whose answer was lost can be made again.
+type Provider interface {
+	// Name identifies the provider in stored payments and webhook URLs.
+	Name() string
+	// Authorize holds the amount on the card. The result may be authorized,
+	// declined, pending until a webhook says otherwise, or require the
+	// buyer to complete a challenge at the result's ActionURL.
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error)
+	// Capture takes amount of an authorized payment.
+	Capture(ctx context.Context, reference string, amount int64, key string) (*Result, error)
+	// Void releases the hold of an authorized payment that wasn't captured.
+	Void(ctx context.Context, reference string, key string) (*Result, error)
+	// Refund gives back amount of a captured payment. Payments can be
+	// refunded in parts, up to what was captured.
+	Refund(ctx context.Context, reference string, amount int64, key string) (*Result, error)
+	// VerifyWebhook checks that a webhook was sent by the provider and
+	// returns its event.
+	VerifyWebhook(header http.Header, body []byte) (*Event, error)
+}
+
+// AuthorizeRequest is a card payment for one of our payments. PaymentID
+// lets the provider tell retries apart from new payments.
+type AuthorizeRequest struct {
+	PaymentID int
+	Amount    int64
+	Currency  string
+	Card      models.PaymentCard
+}
+
+// Result is a payment's state at the provider.
+type Result struct {
+	Reference      string               `json:"reference"`
+	Status         models.PaymentStatus `json:"status"`
+	Amount         int64                `json:"amount"`
+	CapturedAmount int64                `json:"captured_amount"`
+	RefundedAmount int64                `json:"refunded_amount"`
+	DeclineCode    string               `json:"decline_code,omitempty"`
+	ActionURL      string               `json:"action_url,omitempty"`
+}
+
+// Event is a webhook announcing a payment's new state. ID is unique per
+// event, so redelivered events can be recognised.
+type Event struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Result Result `json:"payment"`
+}
+
+// NewProvider returns the provider selected by conf, whose routes, if any,
+// are served under prefix.
+func NewProvider(conf *config.Payments, prefix string) (Provider, error) {
+	switch conf.Provider {
+	case "simulated":
+		return NewSimulator(conf, prefix), nil
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", conf.Provider)
+}
+
+// Brand names the card network of number from its leading digits.
+func Brand(number string) string {
+	switch {
+	case len(number) < 2:
+		return "unknown"
+	case number[0] == '4':
+		return "visa"
+	case number[0] == '5' || number[:2] >= "22" && number[:2] <= "27":
+		return "mastercard"
+	case number[:2] == "34" || number[:2] == "37":
+		return "amex"
+	case number[:2] == "60" || number[:2] == "65":
+		return "discover"
+	}
+	return "unknown"
+}
+
+// Last4 returns the last four digits of number.
+func Last4(number string) string {
+	return number[max(0, len(number)-4):]
+}
@@ -0,0 +1,378 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/worker"
+)
+
+// Test cards of the simulated provider. Any other valid card number is
+// authorized.
+const (
+	CardSuccess           = "4242424242424242"
+	CardDeclined          = "4000000000000002"
+	CardInsufficientFunds = "4000000000009995"
+	// CardChallenge needs the buyer to complete a challenge at the
+	// payment's ActionURL.
+	CardChallenge = "4000000000003220"
+	// CardDelayed stays pending until the webhook authorizing it is sent,
+	// after the configured delay.
+	CardDelayed = "4000000000000077"
+)
+
+// SignatureHeader carries the signature of the simulated provider's
+// webhooks: t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">.
+const SignatureHeader = "Simulator-Signature"
+
+// ChallengePath is where the buyer completes a challenge, relative to the
+// prefix given to NewSimulator; :reference is the payment's reference.
+const ChallengePath = "/payments/simulator/challenges/:reference"
+
+const (
+	// signatureTolerance is how old a webhook may be before it is refused
+	// as a replay.
+	signatureTolerance = 5 * time.Minute
+	deliveryInterval   = time.Second
+	deliveryTimeout    = 10 * time.Second
+	maxDeliveries      = 8
+)
+
+var (
+	errInvalidSignature = apperror.Unauthorized("invalid_webhook_signature", "The webhook signature is invalid or too old")
+	errInvalidWebhook   = apperror.Validation("invalid_webhook", "The webhook body is not a payment event")
+)
+
+// Simulator is a payment provider that runs in the process, for development
+// and tests. The card number decides what happens to a payment; see the
+// Card constants. It remembers payments only until the process exits.
+//
+// Its webhooks are posted to the configured URL by the simulator's Worker,
+// which must be running. Failed deliveries are retried with backoff.
+type Simulator struct {
+	secret []byte
+	url    string
+	delay  time.Duration
+	prefix string
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	payments  map[string]*Result
+	byPayment map[int]string
+	// done holds the answers to captures, voids and refunds by key.
+	done   map[string]*Result
+	outbox []*delivery
+}
+
+// delivery is a webhook waiting to be sent at due. apply, if set, changes
+// the payment when the webhook is first due.
+type delivery struct {
+	due      time.Time
+	attempts int
+	event    *Event
+	apply    func()
+}
+
+// NewSimulator returns a simulated provider whose challenge route is served
+// under prefix, e.g. /api/v2.
+func NewSimulator(conf *config.Payments, prefix string) *Simulator {
+	return &Simulator{
+		secret:    []byte(conf.WebhookSecret),
+		url:       conf.Simulator.WebhookURL,
+		delay:     conf.Simulator.WebhookDelay,
+		prefix:    prefix,
+		client:    &http.Client{Timeout: deliveryTimeout},
+		now:       time.Now,
+		payments:  make(map[string]*Result),
+		byPayment: make(map[int]string),
+		done:      make(map[string]*Result),
+	}
+}
+
+func (s *Simulator) Name() string { return "simulated" }
+
+func (s *Simulator) Authorize(_ context.Context, req AuthorizeRequest) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Retries of the same payment get the same answer.
+	if ref, ok := s.byPayment[req.PaymentID]; ok {
+		res := *s.payments[ref]
+		return &res, nil
+	}
+	res := &Result{Reference: "sim_" + randomHex(12), Status: models.PaymentAuthorized, Amount: req.Amount}
+	s.payments[res.Reference] = res
+	s.byPayment[req.PaymentID] = res.Reference
+
+	now := s.now()
+	expiry := time.Date(req.Card.ExpYear, time.Month(req.Card.ExpMonth)+1, 1, 0, 0, 0, 0, time.UTC)
+	switch {
+	case !now.Before(expiry):
+		s.decline(res, "expired_card")
+	case req.Card.Number == CardDeclined:
+		s.decline(res, "card_declined")
+	case req.Card.Number == CardInsufficientFunds:
+		s.decline(res, "insufficient_funds")
+	case req.Card.Number == CardChallenge:
+		res.Status = models.PaymentRequiresAction
+		res.ActionURL = s.prefix + strings.Replace(ChallengePath, ":reference", res.Reference, 1)
+	case req.Card.Number == CardDelayed:
+		res.Status = models.PaymentPending
+		s.send(now.Add(s.delay), "payment.authorized", res, func() { res.Status = models.PaymentAuthorized })
+	default:
+		s.send(now, "payment.authorized", res, nil)
+	}
+	cp := *res
+	return &cp, nil
+}
+
+func (s *Simulator) decline(res *Result, code string) {
+	res.Status = models.PaymentDeclined
+	res.DeclineCode = code
+	s.send(s.now(), "payment.declined", res, nil)
+}
+
+// Complete finishes the challenge of a payment, as the buyer's bank would:
+// approved payments are authorized, the others declined.
+func (s *Simulator) Complete(reference string, approve bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, ok := s.payments[reference]
+	if !ok {
+		return ErrUnknownPayment
+	}
+	if res.Status != models.PaymentRequiresAction {
+		return ErrInvalidState
+	}
+	res.ActionURL = ""
+	if approve {
+		res.Status = models.PaymentAuthorized
+		s.send(s.now(), "payment.authorized", res, nil)
+	} else {
+		s.decline(res, "authentication_failed")
+	}
+	return nil
+}
+
+func (s *Simulator) Capture(_ context.Context, reference string, amount int64, key string) (*Result, error) {
+	return s.change(reference, key, models.PaymentAuthorized, "payment.captured", func(res *Result) error {
+		if amount <= 0 || amount > res.Amount {
+			return ErrInvalidAmount
+		}
+		res.Status = models.PaymentCaptured
+		res.CapturedAmount = amount
+		return nil
+	})
+}
+
+func (s *Simulator) Void(_ context.Context, reference string, key string) (*Result, error) {
+	return s.change(reference, key, models.PaymentAuthorized, "payment.voided", func(res *Result) error {
+		res.Status = models.PaymentVoided
+		return nil
+	})
+}
+
+func (s *Simulator) Refund(_ context.Context, reference string, amount int64, key string) (*Result, error) {
+	return s.change(reference, key, models.PaymentCaptured, "payment.refunded", func(res *Result) error {
+		if amount <= 0 || amount > res.CapturedAmount-res.RefundedAmount {
+			return ErrInvalidAmount
+		}
+		res.RefundedAmount += amount
+		if res.RefundedAmount == res.CapturedAmount {
+			res.Status = models.PaymentRefunded
+		}
+		return nil
+	})
+}
+
+// change applies fn to a payment in status from and announces it with a
+// webhook of type event. Repeated keys get the answer of their first call.
+func (s *Simulator) change(reference, key string, from models.PaymentStatus, event string, fn func(*Result) error) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if res, ok := s.done[key]; ok {
+		cp := *res
+		return &cp, nil
+	}
+	res, ok := s.payments[reference]
+	if !ok {
+		return nil, ErrUnknownPayment
+	}
+	if res.Status != from {
+		return nil, ErrInvalidState
+	}
+	updated := *res
+	if err := fn(&updated); err != nil {
+		return nil, err
+	}
+	*res = updated
+	s.send(s.now(), event, res, nil)
+	answer := updated
+	s.done[key] = &answer
+	return &updated, nil
+}
+
+// send queues a webhook about res. The caller holds s.mu.
+func (s *Simulator) send(due time.Time, eventType string, res *Result, apply func()) {
+	event := &Event{ID: "evt_" + randomHex(12), Type: eventType}
+	d := &delivery{due: due, event: event}
+	if apply == nil {
+		event.Result = *res
+	} else {
+		d.apply = func() {
+			apply()
+			event.Result = *res
+		}
+	}
+	s.outbox = append(s.outbox, d)
+}
+
+func (s *Simulator) VerifyWebhook(header http.Header, body []byte) (*Event, error) {
+	var timestamp, signature string
+	for _, part := range strings.Split(header.Get(SignatureHeader), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errInvalidSignature
+	}
+	if age := s.now().Sub(time.Unix(t, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return nil, errInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(timestamp, body))) {
+		return nil, errInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" || event.Result.Reference == "" {
+		return nil, errInvalidWebhook
+	}
+	return &event, nil
+}
+
+func (s *Simulator) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Worker returns the worker that sends the simulator's webhooks.
+func (s *Simulator) Worker() worker.Worker {
+	return worker.Func{WorkerName: "payment-simulator", Fn: s.run}
+}
+
+// run sends queued webhooks until ctx is done.
+func (s *Simulator) run(ctx context.Context) error {
+	ticker := time.NewTicker(deliveryInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, d := range s.due() {
+			s.deliver(ctx, d)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// due removes the webhooks that are due from the outbox, oldest first.
+func (s *Simulator) due() []*delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var due, later []*delivery
+	for _, d := range s.outbox {
+		if d.due.After(now) {
+			later = append(later, d)
+			continue
+		}
+		if d.apply != nil {
+			d.apply()
+			d.apply = nil
+		}
+		due = append(due, d)
+	}
+	s.outbox = later
+	return due
+}
+
+// deliver posts a webhook and requeues it with backoff if it isn't
+// accepted.
+func (s *Simulator) deliver(ctx context.Context, d *delivery) {
+	err := s.post(ctx, d.event)
+	if err == nil {
+		return
+	}
+	d.attempts++
+	if d.attempts >= maxDeliveries {
+		slog.Error("give up delivering payment webhook", slog.String("event_id", d.event.ID), slog.Any("error", err))
+		return
+	}
+	slog.Warn("deliver payment webhook", slog.String("event_id", d.event.ID), slog.Int("attempt", d.attempts), slog.Any("error", err))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d.due = s.now().Add(deliveryInterval << d.attempts)
+	s.outbox = append(s.outbox, d)
+}
+
+func (s *Simulator) post(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, "t="+timestamp+",v1="+s.sign(timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint answered %s", resp.Status)
+	}
+	return nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
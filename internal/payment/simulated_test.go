@@ -0,0 +1,160 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+func newTestSimulator(now time.Time) *Simulator {
+	s := NewSimulator(&config.Payments{
+		WebhookSecret: "secret",
+		Simulator:     &config.PaymentSimulator{WebhookURL: "http://localhost/webhooks"},
+	}, "/api/v2")
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func authorize(t *testing.T, s *Simulator, paymentID int, number string) *Result {
+	t.Helper()
+	res, err := s.Authorize(context.Background(), AuthorizeRequest{
+		PaymentID: paymentID,
+		Amount:    1000,
+		Currency:  "USD",
+		Card:      models.PaymentCard{Number: number, ExpMonth: 12, ExpYear: 2099, CVC: "123"},
+	})
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	return res
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := newTestSimulator(now)
+	body := []byte(`{"id":"evt_1","type":"payment.authorized","payment":{"reference":"sim_1","status":"authorized"}}`)
+	signed := func(at time.Time, body []byte) http.Header {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		return http.Header{SignatureHeader: {"t=" + timestamp + ",v1=" + s.sign(timestamp, body)}}
+	}
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		body    []byte
+		wantErr error
+	}{
+		{name: "valid", header: signed(now, body), body: body},
+		{name: "slightly early clock", header: signed(now.Add(time.Minute), body), body: body},
+		{name: "missing header", header: http.Header{}, body: body, wantErr: errInvalidSignature},
+		{name: "too old", header: signed(now.Add(-signatureTolerance-time.Second), body), body: body, wantErr: errInvalidSignature},
+		{name: "tampered body", header: signed(now, body), body: []byte(`{"id":"evt_2"}`), wantErr: errInvalidSignature},
+		{
+			name:    "other secret",
+			header:  http.Header{SignatureHeader: {"t=" + strconv.FormatInt(now.Unix(), 10) + ",v1=00"}},
+			body:    body,
+			wantErr: errInvalidSignature,
+		},
+		{name: "not an event", header: signed(now, []byte(`{}`)), body: []byte(`{}`), wantErr: errInvalidWebhook},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := s.VerifyWebhook(tt.header, tt.body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && event.ID != "evt_1" {
+				t.Errorf("event ID = %q, want evt_1", event.ID)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRetriesGetTheSameAnswer(t *testing.T) {
+	s := newTestSimulator(time.Now())
+	first := authorize(t, s, 1, CardSuccess)
+	again := authorize(t, s, 1, CardSuccess)
+	if again.Reference != first.Reference {
+		t.Errorf("retry got reference %q, want %q", again.Reference, first.Reference)
+	}
+	if other := authorize(t, s, 2, CardSuccess); other.Reference == first.Reference {
+		t.Error("another payment got the same reference")
+	}
+}
+
+func TestChangesAreIdempotent(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSimulator(time.Now())
+	ref := authorize(t, s, 1, CardSuccess).Reference
+
+	if _, err := s.Capture(ctx, ref, 1000, "capture"); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	// A capture repeated after a lost answer gets the first answer instead
+	// of failing on the captured payment.
+	res, err := s.Capture(ctx, ref, 1000, "capture")
+	if err != nil {
+		t.Fatalf("repeated capture: %v", err)
+	}
+	if res.Status != models.PaymentCaptured || res.CapturedAmount != 1000 {
+		t.Errorf("repeated capture = %s %d, want captured 1000", res.Status, res.CapturedAmount)
+	}
+
+	tests := []struct {
+		key          string
+		amount       int64
+		wantErr      error
+		wantRefunded int64
+	}{
+		{key: "refund-1", amount: 300, wantRefunded: 300},
+		{key: "refund-1", amount: 300, wantRefunded: 300},
+		{key: "refund-2", amount: 300, wantRefunded: 600},
+		{key: "refund-3", amount: 500, wantErr: ErrInvalidAmount},
+		{key: "refund-3", amount: 400, wantRefunded: 1000},
+	}
+	for _, tt := range tests {
+		res, err := s.Refund(ctx, ref, tt.amount, tt.key)
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("refund %s of %d: err = %v, want %v", tt.key, tt.amount, err, tt.wantErr)
+		}
+		if err == nil && res.RefundedAmount != tt.wantRefunded {
+			t.Errorf("refund %s of %d: refunded %d, want %d", tt.key, tt.amount, res.RefundedAmount, tt.wantRefunded)
+		}
+	}
+}
+
+func TestChangesNeedTheRightState(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSimulator(time.Now())
+	declined := authorize(t, s, 1, CardDeclined)
+	if declined.Status != models.PaymentDeclined {
+		t.Fatalf("status = %s, want declined", declined.Status)
+	}
+	authorized := authorize(t, s, 2, CardSuccess)
+
+	tests := []struct {
+		name    string
+		change  func() (*Result, error)
+		wantErr error
+	}{
+		{"capture declined", func() (*Result, error) { return s.Capture(ctx, declined.Reference, 1000, "a") }, ErrInvalidState},
+		{"void declined", func() (*Result, error) { return s.Void(ctx, declined.Reference, "b") }, ErrInvalidState},
+		{"refund uncaptured", func() (*Result, error) { return s.Refund(ctx, authorized.Reference, 100, "c") }, ErrInvalidState},
+		{"capture too much", func() (*Result, error) { return s.Capture(ctx, authorized.Reference, 1001, "d") }, ErrInvalidAmount},
+		{"unknown payment", func() (*Result, error) { return s.Void(ctx, "sim_unknown", "e") }, ErrUnknownPayment},
+		{"void authorized", func() (*Result, error) { return s.Void(ctx, authorized.Reference, "f") }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.change(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
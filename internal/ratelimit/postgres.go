@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/database"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so every
+// instance shares the same limits. Each Take locks its bucket row for the
+// duration of a short transaction.
+type PostgresStore struct {
+	db database.DBTX
+	tx database.Transactor
+}
+
+func NewPostgresStore(db database.DBTX, tx database.Transactor) *PostgresStore {
+	return &PostgresStore{db: db, tx: tx}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	var res Result
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		conn := database.Conn(ctx, s.db)
+		now := time.Now()
+
+		insert := `
+            -- name: CreateBucket
+            INSERT INTO rate_limit_buckets (key, tokens, updated_at, full_at)
+            VALUES ($1, $2, $3, $3)
+            ON CONFLICT (key) DO NOTHING
+        `
+		if _, err := conn.ExecContext(ctx, insert, key, limit.Burst, now); err != nil {
+			return err
+		}
+
+		lock := `
+            -- name: LockBucket
+            SELECT tokens, updated_at
+            FROM rate_limit_buckets
+            WHERE key = $1
+            FOR UPDATE
+        `
+		var b bucket
+		if err := conn.QueryRowContext(ctx, lock, key).Scan(&b.tokens, &b.updated); err != nil {
+			return err
+		}
+
+		res = b.take(limit, now)
+
+		update := `
+            -- name: UpdateBucket
+            UPDATE rate_limit_buckets
+            SET tokens = $2, updated_at = $3, full_at = $4
+            WHERE key = $1
+        `
+		_, err := conn.ExecContext(ctx, update, key, b.tokens, b.updated, b.fullAt(limit))
+		return err
+	})
+	return res, err
+}
+
+func (s *PostgresStore) Name() string { return "ratelimit-sweeper" }
+
+// Run deletes refilled buckets until ctx is done. It implements
+// worker.Worker.
+func (s *PostgresStore) Run(ctx context.Context) error {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	query := `
+        -- name: DeleteFullBuckets
+        DELETE FROM rate_limit_buckets
+        WHERE full_at <= $1
+    `
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			if _, err := s.db.ExecContext(ctx, query, now); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "sweeping rate limit buckets failed", slog.Any("error", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/config"
+)
+
+func TestBucketTake(t *testing.T) {
+	limit := Limit{Rate: 1, Burst: 3}
+	start := time.Unix(1_700_000_000, 0)
+	b := bucket{tokens: 3, updated: start}
+	tests := []struct {
+		name           string
+		at             time.Duration
+		wantAllowed    bool
+		wantRemaining  int
+		wantRetryAfter time.Duration
+		wantReset      time.Duration
+	}{
+		{name: "first", wantAllowed: true, wantRemaining: 2, wantReset: time.Second},
+		{name: "second", wantAllowed: true, wantRemaining: 1, wantReset: 2 * time.Second},
+		{name: "burst used up", wantAllowed: true, wantRemaining: 0, wantReset: 3 * time.Second},
+		{name: "empty", wantRetryAfter: time.Second, wantReset: 3 * time.Second},
+		{name: "half a token later", at: 500 * time.Millisecond, wantRetryAfter: 500 * time.Millisecond, wantReset: 2500 * time.Millisecond},
+		{name: "a token later", at: time.Second, wantAllowed: true, wantRemaining: 0, wantReset: 3 * time.Second},
+		{name: "refilled no further than the burst", at: time.Minute, wantAllowed: true, wantRemaining: 2, wantReset: time.Second},
+		{name: "clock moved back", at: 59 * time.Second, wantAllowed: true, wantRemaining: 1, wantReset: 2 * time.Second},
+	}
+	for _, tt := range tests {
+		res := b.take(limit, start.Add(tt.at))
+		if res.Allowed != tt.wantAllowed || res.Remaining != tt.wantRemaining ||
+			res.RetryAfter != tt.wantRetryAfter || res.Reset != tt.wantReset {
+			t.Errorf("%s: allowed %v, remaining %d, retry after %v, reset %v; want %v, %d, %v, %v", tt.name,
+				res.Allowed, res.Remaining, res.RetryAfter, res.Reset,
+				tt.wantAllowed, tt.wantRemaining, tt.wantRetryAfter, tt.wantReset)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	limit := Limit{Rate: 0.001, Burst: 2}
+	take := func(key string) bool {
+		res, err := s.Take(ctx, key, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Allowed
+	}
+
+	for i, want := range []bool{true, true, false} {
+		if got := take("a"); got != want {
+			t.Errorf("take %d from a: allowed %v, want %v", i+1, got, want)
+		}
+	}
+	if !take("b") {
+		t.Error("b shares a's bucket")
+	}
+
+	s.sweep(time.Now())
+	if len(s.buckets) != 2 {
+		t.Errorf("swept %d buckets that aren't full", 2-len(s.buckets))
+	}
+	s.sweep(time.Now().Add(time.Hour))
+	if len(s.buckets) != 0 {
+		t.Errorf("%d full buckets left after the sweep", len(s.buckets))
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		by     string
+		userID any
+		apiKey string
+		want   string
+	}{
+		{name: "ip", by: "ip", userID: 7, apiKey: "secret", want: "ip:192.0.2.1"},
+		{name: "default", want: "ip:192.0.2.1"},
+		{name: "user", by: "user", userID: 7, want: "user:7"},
+		{name: "user signed out", by: "user", want: "ip:192.0.2.1"},
+		{
+			name:   "api key",
+			by:     "api_key",
+			apiKey: "secret",
+			// The SHA-256 of "secret".
+			want: "key:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b",
+		},
+		{name: "api key missing", by: "api_key", want: "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.RemoteAddr = "192.0.2.1:4321"
+			if tt.userID != nil {
+				c.Set("user_id", tt.userID)
+			}
+			if tt.apiKey != "" {
+				c.Request.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			if got := identity(c, tt.by); got != tt.want {
+				t.Errorf("identity = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	conf := &config.Config{RateLimits: map[string]*config.RateLimit{
+		"auth": {Rate: 0.001, Burst: 2, By: "ip"},
+	}}
+	limiter := New(config.Static(conf), NewMemoryStore())
+	r := gin.New()
+	r.GET("/login", limiter.Limit("auth"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/products", limiter.Limit("unconfigured"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name          string
+		path          string
+		ip            string
+		wantPassed    bool
+		wantRemaining string
+		wantRetry     bool
+	}{
+		{name: "first", path: "/login", ip: "192.0.2.1", wantPassed: true, wantRemaining: "1"},
+		{name: "second", path: "/login", ip: "192.0.2.1", wantPassed: true, wantRemaining: "0"},
+		{name: "limited", path: "/login", ip: "192.0.2.1", wantRemaining: "0", wantRetry: true},
+		{name: "another client", path: "/login", ip: "192.0.2.2", wantPassed: true, wantRemaining: "1"},
+		{name: "group without a policy", path: "/products", ip: "192.0.2.1", wantPassed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.ip + ":4321"
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			// Rejections are rendered by the error middleware, which isn't
+			// installed here, so the handler not running is the rejection.
+			passed := rec.Code == http.StatusOK && rec.Header().Get("Retry-After") == ""
+			if passed != tt.wantPassed {
+				t.Errorf("passed = %v, want %v", passed, tt.wantPassed)
+			}
+			if got := rec.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("RateLimit-Remaining = %q, want %q", got, tt.wantRemaining)
+			}
+			if got := rec.Header().Get("Retry-After"); (got != "") != tt.wantRetry {
+				t.Errorf("Retry-After = %q", got)
+			}
+			if tt.wantRemaining != "" && !strings.HasPrefix(rec.Header().Get("RateLimit-Policy"), "2;w=") {
+				t.Errorf("RateLimit-Policy = %q, want a burst of 2", rec.Header().Get("RateLimit-Policy"))
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Package ratelimit limits request rates with token buckets kept in a
+// pluggable Store.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often stores drop buckets that have refilled, since
+// a full bucket behaves exactly like a missing one.
+const sweepInterval = time.Minute
+
+// Limit is a token bucket: Rate tokens per second, up to Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking one token.
+type Result struct {
+	Limit     Limit
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token, when not Allowed.
+	RetryAfter time.Duration
+}
+
+// Store takes tokens from the bucket identified by key, creating a full
+// bucket on first use.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills b for the time since it was last updated and removes one
+// token if there is one. Rejected requests do not consume tokens.
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	burst := float64(limit.Burst)
+	b.tokens = math.Min(burst, b.tokens+elapsed*limit.Rate)
+	b.updated = now
+
+	res := Result{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((burst - b.tokens) / limit.Rate)
+	return res
+}
+
+// fullAt is when b will have refilled to limit.Burst.
+func (b *bucket) fullAt(limit Limit) time.Time {
+	return b.updated.Add(seconds((float64(limit.Burst) - b.tokens) / limit.Rate))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// MemoryStore keeps buckets in process memory. Limits are per instance.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	bucket
+	full time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: bucket{tokens: float64(limit.Burst), updated: now}}
+		s.buckets[key] = b
+	}
+	res := b.take(limit, now)
+	b.full = b.fullAt(limit)
+	return res, nil
+}
+
+func (s *MemoryStore) Name() string { return "ratelimit-sweeper" }
+
+// Run drops refilled buckets until ctx is done. It implements worker.Worker.
+func (s *MemoryStore) Run(ctx context.Context) error {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			s.sweep(now)
+		}
+	}
+}
+
+// sweep drops the buckets that are full at now.
+func (s *MemoryStore) sweep(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+type CartRepository interface {
+	// A user has at most one cart, and token hashes are unique.
+	CreateCart(ctx context.Context, cart *models.Cart) error
+	// UpdateCart saves a cart's owner, currency and expiry.
+	UpdateCart(ctx context.Context, cart *models.Cart) error
+	// GetCartByUserID and GetCartByTokenHash return a cart with its items,
+	// oldest first.
+	GetCartByUserID(ctx context.Context, userID int) (*models.Cart, error)
+	GetCartByTokenHash(ctx context.Context, tokenHash string) (*models.Cart, error)
+	// LockCart returns a cart with its items and locks it until the
+	// transaction ends, so concurrent changes to it take turns.
+	LockCart(ctx context.Context, id int) (*models.Cart, error)
+	DeleteCart(ctx context.Context, id int) error
+	// DeleteExpiredCarts deletes up to limit carts that expired before now
+	// and returns how many it deleted.
+	DeleteExpiredCarts(ctx context.Context, now time.Time, limit int) (int, error)
+
+	// SaveItem adds an item to a cart or replaces its quantity and price.
+	SaveItem(ctx context.Context, cartID int, item *models.CartItem) error
+	DeleteItem(ctx context.Context, cartID, variantID int) error
+}
+
+type cartRepository struct {
+	db database.DBTX
+}
+
+// NewCartRepository returns a CartRepository backed by db. Calls made with
+// a context carrying a transaction from database.WithTx run inside it;
+// LockCart must.
+func NewCartRepository(db database.DBTX) CartRepository {
+	return &cartRepository{db: db}
+}
+
+func (r *cartRepository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
+const cartColumns = `id, user_id, token_hash, currency, expires_at, created_at, updated_at`
+
+func scanCart(row interface{ Scan(...any) error }) (*models.Cart, error) {
+	cart := &models.Cart{}
+	var userID sql.NullInt64
+	var tokenHash sql.NullString
+	err := row.Scan(&cart.ID, &userID, &tokenHash, &cart.Currency, &cart.ExpiresAt, &cart.CreatedAt, &cart.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		id := int(userID.Int64)
+		cart.UserID = &id
+	}
+	cart.TokenHash = tokenHash.String
+	return cart, nil
+}
+
+// nullString stores "" as NULL, which unique columns allow more than once.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func (r *cartRepository) CreateCart(ctx context.Context, cart *models.Cart) error {
+	query := `
+        -- name: CreateCart
+        INSERT INTO carts (user_id, token_hash, currency, expires_at)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at, updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		cart.UserID, nullString(cart.TokenHash), cart.Currency, cart.ExpiresAt,
+	).Scan(&cart.ID, &cart.CreatedAt, &cart.UpdatedAt)
+	return translateError(err, "cart")
+}
+
+func (r *cartRepository) UpdateCart(ctx context.Context, cart *models.Cart) error {
+	query := `
+        -- name: UpdateCart
+        UPDATE carts
+        SET user_id = $1, token_hash = $2, currency = $3, expires_at = $4, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $5
+        RETURNING updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		cart.UserID, nullString(cart.TokenHash), cart.Currency, cart.ExpiresAt, cart.ID,
+	).Scan(&cart.UpdatedAt)
+	return translateError(err, "cart")
+}
+
+func (r *cartRepository) GetCartByUserID(ctx context.Context, userID int) (*models.Cart, error) {
+	query := `
+        -- name: GetCartByUserID
+        SELECT ` + cartColumns + `
+        FROM carts
+        WHERE user_id = $1
+    `
+	return r.getCart(ctx, query, userID)
+}
+
+func (r *cartRepository) GetCartByTokenHash(ctx context.Context, tokenHash string) (*models.Cart, error) {
+	query := `
+        -- name: GetCartByTokenHash
+        SELECT ` + cartColumns + `
+        FROM carts
+        WHERE token_hash = $1
+    `
+	return r.getCart(ctx, query, tokenHash)
+}
+
+func (r *cartRepository) LockCart(ctx context.Context, id int) (*models.Cart, error) {
+	query := `
+        -- name: LockCart
+        SELECT ` + cartColumns + `
+        FROM carts
+        WHERE id = $1
+        FOR UPDATE
+    `
+	return r.getCart(ctx, query, id)
+}
+
+func (r *cartRepository) getCart(ctx context.Context, query string, arg any) (*models.Cart, error) {
+	cart, err := scanCart(r.conn(ctx).QueryRowContext(ctx, query, arg))
+	if err != nil {
+		return nil, translateError(err, "cart")
+	}
+
+	query = `
+        -- name: ListCartItems
+        SELECT variant_id, quantity, added_price, added_at
+        FROM cart_items
+        WHERE cart_id = $1
+        ORDER BY added_at, variant_id
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, cart.ID)
+	if err != nil {
+		return nil, translateError(err, "cart")
+	}
+	defer rows.Close()
+
+	cart.Items = []models.CartItem{}
+	for rows.Next() {
+		var item models.CartItem
+		if err := rows.Scan(&item.VariantID, &item.Quantity, &item.AddedPrice, &item.AddedAt); err != nil {
+			return nil, err
+		}
+		cart.Items = append(cart.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+func (r *cartRepository) DeleteCart(ctx context.Context, id int) error {
+	query := `
+        -- name: DeleteCart
+        DELETE FROM carts
+        WHERE id = $1
+    `
+	_, err := r.conn(ctx).ExecContext(ctx, query, id)
+	return translateError(err, "cart")
+}
+
+func (r *cartRepository) DeleteExpiredCarts(ctx context.Context, now time.Time, limit int) (int, error) {
+	query := `
+        -- name: DeleteExpiredCarts
+        DELETE FROM carts
+        WHERE id IN (
+            SELECT id
+            FROM carts
+            WHERE expires_at < $1
+            ORDER BY expires_at
+            LIMIT $2
+        )
+    `
+	res, err := r.conn(ctx).ExecContext(ctx, query, now, limit)
+	if err != nil {
+		return 0, translateError(err, "cart")
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (r *cartRepository) SaveItem(ctx context.Context, cartID int, item *models.CartItem) error {
+	query := `
+        -- name: SaveCartItem
+        INSERT INTO cart_items (cart_id, variant_id, quantity, added_price)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (cart_id, variant_id) DO UPDATE
+        SET quantity = EXCLUDED.quantity, added_price = EXCLUDED.added_price
+        RETURNING added_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		cartID, item.VariantID, item.Quantity, item.AddedPrice,
+	).Scan(&item.AddedAt)
+	return translateError(err, "cart_item")
+}
+
+func (r *cartRepository) DeleteItem(ctx context.Context, cartID, variantID int) error {
+	query := `
+        -- name: DeleteCartItem
+        DELETE FROM cart_items
+        WHERE cart_id = $1 AND variant_id = $2
+    `
+	_, err := r.conn(ctx).ExecContext(ctx, query, cartID, variantID)
+	return translateError(err, "cart_item")
+}
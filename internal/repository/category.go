@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+type CategoryRepository interface {
+	CreateCategory(ctx context.Context, category *models.Category) error
+	UpdateCategory(ctx context.Context, category *models.Category) error
+	GetCategoryByID(ctx context.Context, id int) (*models.Category, error)
+	// ListCategories returns the whole tree, flattened, with siblings in
+	// order. Catalogs have at most a few thousand categories.
+	ListCategories(ctx context.Context) ([]*models.Category, error)
+	DeleteCategory(ctx context.Context, id int) error
+
+	// ListAttributes returns the attributes a category itself defines, in
+	// order, without inherited ones.
+	ListAttributes(ctx context.Context, categoryID int) ([]models.CategoryAttribute, error)
+	ReplaceAttributes(ctx context.Context, categoryID int, attributes []models.CategoryAttribute) error
+}
+
+type categoryRepository struct {
+	db database.DBTX
+}
+
+// NewCategoryRepository returns a CategoryRepository backed by db. Calls
+// made with a context carrying a transaction from database.WithTx run
+// inside it.
+func NewCategoryRepository(db database.DBTX) CategoryRepository {
+	return &categoryRepository{db: db}
+}
+
+func (r *categoryRepository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
+const categoryColumns = `id, parent_id, name, slug, position, created_at, updated_at`
+
+func scanCategory(row interface{ Scan(...any) error }) (*models.Category, error) {
+	c := &models.Category{}
+	var parentID sql.NullInt64
+	if err := row.Scan(&c.ID, &parentID, &c.Name, &c.Slug, &c.Position, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
+	return c, nil
+}
+
+func (r *categoryRepository) CreateCategory(ctx context.Context, category *models.Category) error {
+	query := `
+        -- name: CreateCategory
+        INSERT INTO categories (parent_id, name, slug, position)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at, updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		category.ParentID, category.Name, category.Slug, category.Position,
+	).Scan(&category.ID, &category.CreatedAt, &category.UpdatedAt)
+	return translateError(err, "category")
+}
+
+func (r *categoryRepository) UpdateCategory(ctx context.Context, category *models.Category) error {
+	query := `
+        -- name: UpdateCategory
+        UPDATE categories
+        SET parent_id = $1, name = $2, slug = $3, position = $4, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $5
+        RETURNING updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		category.ParentID, category.Name, category.Slug, category.Position, category.ID,
+	).Scan(&category.UpdatedAt)
+	return translateError(err, "category")
+}
+
+func (r *categoryRepository) GetCategoryByID(ctx context.Context, id int) (*models.Category, error) {
+	query := `
+        -- name: GetCategoryByID
+        SELECT ` + categoryColumns + `
+        FROM categories
+        WHERE id = $1
+    `
+	category, err := scanCategory(r.conn(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, translateError(err, "category")
+	}
+	return category, nil
+}
+
+func (r *categoryRepository) ListCategories(ctx context.Context) ([]*models.Category, error) {
+	query := `
+        -- name: ListCategories
+        SELECT ` + categoryColumns + `
+        FROM categories
+        ORDER BY position, id
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, translateError(err, "category")
+	}
+	defer rows.Close()
+
+	var categories []*models.Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *categoryRepository) DeleteCategory(ctx context.Context, id int) error {
+	query := `
+        -- name: DeleteCategory
+        DELETE FROM categories
+        WHERE id = $1
+    `
+	res, err := r.conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return translateDeleteError(err, "category")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return translateError(sql.ErrNoRows, "category")
+	}
+	return nil
+}
+
+func (r *categoryRepository) ListAttributes(ctx context.Context, categoryID int) ([]models.CategoryAttribute, error) {
+	query := `
+        -- name: ListCategoryAttributes
+        SELECT category_id, name, label, type, unit, required, options
+        FROM category_attributes
+        WHERE category_id = $1
+        ORDER BY position
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, categoryID)
+	if err != nil {
+		return nil, translateError(err, "category_attribute")
+	}
+	defer rows.Close()
+
+	var attributes []models.CategoryAttribute
+	for rows.Next() {
+		var a models.CategoryAttribute
+		if err := rows.Scan(&a.CategoryID, &a.Name, &a.Label, &a.Type, &a.Unit, &a.Required, pq.Array(&a.Options)); err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+// ReplaceAttributes should run in a transaction so the category is never
+// seen without attributes.
+func (r *categoryRepository) ReplaceAttributes(ctx context.Context, categoryID int, attributes []models.CategoryAttribute) error {
+	query := `
+        -- name: DeleteCategoryAttributes
+        DELETE FROM category_attributes
+        WHERE category_id = $1
+    `
+	if _, err := r.conn(ctx).ExecContext(ctx, query, categoryID); err != nil {
+		return translateError(err, "category_attribute")
+	}
+
+	query = `
+        -- name: CreateCategoryAttribute
+        INSERT INTO category_attributes (category_id, name, label, type, unit, required, options, position)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `
+	for i, a := range attributes {
+		options := a.Options
+		if options == nil {
+			options = []string{}
+		}
+		_, err := r.conn(ctx).ExecContext(ctx, query, categoryID, a.Name, a.Label, a.Type, a.Unit, a.Required, pq.Array(options), i)
+		if err != nil {
+			return translateError(err, "category_attribute")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+)
+
+// translateError converts driver errors into domain errors for entity, e.g.
+// "user" yields codes like "user_not_found". Unknown errors are returned
+// unchanged and surface as internal errors.
+func translateError(err error, entity string) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperror.NotFound(entity+"_not_found", entity+" not found").Wrap(err)
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code.Name() {
+	case "unique_violation":
+		return apperror.Conflict(entity+"_already_exists", entity+" already exists").Wrap(err)
+	case "foreign_key_violation":
+		return apperror.Validation("invalid_reference", "referenced record does not exist").Wrap(err)
+	case "not_null_violation", "check_violation", "string_data_right_truncation",
+		"invalid_text_representation", "numeric_value_out_of_range":
+		return apperror.Validation("invalid_"+entity, entity+" is invalid").Wrap(err)
+	}
+	return err
+}
+
+// translateDeleteError is translateError for DELETE statements. A foreign
+// key violation there means other rows still reference the entity, as
+// ON DELETE RESTRICT constraints require, so it is a conflict rather than
+// an invalid reference in the request.
+func translateDeleteError(err error, entity string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation" {
+		return apperror.Conflict(entity+"_in_use", entity+" is still referenced").Wrap(err)
+	}
+	return translateError(err, entity)
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+)
+
+func TestTranslateError(t *testing.T) {
+	errOther := errors.New("connection reset")
+	foreignKey := &pq.Error{Code: "23503"}
+	tests := []struct {
+		name      string
+		translate func(err error, entity string) error
+		err       error
+		want      string
+	}{
+		{name: "nil", translate: translateError},
+		{name: "no rows", translate: translateError, err: sql.ErrNoRows, want: "category_not_found"},
+		{name: "unique", translate: translateError, err: &pq.Error{Code: "23505"}, want: "category_already_exists"},
+		{name: "check", translate: translateError, err: &pq.Error{Code: "23514"}, want: "invalid_category"},
+		{name: "missing reference", translate: translateError, err: foreignKey, want: "invalid_reference"},
+		{name: "other", translate: translateError, err: errOther, want: errOther.Error()},
+		{name: "delete still referenced", translate: translateDeleteError, err: foreignKey, want: "category_in_use"},
+		{name: "delete no rows", translate: translateDeleteError, err: sql.ErrNoRows, want: "category_not_found"},
+		{name: "delete other", translate: translateDeleteError, err: errOther, want: errOther.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.translate(tt.err, "category")
+			got := ""
+			var appErr *apperror.Error
+			if errors.As(err, &appErr) {
+				got = appErr.Code
+			} else if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("code = %q, want %q", got, tt.want)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("%v doesn't wrap %v", err, tt.err)
+			}
+		})
+	}
+}
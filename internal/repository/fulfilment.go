@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/lib/pq"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+// FulfilmentFilter selects fulfilments for ListFulfilments. Zero fields
+// match every fulfilment.
+type FulfilmentFilter struct {
+	SellerID int
+	Status   models.FulfilmentStatus
+	Limit    int
+	Offset   int
+}
+
+type FulfilmentRepository interface {
+	// CreateFulfilment saves a fulfilment of an order that is already
+	// saved. Its items are the order's items with the same seller.
+	CreateFulfilment(ctx context.Context, fulfilment *models.Fulfilment) error
+	// GetFulfilmentByID and LockFulfilment return a fulfilment with its
+	// items and events. LockFulfilment also locks it until the transaction
+	// ends.
+	GetFulfilmentByID(ctx context.Context, id int) (*models.Fulfilment, error)
+	LockFulfilment(ctx context.Context, id int) (*models.Fulfilment, error)
+	// ListFulfilmentsByOrderID returns an order's fulfilments with their
+	// items and events, oldest first.
+	ListFulfilmentsByOrderID(ctx context.Context, orderID int) ([]*models.Fulfilment, error)
+	// ListFulfilments returns a page of the matching fulfilments with their
+	// items, newest first, and how many match in total.
+	ListFulfilments(ctx context.Context, filter FulfilmentFilter) ([]*models.Fulfilment, int, error)
+	// SummarizeFulfilments counts a seller's fulfilments in each status.
+	SummarizeFulfilments(ctx context.Context, sellerID int) (*models.FulfilmentSummary, error)
+	// UpdateFulfilment saves a fulfilment's status, tracking and the times
+	// its status changed.
+	UpdateFulfilment(ctx context.Context, fulfilment *models.Fulfilment) error
+	// CreateShipmentEvent adds an event to a fulfilment's history.
+	CreateShipmentEvent(ctx context.Context, event *models.ShipmentEvent) error
+}
+
+type fulfilmentRepository struct {
+	db database.DBTX
+}
+
+// NewFulfilmentRepository returns a FulfilmentRepository backed by db.
+// Calls made with a context carrying a transaction from database.WithTx run
+// inside it; LockFulfilment must.
+func NewFulfilmentRepository(db database.DBTX) FulfilmentRepository {
+	return &fulfilmentRepository{db: db}
+}
+
+func (r *fulfilmentRepository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
+// The shipping address is the order's.
+const fulfilmentColumns = `f.id, f.order_id, f.seller_id, f.status, o.shipping_address, f.carrier, f.tracking_number,
+    f.accepted_at, f.shipped_at, f.delivered_at, f.cancelled_at, f.created_at, f.updated_at`
+
+func scanFulfilment(row interface{ Scan(...any) error }) (*models.Fulfilment, error) {
+	f := &models.Fulfilment{}
+	var (
+		shipping                                     []byte
+		acceptedAt, shippedAt, deliveredAt, cancelAt sql.NullTime
+	)
+	err := row.Scan(&f.ID, &f.OrderID, &f.SellerID, &f.Status, &shipping, &f.Carrier, &f.TrackingNumber,
+		&acceptedAt, &shippedAt, &deliveredAt, &cancelAt, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(shipping, &f.ShippingAddress); err != nil {
+		return nil, err
+	}
+	f.AcceptedAt = nullTime(acceptedAt)
+	f.ShippedAt = nullTime(shippedAt)
+	f.DeliveredAt = nullTime(deliveredAt)
+	f.CancelledAt = nullTime(cancelAt)
+	return f, nil
+}
+
+func (r *fulfilmentRepository) CreateFulfilment(ctx context.Context, fulfilment *models.Fulfilment) error {
+	query := `
+        -- name: CreateFulfilment
+        INSERT INTO fulfilments (order_id, seller_id, status)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at, updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query, fulfilment.OrderID, fulfilment.SellerID, fulfilment.Status).
+		Scan(&fulfilment.ID, &fulfilment.CreatedAt, &fulfilment.UpdatedAt)
+	return translateError(err, "fulfilment")
+}
+
+func (r *fulfilmentRepository) GetFulfilmentByID(ctx context.Context, id int) (*models.Fulfilment, error) {
+	query := `
+        -- name: GetFulfilmentByID
+        SELECT ` + fulfilmentColumns + `
+        FROM fulfilments f
+        JOIN orders o ON o.id = f.order_id
+        WHERE f.id = $1
+    `
+	return r.getFulfilment(ctx, query, id)
+}
+
+func (r *fulfilmentRepository) LockFulfilment(ctx context.Context, id int) (*models.Fulfilment, error) {
+	query := `
+        -- name: LockFulfilment
+        SELECT ` + fulfilmentColumns + `
+        FROM fulfilments f
+        JOIN orders o ON o.id = f.order_id
+        WHERE f.id = $1
+        FOR UPDATE OF f
+    `
+	return r.getFulfilment(ctx, query, id)
+}
+
+func (r *fulfilmentRepository) getFulfilment(ctx context.Context, query string, args ...any) (*models.Fulfilment, error) {
+	fulfilment, err := scanFulfilment(r.conn(ctx).QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return nil, translateError(err, "fulfilment")
+	}
+	fulfilments := []*models.Fulfilment{fulfilment}
+	if err := r.loadItems(ctx, fulfilments); err != nil {
+		return nil, err
+	}
+	if err := r.loadEvents(ctx, fulfilments); err != nil {
+		return nil, err
+	}
+	return fulfilment, nil
+}
+
+func (r *fulfilmentRepository) ListFulfilmentsByOrderID(ctx context.Context, orderID int) ([]*models.Fulfilment, error) {
+	query := `
+        -- name: ListFulfilmentsByOrderID
+        SELECT ` + fulfilmentColumns + `
+        FROM fulfilments f
+        JOIN orders o ON o.id = f.order_id
+        WHERE f.order_id = $1
+        ORDER BY f.id
+    `
+	fulfilments, err := r.list(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadEvents(ctx, fulfilments); err != nil {
+		return nil, err
+	}
+	return fulfilments, nil
+}
+
+func (r *fulfilmentRepository) ListFulfilments(ctx context.Context, filter FulfilmentFilter) ([]*models.Fulfilment, int, error) {
+	countQuery := `
+        -- name: CountFulfilments
+        SELECT COUNT(*)
+        FROM fulfilments
+        WHERE ($1 = 0 OR seller_id = $1) AND ($2 = '' OR status = $2)
+    `
+	var total int
+	err := r.conn(ctx).QueryRowContext(ctx, countQuery, filter.SellerID, filter.Status).Scan(&total)
+	if err != nil {
+		return nil, 0, translateError(err, "fulfilment")
+	}
+
+	query := `
+        -- name: ListFulfilments
+        SELECT ` + fulfilmentColumns + `
+        FROM fulfilments f
+        JOIN orders o ON o.id = f.order_id
+        WHERE ($1 = 0 OR f.seller_id = $1) AND ($2 = '' OR f.status = $2)
+        ORDER BY f.id DESC
+        LIMIT $3 OFFSET $4
+    `
+	fulfilments, err := r.list(ctx, query, filter.SellerID, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return fulfilments, total, nil
+}
+
+// list returns the fulfilments query selects, with their items.
+func (r *fulfilmentRepository) list(ctx context.Context, query string, args ...any) ([]*models.Fulfilment, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, translateError(err, "fulfilment")
+	}
+	defer rows.Close()
+
+	fulfilments := []*models.Fulfilment{}
+	for rows.Next() {
+		fulfilment, err := scanFulfilment(rows)
+		if err != nil {
+			return nil, err
+		}
+		fulfilments = append(fulfilments, fulfilment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.loadItems(ctx, fulfilments); err != nil {
+		return nil, err
+	}
+	return fulfilments, nil
+}
+
+// loadItems sets the items of fulfilments: their orders' items with the
+// same seller.
+func (r *fulfilmentRepository) loadItems(ctx context.Context, fulfilments []*models.Fulfilment) error {
+	byOrderSeller := make(map[[2]int]*models.Fulfilment, len(fulfilments))
+	orderIDs := make([]int, len(fulfilments))
+	for i, fulfilment := range fulfilments {
+		fulfilment.Items = []models.OrderItem{}
+		byOrderSeller[[2]int{fulfilment.OrderID, fulfilment.SellerID}] = fulfilment
+		orderIDs[i] = fulfilment.OrderID
+	}
+	if len(orderIDs) == 0 {
+		return nil
+	}
+
+	query := `
+        -- name: ListFulfilmentItems
+        SELECT order_id, variant_id, product_id, seller_id, product_name, sku, options, unit_price, quantity, line_total
+        FROM order_items
+        WHERE order_id = ANY($1)
+        ORDER BY order_id, product_name, sku
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, pq.Array(orderIDs))
+	if err != nil {
+		return translateError(err, "fulfilment")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			orderID int
+			item    models.OrderItem
+			options []byte
+		)
+		err := rows.Scan(&orderID, &item.VariantID, &item.ProductID, &item.SellerID, &item.ProductName, &item.SKU, &options,
+			&item.UnitPrice, &item.Quantity, &item.LineTotal)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(options, &item.Options); err != nil {
+			return err
+		}
+		if fulfilment, ok := byOrderSeller[[2]int{orderID, item.SellerID}]; ok {
+			fulfilment.Items = append(fulfilment.Items, item)
+		}
+	}
+	return rows.Err()
+}
+
+// loadEvents sets the events of fulfilments, in the order they happened.
+func (r *fulfilmentRepository) loadEvents(ctx context.Context, fulfilments []*models.Fulfilment) error {
+	byID := make(map[int]*models.Fulfilment, len(fulfilments))
+	ids := make([]int, len(fulfilments))
+	for i, fulfilment := range fulfilments {
+		fulfilment.Events = []models.ShipmentEvent{}
+		byID[fulfilment.ID] = fulfilment
+		ids[i] = fulfilment.ID
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+        -- name: ListShipmentEvents
+        SELECT id, fulfilment_id, actor_id, type, location, description, occurred_at, created_at
+        FROM shipment_events
+        WHERE fulfilment_id = ANY($1)
+        ORDER BY fulfilment_id, occurred_at, id
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return translateError(err, "fulfilment")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			event   models.ShipmentEvent
+			actorID sql.NullInt64
+		)
+		err := rows.Scan(&event.ID, &event.FulfilmentID, &actorID, &event.Type, &event.Location, &event.Description,
+			&event.OccurredAt, &event.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			event.ActorID = &id
+		}
+		fulfilment := byID[event.FulfilmentID]
+		fulfilment.Events = append(fulfilment.Events, event)
+	}
+	return rows.Err()
+}
+
+func (r *fulfilmentRepository) SummarizeFulfilments(ctx context.Context, sellerID int) (*models.FulfilmentSummary, error) {
+	query := `
+        -- name: SummarizeFulfilments
+        SELECT
+            COUNT(*) FILTER (WHERE status = 'pending'),
+            COUNT(*) FILTER (WHERE status = 'accepted'),
+            COUNT(*) FILTER (WHERE status = 'shipped'),
+            COUNT(*) FILTER (WHERE status = 'delivered'),
+            COUNT(*) FILTER (WHERE status = 'cancelled')
+        FROM fulfilments
+        WHERE seller_id = $1
+    `
+	s := &models.FulfilmentSummary{}
+	err := r.conn(ctx).QueryRowContext(ctx, query, sellerID).
+		Scan(&s.Pending, &s.Accepted, &s.Shipped, &s.Delivered, &s.Cancelled)
+	if err != nil {
+		return nil, translateError(err, "fulfilment")
+	}
+	return s, nil
+}
+
+func (r *fulfilmentRepository) UpdateFulfilment(ctx context.Context, fulfilment *models.Fulfilment) error {
+	query := `
+        -- name: UpdateFulfilment
+        UPDATE fulfilments
+        SET status = $1, carrier = $2, tracking_number = $3, accepted_at = $4, shipped_at = $5, delivered_at = $6,
+            cancelled_at = $7, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $8
+        RETURNING updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		fulfilment.Status, fulfilment.Carrier, fulfilment.TrackingNumber, fulfilment.AcceptedAt, fulfilment.ShippedAt,
+		fulfilment.DeliveredAt, fulfilment.CancelledAt, fulfilment.ID,
+	).Scan(&fulfilment.UpdatedAt)
+	return translateError(err, "fulfilment")
+}
+
+func (r *fulfilmentRepository) CreateShipmentEvent(ctx context.Context, event *models.ShipmentEvent) error {
+	query := `
+        -- name: CreateShipmentEvent
+        INSERT INTO shipment_events (fulfilment_id, actor_id, type, location, description, occurred_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		event.FulfilmentID, event.ActorID, event.Type, event.Location, event.Description, event.OccurredAt,
+	).Scan(&event.ID, &event.CreatedAt)
+	return translateError(err, "shipment_event")
+}
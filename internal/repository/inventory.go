@@ -0,0 +1,438 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+type InventoryRepository interface {
+	// Warehouse codes are unique per seller.
+	CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error
+	UpdateWarehouse(ctx context.Context, warehouse *models.Warehouse) error
+	GetWarehouseByID(ctx context.Context, id int) (*models.Warehouse, error)
+	ListWarehouses(ctx context.Context, sellerID int) ([]*models.Warehouse, error)
+
+	// ListStock returns the stock levels of the variants, by variant and
+	// then warehouse.
+	ListStock(ctx context.Context, variantIDs []int) ([]*models.StockLevel, error)
+	// LockStock is ListStock that also locks the levels until the
+	// transaction ends, so concurrent changes to the same stock take turns.
+	// Levels are always locked in the same order, which avoids deadlocks.
+	LockStock(ctx context.Context, variantIDs []int) ([]*models.StockLevel, error)
+	// CreateStock adds an empty stock level unless there already is one, so
+	// that it can be locked.
+	CreateStock(ctx context.Context, variantID, warehouseID int) error
+	// UpdateStock saves a level's units and threshold.
+	UpdateStock(ctx context.Context, level *models.StockLevel) error
+
+	CreateAdjustment(ctx context.Context, adjustment *models.StockAdjustment) error
+	// ListAdjustments returns a page of a variant's ledger, newest first,
+	// and its length.
+	ListAdjustments(ctx context.Context, variantID, limit, offset int) ([]*models.StockAdjustment, int, error)
+
+	// CreateReservation saves a reservation with its items.
+	CreateReservation(ctx context.Context, reservation *models.Reservation) error
+	GetReservationByID(ctx context.Context, id int) (*models.Reservation, error)
+	// LockReservation is GetReservationByID that also locks the reservation
+	// until the transaction ends.
+	LockReservation(ctx context.Context, id int) (*models.Reservation, error)
+	UpdateReservationStatus(ctx context.Context, reservation *models.Reservation) error
+	// ListExpiredReservations returns up to limit active reservations that
+	// expired before now, without their items.
+	ListExpiredReservations(ctx context.Context, now time.Time, limit int) ([]*models.Reservation, error)
+}
+
+type inventoryRepository struct {
+	db database.DBTX
+}
+
+// NewInventoryRepository returns an InventoryRepository backed by db. Calls
+// made with a context carrying a transaction from database.WithTx run
+// inside it; LockStock and LockReservation must.
+func NewInventoryRepository(db database.DBTX) InventoryRepository {
+	return &inventoryRepository{db: db}
+}
+
+func (r *inventoryRepository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
+const warehouseColumns = `id, seller_id, name, code, created_at, updated_at`
+
+func scanWarehouse(row interface{ Scan(...any) error }) (*models.Warehouse, error) {
+	w := &models.Warehouse{}
+	if err := row.Scan(&w.ID, &w.SellerID, &w.Name, &w.Code, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (r *inventoryRepository) CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	query := `
+        -- name: CreateWarehouse
+        INSERT INTO warehouses (seller_id, name, code)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at, updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		warehouse.SellerID, warehouse.Name, warehouse.Code,
+	).Scan(&warehouse.ID, &warehouse.CreatedAt, &warehouse.UpdatedAt)
+	return translateError(err, "warehouse")
+}
+
+func (r *inventoryRepository) UpdateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	query := `
+        -- name: UpdateWarehouse
+        UPDATE warehouses
+        SET name = $1, code = $2, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $3
+        RETURNING updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		warehouse.Name, warehouse.Code, warehouse.ID,
+	).Scan(&warehouse.UpdatedAt)
+	return translateError(err, "warehouse")
+}
+
+func (r *inventoryRepository) GetWarehouseByID(ctx context.Context, id int) (*models.Warehouse, error) {
+	query := `
+        -- name: GetWarehouseByID
+        SELECT ` + warehouseColumns + `
+        FROM warehouses
+        WHERE id = $1
+    `
+	warehouse, err := scanWarehouse(r.conn(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, translateError(err, "warehouse")
+	}
+	return warehouse, nil
+}
+
+func (r *inventoryRepository) ListWarehouses(ctx context.Context, sellerID int) ([]*models.Warehouse, error) {
+	query := `
+        -- name: ListWarehouses
+        SELECT ` + warehouseColumns + `
+        FROM warehouses
+        WHERE seller_id = $1
+        ORDER BY name, id
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, sellerID)
+	if err != nil {
+		return nil, translateError(err, "warehouse")
+	}
+	defer rows.Close()
+
+	var warehouses []*models.Warehouse
+	for rows.Next() {
+		warehouse, err := scanWarehouse(rows)
+		if err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, warehouse)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return warehouses, nil
+}
+
+const stockColumns = `variant_id, warehouse_id, on_hand, reserved, low_stock_threshold, updated_at`
+
+func scanStock(row interface{ Scan(...any) error }) (*models.StockLevel, error) {
+	s := &models.StockLevel{}
+	if err := row.Scan(&s.VariantID, &s.WarehouseID, &s.OnHand, &s.Reserved, &s.LowStockThreshold, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	s.Available = s.OnHand - s.Reserved
+	return s, nil
+}
+
+func (r *inventoryRepository) ListStock(ctx context.Context, variantIDs []int) ([]*models.StockLevel, error) {
+	query := `
+        -- name: ListStock
+        SELECT ` + stockColumns + `
+        FROM stock_levels
+        WHERE variant_id = ANY($1)
+        ORDER BY variant_id, warehouse_id
+    `
+	return r.listStock(ctx, query, variantIDs)
+}
+
+func (r *inventoryRepository) LockStock(ctx context.Context, variantIDs []int) ([]*models.StockLevel, error) {
+	query := `
+        -- name: LockStock
+        SELECT ` + stockColumns + `
+        FROM stock_levels
+        WHERE variant_id = ANY($1)
+        ORDER BY variant_id, warehouse_id
+        FOR UPDATE
+    `
+	return r.listStock(ctx, query, variantIDs)
+}
+
+func (r *inventoryRepository) listStock(ctx context.Context, query string, variantIDs []int) ([]*models.StockLevel, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, query, pq.Array(variantIDs))
+	if err != nil {
+		return nil, translateError(err, "stock")
+	}
+	defer rows.Close()
+
+	var levels []*models.StockLevel
+	for rows.Next() {
+		level, err := scanStock(rows)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+func (r *inventoryRepository) CreateStock(ctx context.Context, variantID, warehouseID int) error {
+	query := `
+        -- name: CreateStock
+        INSERT INTO stock_levels (variant_id, warehouse_id)
+        VALUES ($1, $2)
+        ON CONFLICT (variant_id, warehouse_id) DO NOTHING
+    `
+	_, err := r.conn(ctx).ExecContext(ctx, query, variantID, warehouseID)
+	return translateError(err, "stock")
+}
+
+func (r *inventoryRepository) UpdateStock(ctx context.Context, level *models.StockLevel) error {
+	query := `
+        -- name: UpdateStock
+        UPDATE stock_levels
+        SET on_hand = $1, reserved = $2, low_stock_threshold = $3, updated_at = CURRENT_TIMESTAMP
+        WHERE variant_id = $4 AND warehouse_id = $5
+        RETURNING updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		level.OnHand, level.Reserved, level.LowStockThreshold, level.VariantID, level.WarehouseID,
+	).Scan(&level.UpdatedAt)
+	if err != nil {
+		return translateError(err, "stock")
+	}
+	level.Available = level.OnHand - level.Reserved
+	return nil
+}
+
+const adjustmentColumns = `id, variant_id, warehouse_id, reason, on_hand_change, reserved_change, on_hand, reserved,
+    reservation_id, actor_id, note, created_at`
+
+func scanAdjustment(row interface{ Scan(...any) error }) (*models.StockAdjustment, error) {
+	a := &models.StockAdjustment{}
+	var reservationID, actorID sql.NullInt64
+	err := row.Scan(&a.ID, &a.VariantID, &a.WarehouseID, &a.Reason, &a.OnHandChange, &a.ReservedChange, &a.OnHand, &a.Reserved,
+		&reservationID, &actorID, &a.Note, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if reservationID.Valid {
+		id := int(reservationID.Int64)
+		a.ReservationID = &id
+	}
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		a.ActorID = &id
+	}
+	return a, nil
+}
+
+func (r *inventoryRepository) CreateAdjustment(ctx context.Context, adjustment *models.StockAdjustment) error {
+	query := `
+        -- name: CreateStockAdjustment
+        INSERT INTO stock_adjustments (variant_id, warehouse_id, reason, on_hand_change, reserved_change, on_hand, reserved,
+            reservation_id, actor_id, note)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id, created_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		adjustment.VariantID, adjustment.WarehouseID, adjustment.Reason, adjustment.OnHandChange, adjustment.ReservedChange,
+		adjustment.OnHand, adjustment.Reserved, adjustment.ReservationID, adjustment.ActorID, adjustment.Note,
+	).Scan(&adjustment.ID, &adjustment.CreatedAt)
+	return translateError(err, "stock_adjustment")
+}
+
+func (r *inventoryRepository) ListAdjustments(ctx context.Context, variantID, limit, offset int) ([]*models.StockAdjustment, int, error) {
+	var total int
+	query := `
+        -- name: CountStockAdjustments
+        SELECT count(*)
+        FROM stock_adjustments
+        WHERE variant_id = $1
+    `
+	if err := r.conn(ctx).QueryRowContext(ctx, query, variantID).Scan(&total); err != nil {
+		return nil, 0, translateError(err, "stock_adjustment")
+	}
+
+	query = `
+        -- name: ListStockAdjustments
+        SELECT ` + adjustmentColumns + `
+        FROM stock_adjustments
+        WHERE variant_id = $1
+        ORDER BY id DESC
+        LIMIT $2 OFFSET $3
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, variantID, limit, offset)
+	if err != nil {
+		return nil, 0, translateError(err, "stock_adjustment")
+	}
+	defer rows.Close()
+
+	var adjustments []*models.StockAdjustment
+	for rows.Next() {
+		adjustment, err := scanAdjustment(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return adjustments, total, nil
+}
+
+const reservationColumns = `id, user_id, status, expires_at, created_at, updated_at`
+
+func scanReservation(row interface{ Scan(...any) error }) (*models.Reservation, error) {
+	res := &models.Reservation{}
+	if err := row.Scan(&res.ID, &res.UserID, &res.Status, &res.ExpiresAt, &res.CreatedAt, &res.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CreateReservation should run in a transaction so the reservation is never
+// seen without its items.
+func (r *inventoryRepository) CreateReservation(ctx context.Context, reservation *models.Reservation) error {
+	query := `
+        -- name: CreateReservation
+        INSERT INTO reservations (user_id, status, expires_at)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at, updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		reservation.UserID, reservation.Status, reservation.ExpiresAt,
+	).Scan(&reservation.ID, &reservation.CreatedAt, &reservation.UpdatedAt)
+	if err != nil {
+		return translateError(err, "reservation")
+	}
+
+	query = `
+        -- name: CreateReservationItem
+        INSERT INTO reservation_items (reservation_id, variant_id, warehouse_id, quantity)
+        VALUES ($1, $2, $3, $4)
+    `
+	for _, item := range reservation.Items {
+		_, err := r.conn(ctx).ExecContext(ctx, query, reservation.ID, item.VariantID, item.WarehouseID, item.Quantity)
+		if err != nil {
+			return translateError(err, "reservation")
+		}
+	}
+	return nil
+}
+
+func (r *inventoryRepository) GetReservationByID(ctx context.Context, id int) (*models.Reservation, error) {
+	query := `
+        -- name: GetReservationByID
+        SELECT ` + reservationColumns + `
+        FROM reservations
+        WHERE id = $1
+    `
+	return r.getReservation(ctx, query, id)
+}
+
+func (r *inventoryRepository) LockReservation(ctx context.Context, id int) (*models.Reservation, error) {
+	query := `
+        -- name: LockReservation
+        SELECT ` + reservationColumns + `
+        FROM reservations
+        WHERE id = $1
+        FOR UPDATE
+    `
+	return r.getReservation(ctx, query, id)
+}
+
+func (r *inventoryRepository) getReservation(ctx context.Context, query string, id int) (*models.Reservation, error) {
+	reservation, err := scanReservation(r.conn(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, translateError(err, "reservation")
+	}
+
+	query = `
+        -- name: ListReservationItems
+        SELECT variant_id, warehouse_id, quantity
+        FROM reservation_items
+        WHERE reservation_id = $1
+        ORDER BY variant_id, warehouse_id
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, translateError(err, "reservation")
+	}
+	defer rows.Close()
+
+	reservation.Items = []models.ReservationItem{}
+	for rows.Next() {
+		var item models.ReservationItem
+		if err := rows.Scan(&item.VariantID, &item.WarehouseID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		reservation.Items = append(reservation.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+func (r *inventoryRepository) UpdateReservationStatus(ctx context.Context, reservation *models.Reservation) error {
+	query := `
+        -- name: UpdateReservationStatus
+        UPDATE reservations
+        SET status = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $2
+        RETURNING updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query, reservation.Status, reservation.ID).Scan(&reservation.UpdatedAt)
+	return translateError(err, "reservation")
+}
+
+func (r *inventoryRepository) ListExpiredReservations(ctx context.Context, now time.Time, limit int) ([]*models.Reservation, error) {
+	query := `
+        -- name: ListExpiredReservations
+        SELECT ` + reservationColumns + `
+        FROM reservations
+        WHERE status = 'active' AND expires_at < $1
+        ORDER BY expires_at
+        LIMIT $2
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, translateError(err, "reservation")
+	}
+	defer rows.Close()
+
+	var reservations []*models.Reservation
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+// memoryUserRepository is an in-memory UserRepository for tests and local
+// runs without Postgres. It returns the same domain errors as the SQL
+// implementation.
+type memoryUserRepository struct {
+	mu        sync.Mutex
+	users     map[int]*models.User
+	otps      map[int]*models.OTP
+	addresses map[int]*models.Address
+	nextID    int
+}
+
+func NewMemoryUserRepository() UserRepository {
+	return &memoryUserRepository{
+		users:     make(map[int]*models.User),
+		otps:      make(map[int]*models.OTP),
+		addresses: make(map[int]*models.Address),
+	}
+}
+
+func (r *memoryUserRepository) id() int {
+	r.nextID++
+	return r.nextID
+}
+
+func (r *memoryUserRepository) CreateUser(_ context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.Email == user.Email {
+			return apperror.Conflict("user_already_exists", "user already exists")
+		}
+	}
+	user.ID = r.id()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+func (r *memoryUserRepository) UpdateUser(_ context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.users[user.ID]
+	if !ok {
+		return apperror.NotFound("user_not_found", "user not found")
+	}
+	for _, u := range r.users {
+		if u.ID != user.ID && u.Email == user.Email {
+			return apperror.Conflict("user_already_exists", "user already exists")
+		}
+	}
+	existing.FirstName = user.FirstName
+	existing.LastName = user.LastName
+	existing.Email = user.Email
+	existing.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *memoryUserRepository) UpdatePassword(_ context.Context, userID int, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.users[userID]
+	if !ok {
+		return apperror.NotFound("user_not_found", "user not found")
+	}
+	existing.Password = hash
+	existing.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *memoryUserRepository) UpdatePermissions(_ context.Context, userID int, permissions []models.Permission) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.users[userID]
+	if !ok {
+		return apperror.NotFound("user_not_found", "user not found")
+	}
+	existing.Permissions = slices.Clone(permissions)
+	existing.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *memoryUserRepository) GetUserByEmail(_ context.Context, email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.Email == email {
+			user := *u
+			return &user, nil
+		}
+	}
+	return nil, apperror.NotFound("user_not_found", "user not found")
+}
+
+func (r *memoryUserRepository) GetUserByID(_ context.Context, id int) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return nil, apperror.NotFound("user_not_found", "user not found")
+	}
+	user := *u
+	return &user, nil
+}
+
+func (r *memoryUserRepository) CreateOTP(_ context.Context, otp *models.OTP) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[otp.UserID]; !ok {
+		return apperror.Validation("invalid_reference", "referenced record does not exist")
+	}
+	otp.ID = r.id()
+	otp.CreatedAt = time.Now()
+	otp.UpdatedAt = otp.CreatedAt
+	stored := *otp
+	r.otps[otp.ID] = &stored
+	return nil
+}
+
+func (r *memoryUserRepository) GetOTPByUserID(_ context.Context, userID int) (*models.OTP, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var latest *models.OTP
+	for _, o := range r.otps {
+		if o.UserID == userID && (latest == nil || o.ID > latest.ID) {
+			latest = o
+		}
+	}
+	if latest == nil {
+		return nil, apperror.NotFound("otp_not_found", "otp not found")
+	}
+	otp := *latest
+	return &otp, nil
+}
+
+func (r *memoryUserRepository) GetOTPByOTP(_ context.Context, code string) (*models.OTP, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, o := range r.otps {
+		if o.OTP == code {
+			otp := *o
+			return &otp, nil
+		}
+	}
+	return nil, apperror.NotFound("otp_not_found", "otp not found")
+}
+
+func (r *memoryUserRepository) DeleteOTP(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.otps[id]; !ok {
+		return apperror.NotFound("otp_not_found", "otp not found")
+	}
+	delete(r.otps, id)
+	return nil
+}
+
+func (r *memoryUserRepository) CreateAddress(_ context.Context, address *models.Address) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[address.UserID]; !ok {
+		return apperror.Validation("invalid_reference", "referenced record does not exist")
+	}
+	address.ID = r.id()
+	address.CreatedAt = time.Now()
+	address.UpdatedAt = address.CreatedAt
+	stored := *address
+	r.addresses[address.ID] = &stored
+	return nil
+}
+
+func (r *memoryUserRepository) GetAddressesByUserID(_ context.Context, userID int) ([]*models.Address, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var addresses []*models.Address
+	for _, a := range r.addresses {
+		if a.UserID == userID {
+			address := *a
+			addresses = append(addresses, &address)
+		}
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i].ID < addresses[j].ID })
+	return addresses, nil
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+var (
+	errCartNotFound = apperror.NotFound("cart_not_found", "cart not found")
+	errCartExists   = apperror.Conflict("cart_already_exists", "cart already exists")
+)
+
+// memoryCartRepository is an in-memory CartRepository for tests and local
+// runs without Postgres. Nothing is locked between calls, and items of
+// deleted variants stay in carts.
+type memoryCartRepository struct {
+	mu     sync.Mutex
+	carts  map[int]*models.Cart
+	nextID int
+}
+
+func NewMemoryCartRepository() CartRepository {
+	return &memoryCartRepository{carts: make(map[int]*models.Cart)}
+}
+
+// taken reports whether another cart has the same user or token hash.
+func (r *memoryCartRepository) taken(cart *models.Cart) bool {
+	for _, existing := range r.carts {
+		if existing.ID == cart.ID {
+			continue
+		}
+		if cart.UserID != nil && existing.UserID != nil && *cart.UserID == *existing.UserID {
+			return true
+		}
+		if cart.TokenHash != "" && cart.TokenHash == existing.TokenHash {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryCartRepository) CreateCart(_ context.Context, cart *models.Cart) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.taken(cart) {
+		return errCartExists
+	}
+	r.nextID++
+	cart.ID = r.nextID
+	cart.CreatedAt = time.Now()
+	cart.UpdatedAt = cart.CreatedAt
+	r.carts[cart.ID] = copyCart(cart)
+	return nil
+}
+
+func (r *memoryCartRepository) UpdateCart(_ context.Context, cart *models.Cart) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.carts[cart.ID]
+	if !ok {
+		return errCartNotFound
+	}
+	if r.taken(cart) {
+		return errCartExists
+	}
+	existing.UserID = cart.UserID
+	existing.TokenHash = cart.TokenHash
+	existing.Currency = cart.Currency
+	existing.ExpiresAt = cart.ExpiresAt
+	existing.UpdatedAt = time.Now()
+	cart.UpdatedAt = existing.UpdatedAt
+	return nil
+}
+
+func (r *memoryCartRepository) GetCartByUserID(_ context.Context, userID int) (*models.Cart, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, cart := range r.carts {
+		if cart.UserID != nil && *cart.UserID == userID {
+			return copyCart(cart), nil
+		}
+	}
+	return nil, errCartNotFound
+}
+
+func (r *memoryCartRepository) GetCartByTokenHash(_ context.Context, tokenHash string) (*models.Cart, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, cart := range r.carts {
+		if cart.TokenHash != "" && cart.TokenHash == tokenHash {
+			return copyCart(cart), nil
+		}
+	}
+	return nil, errCartNotFound
+}
+
+func (r *memoryCartRepository) LockCart(_ context.Context, id int) (*models.Cart, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cart, ok := r.carts[id]
+	if !ok {
+		return nil, errCartNotFound
+	}
+	return copyCart(cart), nil
+}
+
+func (r *memoryCartRepository) DeleteCart(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.carts, id)
+	return nil
+}
+
+func (r *memoryCartRepository) DeleteExpiredCarts(_ context.Context, now time.Time, limit int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var expired []*models.Cart
+	for _, cart := range r.carts {
+		if cart.ExpiresAt.Before(now) {
+			expired = append(expired, cart)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].ExpiresAt.Before(expired[j].ExpiresAt) })
+	expired = paginate(expired, limit, 0)
+	for _, cart := range expired {
+		delete(r.carts, cart.ID)
+	}
+	return len(expired), nil
+}
+
+func (r *memoryCartRepository) SaveItem(_ context.Context, cartID int, item *models.CartItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cart, ok := r.carts[cartID]
+	if !ok {
+		return apperror.Validation("invalid_reference", "referenced record does not exist")
+	}
+	i := slices.IndexFunc(cart.Items, func(it models.CartItem) bool { return it.VariantID == item.VariantID })
+	if i >= 0 {
+		item.AddedAt = cart.Items[i].AddedAt
+		cart.Items[i].Quantity = item.Quantity
+		cart.Items[i].AddedPrice = item.AddedPrice
+		return nil
+	}
+	item.AddedAt = time.Now()
+	cart.Items = append(cart.Items, models.CartItem{
+		VariantID: item.VariantID, Quantity: item.Quantity, AddedPrice: item.AddedPrice, AddedAt: item.AddedAt,
+	})
+	return nil
+}
+
+func (r *memoryCartRepository) DeleteItem(_ context.Context, cartID, variantID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cart, ok := r.carts[cartID]; ok {
+		cart.Items = slices.DeleteFunc(cart.Items, func(it models.CartItem) bool { return it.VariantID == variantID })
+	}
+	return nil
+}
+
+func copyCart(cart *models.Cart) *models.Cart {
+	cp := *cart
+	cp.Token = ""
+	cp.Items = slices.Clone(cart.Items)
+	if cp.Items == nil {
+		cp.Items = []models.CartItem{}
+	}
+	if cart.UserID != nil {
+		id := *cart.UserID
+		cp.UserID = &id
+	}
+	return &cp
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+var (
+	errCategoryNotFound = apperror.NotFound("category_not_found", "category not found")
+	errCategoryExists   = apperror.Conflict("category_already_exists", "category already exists")
+)
+
+// memoryCategoryRepository is an in-memory CategoryRepository for tests and
+// local runs without Postgres.
+type memoryCategoryRepository struct {
+	mu         sync.Mutex
+	categories map[int]*models.Category
+	attributes map[int][]models.CategoryAttribute
+	nextID     int
+}
+
+func NewMemoryCategoryRepository() CategoryRepository {
+	return &memoryCategoryRepository{
+		categories: make(map[int]*models.Category),
+		attributes: make(map[int][]models.CategoryAttribute),
+	}
+}
+
+func (r *memoryCategoryRepository) slugTaken(slug string, except int) bool {
+	for _, c := range r.categories {
+		if c.ID != except && c.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// checkParent fails like the foreign key when the parent doesn't exist.
+func (r *memoryCategoryRepository) checkParent(category *models.Category) error {
+	if category.ParentID == nil {
+		return nil
+	}
+	if _, ok := r.categories[*category.ParentID]; !ok {
+		return apperror.Validation("invalid_reference", "referenced record does not exist")
+	}
+	return nil
+}
+
+func (r *memoryCategoryRepository) CreateCategory(_ context.Context, category *models.Category) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkParent(category); err != nil {
+		return err
+	}
+	if r.slugTaken(category.Slug, 0) {
+		return errCategoryExists
+	}
+	r.nextID++
+	category.ID = r.nextID
+	category.CreatedAt = time.Now()
+	category.UpdatedAt = category.CreatedAt
+	r.categories[category.ID] = copyCategory(category)
+	return nil
+}
+
+func (r *memoryCategoryRepository) UpdateCategory(_ context.Context, category *models.Category) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.categories[category.ID]
+	if !ok {
+		return errCategoryNotFound
+	}
+	if err := r.checkParent(category); err != nil {
+		return err
+	}
+	if r.slugTaken(category.Slug, category.ID) {
+		return errCategoryExists
+	}
+	category.CreatedAt = existing.CreatedAt
+	category.UpdatedAt = time.Now()
+	r.categories[category.ID] = copyCategory(category)
+	return nil
+}
+
+func (r *memoryCategoryRepository) GetCategoryByID(_ context.Context, id int) (*models.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.categories[id]
+	if !ok {
+		return nil, errCategoryNotFound
+	}
+	return copyCategory(c), nil
+}
+
+func (r *memoryCategoryRepository) ListCategories(_ context.Context) ([]*models.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var categories []*models.Category
+	for _, c := range r.categories {
+		categories = append(categories, copyCategory(c))
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Position != categories[j].Position {
+			return categories[i].Position < categories[j].Position
+		}
+		return categories[i].ID < categories[j].ID
+	})
+	return categories, nil
+}
+
+func (r *memoryCategoryRepository) DeleteCategory(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.categories[id]; !ok {
+		return errCategoryNotFound
+	}
+	delete(r.categories, id)
+	delete(r.attributes, id)
+	return nil
+}
+
+func (r *memoryCategoryRepository) ListAttributes(_ context.Context, categoryID int) ([]models.CategoryAttribute, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return copyAttributes(r.attributes[categoryID]), nil
+}
+
+func (r *memoryCategoryRepository) ReplaceAttributes(_ context.Context, categoryID int, attributes []models.CategoryAttribute) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.categories[categoryID]; !ok {
+		return apperror.Validation("invalid_reference", "referenced record does not exist")
+	}
+	attributes = copyAttributes(attributes)
+	for i := range attributes {
+		attributes[i].CategoryID = categoryID
+	}
+	r.attributes[categoryID] = attributes
+	return nil
+}
+
+func copyCategory(c *models.Category) *models.Category {
+	cp := *c
+	if c.ParentID != nil {
+		parentID := *c.ParentID
+		cp.ParentID = &parentID
+	}
+	return &cp
+}
+
+func copyAttributes(attributes []models.CategoryAttribute) []models.CategoryAttribute {
+	cp := slices.Clone(attributes)
+	for i := range cp {
+		cp[i].Options = slices.Clone(cp[i].Options)
+	}
+	return cp
+}
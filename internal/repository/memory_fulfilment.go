@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+var (
+	errFulfilmentNotFound = apperror.NotFound("fulfilment_not_found", "fulfilment not found")
+	errFulfilmentExists   = apperror.Conflict("fulfilment_already_exists", "fulfilment already exists")
+)
+
+// memoryFulfilmentRepository is an in-memory FulfilmentRepository for tests
+// and local runs without Postgres. Nothing is locked between calls. As it
+// can't read the orders, it keeps the items and shipping address given to
+// CreateFulfilment.
+type memoryFulfilmentRepository struct {
+	mu          sync.Mutex
+	fulfilments map[int]*models.Fulfilment
+	nextID      int
+	nextEventID int
+}
+
+func NewMemoryFulfilmentRepository() FulfilmentRepository {
+	return &memoryFulfilmentRepository{fulfilments: make(map[int]*models.Fulfilment)}
+}
+
+func (r *memoryFulfilmentRepository) CreateFulfilment(_ context.Context, fulfilment *models.Fulfilment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.fulfilments {
+		if existing.OrderID == fulfilment.OrderID && existing.SellerID == fulfilment.SellerID {
+			return errFulfilmentExists
+		}
+	}
+	r.nextID++
+	fulfilment.ID = r.nextID
+	fulfilment.CreatedAt = time.Now()
+	fulfilment.UpdatedAt = fulfilment.CreatedAt
+	cp := copyFulfilment(fulfilment)
+	cp.Events = []models.ShipmentEvent{}
+	r.fulfilments[fulfilment.ID] = cp
+	return nil
+}
+
+func (r *memoryFulfilmentRepository) GetFulfilmentByID(_ context.Context, id int) (*models.Fulfilment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fulfilment, ok := r.fulfilments[id]
+	if !ok {
+		return nil, errFulfilmentNotFound
+	}
+	return copyFulfilment(fulfilment), nil
+}
+
+func (r *memoryFulfilmentRepository) LockFulfilment(ctx context.Context, id int) (*models.Fulfilment, error) {
+	return r.GetFulfilmentByID(ctx, id)
+}
+
+func (r *memoryFulfilmentRepository) ListFulfilmentsByOrderID(_ context.Context, orderID int) ([]*models.Fulfilment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fulfilments := []*models.Fulfilment{}
+	for _, fulfilment := range r.fulfilments {
+		if fulfilment.OrderID == orderID {
+			fulfilments = append(fulfilments, copyFulfilment(fulfilment))
+		}
+	}
+	sort.Slice(fulfilments, func(i, j int) bool { return fulfilments[i].ID < fulfilments[j].ID })
+	return fulfilments, nil
+}
+
+func (r *memoryFulfilmentRepository) ListFulfilments(_ context.Context, filter FulfilmentFilter) ([]*models.Fulfilment, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	matched := []*models.Fulfilment{}
+	for _, fulfilment := range r.fulfilments {
+		if filter.SellerID != 0 && fulfilment.SellerID != filter.SellerID {
+			continue
+		}
+		if filter.Status != "" && fulfilment.Status != filter.Status {
+			continue
+		}
+		cp := copyFulfilment(fulfilment)
+		cp.Events = nil
+		matched = append(matched, cp)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+	return paginate(matched, filter.Limit, filter.Offset), len(matched), nil
+}
+
+func (r *memoryFulfilmentRepository) SummarizeFulfilments(_ context.Context, sellerID int) (*models.FulfilmentSummary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &models.FulfilmentSummary{}
+	for _, fulfilment := range r.fulfilments {
+		if fulfilment.SellerID != sellerID {
+			continue
+		}
+		switch fulfilment.Status {
+		case models.FulfilmentPending:
+			s.Pending++
+		case models.FulfilmentAccepted:
+			s.Accepted++
+		case models.FulfilmentShipped:
+			s.Shipped++
+		case models.FulfilmentDelivered:
+			s.Delivered++
+		case models.FulfilmentCancelled:
+			s.Cancelled++
+		}
+	}
+	return s, nil
+}
+
+func (r *memoryFulfilmentRepository) UpdateFulfilment(_ context.Context, fulfilment *models.Fulfilment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.fulfilments[fulfilment.ID]
+	if !ok {
+		return errFulfilmentNotFound
+	}
+	existing.Status = fulfilment.Status
+	existing.Carrier = fulfilment.Carrier
+	existing.TrackingNumber = fulfilment.TrackingNumber
+	existing.AcceptedAt = fulfilment.AcceptedAt
+	existing.ShippedAt = fulfilment.ShippedAt
+	existing.DeliveredAt = fulfilment.DeliveredAt
+	existing.CancelledAt = fulfilment.CancelledAt
+	existing.UpdatedAt = time.Now()
+	fulfilment.UpdatedAt = existing.UpdatedAt
+	return nil
+}
+
+func (r *memoryFulfilmentRepository) CreateShipmentEvent(_ context.Context, event *models.ShipmentEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fulfilment, ok := r.fulfilments[event.FulfilmentID]
+	if !ok {
+		return errFulfilmentNotFound
+	}
+	r.nextEventID++
+	event.ID = r.nextEventID
+	event.CreatedAt = time.Now()
+	fulfilment.Events = append(fulfilment.Events, *event)
+	return nil
+}
+
+func copyFulfilment(fulfilment *models.Fulfilment) *models.Fulfilment {
+	cp := *fulfilment
+	cp.Items = make([]models.OrderItem, len(fulfilment.Items))
+	for i, item := range fulfilment.Items {
+		item.Options = maps.Clone(item.Options)
+		cp.Items[i] = item
+	}
+	slices.SortFunc(cp.Items, func(a, b models.OrderItem) int {
+		return cmp.Or(cmp.Compare(a.ProductName, b.ProductName), cmp.Compare(a.SKU, b.SKU))
+	})
+	cp.Events = slices.Clone(fulfilment.Events)
+	slices.SortStableFunc(cp.Events, func(a, b models.ShipmentEvent) int {
+		return a.OccurredAt.Compare(b.OccurredAt)
+	})
+	return &cp
+}
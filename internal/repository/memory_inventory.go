@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+var (
+	errWarehouseNotFound   = apperror.NotFound("warehouse_not_found", "warehouse not found")
+	errWarehouseExists     = apperror.Conflict("warehouse_already_exists", "warehouse already exists")
+	errStockNotFound       = apperror.NotFound("stock_not_found", "stock not found")
+	errReservationNotFound = apperror.NotFound("reservation_not_found", "reservation not found")
+)
+
+type stockKey struct {
+	variantID, warehouseID int
+}
+
+// memoryInventoryRepository is an in-memory InventoryRepository for tests
+// and local runs without Postgres. Nothing is locked between calls, so
+// concurrent reservations of the same stock are not serialized.
+type memoryInventoryRepository struct {
+	mu           sync.Mutex
+	warehouses   map[int]*models.Warehouse
+	stock        map[stockKey]*models.StockLevel
+	adjustments  []*models.StockAdjustment
+	reservations map[int]*models.Reservation
+	nextID       int
+}
+
+func NewMemoryInventoryRepository() InventoryRepository {
+	return &memoryInventoryRepository{
+		warehouses:   make(map[int]*models.Warehouse),
+		stock:        make(map[stockKey]*models.StockLevel),
+		reservations: make(map[int]*models.Reservation),
+	}
+}
+
+func (r *memoryInventoryRepository) id() int {
+	r.nextID++
+	return r.nextID
+}
+
+func (r *memoryInventoryRepository) codeTaken(w *models.Warehouse) bool {
+	for _, existing := range r.warehouses {
+		if existing.ID != w.ID && existing.SellerID == w.SellerID && existing.Code == w.Code {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryInventoryRepository) CreateWarehouse(_ context.Context, warehouse *models.Warehouse) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.codeTaken(warehouse) {
+		return errWarehouseExists
+	}
+	warehouse.ID = r.id()
+	warehouse.CreatedAt = time.Now()
+	warehouse.UpdatedAt = warehouse.CreatedAt
+	cp := *warehouse
+	r.warehouses[warehouse.ID] = &cp
+	return nil
+}
+
+func (r *memoryInventoryRepository) UpdateWarehouse(_ context.Context, warehouse *models.Warehouse) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.warehouses[warehouse.ID]
+	if !ok {
+		return errWarehouseNotFound
+	}
+	warehouse.SellerID = existing.SellerID
+	if r.codeTaken(warehouse) {
+		return errWarehouseExists
+	}
+	warehouse.CreatedAt = existing.CreatedAt
+	warehouse.UpdatedAt = time.Now()
+	cp := *warehouse
+	r.warehouses[warehouse.ID] = &cp
+	return nil
+}
+
+func (r *memoryInventoryRepository) GetWarehouseByID(_ context.Context, id int) (*models.Warehouse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.warehouses[id]
+	if !ok {
+		return nil, errWarehouseNotFound
+	}
+	cp := *w
+	return &cp, nil
+}
+
+func (r *memoryInventoryRepository) ListWarehouses(_ context.Context, sellerID int) ([]*models.Warehouse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var warehouses []*models.Warehouse
+	for _, w := range r.warehouses {
+		if w.SellerID == sellerID {
+			cp := *w
+			warehouses = append(warehouses, &cp)
+		}
+	}
+	sort.Slice(warehouses, func(i, j int) bool {
+		if warehouses[i].Name != warehouses[j].Name {
+			return warehouses[i].Name < warehouses[j].Name
+		}
+		return warehouses[i].ID < warehouses[j].ID
+	})
+	return warehouses, nil
+}
+
+func (r *memoryInventoryRepository) ListStock(_ context.Context, variantIDs []int) ([]*models.StockLevel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var levels []*models.StockLevel
+	for _, s := range r.stock {
+		if slices.Contains(variantIDs, s.VariantID) {
+			cp := *s
+			cp.Available = cp.OnHand - cp.Reserved
+			levels = append(levels, &cp)
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if levels[i].VariantID != levels[j].VariantID {
+			return levels[i].VariantID < levels[j].VariantID
+		}
+		return levels[i].WarehouseID < levels[j].WarehouseID
+	})
+	return levels, nil
+}
+
+func (r *memoryInventoryRepository) LockStock(ctx context.Context, variantIDs []int) ([]*models.StockLevel, error) {
+	return r.ListStock(ctx, variantIDs)
+}
+
+func (r *memoryInventoryRepository) CreateStock(_ context.Context, variantID, warehouseID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.warehouses[warehouseID]; !ok {
+		return apperror.Validation("invalid_reference", "referenced record does not exist")
+	}
+	key := stockKey{variantID, warehouseID}
+	if _, ok := r.stock[key]; !ok {
+		r.stock[key] = &models.StockLevel{VariantID: variantID, WarehouseID: warehouseID, UpdatedAt: time.Now()}
+	}
+	return nil
+}
+
+func (r *memoryInventoryRepository) UpdateStock(_ context.Context, level *models.StockLevel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := stockKey{level.VariantID, level.WarehouseID}
+	if _, ok := r.stock[key]; !ok {
+		return errStockNotFound
+	}
+	if level.OnHand < 0 || level.Reserved < 0 || level.Reserved > level.OnHand || level.LowStockThreshold < 0 {
+		return apperror.Validation("invalid_stock", "stock is invalid")
+	}
+	level.UpdatedAt = time.Now()
+	level.Available = level.OnHand - level.Reserved
+	cp := *level
+	r.stock[key] = &cp
+	return nil
+}
+
+func (r *memoryInventoryRepository) CreateAdjustment(_ context.Context, adjustment *models.StockAdjustment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	adjustment.ID = r.id()
+	adjustment.CreatedAt = time.Now()
+	cp := *adjustment
+	r.adjustments = append(r.adjustments, &cp)
+	return nil
+}
+
+func (r *memoryInventoryRepository) ListAdjustments(_ context.Context, variantID, limit, offset int) ([]*models.StockAdjustment, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matched []*models.StockAdjustment
+	for i := len(r.adjustments) - 1; i >= 0; i-- {
+		if a := r.adjustments[i]; a.VariantID == variantID {
+			cp := *a
+			matched = append(matched, &cp)
+		}
+	}
+	return paginate(matched, limit, offset), len(matched), nil
+}
+
+func (r *memoryInventoryRepository) CreateReservation(_ context.Context, reservation *models.Reservation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reservation.ID = r.id()
+	reservation.CreatedAt = time.Now()
+	reservation.UpdatedAt = reservation.CreatedAt
+	r.reservations[reservation.ID] = copyReservation(reservation)
+	return nil
+}
+
+func (r *memoryInventoryRepository) GetReservationByID(_ context.Context, id int) (*models.Reservation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, ok := r.reservations[id]
+	if !ok {
+		return nil, errReservationNotFound
+	}
+	return copyReservation(res), nil
+}
+
+func (r *memoryInventoryRepository) LockReservation(ctx context.Context, id int) (*models.Reservation, error) {
+	return r.GetReservationByID(ctx, id)
+}
+
+func (r *memoryInventoryRepository) UpdateReservationStatus(_ context.Context, reservation *models.Reservation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.reservations[reservation.ID]
+	if !ok {
+		return errReservationNotFound
+	}
+	existing.Status = reservation.Status
+	existing.UpdatedAt = time.Now()
+	reservation.UpdatedAt = existing.UpdatedAt
+	return nil
+}
+
+func (r *memoryInventoryRepository) ListExpiredReservations(_ context.Context, now time.Time, limit int) ([]*models.Reservation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var expired []*models.Reservation
+	for _, res := range r.reservations {
+		if res.Status == models.ReservationActive && res.ExpiresAt.Before(now) {
+			cp := *res
+			cp.Items = nil
+			expired = append(expired, &cp)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].ExpiresAt.Before(expired[j].ExpiresAt) })
+	return paginate(expired, limit, 0), nil
+}
+
+func copyReservation(res *models.Reservation) *models.Reservation {
+	cp := *res
+	cp.Items = slices.Clone(res.Items)
+	if cp.Items == nil {
+		cp.Items = []models.ReservationItem{}
+	}
+	return &cp
+}
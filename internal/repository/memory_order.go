@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+var (
+	errOrderNotFound = apperror.NotFound("order_not_found", "order not found")
+	errOrderExists   = apperror.Conflict("order_already_exists", "order already exists")
+)
+
+// memoryOrderRepository is an in-memory OrderRepository for tests and local
+// runs without Postgres. Nothing is locked between calls.
+type memoryOrderRepository struct {
+	mu     sync.Mutex
+	orders map[int]*models.Order
+	nextID int
+}
+
+func NewMemoryOrderRepository() OrderRepository {
+	return &memoryOrderRepository{orders: make(map[int]*models.Order)}
+}
+
+func (r *memoryOrderRepository) CreateOrder(_ context.Context, order *models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.orders {
+		if existing.UserID == order.UserID && existing.IdempotencyKey == order.IdempotencyKey {
+			return errOrderExists
+		}
+	}
+	r.nextID++
+	order.ID = r.nextID
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = order.CreatedAt
+	r.orders[order.ID] = copyOrder(order)
+	return nil
+}
+
+func (r *memoryOrderRepository) GetOrderByID(_ context.Context, id int) (*models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[id]
+	if !ok {
+		return nil, errOrderNotFound
+	}
+	return copyOrder(order), nil
+}
+
+func (r *memoryOrderRepository) GetOrderByIdempotencyKey(_ context.Context, userID int, key string) (*models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, order := range r.orders {
+		if order.UserID == userID && order.IdempotencyKey == key {
+			return copyOrder(order), nil
+		}
+	}
+	return nil, errOrderNotFound
+}
+
+func (r *memoryOrderRepository) LockOrder(ctx context.Context, id int) (*models.Order, error) {
+	return r.GetOrderByID(ctx, id)
+}
+
+func (r *memoryOrderRepository) ListOrders(_ context.Context, filter OrderFilter) ([]*models.Order, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matched []*models.Order
+	for _, order := range r.orders {
+		if filter.UserID != 0 && order.UserID != filter.UserID {
+			continue
+		}
+		if filter.Status != "" && order.Status != filter.Status {
+			continue
+		}
+		matched = append(matched, copyOrder(order))
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+	return paginate(matched, filter.Limit, filter.Offset), len(matched), nil
+}
+
+func (r *memoryOrderRepository) UpdateOrderStatus(_ context.Context, order *models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.orders[order.ID]
+	if !ok {
+		return errOrderNotFound
+	}
+	existing.Status = order.Status
+	existing.PaidAt = order.PaidAt
+	existing.FulfilledAt = order.FulfilledAt
+	existing.DeliveredAt = order.DeliveredAt
+	existing.CancelledAt = order.CancelledAt
+	existing.UpdatedAt = time.Now()
+	order.UpdatedAt = existing.UpdatedAt
+	return nil
+}
+
+func (r *memoryOrderRepository) ListExpiredOrders(_ context.Context, now time.Time, limit int) ([]*models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var expired []*models.Order
+	for _, order := range r.orders {
+		if order.Status == models.OrderPendingPayment && order.ExpiresAt.Before(now) {
+			cp := *order
+			cp.Items = nil
+			expired = append(expired, &cp)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].ExpiresAt.Before(expired[j].ExpiresAt) })
+	return paginate(expired, limit, 0), nil
+}
+
+func copyOrder(order *models.Order) *models.Order {
+	cp := *order
+	cp.Items = make([]models.OrderItem, len(order.Items))
+	for i, item := range order.Items {
+		item.Options = maps.Clone(item.Options)
+		cp.Items[i] = item
+	}
+	slices.SortFunc(cp.Items, func(a, b models.OrderItem) int {
+		return cmp.Or(cmp.Compare(a.ProductName, b.ProductName), cmp.Compare(a.SKU, b.SKU))
+	})
+	return &cp
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+var (
+	errPaymentNotFound   = apperror.NotFound("payment_not_found", "payment not found")
+	errPaymentExists     = apperror.Conflict("payment_already_exists", "payment already exists")
+	errOperationNotFound = apperror.NotFound("payment_operation_not_found", "payment_operation not found")
+	errOperationExists   = apperror.Conflict("payment_operation_already_exists", "payment_operation already exists")
+)
+
+// memoryPaymentRepository is an in-memory PaymentRepository for tests and
+// local runs without Postgres. Nothing is locked between calls.
+type memoryPaymentRepository struct {
+	mu       sync.Mutex
+	payments map[int]*models.Payment
+	events   map[[2]string]bool
+	ops      map[int]*models.PaymentOperation
+	nextID   int
+}
+
+func NewMemoryPaymentRepository() PaymentRepository {
+	return &memoryPaymentRepository{
+		payments: make(map[int]*models.Payment),
+		events:   make(map[[2]string]bool),
+		ops:      make(map[int]*models.PaymentOperation),
+	}
+}
+
+// taken reports whether another payment has the same provider reference.
+func (r *memoryPaymentRepository) taken(payment *models.Payment) bool {
+	if payment.Reference == "" {
+		return false
+	}
+	for _, existing := range r.payments {
+		if existing.ID != payment.ID && existing.Provider == payment.Provider && existing.Reference == payment.Reference {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryPaymentRepository) CreatePayment(_ context.Context, payment *models.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.taken(payment) {
+		return errPaymentExists
+	}
+	r.nextID++
+	payment.ID = r.nextID
+	payment.CreatedAt = time.Now()
+	payment.UpdatedAt = payment.CreatedAt
+	cp := *payment
+	r.payments[payment.ID] = &cp
+	return nil
+}
+
+func (r *memoryPaymentRepository) LockPayment(_ context.Context, id int) (*models.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payment, ok := r.payments[id]
+	if !ok {
+		return nil, errPaymentNotFound
+	}
+	cp := *payment
+	return &cp, nil
+}
+
+func (r *memoryPaymentRepository) LockPaymentByReference(_ context.Context, provider, reference string) (*models.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, payment := range r.payments {
+		if payment.Provider == provider && payment.Reference == reference {
+			cp := *payment
+			return &cp, nil
+		}
+	}
+	return nil, errPaymentNotFound
+}
+
+func (r *memoryPaymentRepository) ListPaymentsByOrderID(_ context.Context, orderID int) ([]*models.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payments := []*models.Payment{}
+	for _, payment := range r.payments {
+		if payment.OrderID == orderID {
+			cp := *payment
+			payments = append(payments, &cp)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].ID < payments[j].ID })
+	return payments, nil
+}
+
+func (r *memoryPaymentRepository) UpdatePayment(_ context.Context, payment *models.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.payments[payment.ID]
+	if !ok {
+		return errPaymentNotFound
+	}
+	if r.taken(payment) {
+		return errPaymentExists
+	}
+	existing.Reference = payment.Reference
+	existing.Status = payment.Status
+	existing.CapturedAmount = payment.CapturedAmount
+	existing.RefundedAmount = payment.RefundedAmount
+	existing.DeclineCode = payment.DeclineCode
+	existing.ActionURL = payment.ActionURL
+	existing.UpdatedAt = time.Now()
+	payment.UpdatedAt = existing.UpdatedAt
+	return nil
+}
+
+func (r *memoryPaymentRepository) RecordEvent(_ context.Context, provider, eventID string, _ int, _ string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]string{provider, eventID}
+	if r.events[key] {
+		return false, nil
+	}
+	r.events[key] = true
+	return true, nil
+}
+
+// byKey returns the operation with key that hasn't failed.
+func (r *memoryPaymentRepository) byKey(key string) *models.PaymentOperation {
+	for _, op := range r.ops {
+		if op.Key == key && op.Status != models.OperationFailed {
+			return op
+		}
+	}
+	return nil
+}
+
+func (r *memoryPaymentRepository) CreateOperation(_ context.Context, op *models.PaymentOperation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.payments[op.PaymentID]; !ok {
+		return apperror.Validation("invalid_reference", "referenced record does not exist")
+	}
+	if r.byKey(op.Key) != nil {
+		return errOperationExists
+	}
+	r.nextID++
+	op.ID = r.nextID
+	op.CreatedAt = time.Now()
+	op.UpdatedAt = op.CreatedAt
+	cp := *op
+	r.ops[op.ID] = &cp
+	return nil
+}
+
+func (r *memoryPaymentRepository) GetOperationByKey(_ context.Context, key string) (*models.PaymentOperation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op := r.byKey(key)
+	if op == nil {
+		return nil, errOperationNotFound
+	}
+	cp := *op
+	return &cp, nil
+}
+
+func (r *memoryPaymentRepository) LockOperation(_ context.Context, id int) (*models.PaymentOperation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op, ok := r.ops[id]
+	if !ok {
+		return nil, errOperationNotFound
+	}
+	cp := *op
+	return &cp, nil
+}
+
+func (r *memoryPaymentRepository) ListOperationsByPaymentID(_ context.Context, paymentID int) ([]*models.PaymentOperation, error) {
+	return r.listOperations(func(op *models.PaymentOperation) bool { return op.PaymentID == paymentID }, 0), nil
+}
+
+func (r *memoryPaymentRepository) ListPendingOperations(_ context.Context, before time.Time, limit int) ([]*models.PaymentOperation, error) {
+	return r.listOperations(func(op *models.PaymentOperation) bool {
+		return op.Status == models.OperationPending && op.CreatedAt.Before(before)
+	}, limit), nil
+}
+
+// listOperations returns copies of up to limit operations that match,
+// oldest first. A limit of 0 returns them all.
+func (r *memoryPaymentRepository) listOperations(match func(*models.PaymentOperation) bool, limit int) []*models.PaymentOperation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ops := []*models.PaymentOperation{}
+	for _, op := range r.ops {
+		if match(op) {
+			cp := *op
+			ops = append(ops, &cp)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].ID < ops[j].ID })
+	if limit > 0 && len(ops) > limit {
+		ops = ops[:limit]
+	}
+	return ops
+}
+
+func (r *memoryPaymentRepository) UpdateOperation(_ context.Context, op *models.PaymentOperation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.ops[op.ID]
+	if !ok {
+		return errOperationNotFound
+	}
+	existing.Status = op.Status
+	existing.Error = op.Error
+	existing.UpdatedAt = time.Now()
+	op.UpdatedAt = existing.UpdatedAt
+	return nil
+}
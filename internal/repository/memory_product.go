@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+var (
+	errProductNotFound = apperror.NotFound("product_not_found", "product not found")
+	errProductExists   = apperror.Conflict("product_already_exists", "product already exists")
+	errVariantNotFound = apperror.NotFound("variant_not_found", "variant not found")
+	errImageNotFound   = apperror.NotFound("image_not_found", "image not found")
+)
+
+// memoryProductRepository is an in-memory ProductRepository for tests and
+// local runs without Postgres.
+type memoryProductRepository struct {
+	mu       sync.Mutex
+	products map[int]*models.Product
+	options  map[int][]models.ProductOption
+	variants map[int]*models.ProductVariant
+	images   map[int]*models.ProductImage
+	nextID   int
+}
+
+func NewMemoryProductRepository() ProductRepository {
+	return &memoryProductRepository{
+		products: make(map[int]*models.Product),
+		options:  make(map[int][]models.ProductOption),
+		variants: make(map[int]*models.ProductVariant),
+		images:   make(map[int]*models.ProductImage),
+	}
+}
+
+func (r *memoryProductRepository) id() int {
+	r.nextID++
+	return r.nextID
+}
+
+func (r *memoryProductRepository) slugTaken(slug string, except int) bool {
+	for _, p := range r.products {
+		if p.ID != except && p.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryProductRepository) CreateProduct(_ context.Context, product *models.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.slugTaken(product.Slug, 0) {
+		return errProductExists
+	}
+	product.ID = r.id()
+	product.CreatedAt = time.Now()
+	product.UpdatedAt = product.CreatedAt
+	r.products[product.ID] = copyProduct(product)
+	return nil
+}
+
+func (r *memoryProductRepository) UpdateProduct(_ context.Context, product *models.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.products[product.ID]
+	if !ok {
+		return errProductNotFound
+	}
+	if r.slugTaken(product.Slug, product.ID) {
+		return errProductExists
+	}
+	product.SellerID = existing.SellerID
+	product.CreatedAt = existing.CreatedAt
+	product.UpdatedAt = time.Now()
+	r.products[product.ID] = copyProduct(product)
+	return nil
+}
+
+func (r *memoryProductRepository) GetProductByID(_ context.Context, id int) (*models.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.products[id]
+	if !ok {
+		return nil, errProductNotFound
+	}
+	return copyProduct(p), nil
+}
+
+func (r *memoryProductRepository) ListProducts(_ context.Context, filter ProductFilter) ([]*models.Product, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matched []*models.Product
+	for _, p := range r.products {
+		if (filter.SellerID == 0 || p.SellerID == filter.SellerID) && (filter.Status == "" || p.Status == filter.Status) &&
+			(len(filter.CategoryIDs) == 0 || p.CategoryID != nil && slices.Contains(filter.CategoryIDs, *p.CategoryID)) {
+			matched = append(matched, copyProduct(p))
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+	return paginate(matched, filter.Limit, filter.Offset), len(matched), nil
+}
+
+func (r *memoryProductRepository) DeleteProduct(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.products[id]; !ok {
+		return errProductNotFound
+	}
+	delete(r.products, id)
+	delete(r.options, id)
+	for variantID, v := range r.variants {
+		if v.ProductID == id {
+			delete(r.variants, variantID)
+		}
+	}
+	for imageID, i := range r.images {
+		if i.ProductID == id {
+			delete(r.images, imageID)
+		}
+	}
+	return nil
+}
+
+func (r *memoryProductRepository) ListOptions(_ context.Context, productID int) ([]models.ProductOption, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]models.ProductOption(nil), r.options[productID]...), nil
+}
+
+func (r *memoryProductRepository) ReplaceOptions(_ context.Context, productID int, options []models.ProductOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.products[productID]; !ok {
+		return apperror.Validation("invalid_reference", "referenced record does not exist")
+	}
+	r.options[productID] = append([]models.ProductOption(nil), options...)
+	return nil
+}
+
+// skuTaken reports whether the seller of productID has another variant
+// with sku.
+func (r *memoryProductRepository) skuTaken(productID int, sku string, except int) bool {
+	seller := r.products[productID].SellerID
+	for _, v := range r.variants {
+		if v.ID != except && v.SKU == sku && r.products[v.ProductID].SellerID == seller {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryProductRepository) CreateVariant(_ context.Context, variant *models.ProductVariant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.products[variant.ProductID]; !ok {
+		return errProductNotFound
+	}
+	if r.skuTaken(variant.ProductID, variant.SKU, 0) {
+		return ErrSKUTaken
+	}
+	variant.ID = r.id()
+	variant.CreatedAt = time.Now()
+	variant.UpdatedAt = variant.CreatedAt
+	r.variants[variant.ID] = copyVariant(variant)
+	return nil
+}
+
+func (r *memoryProductRepository) UpdateVariant(_ context.Context, variant *models.ProductVariant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.variants[variant.ID]
+	if !ok {
+		return errVariantNotFound
+	}
+	if r.skuTaken(existing.ProductID, variant.SKU, variant.ID) {
+		return ErrSKUTaken
+	}
+	variant.ProductID = existing.ProductID
+	variant.CreatedAt = existing.CreatedAt
+	variant.UpdatedAt = time.Now()
+	r.variants[variant.ID] = copyVariant(variant)
+	return nil
+}
+
+func (r *memoryProductRepository) GetVariantByID(_ context.Context, id int) (*models.ProductVariant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v, ok := r.variants[id]
+	if !ok {
+		return nil, errVariantNotFound
+	}
+	return copyVariant(v), nil
+}
+
+func (r *memoryProductRepository) ListVariants(_ context.Context, productID int) ([]*models.ProductVariant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var variants []*models.ProductVariant
+	for _, v := range r.variants {
+		if v.ProductID == productID {
+			variants = append(variants, copyVariant(v))
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].ID < variants[j].ID })
+	return variants, nil
+}
+
+func (r *memoryProductRepository) DeleteVariant(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.variants, id)
+	return nil
+}
+
+func (r *memoryProductRepository) CreateImage(_ context.Context, image *models.ProductImage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.products[image.ProductID]; !ok {
+		return apperror.Validation("invalid_reference", "referenced record does not exist")
+	}
+	image.ID = r.id()
+	image.CreatedAt = time.Now()
+	image.UpdatedAt = image.CreatedAt
+	r.images[image.ID] = copyImage(image)
+	return nil
+}
+
+func (r *memoryProductRepository) UpdateImage(_ context.Context, image *models.ProductImage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.images[image.ID]
+	if !ok {
+		return errImageNotFound
+	}
+	existing.Status = image.Status
+	existing.Renditions = maps.Clone(image.Renditions)
+	existing.UpdatedAt = time.Now()
+	image.UpdatedAt = existing.UpdatedAt
+	return nil
+}
+
+func (r *memoryProductRepository) GetImageByID(_ context.Context, id int) (*models.ProductImage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.images[id]
+	if !ok {
+		return nil, errImageNotFound
+	}
+	return copyImage(i), nil
+}
+
+func (r *memoryProductRepository) ListImages(_ context.Context, productID int) ([]*models.ProductImage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var images []*models.ProductImage
+	for _, i := range r.images {
+		if i.ProductID == productID {
+			images = append(images, copyImage(i))
+		}
+	}
+	sort.Slice(images, func(a, b int) bool {
+		if images[a].Position != images[b].Position {
+			return images[a].Position < images[b].Position
+		}
+		return images[a].ID < images[b].ID
+	})
+	return images, nil
+}
+
+func (r *memoryProductRepository) ListPendingImages(_ context.Context, limit int) ([]*models.ProductImage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var images []*models.ProductImage
+	for _, i := range r.images {
+		if i.Status == models.ImageProcessing {
+			images = append(images, copyImage(i))
+		}
+	}
+	sort.Slice(images, func(a, b int) bool { return images[a].ID < images[b].ID })
+	return paginate(images, limit, 0), nil
+}
+
+func (r *memoryProductRepository) DeleteImage(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.images[id]; !ok {
+		return errImageNotFound
+	}
+	delete(r.images, id)
+	return nil
+}
+
+func copyImage(i *models.ProductImage) *models.ProductImage {
+	cp := *i
+	cp.Renditions = maps.Clone(i.Renditions)
+	return &cp
+}
+
+// copyProduct copies p without its options, variants and images, which are
+// stored separately.
+func copyProduct(p *models.Product) *models.Product {
+	cp := *p
+	cp.Options, cp.Variants, cp.Images = nil, nil, nil
+	cp.Attributes = maps.Clone(p.Attributes)
+	if cp.Attributes == nil {
+		cp.Attributes = map[string]any{}
+	}
+	if p.CategoryID != nil {
+		categoryID := *p.CategoryID
+		cp.CategoryID = &categoryID
+	}
+	return &cp
+}
+
+// copyVariant copies v deeply enough that callers can't change the stored
+// variant through its map or slice.
+func copyVariant(v *models.ProductVariant) *models.ProductVariant {
+	cp := *v
+	cp.Options = maps.Clone(v.Options)
+	cp.Images = slices.Clone(v.Images)
+	return &cp
+}
+
+// paginate returns the page of items at offset, like LIMIT and OFFSET.
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+var (
+	errReturnNotFound     = apperror.NotFound("return_not_found", "return not found")
+	errReturnItemNotFound = apperror.NotFound("return_item_not_found", "return item not found")
+)
+
+// memoryReturnRepository is an in-memory ReturnRepository for tests and
+// local runs without Postgres. Nothing is locked between calls.
+type memoryReturnRepository struct {
+	mu          sync.Mutex
+	returns     map[int]*models.Return
+	nextID      int
+	nextPhotoID int
+	nextEventID int
+}
+
+func NewMemoryReturnRepository() ReturnRepository {
+	return &memoryReturnRepository{returns: make(map[int]*models.Return)}
+}
+
+func (r *memoryReturnRepository) CreateReturn(_ context.Context, ret *models.Return) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	ret.ID = r.nextID
+	ret.CreatedAt = time.Now()
+	ret.UpdatedAt = ret.CreatedAt
+	for i := range ret.Items {
+		ret.Items[i].Photos = []models.ReturnPhoto{}
+	}
+	cp := copyReturn(ret)
+	cp.Events = []models.ReturnEvent{}
+	r.returns[ret.ID] = cp
+	return nil
+}
+
+func (r *memoryReturnRepository) GetReturnByID(_ context.Context, id int) (*models.Return, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ret, ok := r.returns[id]
+	if !ok {
+		return nil, errReturnNotFound
+	}
+	return copyReturn(ret), nil
+}
+
+func (r *memoryReturnRepository) LockReturn(ctx context.Context, id int) (*models.Return, error) {
+	return r.GetReturnByID(ctx, id)
+}
+
+func (r *memoryReturnRepository) ListReturns(_ context.Context, filter ReturnFilter) ([]*models.Return, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matched []*models.Return
+	for _, ret := range r.returns {
+		if filter.UserID != 0 && ret.UserID != filter.UserID {
+			continue
+		}
+		if filter.SellerID != 0 && ret.SellerID != filter.SellerID {
+			continue
+		}
+		if filter.Status != "" && ret.Status != filter.Status {
+			continue
+		}
+		cp := copyReturn(ret)
+		cp.Events = nil
+		matched = append(matched, cp)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+	return paginate(matched, filter.Limit, filter.Offset), len(matched), nil
+}
+
+func (r *memoryReturnRepository) ReturnedQuantities(_ context.Context, orderID int) (map[int]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	quantities := make(map[int]int)
+	for _, ret := range r.returns {
+		if ret.OrderID != orderID || ret.Status == models.ReturnRejected {
+			continue
+		}
+		for _, item := range ret.Items {
+			quantities[item.VariantID] += item.Quantity
+		}
+	}
+	return quantities, nil
+}
+
+func (r *memoryReturnRepository) UpdateReturn(_ context.Context, ret *models.Return) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.returns[ret.ID]
+	if !ok {
+		return errReturnNotFound
+	}
+	existing.Status = ret.Status
+	existing.Label = nil
+	if ret.Label != nil {
+		label := *ret.Label
+		existing.Label = &label
+	}
+	existing.RejectionReason = ret.RejectionReason
+	existing.RefundAmount = ret.RefundAmount
+	for _, item := range ret.Items {
+		for i := range existing.Items {
+			if existing.Items[i].VariantID == item.VariantID {
+				existing.Items[i].Condition = item.Condition
+				existing.Items[i].ReceivedQuantity = item.ReceivedQuantity
+			}
+		}
+	}
+	existing.UpdatedAt = time.Now()
+	ret.UpdatedAt = existing.UpdatedAt
+	return nil
+}
+
+func (r *memoryReturnRepository) AddReturnPhoto(_ context.Context, returnID, variantID int, photo *models.ReturnPhoto) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ret, ok := r.returns[returnID]
+	if !ok {
+		return errReturnNotFound
+	}
+	i := slices.IndexFunc(ret.Items, func(item models.ReturnItem) bool { return item.VariantID == variantID })
+	if i < 0 {
+		return errReturnItemNotFound
+	}
+	r.nextPhotoID++
+	photo.ID = r.nextPhotoID
+	photo.CreatedAt = time.Now()
+	ret.Items[i].Photos = append(ret.Items[i].Photos, *photo)
+	return nil
+}
+
+func (r *memoryReturnRepository) CreateReturnEvent(_ context.Context, event *models.ReturnEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ret, ok := r.returns[event.ReturnID]
+	if !ok {
+		return errReturnNotFound
+	}
+	r.nextEventID++
+	event.ID = r.nextEventID
+	event.CreatedAt = time.Now()
+	ret.Events = append(ret.Events, *event)
+	return nil
+}
+
+func copyReturn(ret *models.Return) *models.Return {
+	cp := *ret
+	if ret.Label != nil {
+		label := *ret.Label
+		cp.Label = &label
+	}
+	cp.Items = make([]models.ReturnItem, len(ret.Items))
+	for i, item := range ret.Items {
+		item.Photos = slices.Clone(item.Photos)
+		cp.Items[i] = item
+	}
+	slices.SortFunc(cp.Items, func(a, b models.ReturnItem) int {
+		return cmp.Or(cmp.Compare(a.ProductName, b.ProductName), cmp.Compare(a.SKU, b.SKU))
+	})
+	cp.Events = slices.Clone(ret.Events)
+	return &cp
+}
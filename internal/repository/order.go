@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+// OrderFilter selects orders for ListOrders. Zero fields match every order.
+type OrderFilter struct {
+	UserID int
+	Status models.OrderStatus
+	Limit  int
+	Offset int
+}
+
+type OrderRepository interface {
+	// CreateOrder saves an order with its items. Idempotency keys are
+	// unique per user.
+	CreateOrder(ctx context.Context, order *models.Order) error
+	// GetOrderByID, GetOrderByIdempotencyKey and ListOrders return orders
+	// with their items.
+	GetOrderByID(ctx context.Context, id int) (*models.Order, error)
+	GetOrderByIdempotencyKey(ctx context.Context, userID int, key string) (*models.Order, error)
+	// LockOrder is GetOrderByID that also locks the order until the
+	// transaction ends.
+	LockOrder(ctx context.Context, id int) (*models.Order, error)
+	// ListOrders returns a page of the matching orders, newest first, and
+	// how many match in total.
+	ListOrders(ctx context.Context, filter OrderFilter) ([]*models.Order, int, error)
+	// UpdateOrderStatus saves an order's status and the times it changed.
+	UpdateOrderStatus(ctx context.Context, order *models.Order) error
+	// ListExpiredOrders returns up to limit unpaid orders that expired
+	// before now, without their items.
+	ListExpiredOrders(ctx context.Context, now time.Time, limit int) ([]*models.Order, error)
+}
+
+type orderRepository struct {
+	db database.DBTX
+}
+
+// NewOrderRepository returns an OrderRepository backed by db. Calls made
+// with a context carrying a transaction from database.WithTx run inside it;
+// CreateOrder and LockOrder must.
+func NewOrderRepository(db database.DBTX) OrderRepository {
+	return &orderRepository{db: db}
+}
+
+func (r *orderRepository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
+const orderColumns = `id, user_id, status, currency, quantity, subtotal, discount, tax, shipping, total,
+    shipping_address, billing_address, reservation_id, idempotency_key, request_hash,
+    expires_at, paid_at, fulfilled_at, delivered_at, cancelled_at, created_at, updated_at`
+
+func scanOrder(row interface{ Scan(...any) error }) (*models.Order, error) {
+	o := &models.Order{}
+	var (
+		shipping, billing                          []byte
+		reservationID                              sql.NullInt64
+		paidAt, fulfilledAt, deliveredAt, cancelAt sql.NullTime
+	)
+	err := row.Scan(&o.ID, &o.UserID, &o.Status, &o.Currency,
+		&o.Totals.Quantity, &o.Totals.Subtotal, &o.Totals.Discount, &o.Totals.Tax, &o.Totals.Shipping, &o.Totals.Total,
+		&shipping, &billing, &reservationID, &o.IdempotencyKey, &o.RequestHash,
+		&o.ExpiresAt, &paidAt, &fulfilledAt, &deliveredAt, &cancelAt, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(shipping, &o.ShippingAddress); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(billing, &o.BillingAddress); err != nil {
+		return nil, err
+	}
+	if reservationID.Valid {
+		id := int(reservationID.Int64)
+		o.ReservationID = &id
+	}
+	o.PaidAt = nullTime(paidAt)
+	o.FulfilledAt = nullTime(fulfilledAt)
+	o.DeliveredAt = nullTime(deliveredAt)
+	o.CancelledAt = nullTime(cancelAt)
+	return o, nil
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// CreateOrder should run in a transaction so the order is never seen
+// without its items.
+func (r *orderRepository) CreateOrder(ctx context.Context, order *models.Order) error {
+	shipping, err := json.Marshal(order.ShippingAddress)
+	if err != nil {
+		return err
+	}
+	billing, err := json.Marshal(order.BillingAddress)
+	if err != nil {
+		return err
+	}
+	query := `
+        -- name: CreateOrder
+        INSERT INTO orders (user_id, status, currency, quantity, subtotal, discount, tax, shipping, total,
+            shipping_address, billing_address, reservation_id, idempotency_key, request_hash, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+        RETURNING id, created_at, updated_at
+    `
+	t := order.Totals
+	err = r.conn(ctx).QueryRowContext(ctx, query,
+		order.UserID, order.Status, order.Currency, t.Quantity, t.Subtotal, t.Discount, t.Tax, t.Shipping, t.Total,
+		shipping, billing, order.ReservationID, order.IdempotencyKey, order.RequestHash, order.ExpiresAt,
+	).Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return translateError(err, "order")
+	}
+
+	query = `
+        -- name: CreateOrderItem
+        INSERT INTO order_items (order_id, variant_id, product_id, seller_id, product_name, sku, options,
+            unit_price, quantity, line_total)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+    `
+	for _, item := range order.Items {
+		options, err := json.Marshal(item.Options)
+		if err != nil {
+			return err
+		}
+		_, err = r.conn(ctx).ExecContext(ctx, query,
+			order.ID, item.VariantID, item.ProductID, item.SellerID, item.ProductName, item.SKU, options,
+			item.UnitPrice, item.Quantity, item.LineTotal,
+		)
+		if err != nil {
+			return translateError(err, "order")
+		}
+	}
+	return nil
+}
+
+func (r *orderRepository) GetOrderByID(ctx context.Context, id int) (*models.Order, error) {
+	query := `
+        -- name: GetOrderByID
+        SELECT ` + orderColumns + `
+        FROM orders
+        WHERE id = $1
+    `
+	return r.getOrder(ctx, query, id)
+}
+
+func (r *orderRepository) GetOrderByIdempotencyKey(ctx context.Context, userID int, key string) (*models.Order, error) {
+	query := `
+        -- name: GetOrderByIdempotencyKey
+        SELECT ` + orderColumns + `
+        FROM orders
+        WHERE user_id = $1 AND idempotency_key = $2
+    `
+	return r.getOrder(ctx, query, userID, key)
+}
+
+func (r *orderRepository) LockOrder(ctx context.Context, id int) (*models.Order, error) {
+	query := `
+        -- name: LockOrder
+        SELECT ` + orderColumns + `
+        FROM orders
+        WHERE id = $1
+        FOR UPDATE
+    `
+	return r.getOrder(ctx, query, id)
+}
+
+func (r *orderRepository) getOrder(ctx context.Context, query string, args ...any) (*models.Order, error) {
+	order, err := scanOrder(r.conn(ctx).QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return nil, translateError(err, "order")
+	}
+	if err := r.loadItems(ctx, []*models.Order{order}); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// loadItems sets the items of orders.
+func (r *orderRepository) loadItems(ctx context.Context, orders []*models.Order) error {
+	byID := make(map[int]*models.Order, len(orders))
+	ids := make([]int, len(orders))
+	for i, order := range orders {
+		order.Items = []models.OrderItem{}
+		byID[order.ID] = order
+		ids[i] = order.ID
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+        -- name: ListOrderItems
+        SELECT order_id, variant_id, product_id, seller_id, product_name, sku, options, unit_price, quantity, line_total
+        FROM order_items
+        WHERE order_id = ANY($1)
+        ORDER BY order_id, product_name, sku
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return translateError(err, "order")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			orderID int
+			item    models.OrderItem
+			options []byte
+		)
+		err := rows.Scan(&orderID, &item.VariantID, &item.ProductID, &item.SellerID, &item.ProductName, &item.SKU, &options,
+			&item.UnitPrice, &item.Quantity, &item.LineTotal)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(options, &item.Options); err != nil {
+			return err
+		}
+		order := byID[orderID]
+		order.Items = append(order.Items, item)
+	}
+	return rows.Err()
+}
+
+func (r *orderRepository) ListOrders(ctx context.Context, filter OrderFilter) ([]*models.Order, int, error) {
+	countQuery := `
+        -- name: CountOrders
+        SELECT COUNT(*)
+        FROM orders
+        WHERE ($1 = 0 OR user_id = $1) AND ($2 = '' OR status = $2)
+    `
+	var total int
+	err := r.conn(ctx).QueryRowContext(ctx, countQuery, filter.UserID, filter.Status).Scan(&total)
+	if err != nil {
+		return nil, 0, translateError(err, "order")
+	}
+
+	query := `
+        -- name: ListOrders
+        SELECT ` + orderColumns + `
+        FROM orders
+        WHERE ($1 = 0 OR user_id = $1) AND ($2 = '' OR status = $2)
+        ORDER BY id DESC
+        LIMIT $3 OFFSET $4
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, filter.UserID, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, translateError(err, "order")
+	}
+	defer rows.Close()
+
+	var orders []*models.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if err := r.loadItems(ctx, orders); err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+func (r *orderRepository) UpdateOrderStatus(ctx context.Context, order *models.Order) error {
+	query := `
+        -- name: UpdateOrderStatus
+        UPDATE orders
+        SET status = $1, paid_at = $2, fulfilled_at = $3, delivered_at = $4, cancelled_at = $5,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $6
+        RETURNING updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		order.Status, order.PaidAt, order.FulfilledAt, order.DeliveredAt, order.CancelledAt, order.ID,
+	).Scan(&order.UpdatedAt)
+	return translateError(err, "order")
+}
+
+func (r *orderRepository) ListExpiredOrders(ctx context.Context, now time.Time, limit int) ([]*models.Order, error) {
+	query := `
+        -- name: ListExpiredOrders
+        SELECT ` + orderColumns + `
+        FROM orders
+        WHERE status = 'pending_payment' AND expires_at < $1
+        ORDER BY expires_at
+        LIMIT $2
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, translateError(err, "order")
+	}
+	defer rows.Close()
+
+	var orders []*models.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+type PaymentRepository interface {
+	CreatePayment(ctx context.Context, payment *models.Payment) error
+	// LockPayment and LockPaymentByReference return a payment and lock it
+	// until the transaction ends.
+	LockPayment(ctx context.Context, id int) (*models.Payment, error)
+	LockPaymentByReference(ctx context.Context, provider, reference string) (*models.Payment, error)
+	// ListPaymentsByOrderID returns an order's payments, oldest first.
+	ListPaymentsByOrderID(ctx context.Context, orderID int) ([]*models.Payment, error)
+	// UpdatePayment saves what the provider said about a payment.
+	UpdatePayment(ctx context.Context, payment *models.Payment) error
+	// RecordEvent records that a provider's webhook event was handled. It
+	// reports false if the event was recorded before.
+	RecordEvent(ctx context.Context, provider, eventID string, paymentID int, eventType string) (bool, error)
+
+	// CreateOperation records a pending operation. It returns a conflict if
+	// an operation that hasn't failed has the same key.
+	CreateOperation(ctx context.Context, op *models.PaymentOperation) error
+	// GetOperationByKey returns the operation with key that hasn't failed.
+	GetOperationByKey(ctx context.Context, key string) (*models.PaymentOperation, error)
+	// LockOperation returns an operation and locks it until the transaction
+	// ends.
+	LockOperation(ctx context.Context, id int) (*models.PaymentOperation, error)
+	// ListOperationsByPaymentID returns a payment's operations, oldest first.
+	ListOperationsByPaymentID(ctx context.Context, paymentID int) ([]*models.PaymentOperation, error)
+	// ListPendingOperations returns up to limit operations created before
+	// before that are still pending, oldest first.
+	ListPendingOperations(ctx context.Context, before time.Time, limit int) ([]*models.PaymentOperation, error)
+	// UpdateOperation saves an operation's status and error.
+	UpdateOperation(ctx context.Context, op *models.PaymentOperation) error
+}
+
+type paymentRepository struct {
+	db database.DBTX
+}
+
+// NewPaymentRepository returns a PaymentRepository backed by db. Calls made
+// with a context carrying a transaction from database.WithTx run inside it;
+// the Lock methods must.
+func NewPaymentRepository(db database.DBTX) PaymentRepository {
+	return &paymentRepository{db: db}
+}
+
+func (r *paymentRepository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
+const paymentColumns = `id, order_id, user_id, provider, COALESCE(reference, ''), status, amount, captured_amount,
+    refunded_amount, currency, card_brand, card_last4, decline_code, action_url, created_at, updated_at`
+
+func scanPayment(row interface{ Scan(...any) error }) (*models.Payment, error) {
+	p := &models.Payment{}
+	err := row.Scan(&p.ID, &p.OrderID, &p.UserID, &p.Provider, &p.Reference, &p.Status, &p.Amount, &p.CapturedAmount,
+		&p.RefundedAmount, &p.Currency, &p.CardBrand, &p.CardLast4, &p.DeclineCode, &p.ActionURL, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *paymentRepository) CreatePayment(ctx context.Context, payment *models.Payment) error {
+	query := `
+        -- name: CreatePayment
+        INSERT INTO payments (order_id, user_id, provider, reference, status, amount, currency, card_brand, card_last4)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id, created_at, updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		payment.OrderID, payment.UserID, payment.Provider, nullString(payment.Reference), payment.Status,
+		payment.Amount, payment.Currency, payment.CardBrand, payment.CardLast4,
+	).Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+	return translateError(err, "payment")
+}
+
+func (r *paymentRepository) LockPayment(ctx context.Context, id int) (*models.Payment, error) {
+	query := `
+        -- name: LockPayment
+        SELECT ` + paymentColumns + `
+        FROM payments
+        WHERE id = $1
+        FOR UPDATE
+    `
+	payment, err := scanPayment(r.conn(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, translateError(err, "payment")
+	}
+	return payment, nil
+}
+
+func (r *paymentRepository) LockPaymentByReference(ctx context.Context, provider, reference string) (*models.Payment, error) {
+	query := `
+        -- name: LockPaymentByReference
+        SELECT ` + paymentColumns + `
+        FROM payments
+        WHERE provider = $1 AND reference = $2
+        FOR UPDATE
+    `
+	payment, err := scanPayment(r.conn(ctx).QueryRowContext(ctx, query, provider, reference))
+	if err != nil {
+		return nil, translateError(err, "payment")
+	}
+	return payment, nil
+}
+
+func (r *paymentRepository) ListPaymentsByOrderID(ctx context.Context, orderID int) ([]*models.Payment, error) {
+	query := `
+        -- name: ListPaymentsByOrderID
+        SELECT ` + paymentColumns + `
+        FROM payments
+        WHERE order_id = $1
+        ORDER BY id
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, translateError(err, "payment")
+	}
+	defer rows.Close()
+
+	payments := []*models.Payment{}
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
+
+func (r *paymentRepository) UpdatePayment(ctx context.Context, payment *models.Payment) error {
+	query := `
+        -- name: UpdatePayment
+        UPDATE payments
+        SET reference = $1, status = $2, captured_amount = $3, refunded_amount = $4, decline_code = $5,
+            action_url = $6, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $7
+        RETURNING updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		nullString(payment.Reference), payment.Status, payment.CapturedAmount, payment.RefundedAmount,
+		payment.DeclineCode, payment.ActionURL, payment.ID,
+	).Scan(&payment.UpdatedAt)
+	return translateError(err, "payment")
+}
+
+func (r *paymentRepository) RecordEvent(ctx context.Context, provider, eventID string, paymentID int, eventType string) (bool, error) {
+	query := `
+        -- name: RecordPaymentEvent
+        INSERT INTO payment_events (provider, event_id, payment_id, type)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (provider, event_id) DO NOTHING
+        RETURNING event_id
+    `
+	var id string
+	err := r.conn(ctx).QueryRowContext(ctx, query, provider, eventID, paymentID, eventType).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, translateError(err, "payment_event")
+	}
+	return true, nil
+}
+
+const operationColumns = `id, payment_id, kind, amount, status, key, error, created_at, updated_at`
+
+func scanOperation(row interface{ Scan(...any) error }) (*models.PaymentOperation, error) {
+	op := &models.PaymentOperation{}
+	err := row.Scan(&op.ID, &op.PaymentID, &op.Kind, &op.Amount, &op.Status, &op.Key, &op.Error, &op.CreatedAt, &op.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (r *paymentRepository) CreateOperation(ctx context.Context, op *models.PaymentOperation) error {
+	query := `
+        -- name: CreatePaymentOperation
+        INSERT INTO payment_operations (payment_id, kind, amount, status, key)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query, op.PaymentID, op.Kind, op.Amount, op.Status, op.Key).
+		Scan(&op.ID, &op.CreatedAt, &op.UpdatedAt)
+	return translateError(err, "payment_operation")
+}
+
+func (r *paymentRepository) GetOperationByKey(ctx context.Context, key string) (*models.PaymentOperation, error) {
+	query := `
+        -- name: GetPaymentOperationByKey
+        SELECT ` + operationColumns + `
+        FROM payment_operations
+        WHERE key = $1 AND status <> 'failed'
+    `
+	op, err := scanOperation(r.conn(ctx).QueryRowContext(ctx, query, key))
+	if err != nil {
+		return nil, translateError(err, "payment_operation")
+	}
+	return op, nil
+}
+
+func (r *paymentRepository) LockOperation(ctx context.Context, id int) (*models.PaymentOperation, error) {
+	query := `
+        -- name: LockPaymentOperation
+        SELECT ` + operationColumns + `
+        FROM payment_operations
+        WHERE id = $1
+        FOR UPDATE
+    `
+	op, err := scanOperation(r.conn(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, translateError(err, "payment_operation")
+	}
+	return op, nil
+}
+
+func (r *paymentRepository) ListOperationsByPaymentID(ctx context.Context, paymentID int) ([]*models.PaymentOperation, error) {
+	query := `
+        -- name: ListPaymentOperationsByPaymentID
+        SELECT ` + operationColumns + `
+        FROM payment_operations
+        WHERE payment_id = $1
+        ORDER BY id
+    `
+	return r.listOperations(ctx, query, paymentID)
+}
+
+func (r *paymentRepository) ListPendingOperations(ctx context.Context, before time.Time, limit int) ([]*models.PaymentOperation, error) {
+	query := `
+        -- name: ListPendingPaymentOperations
+        SELECT ` + operationColumns + `
+        FROM payment_operations
+        WHERE status = 'pending' AND created_at < $1
+        ORDER BY created_at, id
+        LIMIT $2
+    `
+	return r.listOperations(ctx, query, before, limit)
+}
+
+func (r *paymentRepository) listOperations(ctx context.Context, query string, args ...any) ([]*models.PaymentOperation, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, translateError(err, "payment_operation")
+	}
+	defer rows.Close()
+
+	ops := []*models.PaymentOperation{}
+	for rows.Next() {
+		op, err := scanOperation(rows)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, rows.Err()
+}
+
+func (r *paymentRepository) UpdateOperation(ctx context.Context, op *models.PaymentOperation) error {
+	query := `
+        -- name: UpdatePaymentOperation
+        UPDATE payment_operations
+        SET status = $1, error = $2, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $3
+        RETURNING updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query, op.Status, op.Error, op.ID).Scan(&op.UpdatedAt)
+	return translateError(err, "payment_operation")
+}
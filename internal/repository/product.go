@@ -0,0 +1,522 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+// ProductFilter selects products for ListProducts. Zero fields match every
+// product.
+type ProductFilter struct {
+	SellerID int
+	Status   models.ProductStatus
+	// CategoryIDs matches products in any of the categories.
+	CategoryIDs []int
+	Limit       int
+	Offset      int
+}
+
+type ProductRepository interface {
+	CreateProduct(ctx context.Context, product *models.Product) error
+	UpdateProduct(ctx context.Context, product *models.Product) error
+	GetProductByID(ctx context.Context, id int) (*models.Product, error)
+	// ListProducts returns a page of the matching products, newest first,
+	// and how many match in total.
+	ListProducts(ctx context.Context, filter ProductFilter) ([]*models.Product, int, error)
+	DeleteProduct(ctx context.Context, id int) error
+
+	// ListOptions returns a product's options in order.
+	ListOptions(ctx context.Context, productID int) ([]models.ProductOption, error)
+	ReplaceOptions(ctx context.Context, productID int, options []models.ProductOption) error
+
+	// Variant SKUs are unique per seller; reusing one fails with ErrSKUTaken.
+	CreateVariant(ctx context.Context, variant *models.ProductVariant) error
+	UpdateVariant(ctx context.Context, variant *models.ProductVariant) error
+	GetVariantByID(ctx context.Context, id int) (*models.ProductVariant, error)
+	ListVariants(ctx context.Context, productID int) ([]*models.ProductVariant, error)
+	DeleteVariant(ctx context.Context, id int) error
+
+	CreateImage(ctx context.Context, image *models.ProductImage) error
+	// UpdateImage saves an image's status and renditions.
+	UpdateImage(ctx context.Context, image *models.ProductImage) error
+	GetImageByID(ctx context.Context, id int) (*models.ProductImage, error)
+	// ListImages returns a product's images in order.
+	ListImages(ctx context.Context, productID int) ([]*models.ProductImage, error)
+	// ListPendingImages returns up to limit images still being processed,
+	// oldest first.
+	ListPendingImages(ctx context.Context, limit int) ([]*models.ProductImage, error)
+	DeleteImage(ctx context.Context, id int) error
+}
+
+// ErrSKUTaken is returned when a seller already has a variant with the SKU.
+var ErrSKUTaken = apperror.Conflict("sku_taken", "Another of your variants already uses this SKU")
+
+type productRepository struct {
+	db database.DBTX
+}
+
+// NewProductRepository returns a ProductRepository backed by db. Calls made
+// with a context carrying a transaction from database.WithTx run inside it.
+func NewProductRepository(db database.DBTX) ProductRepository {
+	return &productRepository{db: db}
+}
+
+func (r *productRepository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
+const productColumns = `id, seller_id, name, slug, description, price, currency, status, category_id, attributes, published_at, created_at, updated_at`
+
+func scanProduct(row interface{ Scan(...any) error }) (*models.Product, error) {
+	p := &models.Product{}
+	var (
+		categoryID  sql.NullInt64
+		attributes  []byte
+		publishedAt sql.NullTime
+	)
+	err := row.Scan(&p.ID, &p.SellerID, &p.Name, &p.Slug, &p.Description, &p.Price, &p.Currency, &p.Status, &categoryID, &attributes, &publishedAt, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if categoryID.Valid {
+		id := int(categoryID.Int64)
+		p.CategoryID = &id
+	}
+	if err := json.Unmarshal(attributes, &p.Attributes); err != nil {
+		return nil, err
+	}
+	if publishedAt.Valid {
+		p.PublishedAt = &publishedAt.Time
+	}
+	return p, nil
+}
+
+// productAttributes encodes the product's attributes for the JSONB column,
+// which holds an empty object rather than NULL.
+func productAttributes(product *models.Product) ([]byte, error) {
+	if product.Attributes == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(product.Attributes)
+}
+
+func (r *productRepository) CreateProduct(ctx context.Context, product *models.Product) error {
+	attributes, err := productAttributes(product)
+	if err != nil {
+		return err
+	}
+	query := `
+        -- name: CreateProduct
+        INSERT INTO products (seller_id, name, slug, description, price, currency, status, category_id, attributes, published_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id, created_at, updated_at
+    `
+	err = r.conn(ctx).QueryRowContext(ctx, query,
+		product.SellerID, product.Name, product.Slug, product.Description, product.Price, product.Currency, product.Status,
+		product.CategoryID, attributes, product.PublishedAt,
+	).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt)
+	return translateError(err, "product")
+}
+
+func (r *productRepository) UpdateProduct(ctx context.Context, product *models.Product) error {
+	attributes, err := productAttributes(product)
+	if err != nil {
+		return err
+	}
+	query := `
+        -- name: UpdateProduct
+        UPDATE products
+        SET name = $1, slug = $2, description = $3, price = $4, currency = $5, status = $6,
+            category_id = $7, attributes = $8, published_at = $9, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $10
+        RETURNING updated_at
+    `
+	err = r.conn(ctx).QueryRowContext(ctx, query,
+		product.Name, product.Slug, product.Description, product.Price, product.Currency, product.Status,
+		product.CategoryID, attributes, product.PublishedAt, product.ID,
+	).Scan(&product.UpdatedAt)
+	return translateError(err, "product")
+}
+
+func (r *productRepository) GetProductByID(ctx context.Context, id int) (*models.Product, error) {
+	query := `
+        -- name: GetProductByID
+        SELECT ` + productColumns + `
+        FROM products
+        WHERE id = $1
+    `
+	product, err := scanProduct(r.conn(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, translateError(err, "product")
+	}
+	return product, nil
+}
+
+func (r *productRepository) ListProducts(ctx context.Context, filter ProductFilter) ([]*models.Product, int, error) {
+	countQuery := `
+        -- name: CountProducts
+        SELECT COUNT(*)
+        FROM products
+        WHERE ($1 = 0 OR seller_id = $1) AND ($2 = '' OR status = $2)
+            AND (COALESCE(cardinality($3::int[]), 0) = 0 OR category_id = ANY($3))
+    `
+	categoryIDs := pq.Array(filter.CategoryIDs)
+	var total int
+	err := r.conn(ctx).QueryRowContext(ctx, countQuery, filter.SellerID, filter.Status, categoryIDs).Scan(&total)
+	if err != nil {
+		return nil, 0, translateError(err, "product")
+	}
+
+	query := `
+        -- name: ListProducts
+        SELECT ` + productColumns + `
+        FROM products
+        WHERE ($1 = 0 OR seller_id = $1) AND ($2 = '' OR status = $2)
+            AND (COALESCE(cardinality($3::int[]), 0) = 0 OR category_id = ANY($3))
+        ORDER BY id DESC
+        LIMIT $4 OFFSET $5
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, filter.SellerID, filter.Status, categoryIDs, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, translateError(err, "product")
+	}
+	defer rows.Close()
+
+	var products []*models.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return products, total, nil
+}
+
+func (r *productRepository) DeleteProduct(ctx context.Context, id int) error {
+	query := `
+        -- name: DeleteProduct
+        DELETE FROM products
+        WHERE id = $1
+    `
+	res, err := r.conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return translateDeleteError(err, "product")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return translateError(sql.ErrNoRows, "product")
+	}
+	return nil
+}
+
+func (r *productRepository) ListOptions(ctx context.Context, productID int) ([]models.ProductOption, error) {
+	query := `
+        -- name: ListProductOptions
+        SELECT name, option_values
+        FROM product_options
+        WHERE product_id = $1
+        ORDER BY position
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, translateError(err, "product_option")
+	}
+	defer rows.Close()
+
+	var options []models.ProductOption
+	for rows.Next() {
+		var option models.ProductOption
+		if err := rows.Scan(&option.Name, pq.Array(&option.Values)); err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// ReplaceOptions should run in a transaction so the product is never seen
+// without options.
+func (r *productRepository) ReplaceOptions(ctx context.Context, productID int, options []models.ProductOption) error {
+	query := `
+        -- name: DeleteProductOptions
+        DELETE FROM product_options
+        WHERE product_id = $1
+    `
+	if _, err := r.conn(ctx).ExecContext(ctx, query, productID); err != nil {
+		return translateError(err, "product_option")
+	}
+
+	query = `
+        -- name: CreateProductOption
+        INSERT INTO product_options (product_id, name, position, option_values)
+        VALUES ($1, $2, $3, $4)
+    `
+	for i, option := range options {
+		if _, err := r.conn(ctx).ExecContext(ctx, query, productID, option.Name, i, pq.Array(option.Values)); err != nil {
+			return translateError(err, "product_option")
+		}
+	}
+	return nil
+}
+
+const variantColumns = `id, product_id, sku, options, price, weight_grams, barcode, images, created_at, updated_at`
+
+func scanVariant(row interface{ Scan(...any) error }) (*models.ProductVariant, error) {
+	v := &models.ProductVariant{}
+	var options []byte
+	err := row.Scan(&v.ID, &v.ProductID, &v.SKU, &options, &v.Price, &v.WeightGrams, &v.Barcode, pq.Array(&v.Images), &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(options, &v.Options); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// translateVariantError reports unique violations as ErrSKUTaken, the only
+// unique constraint on variants.
+func translateVariantError(err error) error {
+	err = translateError(err, "variant")
+	if errors.Is(err, apperror.ErrConflict) {
+		return ErrSKUTaken.Wrap(err)
+	}
+	return err
+}
+
+func (r *productRepository) CreateVariant(ctx context.Context, variant *models.ProductVariant) error {
+	options, err := json.Marshal(variant.Options)
+	if err != nil {
+		return err
+	}
+	query := `
+        -- name: CreateVariant
+        INSERT INTO product_variants (product_id, seller_id, sku, options, price, weight_grams, barcode, images)
+        SELECT id, seller_id, $2, $3, $4, $5, $6, $7
+        FROM products
+        WHERE id = $1
+        RETURNING id, created_at, updated_at
+    `
+	err = r.conn(ctx).QueryRowContext(ctx, query,
+		variant.ProductID, variant.SKU, options, variant.Price, variant.WeightGrams, variant.Barcode, pq.Array(variant.Images),
+	).Scan(&variant.ID, &variant.CreatedAt, &variant.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return translateError(err, "product")
+	}
+	return translateVariantError(err)
+}
+
+func (r *productRepository) UpdateVariant(ctx context.Context, variant *models.ProductVariant) error {
+	options, err := json.Marshal(variant.Options)
+	if err != nil {
+		return err
+	}
+	query := `
+        -- name: UpdateVariant
+        UPDATE product_variants
+        SET sku = $1, options = $2, price = $3, weight_grams = $4, barcode = $5, images = $6,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $7
+        RETURNING updated_at
+    `
+	err = r.conn(ctx).QueryRowContext(ctx, query,
+		variant.SKU, options, variant.Price, variant.WeightGrams, variant.Barcode, pq.Array(variant.Images), variant.ID,
+	).Scan(&variant.UpdatedAt)
+	return translateVariantError(err)
+}
+
+func (r *productRepository) GetVariantByID(ctx context.Context, id int) (*models.ProductVariant, error) {
+	query := `
+        -- name: GetVariantByID
+        SELECT ` + variantColumns + `
+        FROM product_variants
+        WHERE id = $1
+    `
+	variant, err := scanVariant(r.conn(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, translateError(err, "variant")
+	}
+	return variant, nil
+}
+
+func (r *productRepository) ListVariants(ctx context.Context, productID int) ([]*models.ProductVariant, error) {
+	query := `
+        -- name: ListVariants
+        SELECT ` + variantColumns + `
+        FROM product_variants
+        WHERE product_id = $1
+        ORDER BY id
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, translateError(err, "variant")
+	}
+	defer rows.Close()
+
+	var variants []*models.ProductVariant
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (r *productRepository) DeleteVariant(ctx context.Context, id int) error {
+	query := `
+        -- name: DeleteVariant
+        DELETE FROM product_variants
+        WHERE id = $1
+    `
+	_, err := r.conn(ctx).ExecContext(ctx, query, id)
+	return translateDeleteError(err, "variant")
+}
+
+const imageColumns = `id, product_id, blob_key, content_type, size, width, height, position, status, renditions, created_at, updated_at`
+
+func scanImage(row interface{ Scan(...any) error }) (*models.ProductImage, error) {
+	i := &models.ProductImage{}
+	var renditions []byte
+	err := row.Scan(&i.ID, &i.ProductID, &i.Key, &i.ContentType, &i.Size, &i.Width, &i.Height, &i.Position, &i.Status, &renditions, &i.CreatedAt, &i.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(renditions, &i.Renditions); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// imageRenditions encodes the image's renditions for the JSONB column,
+// which holds an empty object rather than NULL.
+func imageRenditions(image *models.ProductImage) ([]byte, error) {
+	if image.Renditions == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(image.Renditions)
+}
+
+func (r *productRepository) CreateImage(ctx context.Context, image *models.ProductImage) error {
+	renditions, err := imageRenditions(image)
+	if err != nil {
+		return err
+	}
+	query := `
+        -- name: CreateImage
+        INSERT INTO product_images (product_id, blob_key, content_type, size, width, height, position, status, renditions)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id, created_at, updated_at
+    `
+	err = r.conn(ctx).QueryRowContext(ctx, query,
+		image.ProductID, image.Key, image.ContentType, image.Size, image.Width, image.Height, image.Position, image.Status, renditions,
+	).Scan(&image.ID, &image.CreatedAt, &image.UpdatedAt)
+	return translateError(err, "image")
+}
+
+func (r *productRepository) UpdateImage(ctx context.Context, image *models.ProductImage) error {
+	renditions, err := imageRenditions(image)
+	if err != nil {
+		return err
+	}
+	query := `
+        -- name: UpdateImage
+        UPDATE product_images
+        SET status = $1, renditions = $2, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $3
+        RETURNING updated_at
+    `
+	err = r.conn(ctx).QueryRowContext(ctx, query, image.Status, renditions, image.ID).Scan(&image.UpdatedAt)
+	return translateError(err, "image")
+}
+
+func (r *productRepository) GetImageByID(ctx context.Context, id int) (*models.ProductImage, error) {
+	query := `
+        -- name: GetImageByID
+        SELECT ` + imageColumns + `
+        FROM product_images
+        WHERE id = $1
+    `
+	image, err := scanImage(r.conn(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, translateError(err, "image")
+	}
+	return image, nil
+}
+
+func (r *productRepository) ListImages(ctx context.Context, productID int) ([]*models.ProductImage, error) {
+	query := `
+        -- name: ListImages
+        SELECT ` + imageColumns + `
+        FROM product_images
+        WHERE product_id = $1
+        ORDER BY position, id
+    `
+	return r.listImages(ctx, query, productID)
+}
+
+func (r *productRepository) ListPendingImages(ctx context.Context, limit int) ([]*models.ProductImage, error) {
+	query := `
+        -- name: ListPendingImages
+        SELECT ` + imageColumns + `
+        FROM product_images
+        WHERE status = 'processing'
+        ORDER BY id
+        LIMIT $1
+    `
+	return r.listImages(ctx, query, limit)
+}
+
+func (r *productRepository) listImages(ctx context.Context, query string, args ...any) ([]*models.ProductImage, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, translateError(err, "image")
+	}
+	defer rows.Close()
+
+	var images []*models.ProductImage
+	for rows.Next() {
+		image, err := scanImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (r *productRepository) DeleteImage(ctx context.Context, id int) error {
+	query := `
+        -- name: DeleteImage
+        DELETE FROM product_images
+        WHERE id = $1
+    `
+	res, err := r.conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return translateError(err, "image")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return translateError(sql.ErrNoRows, "image")
+	}
+	return nil
+}
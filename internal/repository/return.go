@@ -0,0 +1,391 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+// ReturnFilter selects returns for ListReturns. Zero fields match every
+// return.
+type ReturnFilter struct {
+	UserID   int
+	SellerID int
+	Status   models.ReturnStatus
+	Limit    int
+	Offset   int
+}
+
+type ReturnRepository interface {
+	// CreateReturn saves a return with its items.
+	CreateReturn(ctx context.Context, ret *models.Return) error
+	// GetReturnByID and LockReturn return a return with its items, photos
+	// and events. LockReturn also locks it until the transaction ends.
+	GetReturnByID(ctx context.Context, id int) (*models.Return, error)
+	LockReturn(ctx context.Context, id int) (*models.Return, error)
+	// ListReturns returns a page of the matching returns with their items
+	// and photos, newest first, and how many match in total.
+	ListReturns(ctx context.Context, filter ReturnFilter) ([]*models.Return, int, error)
+	// ReturnedQuantities returns how many of each variant of an order are
+	// in returns that weren't rejected.
+	ReturnedQuantities(ctx context.Context, orderID int) (map[int]int, error)
+	// UpdateReturn saves a return's status, label, rejection reason and
+	// refund, and the inspection results of its items.
+	UpdateReturn(ctx context.Context, ret *models.Return) error
+	// AddReturnPhoto saves a photo of an item of a return.
+	AddReturnPhoto(ctx context.Context, returnID, variantID int, photo *models.ReturnPhoto) error
+	// CreateReturnEvent adds an event to a return's audit trail.
+	CreateReturnEvent(ctx context.Context, event *models.ReturnEvent) error
+}
+
+type returnRepository struct {
+	db database.DBTX
+}
+
+// NewReturnRepository returns a ReturnRepository backed by db. Calls made
+// with a context carrying a transaction from database.WithTx run inside it;
+// CreateReturn, LockReturn and UpdateReturn must.
+func NewReturnRepository(db database.DBTX) ReturnRepository {
+	return &returnRepository{db: db}
+}
+
+func (r *returnRepository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
+const returnColumns = `id, order_id, user_id, seller_id, status, label_carrier, tracking_number, label_key,
+    label_created_at, rejection_reason, currency, refund_amount, created_at, updated_at`
+
+func scanReturn(row interface{ Scan(...any) error }) (*models.Return, error) {
+	ret := &models.Return{}
+	var (
+		label          models.ReturnLabel
+		labelCreatedAt sql.NullTime
+	)
+	err := row.Scan(&ret.ID, &ret.OrderID, &ret.UserID, &ret.SellerID, &ret.Status,
+		&label.Carrier, &label.TrackingNumber, &label.Key, &labelCreatedAt,
+		&ret.RejectionReason, &ret.Currency, &ret.RefundAmount, &ret.CreatedAt, &ret.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if label.Key != "" {
+		label.CreatedAt = labelCreatedAt.Time
+		ret.Label = &label
+	}
+	return ret, nil
+}
+
+// CreateReturn should run in a transaction so the return is never seen
+// without its items.
+func (r *returnRepository) CreateReturn(ctx context.Context, ret *models.Return) error {
+	query := `
+        -- name: CreateReturn
+        INSERT INTO returns (order_id, user_id, seller_id, status, currency)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query, ret.OrderID, ret.UserID, ret.SellerID, ret.Status, ret.Currency).
+		Scan(&ret.ID, &ret.CreatedAt, &ret.UpdatedAt)
+	if err != nil {
+		return translateError(err, "return")
+	}
+
+	query = `
+        -- name: CreateReturnItem
+        INSERT INTO return_items (return_id, variant_id, product_name, sku, unit_price, quantity, reason, note)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `
+	for i := range ret.Items {
+		item := &ret.Items[i]
+		_, err := r.conn(ctx).ExecContext(ctx, query,
+			ret.ID, item.VariantID, item.ProductName, item.SKU, item.UnitPrice, item.Quantity, item.Reason, item.Note,
+		)
+		if err != nil {
+			return translateError(err, "return")
+		}
+		item.Photos = []models.ReturnPhoto{}
+	}
+	return nil
+}
+
+func (r *returnRepository) GetReturnByID(ctx context.Context, id int) (*models.Return, error) {
+	query := `
+        -- name: GetReturnByID
+        SELECT ` + returnColumns + `
+        FROM returns
+        WHERE id = $1
+    `
+	return r.getReturn(ctx, query, id)
+}
+
+func (r *returnRepository) LockReturn(ctx context.Context, id int) (*models.Return, error) {
+	query := `
+        -- name: LockReturn
+        SELECT ` + returnColumns + `
+        FROM returns
+        WHERE id = $1
+        FOR UPDATE
+    `
+	return r.getReturn(ctx, query, id)
+}
+
+func (r *returnRepository) getReturn(ctx context.Context, query string, args ...any) (*models.Return, error) {
+	ret, err := scanReturn(r.conn(ctx).QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return nil, translateError(err, "return")
+	}
+	if err := r.loadItems(ctx, []*models.Return{ret}); err != nil {
+		return nil, err
+	}
+	if err := r.loadEvents(ctx, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// loadItems sets the items of returns, with their photos.
+func (r *returnRepository) loadItems(ctx context.Context, returns []*models.Return) error {
+	byID := make(map[int]*models.Return, len(returns))
+	ids := make([]int, len(returns))
+	for i, ret := range returns {
+		ret.Items = []models.ReturnItem{}
+		byID[ret.ID] = ret
+		ids[i] = ret.ID
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+        -- name: ListReturnItems
+        SELECT return_id, variant_id, product_name, sku, unit_price, quantity, reason, note, condition, received_quantity
+        FROM return_items
+        WHERE return_id = ANY($1)
+        ORDER BY return_id, product_name, sku
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return translateError(err, "return")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			returnID int
+			item     models.ReturnItem
+		)
+		err := rows.Scan(&returnID, &item.VariantID, &item.ProductName, &item.SKU, &item.UnitPrice, &item.Quantity,
+			&item.Reason, &item.Note, &item.Condition, &item.ReceivedQuantity)
+		if err != nil {
+			return err
+		}
+		item.Photos = []models.ReturnPhoto{}
+		ret := byID[returnID]
+		ret.Items = append(ret.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = `
+        -- name: ListReturnPhotos
+        SELECT return_id, variant_id, id, key, content_type, size, created_at
+        FROM return_photos
+        WHERE return_id = ANY($1)
+        ORDER BY id
+    `
+	rows, err = r.conn(ctx).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return translateError(err, "return")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			returnID, variantID int
+			photo               models.ReturnPhoto
+		)
+		err := rows.Scan(&returnID, &variantID, &photo.ID, &photo.Key, &photo.ContentType, &photo.Size, &photo.CreatedAt)
+		if err != nil {
+			return err
+		}
+		items := byID[returnID].Items
+		for i := range items {
+			if items[i].VariantID == variantID {
+				items[i].Photos = append(items[i].Photos, photo)
+			}
+		}
+	}
+	return rows.Err()
+}
+
+// loadEvents sets the events of ret, oldest first.
+func (r *returnRepository) loadEvents(ctx context.Context, ret *models.Return) error {
+	query := `
+        -- name: ListReturnEvents
+        SELECT id, return_id, actor_id, status, note, created_at
+        FROM return_events
+        WHERE return_id = $1
+        ORDER BY id
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, ret.ID)
+	if err != nil {
+		return translateError(err, "return")
+	}
+	defer rows.Close()
+
+	ret.Events = []models.ReturnEvent{}
+	for rows.Next() {
+		var (
+			event   models.ReturnEvent
+			actorID sql.NullInt64
+		)
+		if err := rows.Scan(&event.ID, &event.ReturnID, &actorID, &event.Status, &event.Note, &event.CreatedAt); err != nil {
+			return err
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			event.ActorID = &id
+		}
+		ret.Events = append(ret.Events, event)
+	}
+	return rows.Err()
+}
+
+func (r *returnRepository) ListReturns(ctx context.Context, filter ReturnFilter) ([]*models.Return, int, error) {
+	countQuery := `
+        -- name: CountReturns
+        SELECT COUNT(*)
+        FROM returns
+        WHERE ($1 = 0 OR user_id = $1) AND ($2 = 0 OR seller_id = $2) AND ($3 = '' OR status = $3)
+    `
+	var total int
+	err := r.conn(ctx).QueryRowContext(ctx, countQuery, filter.UserID, filter.SellerID, filter.Status).Scan(&total)
+	if err != nil {
+		return nil, 0, translateError(err, "return")
+	}
+
+	query := `
+        -- name: ListReturns
+        SELECT ` + returnColumns + `
+        FROM returns
+        WHERE ($1 = 0 OR user_id = $1) AND ($2 = 0 OR seller_id = $2) AND ($3 = '' OR status = $3)
+        ORDER BY id DESC
+        LIMIT $4 OFFSET $5
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query,
+		filter.UserID, filter.SellerID, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, translateError(err, "return")
+	}
+	defer rows.Close()
+
+	var returns []*models.Return
+	for rows.Next() {
+		ret, err := scanReturn(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		returns = append(returns, ret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if err := r.loadItems(ctx, returns); err != nil {
+		return nil, 0, err
+	}
+	return returns, total, nil
+}
+
+func (r *returnRepository) ReturnedQuantities(ctx context.Context, orderID int) (map[int]int, error) {
+	query := `
+        -- name: ReturnedQuantities
+        SELECT i.variant_id, SUM(i.quantity)
+        FROM return_items i
+        JOIN returns r ON r.id = i.return_id
+        WHERE r.order_id = $1 AND r.status <> 'rejected'
+        GROUP BY i.variant_id
+    `
+	rows, err := r.conn(ctx).QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, translateError(err, "return")
+	}
+	defer rows.Close()
+
+	quantities := make(map[int]int)
+	for rows.Next() {
+		var variantID, quantity int
+		if err := rows.Scan(&variantID, &quantity); err != nil {
+			return nil, err
+		}
+		quantities[variantID] = quantity
+	}
+	return quantities, rows.Err()
+}
+
+// UpdateReturn should run in a transaction so the return and its items are
+// saved together.
+func (r *returnRepository) UpdateReturn(ctx context.Context, ret *models.Return) error {
+	var label models.ReturnLabel
+	var labelCreatedAt sql.NullTime
+	if ret.Label != nil {
+		label = *ret.Label
+		labelCreatedAt = sql.NullTime{Time: label.CreatedAt, Valid: true}
+	}
+	query := `
+        -- name: UpdateReturn
+        UPDATE returns
+        SET status = $1, label_carrier = $2, tracking_number = $3, label_key = $4, label_created_at = $5,
+            rejection_reason = $6, refund_amount = $7, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $8
+        RETURNING updated_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		ret.Status, label.Carrier, label.TrackingNumber, label.Key, labelCreatedAt,
+		ret.RejectionReason, ret.RefundAmount, ret.ID,
+	).Scan(&ret.UpdatedAt)
+	if err != nil {
+		return translateError(err, "return")
+	}
+
+	query = `
+        -- name: UpdateReturnItem
+        UPDATE return_items
+        SET condition = $1, received_quantity = $2
+        WHERE return_id = $3 AND variant_id = $4
+    `
+	for _, item := range ret.Items {
+		_, err := r.conn(ctx).ExecContext(ctx, query, item.Condition, item.ReceivedQuantity, ret.ID, item.VariantID)
+		if err != nil {
+			return translateError(err, "return")
+		}
+	}
+	return nil
+}
+
+func (r *returnRepository) AddReturnPhoto(ctx context.Context, returnID, variantID int, photo *models.ReturnPhoto) error {
+	query := `
+        -- name: AddReturnPhoto
+        INSERT INTO return_photos (return_id, variant_id, key, content_type, size)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query, returnID, variantID, photo.Key, photo.ContentType, photo.Size).
+		Scan(&photo.ID, &photo.CreatedAt)
+	return translateError(err, "return_photo")
+}
+
+func (r *returnRepository) CreateReturnEvent(ctx context.Context, event *models.ReturnEvent) error {
+	query := `
+        -- name: CreateReturnEvent
+        INSERT INTO return_events (return_id, actor_id, status, note)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at
+    `
+	err := r.conn(ctx).QueryRowContext(ctx, query, event.ReturnID, event.ActorID, event.Status, event.Note).
+		Scan(&event.ID, &event.CreatedAt)
+	return translateError(err, "return_event")
+}
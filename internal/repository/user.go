@@ -4,94 +4,159 @@ import (
 	"context"
 	"database/sql"
 
+	"github.com/lib/pq"
+	"github.com/sudhir512kj/ecommerce_backend/database"
 	"github.com/sudhir512kj/ecommerce_backend/internal/models"
 )
 
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *models.User) error
+	// UpdateUser saves the user's profile: their name and email.
 	UpdateUser(ctx context.Context, user *models.User) error
+	// UpdatePassword replaces the user's password hash.
+	UpdatePassword(ctx context.Context, userID int, hash string) error
+	// UpdatePermissions replaces the user's permissions.
+	UpdatePermissions(ctx context.Context, userID int, permissions []models.Permission) error
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
 	GetUserByID(ctx context.Context, id int) (*models.User, error)
 	CreateOTP(ctx context.Context, otp *models.OTP) error
 	GetOTPByUserID(ctx context.Context, userID int) (*models.OTP, error)
-	GetOTPByOTP(otp string) (*models.OTP, error)
-	DeleteOTP(id int) error
+	GetOTPByOTP(ctx context.Context, otp string) (*models.OTP, error)
+	// DeleteOTP reports ErrNotFound if the OTP is already gone, so a caller
+	// consuming an OTP can tell whether it was the one to use it.
+	DeleteOTP(ctx context.Context, id int) error
 	CreateAddress(ctx context.Context, address *models.Address) error
 	GetAddressesByUserID(ctx context.Context, userID int) ([]*models.Address, error)
 }
 
 type userRepository struct {
-	db *sql.DB
+	db database.DBTX
 }
 
-func NewUserRepository(db *sql.DB) UserRepository {
+// NewUserRepository returns a UserRepository backed by db. Calls made with a
+// context carrying a transaction from database.WithTx run inside it.
+func NewUserRepository(db database.DBTX) UserRepository {
 	return &userRepository{db: db}
 }
 
+func (r *userRepository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
 func (r *userRepository) CreateUser(ctx context.Context, user *models.User) error {
 	// Implement database operations to create a new user
 	query := `
+        -- name: CreateUser
         INSERT INTO users (first_name, last_name, email, password, permissions)
         VALUES ($1, $2, $3, $4, $5)
         RETURNING id
     `
-	err := r.db.QueryRowContext(ctx, query, user.FirstName, user.LastName, user.Email, user.Password, "{seller}").Scan(&user.ID)
-	return err
+	err := r.conn(ctx).QueryRowContext(ctx, query, user.FirstName, user.LastName, user.Email, user.Password, pq.Array(fromPermissions(user.Permissions))).Scan(&user.ID)
+	return translateError(err, "user")
 }
 
 func (r *userRepository) UpdateUser(ctx context.Context, user *models.User) error {
 	// Implement database operations to update an existing user
 	query := `
+        -- name: UpdateUser
         UPDATE users
         SET first_name = $1, last_name = $2, email = $3
         WHERE id = $4
     `
-	_, err := r.db.ExecContext(ctx, query, user.FirstName, user.LastName, user.Email, user.ID)
-	return err
+	res, err := r.conn(ctx).ExecContext(ctx, query, user.FirstName, user.LastName, user.Email, user.ID)
+	if err != nil {
+		return translateError(err, "user")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return translateError(sql.ErrNoRows, "user")
+	}
+	return nil
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, userID int, hash string) error {
+	query := `
+        -- name: UpdatePassword
+        UPDATE users
+        SET password = $1
+        WHERE id = $2
+    `
+	res, err := r.conn(ctx).ExecContext(ctx, query, hash, userID)
+	if err != nil {
+		return translateError(err, "user")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return translateError(sql.ErrNoRows, "user")
+	}
+	return nil
+}
+
+func (r *userRepository) UpdatePermissions(ctx context.Context, userID int, permissions []models.Permission) error {
+	query := `
+        -- name: UpdatePermissions
+        UPDATE users
+        SET permissions = $1
+        WHERE id = $2
+    `
+	res, err := r.conn(ctx).ExecContext(ctx, query, pq.Array(fromPermissions(permissions)), userID)
+	if err != nil {
+		return translateError(err, "user")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return translateError(sql.ErrNoRows, "user")
+	}
+	return nil
 }
 
 func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	// Implement database operations to retrieve a user by email
 	query := `
-        SELECT id, first_name, last_name, email, password
+        -- name: GetUserByEmail
+        SELECT id, first_name, last_name, email, password, permissions
         FROM users
         WHERE email = $1
     `
 	user := &models.User{}
-	err := r.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password)
+	var permissions []string
+	err := r.conn(ctx).QueryRowContext(ctx, query, email).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password, pq.Array(&permissions))
 	if err != nil {
-		return nil, err
+		return nil, translateError(err, "user")
 	}
+	user.Permissions = toPermissions(permissions)
 	return user, nil
 }
 
 func (r *userRepository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
 	query := `
-        SELECT id, first_name, last_name, email, password
+        -- name: GetUserByID
+        SELECT id, first_name, last_name, email, password, permissions
         FROM users
         WHERE id = $1
     `
 	user := &models.User{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password)
+	var permissions []string
+	err := r.conn(ctx).QueryRowContext(ctx, query, id).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password, pq.Array(&permissions))
 	if err != nil {
-		return nil, err
+		return nil, translateError(err, "user")
 	}
+	user.Permissions = toPermissions(permissions)
 	return user, nil
 }
 
 func (r *userRepository) CreateOTP(ctx context.Context, otp *models.OTP) error {
 	// Implement database operations to create a new OTP
 	query := `
+        -- name: CreateOTP
         INSERT INTO otps (user_id, otp, expires_at)
         VALUES ($1, $2, $3)
     `
-	_, err := r.db.ExecContext(ctx, query, otp.UserID, otp.OTP, otp.ExpiresAt)
-	return err
+	_, err := r.conn(ctx).ExecContext(ctx, query, otp.UserID, otp.OTP, otp.ExpiresAt)
+	return translateError(err, "otp")
 }
 
 func (r *userRepository) GetOTPByUserID(ctx context.Context, userID int) (*models.OTP, error) {
 	// Implement database operations to retrieve an OTP by user ID
 	query := `
+        -- name: GetOTPByUserID
         SELECT id, user_id, otp, expires_at
         FROM otps
         WHERE user_id = $1
@@ -99,54 +164,68 @@ func (r *userRepository) GetOTPByUserID(ctx context.Context, userID int) (*model
         LIMIT 1
     `
 	otp := &models.OTP{}
-	err := r.db.QueryRowContext(ctx, query, userID).Scan(&otp.ID, &otp.UserID, &otp.OTP, &otp.ExpiresAt)
+	err := r.conn(ctx).QueryRowContext(ctx, query, userID).Scan(&otp.ID, &otp.UserID, &otp.OTP, &otp.ExpiresAt)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err, "otp")
 	}
 	return otp, nil
 }
 
-func (r *userRepository) GetOTPByOTP(otp string) (*models.OTP, error) {
-	query := "SELECT id, user_id, otp, expires_at, created_at, updated_at FROM otps WHERE otp = $1"
+func (r *userRepository) GetOTPByOTP(ctx context.Context, otp string) (*models.OTP, error) {
+	query := `
+        -- name: GetOTPByOTP
+        SELECT id, user_id, otp, expires_at, created_at, updated_at
+        FROM otps
+        WHERE otp = $1
+    `
 	var o models.OTP
-	err := r.db.QueryRow(query, otp).Scan(&o.ID, &o.UserID, &o.OTP, &o.ExpiresAt, &o.CreatedAt, &o.UpdatedAt)
+	err := r.conn(ctx).QueryRowContext(ctx, query, otp).Scan(&o.ID, &o.UserID, &o.OTP, &o.ExpiresAt, &o.CreatedAt, &o.UpdatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
+		return nil, translateError(err, "otp")
 	}
 	return &o, nil
 }
 
-func (r *userRepository) DeleteOTP(id int) error {
-	query := "DELETE FROM otps WHERE id = $1"
-	_, err := r.db.Exec(query, id)
-	return err
+func (r *userRepository) DeleteOTP(ctx context.Context, id int) error {
+	query := `
+        -- name: DeleteOTP
+        DELETE FROM otps
+        WHERE id = $1
+    `
+	res, err := r.conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return translateError(err, "otp")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return translateError(sql.ErrNoRows, "otp")
+	}
+	return nil
 }
 
 func (r *userRepository) CreateAddress(ctx context.Context, address *models.Address) error {
 	// Implement database operations to create a new address
 	query := `
+        -- name: CreateAddress
         INSERT INTO addresses (user_id, street, city, state, country, zipcode)
         VALUES ($1, $2, $3, $4, $5, $6)
         RETURNING id
     `
-	err := r.db.QueryRowContext(ctx, query, address.UserID, address.Street, address.City, address.State, address.Country, address.Zipcode).Scan(&address.ID)
-	return err
+	err := r.conn(ctx).QueryRowContext(ctx, query, address.UserID, address.Street, address.City, address.State, address.Country, address.Zipcode).Scan(&address.ID)
+	return translateError(err, "address")
 }
 
 func (r *userRepository) GetAddressesByUserID(ctx context.Context, userID int) ([]*models.Address, error) {
 	// Implement database operations to retrieve addresses by user ID
 	query := `
+        -- name: GetAddressesByUserID
         SELECT id, user_id, street, city, state, country, zipcode
         FROM addresses
         WHERE user_id = $1
     `
 	var addresses []*models.Address
-	rows, err := r.db.QueryContext(ctx, query, userID)
+	rows, err := r.conn(ctx).QueryContext(ctx, query, userID)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err, "address")
 	}
 	defer rows.Close()
 
@@ -164,3 +243,19 @@ func (r *userRepository) GetAddressesByUserID(ctx context.Context, userID int) (
 
 	return addresses, nil
 }
+
+func fromPermissions(permissions []models.Permission) []string {
+	names := make([]string, len(permissions))
+	for i, p := range permissions {
+		names[i] = string(p)
+	}
+	return names
+}
+
+func toPermissions(names []string) []models.Permission {
+	permissions := make([]models.Permission, len(names))
+	for i, name := range names {
+		permissions[i] = models.Permission(name)
+	}
+	return permissions
+}
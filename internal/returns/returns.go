@@ -0,0 +1,433 @@
+// Package returns lets buyers send back items of delivered orders: sellers
+// approve or reject each return, inspect what arrives, restock what can be
+// sold again and refund the buyer through the payment provider. Every step
+// is recorded in the return's audit trail.
+package returns
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/database"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/inventory"
+	"github.com/sudhir512kj/ecommerce_backend/internal/media"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/order"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+	"github.com/sudhir512kj/ecommerce_backend/internal/shipping"
+)
+
+// maxPhotos is how many photos each item of a return can have.
+const maxPhotos = 5
+
+var (
+	errOrderNotFound     = apperror.NotFound("order_not_found", "order not found")
+	errReturnNotFound    = apperror.NotFound("return_not_found", "return not found")
+	errNotReturnable     = apperror.Conflict("order_not_returnable", "Only delivered orders can be returned")
+	errWindowClosed      = apperror.Conflict("return_window_closed", "The order was delivered too long ago to be returned")
+	errItemNotInOrder    = apperror.Validation("item_not_in_order", "Only items of the order can be returned")
+	errSeveralSellers    = apperror.Validation("several_sellers", "Items sold by different sellers are returned separately")
+	errTooManyReturned   = apperror.Validation("return_quantity_exceeded", "More items are returned than are left to return from the order")
+	errItemNotInReturn   = apperror.NotFound("return_item_not_found", "return item not found")
+	errTooManyPhotos     = apperror.Conflict("too_many_photos", "An item can have at most "+strconv.Itoa(maxPhotos)+" photos")
+	errPhotosClosed      = apperror.Conflict("return_photos_closed", "Photos can only be added until the return is inspected")
+	errNoSellerAddress   = apperror.Conflict("seller_address_missing", "Add an address to send returns to before approving one")
+	errItemsNotInspected = apperror.Validation("return_items_not_inspected", "Every item of the return must be inspected")
+	errReceivedTooMany   = apperror.Validation("received_quantity_exceeded", "More items were received than are returned")
+	errMissingReceived   = apperror.Validation("missing_item_received", "Missing items can't have been received")
+	errRefundTooLarge    = apperror.Validation("refund_too_large", "The refund can't be more than the returned items cost")
+	errNothingToRefund   = apperror.Conflict("nothing_to_refund", "No items were received; give the amount to refund")
+	errRefundNoteMissing = apperror.Validation("refund_note_required", "Say in the note why more is refunded than the received items cost")
+)
+
+// Service moves returns through their statuses.
+type Service struct {
+	conf      config.Provider
+	returns   repository.ReturnRepository
+	orderRepo repository.OrderRepository
+	users     repository.UserRepository
+	orders    *order.Service
+	inventory *inventory.Service
+	carrier   shipping.Carrier
+	blobs     media.BlobStore
+	tx        database.Transactor
+	now       func() time.Time
+}
+
+func NewService(
+	conf config.Provider,
+	returns repository.ReturnRepository,
+	orderRepo repository.OrderRepository,
+	users repository.UserRepository,
+	orders *order.Service,
+	inventory *inventory.Service,
+	carrier shipping.Carrier,
+	blobs media.BlobStore,
+	tx database.Transactor,
+) *Service {
+	return &Service{
+		conf:      conf,
+		returns:   returns,
+		orderRepo: orderRepo,
+		users:     users,
+		orders:    orders,
+		inventory: inventory,
+		carrier:   carrier,
+		blobs:     blobs,
+		tx:        tx,
+		now:       time.Now,
+	}
+}
+
+// Request asks to return items of one of the user's delivered orders. The
+// items must all be sold by one seller, who then approves or rejects the
+// return.
+func (s *Service) Request(ctx context.Context, orderID, userID int, req models.ReturnCreateRequest) (*models.Return, error) {
+	var ret *models.Return
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		// The lock keeps concurrent requests from returning an item twice.
+		o, err := s.orderRepo.LockOrder(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		if o.UserID != userID {
+			return errOrderNotFound
+		}
+		if o.Status != models.OrderDelivered || o.DeliveredAt == nil {
+			return errNotReturnable
+		}
+		if s.now().After(o.DeliveredAt.Add(s.conf.Current().Returns.Window)) {
+			return errWindowClosed
+		}
+		returned, err := s.returns.ReturnedQuantities(ctx, orderID)
+		if err != nil {
+			return err
+		}
+
+		ret = &models.Return{
+			OrderID:  o.ID,
+			UserID:   userID,
+			Status:   models.ReturnRequested,
+			Currency: o.Currency,
+			Items:    make([]models.ReturnItem, len(req.Items)),
+		}
+		for i, wanted := range req.Items {
+			item := findOrderItem(o, wanted.VariantID)
+			if item == nil {
+				return errItemNotInOrder
+			}
+			if i > 0 && item.SellerID != ret.SellerID {
+				return errSeveralSellers
+			}
+			if wanted.Quantity > item.Quantity-returned[item.VariantID] {
+				return errTooManyReturned
+			}
+			ret.SellerID = item.SellerID
+			ret.Items[i] = models.ReturnItem{
+				VariantID:   item.VariantID,
+				ProductName: item.ProductName,
+				SKU:         item.SKU,
+				UnitPrice:   item.UnitPrice,
+				Quantity:    wanted.Quantity,
+				Reason:      wanted.Reason,
+				Note:        wanted.Note,
+			}
+		}
+		if err := s.returns.CreateReturn(ctx, ret); err != nil {
+			return err
+		}
+		return s.record(ctx, ret, &userID, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.returns.GetReturnByID(ctx, ret.ID)
+}
+
+func findOrderItem(o *models.Order, variantID int) *models.OrderItem {
+	for i := range o.Items {
+		if o.Items[i].VariantID == variantID {
+			return &o.Items[i]
+		}
+	}
+	return nil
+}
+
+// AddPhoto adds a photo showing the state of an item of one of the user's
+// returns. The photo is read from r, which holds size bytes. It is stored
+// before the transaction, so the return isn't locked while it uploads, and
+// deleted again if the transaction fails.
+func (s *Service) AddPhoto(ctx context.Context, id, variantID, userID int, r io.ReadSeeker, size int64) (*models.ReturnPhoto, error) {
+	info, err := media.Inspect(r)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := s.returns.GetReturnByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := checkPhoto(ret, variantID, userID); err != nil {
+		return nil, err
+	}
+	photo := &models.ReturnPhoto{
+		Key:         media.NewKey("returns/"+strconv.Itoa(id), info.Ext),
+		ContentType: info.ContentType,
+		Size:        size,
+	}
+	if err := s.blobs.Put(ctx, photo.Key, r, size, photo.ContentType); err != nil {
+		return nil, err
+	}
+
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		ret, err := s.returns.LockReturn(ctx, id)
+		if err != nil {
+			return err
+		}
+		// Checked again under the lock: another photo or a step of the
+		// return may have come first.
+		item, err := checkPhoto(ret, variantID, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.returns.AddReturnPhoto(ctx, id, variantID, photo); err != nil {
+			return err
+		}
+		return s.record(ctx, ret, &userID, "Added a photo of "+item.SKU)
+	})
+	if err != nil {
+		_ = s.blobs.Delete(ctx, photo.Key)
+		return nil, err
+	}
+	return photo, nil
+}
+
+// checkPhoto returns the item of the user's return ret that a photo can be
+// added to.
+func checkPhoto(ret *models.Return, variantID, userID int) (*models.ReturnItem, error) {
+	if ret.UserID != userID {
+		return nil, errReturnNotFound
+	}
+	if ret.Status != models.ReturnRequested && ret.Status != models.ReturnApproved {
+		return nil, errPhotosClosed
+	}
+	item := findReturnItem(ret, variantID)
+	if item == nil {
+		return nil, errItemNotInReturn
+	}
+	if len(item.Photos) >= maxPhotos {
+		return nil, errTooManyPhotos
+	}
+	return item, nil
+}
+
+func findReturnItem(ret *models.Return, variantID int) *models.ReturnItem {
+	for i := range ret.Items {
+		if ret.Items[i].VariantID == variantID {
+			return &ret.Items[i]
+		}
+	}
+	return nil
+}
+
+// Approve accepts a requested return and buys the label the buyer sends the
+// items back with, from the order's shipping address to the seller's first
+// address. The label is bought outside the transaction, under the return's
+// RMA reference, so a retry gets the same label instead of a second one.
+func (s *Service) Approve(ctx context.Context, id, actorID int) (*models.Return, error) {
+	ret, err := s.returns.GetReturnByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTransition(ret, models.ReturnApproved); err != nil {
+		return nil, err
+	}
+	o, err := s.orderRepo.GetOrderByID(ctx, ret.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := s.users.GetAddressesByUserID(ctx, ret.SellerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return nil, errNoSellerAddress
+	}
+	a := addresses[0]
+	label, err := s.carrier.CreateLabel(ctx, shipping.LabelRequest{
+		Reference: fmt.Sprintf("RMA-%d", ret.ID),
+		From:      o.ShippingAddress,
+		To:        models.OrderAddress{Street: a.Street, City: a.City, State: a.State, Country: a.Country, Zipcode: a.Zipcode},
+	})
+	if err != nil {
+		return nil, err
+	}
+	key := media.NewKey("returns/"+strconv.Itoa(ret.ID), label.Ext)
+	if err := s.blobs.Put(ctx, key, bytes.NewReader(label.Data), int64(len(label.Data)), label.ContentType); err != nil {
+		return nil, err
+	}
+
+	ret, err = s.transition(ctx, id, models.ReturnApproved, actorID, "", func(_ context.Context, ret *models.Return) error {
+		ret.Label = &models.ReturnLabel{
+			Carrier:        s.carrier.Name(),
+			TrackingNumber: label.TrackingNumber,
+			Key:            key,
+			CreatedAt:      s.now(),
+		}
+		return nil
+	})
+	if err != nil {
+		_ = s.blobs.Delete(ctx, key)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Reject turns down a requested return. The items can be asked to be
+// returned again.
+func (s *Service) Reject(ctx context.Context, id, actorID int, reason string) (*models.Return, error) {
+	return s.transition(ctx, id, models.ReturnRejected, actorID, reason, func(_ context.Context, ret *models.Return) error {
+		ret.RejectionReason = reason
+		return nil
+	})
+}
+
+// Inspect records what arrived of each item of an approved return and
+// restocks the resellable items into the warehouses they were sold from.
+func (s *Service) Inspect(ctx context.Context, id, actorID int, req models.ReturnInspectionRequest) (*models.Return, error) {
+	return s.transition(ctx, id, models.ReturnInspected, actorID, req.Note, func(ctx context.Context, ret *models.Return) error {
+		if len(req.Items) != len(ret.Items) {
+			return errItemsNotInspected
+		}
+		var resellable []models.ReservationItem
+		for _, inspected := range req.Items {
+			item := findReturnItem(ret, inspected.VariantID)
+			if item == nil {
+				return errItemsNotInspected
+			}
+			if inspected.ReceivedQuantity > item.Quantity {
+				return errReceivedTooMany
+			}
+			if inspected.Condition == models.ConditionMissing && inspected.ReceivedQuantity > 0 {
+				return errMissingReceived
+			}
+			item.Condition = inspected.Condition
+			item.ReceivedQuantity = inspected.ReceivedQuantity
+			if item.Condition == models.ConditionResellable && item.ReceivedQuantity > 0 {
+				resellable = append(resellable, models.ReservationItem{VariantID: item.VariantID, Quantity: item.ReceivedQuantity})
+			}
+		}
+
+		o, err := s.orderRepo.GetOrderByID(ctx, ret.OrderID)
+		if err != nil {
+			return err
+		}
+		if len(resellable) == 0 || o.ReservationID == nil {
+			return nil
+		}
+		return s.inventory.Restock(ctx, *o.ReservationID, resellable, &actorID, fmt.Sprintf("Return %d was inspected", ret.ID))
+	})
+}
+
+// Refund refunds the buyer for an inspected return through the order's
+// payment. amount defaults to the price of the items received; a smaller
+// amount is a partial refund. Refunding more than was received, up to the
+// price of every returned item, needs a note saying why.
+//
+// The provider is called before the return is marked refunded, outside the
+// transaction, under a key naming the return. Retrying a refund whose
+// answer was lost finishes it instead of refunding the buyer twice. A
+// refund the provider refused frees the key, and the return stays
+// inspected, so it can be refunded again, for a corrected amount too.
+func (s *Service) Refund(ctx context.Context, id, actorID int, req models.ReturnRefundRequest) (*models.Return, error) {
+	ret, err := s.returns.GetReturnByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTransition(ret, models.ReturnRefunded); err != nil {
+		return nil, err
+	}
+	var returned, received int64
+	for _, item := range ret.Items {
+		returned += item.UnitPrice * int64(item.Quantity)
+		received += item.UnitPrice * int64(item.ReceivedQuantity)
+	}
+	amount := req.Amount
+	if amount == 0 {
+		amount = received
+	}
+	if amount == 0 {
+		return nil, errNothingToRefund
+	}
+	if amount > returned {
+		return nil, errRefundTooLarge
+	}
+	if amount > received && req.Note == "" {
+		return nil, errRefundNoteMissing
+	}
+	if _, err := s.orders.Refund(ctx, ret.OrderID, amount, fmt.Sprintf("return-%d", ret.ID)); err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, id, models.ReturnRefunded, actorID, req.Note, func(_ context.Context, ret *models.Return) error {
+		ret.RefundAmount = amount
+		return nil
+	})
+}
+
+// transitions lists the statuses a return can move on to from each status.
+var transitions = map[models.ReturnStatus]models.ReturnStatus{
+	models.ReturnApproved:  models.ReturnRequested,
+	models.ReturnRejected:  models.ReturnRequested,
+	models.ReturnInspected: models.ReturnApproved,
+	models.ReturnRefunded:  models.ReturnInspected,
+}
+
+// transition moves the locked return on to the status to once apply has
+// changed it, and records who did it.
+func (s *Service) transition(ctx context.Context, id int, to models.ReturnStatus, actorID int, note string, apply func(context.Context, *models.Return) error) (*models.Return, error) {
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		ret, err := s.returns.LockReturn(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition(ret, to); err != nil {
+			return err
+		}
+		if err := apply(ctx, ret); err != nil {
+			return err
+		}
+		ret.Status = to
+		if err := s.returns.UpdateReturn(ctx, ret); err != nil {
+			return err
+		}
+		return s.record(ctx, ret, &actorID, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.returns.GetReturnByID(ctx, id)
+}
+
+// checkTransition reports whether ret can move on to the status to.
+func checkTransition(ret *models.Return, to models.ReturnStatus) error {
+	if ret.Status != transitions[to] {
+		return apperror.Conflict("invalid_return_transition",
+			fmt.Sprintf("A return that is %s can't become %s", ret.Status, to))
+	}
+	return nil
+}
+
+// record adds ret's current status to its audit trail.
+func (s *Service) record(ctx context.Context, ret *models.Return, actorID *int, note string) error {
+	return s.returns.CreateReturnEvent(ctx, &models.ReturnEvent{
+		ReturnID: ret.ID,
+		ActorID:  actorID,
+		Status:   ret.Status,
+		Note:     note,
+	})
+}
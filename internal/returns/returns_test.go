@@ -0,0 +1,293 @@
+package returns_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/app"
+	"github.com/sudhir512kj/ecommerce_backend/internal/cart"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/payment"
+	"github.com/sudhir512kj/ecommerce_backend/internal/shipping"
+	"github.com/sudhir512kj/ecommerce_backend/internal/testutil"
+)
+
+// fixture is an in-memory app with a delivered order of three 1000 cent
+// units, paid for by card, and a return of two of them.
+type fixture struct {
+	*app.App
+	buyer, seller, admin *models.User
+	variant              *models.ProductVariant
+	order                *models.Order
+	ret                  *models.Return
+}
+
+// newFixture builds the app with opts applied after app.InMemory.
+func newFixture(t *testing.T, opts ...app.Option) *fixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	a, err := app.New(testutil.Config(t), append([]app.Option{app.InMemory()}, opts...)...)
+	if err != nil {
+		t.Fatalf("new app: %v", err)
+	}
+	f := &fixture{App: a}
+	c := testutil.Catalog{Users: a.Users, Products: a.Products, Inventory: a.Stock}
+	f.buyer = c.User(t, "buyer@example.com", models.PermissionBuyer)
+	address := c.Address(t, f.buyer.ID)
+	// The seller's address is where returns are sent.
+	f.seller = c.User(t, "seller@example.com", models.PermissionSeller)
+	c.Address(t, f.seller.ID)
+	f.admin = c.User(t, "admin@example.com", models.PermissionAdmin)
+	f.variant = c.Variant(t, f.seller.ID, "LAMP-1", 1000, "USD")
+	c.Stock(t, f.variant.ID, c.Warehouse(t, f.seller.ID, "W-1").ID, 10)
+
+	if _, err := a.Cart.AddItem(ctx, cart.Owner{UserID: f.buyer.ID}, f.variant.ID, 3); err != nil {
+		t.Fatal(err)
+	}
+	f.order, _, err = a.Order.Place(ctx, f.buyer.ID, "checkout-1", models.CheckoutRequest{ShippingAddressID: address.ID})
+	if err != nil {
+		t.Fatalf("place: %v", err)
+	}
+	card := models.PaymentCard{Number: payment.CardSuccess, ExpMonth: 12, ExpYear: 2099, CVC: "123"}
+	if _, err := a.Order.Pay(ctx, f.order.ID, f.buyer.ID, card); err != nil {
+		t.Fatalf("pay: %v", err)
+	}
+	for _, to := range []models.OrderStatus{models.OrderFulfilled, models.OrderDelivered} {
+		if _, err := a.Order.Transition(ctx, f.order.ID, to, f.admin.ID); err != nil {
+			t.Fatalf("%s: %v", to, err)
+		}
+	}
+	f.ret, err = a.Return.Request(ctx, f.order.ID, f.buyer.ID, models.ReturnCreateRequest{
+		Items: []models.ReturnItemRequest{{VariantID: f.variant.ID, Quantity: 2, Reason: models.ReturnDamaged}},
+	})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	return f
+}
+
+// inspect approves the return and records received units as arrived.
+func (f *fixture) inspect(t *testing.T, received int, condition models.ReturnCondition) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := f.Return.Approve(ctx, f.ret.ID, f.seller.ID); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	_, err := f.Return.Inspect(ctx, f.ret.ID, f.seller.ID, models.ReturnInspectionRequest{
+		Items: []models.ReturnInspectionItem{{VariantID: f.variant.ID, ReceivedQuantity: received, Condition: condition}},
+	})
+	if err != nil {
+		t.Fatalf("inspect: %v", err)
+	}
+}
+
+// refunded returns how much of the order's payment was refunded.
+func (f *fixture) refunded(t *testing.T) int64 {
+	t.Helper()
+	payments, err := f.Payments.ListPaymentsByOrderID(context.Background(), f.order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payments[len(payments)-1].RefundedAmount
+}
+
+func TestRequest(t *testing.T) {
+	f := newFixture(t)
+	tests := []struct {
+		name     string
+		userID   int
+		quantity int
+		wantCode string
+	}{
+		{name: "someone else's order", userID: f.seller.ID, quantity: 1, wantCode: "order_not_found"},
+		{name: "more than is left", userID: f.buyer.ID, quantity: 2, wantCode: "return_quantity_exceeded"},
+		{name: "the rest", userID: f.buyer.ID, quantity: 1},
+		{name: "nothing left", userID: f.buyer.ID, quantity: 1, wantCode: "return_quantity_exceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.Return.Request(context.Background(), f.order.ID, tt.userID, models.ReturnCreateRequest{
+				Items: []models.ReturnItemRequest{{VariantID: f.variant.ID, Quantity: tt.quantity, Reason: models.ReturnNotNeeded}},
+			})
+			if testutil.Code(err) != tt.wantCode {
+				t.Errorf("err = %v, want %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestApprove(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	approved, err := f.Return.Approve(ctx, f.ret.ID, f.seller.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.Status != models.ReturnApproved || approved.Label == nil {
+		t.Fatalf("return = %s with label %v, want approved with a label", approved.Status, approved.Label)
+	}
+	// A purchase retried after a lost answer gets the label sold first.
+	label, err := f.Carrier.CreateLabel(ctx, shipping.LabelRequest{Reference: fmt.Sprintf("RMA-%d", f.ret.ID)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label.TrackingNumber != approved.Label.TrackingNumber {
+		t.Errorf("retried label %s, want %s", label.TrackingNumber, approved.Label.TrackingNumber)
+	}
+	if _, err := f.Return.Approve(ctx, f.ret.ID, f.seller.ID); testutil.Code(err) != "invalid_return_transition" {
+		t.Errorf("approve again: err = %v, want invalid_return_transition", err)
+	}
+}
+
+func TestAddPhoto(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	add := func(userID int) (*models.ReturnPhoto, error) {
+		return f.Return.AddPhoto(ctx, f.ret.ID, f.variant.ID, userID, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	}
+
+	if _, err := add(f.seller.ID); testutil.Code(err) != "return_not_found" {
+		t.Errorf("someone else's return: err = %v, want return_not_found", err)
+	}
+	for i := range 5 {
+		photo, err := add(f.buyer.ID)
+		if err != nil {
+			t.Fatalf("photo %d: %v", i+1, err)
+		}
+		if _, err := f.Blobs.Get(ctx, photo.Key); err != nil {
+			t.Errorf("photo %d wasn't stored: %v", i+1, err)
+		}
+	}
+	if _, err := add(f.buyer.ID); testutil.Code(err) != "too_many_photos" {
+		t.Errorf("sixth photo: err = %v, want too_many_photos", err)
+	}
+}
+
+func TestInspectRestocksResellableItems(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition models.ReturnCondition
+		received  int
+		available int
+	}{
+		{name: "resellable", condition: models.ConditionResellable, received: 2, available: 9},
+		{name: "one of two arrived", condition: models.ConditionResellable, received: 1, available: 8},
+		{name: "damaged", condition: models.ConditionDamaged, received: 2, available: 7},
+		{name: "missing", condition: models.ConditionMissing, received: 0, available: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			f.inspect(t, tt.received, tt.condition)
+			levels, err := f.Stock.ListStock(context.Background(), []int{f.variant.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := levels[0].Available; got != tt.available {
+				t.Errorf("available = %d, want %d", got, tt.available)
+			}
+		})
+	}
+}
+
+func TestRefund(t *testing.T) {
+	tests := []struct {
+		name     string
+		received int
+		req      models.ReturnRefundRequest
+		wantCode string
+		want     int64
+	}{
+		{name: "what was received", received: 2, want: 2000},
+		{name: "partial", received: 2, req: models.ReturnRefundRequest{Amount: 500}, want: 500},
+		{name: "more than was received", received: 1, req: models.ReturnRefundRequest{Amount: 2000}, wantCode: "refund_note_required"},
+		{name: "more than was received, with a note", received: 1, req: models.ReturnRefundRequest{Amount: 2000, Note: "Lost by the carrier"}, want: 2000},
+		{name: "more than was returned", received: 2, req: models.ReturnRefundRequest{Amount: 2001, Note: "Sorry"}, wantCode: "refund_too_large"},
+		{name: "nothing received", received: 0, wantCode: "nothing_to_refund"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t)
+			condition := models.ConditionDamaged
+			if tt.received == 0 {
+				condition = models.ConditionMissing
+			}
+			f.inspect(t, tt.received, condition)
+			ret, err := f.Return.Refund(ctx, f.ret.ID, f.seller.ID, tt.req)
+			if testutil.Code(err) != tt.wantCode {
+				t.Fatalf("err = %v, want %s", err, tt.wantCode)
+			}
+			if err == nil && (ret.Status != models.ReturnRefunded || ret.RefundAmount != tt.want) {
+				t.Errorf("return = %s, refunded %d; want refunded %d", ret.Status, ret.RefundAmount, tt.want)
+			}
+			if got := f.refunded(t); got != tt.want {
+				t.Errorf("payment refunded %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefundRetryDoesNotRefundTwice(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	f.inspect(t, 2, models.ConditionResellable)
+	// A refund the provider made whose answer was lost is retried under
+	// the same key.
+	if _, err := f.Order.Refund(ctx, f.order.ID, 2000, fmt.Sprintf("return-%d", f.ret.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Return.Refund(ctx, f.ret.ID, f.seller.ID, models.ReturnRefundRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.refunded(t); got != 2000 {
+		t.Errorf("refunded = %d, want 2000", got)
+	}
+}
+
+// refusingProvider refuses the first refund it is asked for.
+type refusingProvider struct {
+	payment.Provider
+	refused bool
+}
+
+func (p *refusingProvider) Refund(ctx context.Context, reference string, amount int64, key string) (*payment.Result, error) {
+	if !p.refused {
+		p.refused = true
+		return nil, payment.ErrInvalidState
+	}
+	return p.Provider.Refund(ctx, reference, amount, key)
+}
+
+func TestRefundAfterTheProviderRefused(t *testing.T) {
+	ctx := context.Background()
+	provider := &refusingProvider{Provider: payment.NewSimulator(testutil.Config(t).Current().Payments, api.V2.Prefix)}
+	f := newFixture(t, app.WithPaymentGateway(provider))
+	f.inspect(t, 2, models.ConditionResellable)
+	if _, err := f.Return.Refund(ctx, f.ret.ID, f.seller.ID, models.ReturnRefundRequest{}); !errors.Is(err, payment.ErrInvalidState) {
+		t.Fatalf("err = %v, want %v", err, payment.ErrInvalidState)
+	}
+	// The return can still be refunded, here for a corrected amount.
+	ret, err := f.Return.Refund(ctx, f.ret.ID, f.seller.ID, models.ReturnRefundRequest{Amount: 1500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.Status != models.ReturnRefunded || ret.RefundAmount != 1500 {
+		t.Errorf("return = %s, refunded %d; want refunded 1500", ret.Status, ret.RefundAmount)
+	}
+	if got := f.refunded(t); got != 1500 {
+		t.Errorf("payment refunded %d, want 1500", got)
+	}
+}
@@ -0,0 +1,49 @@
+// Package shipping talks to carriers: it buys the labels parcels are sent
+// with.
+package shipping
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+)
+
+// Carrier ships parcels.
+type Carrier interface {
+	// Name identifies the carrier on labels and shipments.
+	Name() string
+	// CreateLabel buys a label for a parcel from one address to another.
+	CreateLabel(ctx context.Context, req LabelRequest) (*Label, error)
+}
+
+// LabelRequest describes a parcel. Reference is ours, e.g. RMA-12, and is
+// printed on the label. It is also the idempotency key: asking again for a
+// label with the same reference returns the label bought first.
+type LabelRequest struct {
+	Reference string
+	From      models.OrderAddress
+	To        models.OrderAddress
+}
+
+// Label is a printable shipping label.
+type Label struct {
+	TrackingNumber string
+	ContentType    string
+	// Ext is the extension for the label's key, e.g. ".pdf".
+	Ext  string
+	Data []byte
+}
+
+// NewCarrier returns the carrier called name.
+func NewCarrier(name string) (Carrier, error) {
+	switch name {
+	case "fake":
+		return NewFakeCarrier(), nil
+	}
+	return nil, fmt.Errorf("unknown carrier %q", name)
+}
+
+func formatAddress(a models.OrderAddress) string {
+	return fmt.Sprintf("%s\n%s, %s %s\n%s", a.Street, a.City, a.State, a.Zipcode, a.Country)
+}
@@ -0,0 +1,119 @@
+// Package testutil holds what tests of several packages share: the
+// repository's configuration and the seeding of users, products and stock
+// into in-memory repositories.
+package testutil
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sudhir512kj/ecommerce_backend/config"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+	"github.com/sudhir512kj/ecommerce_backend/internal/models"
+	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+)
+
+// Config loads config.yaml from the root of the repository, wherever the
+// test runs from.
+func Config(t testing.TB) config.Provider {
+	t.Helper()
+	_, file, _, _ := runtime.Caller(0)
+	root := filepath.Join(filepath.Dir(file), "..", "..")
+	conf, err := config.Load(config.Options{Paths: []string{root}})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	return config.Static(conf)
+}
+
+// Code returns the code of an *apperror.Error, the error's text for other
+// errors and "" for nil, so tests can compare errors in tables.
+func Code(err error) string {
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// Catalog seeds the repositories it holds. Only those a method uses need
+// to be set.
+type Catalog struct {
+	Users     repository.UserRepository
+	Products  repository.ProductRepository
+	Inventory repository.InventoryRepository
+}
+
+// User creates a user with permissions.
+func (c Catalog) User(t testing.TB, email string, permissions ...models.Permission) *models.User {
+	t.Helper()
+	u := &models.User{FirstName: "Test", LastName: "User", Email: email, Permissions: permissions}
+	if err := c.Users.CreateUser(context.Background(), u); err != nil {
+		t.Fatalf("create user %s: %v", email, err)
+	}
+	return u
+}
+
+// Address adds an address to the user's address book.
+func (c Catalog) Address(t testing.TB, userID int) *models.Address {
+	t.Helper()
+	a := &models.Address{UserID: userID, Street: "1 Main St", City: "Springfield", State: "IL", Country: "US", Zipcode: "62701"}
+	if err := c.Users.CreateAddress(context.Background(), a); err != nil {
+		t.Fatalf("create address: %v", err)
+	}
+	return a
+}
+
+// Variant publishes a product the seller sells at price, with a single
+// variant named sku.
+func (c Catalog) Variant(t testing.TB, sellerID int, sku string, price int64, currency string) *models.ProductVariant {
+	t.Helper()
+	ctx := context.Background()
+	product := &models.Product{
+		SellerID: sellerID,
+		Name:     sku,
+		Slug:     strings.ToLower(sku),
+		Price:    price,
+		Currency: currency,
+		Status:   models.ProductPublished,
+	}
+	if err := c.Products.CreateProduct(ctx, product); err != nil {
+		t.Fatalf("create product %s: %v", sku, err)
+	}
+	variant := &models.ProductVariant{ProductID: product.ID, SKU: sku, Price: price}
+	if err := c.Products.CreateVariant(ctx, variant); err != nil {
+		t.Fatalf("create variant %s: %v", sku, err)
+	}
+	return variant
+}
+
+// Warehouse creates a warehouse of the seller's.
+func (c Catalog) Warehouse(t testing.TB, sellerID int, code string) *models.Warehouse {
+	t.Helper()
+	w := &models.Warehouse{SellerID: sellerID, Name: code, Code: code}
+	if err := c.Inventory.CreateWarehouse(context.Background(), w); err != nil {
+		t.Fatalf("create warehouse %s: %v", code, err)
+	}
+	return w
+}
+
+// Stock puts onHand units of the variant in the warehouse, none of them
+// reserved.
+func (c Catalog) Stock(t testing.TB, variantID, warehouseID, onHand int) {
+	t.Helper()
+	ctx := context.Background()
+	if err := c.Inventory.CreateStock(ctx, variantID, warehouseID); err != nil {
+		t.Fatalf("create stock: %v", err)
+	}
+	level := &models.StockLevel{VariantID: variantID, WarehouseID: warehouseID, OnHand: onHand}
+	if err := c.Inventory.UpdateStock(ctx, level); err != nil {
+		t.Fatalf("update stock: %v", err)
+	}
+}
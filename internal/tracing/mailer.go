@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/sudhir512kj/ecommerce_backend/internal/logging"
+	"github.com/sudhir512kj/ecommerce_backend/internal/mailer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracedMailer struct {
+	next    mailer.Mailer
+	tracing *Tracing
+}
+
+// InstrumentMailer wraps every send in a client span. Recipients are not
+// recorded, and addresses in SMTP errors are masked as they are in logs.
+func InstrumentMailer(next mailer.Mailer, t *Tracing) mailer.Mailer {
+	return &tracedMailer{next: next, tracing: t}
+}
+
+func (m *tracedMailer) Send(ctx context.Context, msg mailer.Message) error {
+	ctx, span := m.tracing.Start(ctx, "smtp send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("email.template", msg.Template),
+			attribute.Int("email.recipients", len(msg.To)),
+		),
+	)
+	defer span.End()
+
+	err := m.next.Send(ctx, msg)
+	if err != nil {
+		span.SetStatus(codes.Error, logging.MaskEmails(err.Error()))
+	}
+	return err
+}
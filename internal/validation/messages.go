@@ -0,0 +1,109 @@
+package validation
+
+import (
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+)
+
+// Message keys for problems found while decoding, before any rule runs.
+const (
+	unknownFieldKey = "unknown_field"
+	invalidTypeKey  = "invalid_type"
+)
+
+// language is one supported locale: validator's stock messages plus ours
+// for the custom rules and decoding problems. {0} is the field name.
+type language struct {
+	locale   locales.Translator
+	defaults func(*validator.Validate, ut.Translator) error
+	messages map[string]string
+}
+
+// languages are the supported locales. The first is the fallback.
+var languages = []language{
+	{
+		locale:   en.New(),
+		defaults: en_translations.RegisterDefaultTranslations,
+		messages: map[string]string{
+			"phone":         "{0} must be a phone number in international format, e.g. +14155550123",
+			"postal_code":   "{0} must be a valid postal code",
+			"currency":      "{0} must be an ISO 4217 currency code, e.g. USD",
+			"sku":           "{0} must contain only upper-case letters and digits, separated by - or _",
+			"slug":          "{0} must contain only lower-case letters and digits, separated by -",
+			unknownFieldKey: "{0} is not a known field",
+			invalidTypeKey:  "{0} must be of type {1}",
+		},
+	},
+	{
+		locale:   es.New(),
+		defaults: es_translations.RegisterDefaultTranslations,
+		messages: map[string]string{
+			"phone":         "{0} debe ser un número de teléfono en formato internacional, p. ej. +14155550123",
+			"postal_code":   "{0} debe ser un código postal válido",
+			"currency":      "{0} debe ser un código de moneda ISO 4217, p. ej. USD",
+			"sku":           "{0} solo puede contener letras mayúsculas y dígitos, separados por - o _",
+			"slug":          "{0} solo puede contener letras minúsculas y dígitos, separados por -",
+			unknownFieldKey: "{0} no es un campo conocido",
+			invalidTypeKey:  "{0} debe ser de tipo {1}",
+		},
+	},
+	{
+		locale:   fr.New(),
+		defaults: fr_translations.RegisterDefaultTranslations,
+		messages: map[string]string{
+			"phone":         "{0} doit être un numéro de téléphone au format international, par ex. +14155550123",
+			"postal_code":   "{0} doit être un code postal valide",
+			"currency":      "{0} doit être un code de devise ISO 4217, par ex. USD",
+			"sku":           "{0} ne doit contenir que des lettres majuscules et des chiffres, séparés par - ou _",
+			"slug":          "{0} ne doit contenir que des lettres minuscules et des chiffres, séparés par -",
+			unknownFieldKey: "{0} n'est pas un champ connu",
+			invalidTypeKey:  "{0} doit être de type {1}",
+		},
+	},
+}
+
+// newTranslator loads every language into v and returns the translator
+// that picks between them.
+func newTranslator(v *validator.Validate) *ut.UniversalTranslator {
+	supported := make([]locales.Translator, len(languages))
+	for i, lang := range languages {
+		supported[i] = lang.locale
+	}
+	uni := ut.New(supported[0], supported...)
+
+	for _, lang := range languages {
+		trans, _ := uni.GetTranslator(lang.locale.Locale())
+		if err := lang.defaults(v, trans); err != nil {
+			panic("validation: " + err.Error())
+		}
+		for key, text := range lang.messages {
+			if err := trans.Add(key, text, true); err != nil {
+				panic("validation: " + err.Error())
+			}
+			if key == unknownFieldKey || key == invalidTypeKey {
+				continue
+			}
+			err := v.RegisterTranslation(key, trans, noop, func(trans ut.Translator, fe validator.FieldError) string {
+				msg, _ := trans.T(fe.Tag(), fe.Field())
+				return msg
+			})
+			if err != nil {
+				panic("validation: " + err.Error())
+			}
+		}
+	}
+	return uni
+}
+
+// noop is the registration step of RegisterTranslation; the messages are
+// already added to the translator.
+func noop(ut.Translator) error {
+	return nil
+}
@@ -0,0 +1,96 @@
+package validation
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sudhir512kj/ecommerce_backend/internal/openapi"
+)
+
+// Patterns of the custom rules, shared with the OpenAPI document so clients
+// can validate before sending.
+const (
+	// phonePattern is an E.164 number: a plus sign and 8 to 15 digits.
+	phonePattern      = `^\+[1-9][0-9]{7,14}$`
+	postalCodePattern = `^[A-Za-z0-9][A-Za-z0-9 -]{1,8}[A-Za-z0-9]$`
+	currencyPattern   = `^[A-Z]{3}$`
+	skuPattern        = `^[A-Z0-9]+([-_][A-Z0-9]+)*$`
+	slugPattern       = `^[a-z0-9]+(-[a-z0-9]+)*$`
+)
+
+var (
+	phoneRe      = regexp.MustCompile(phonePattern)
+	postalCodeRe = regexp.MustCompile(postalCodePattern)
+	skuRe        = regexp.MustCompile(skuPattern)
+	slugRe       = regexp.MustCompile(slugPattern)
+
+	// postalCodes holds the formats of the countries that postal_code=XX
+	// checks exactly; other countries fall back to postalCodePattern.
+	postalCodes = map[string]string{
+		"CA": `^[A-Za-z][0-9][A-Za-z] ?[0-9][A-Za-z][0-9]$`,
+		"DE": `^[0-9]{5}$`,
+		"FR": `^[0-9]{5}$`,
+		"GB": `^[A-Za-z]{1,2}[0-9][A-Za-z0-9]? ?[0-9][A-Za-z]{2}$`,
+		"IN": `^[1-9][0-9]{5}$`,
+		"US": `^[0-9]{5}(-[0-9]{4})?$`,
+	}
+	postalCodeRes = map[string]*regexp.Regexp{}
+)
+
+func init() {
+	for country, pattern := range postalCodes {
+		postalCodeRes[country] = regexp.MustCompile(pattern)
+	}
+
+	openapi.RegisterRule("phone", pattern(phonePattern))
+	openapi.RegisterRule("currency", pattern(currencyPattern))
+	openapi.RegisterRule("sku", pattern(skuPattern))
+	openapi.RegisterRule("slug", pattern(slugPattern))
+	openapi.RegisterRule("postal_code", func(s *openapi.Schema, country string) {
+		if p, ok := postalCodes[strings.ToUpper(country)]; ok {
+			s.Pattern = p
+			return
+		}
+		s.Pattern = postalCodePattern
+	})
+}
+
+func pattern(p string) openapi.RuleFunc {
+	return func(s *openapi.Schema, _ string) {
+		s.Pattern = p
+	}
+}
+
+// registerRules adds the custom rules to v:
+//
+//	phone               an E.164 phone number, e.g. +14155550123
+//	postal_code[=XX]    a postal code, in the format of country XX if given
+//	currency            an ISO 4217 currency code, e.g. USD
+//	sku                 upper-case letters and digits separated by - or _
+//	slug                lower-case letters and digits separated by -
+func registerRules(v *validator.Validate) {
+	v.RegisterAlias("currency", "iso4217")
+	mustRegister(v, "phone", matches(phoneRe))
+	mustRegister(v, "sku", matches(skuRe))
+	mustRegister(v, "slug", matches(slugRe))
+	mustRegister(v, "postal_code", func(fl validator.FieldLevel) bool {
+		re, ok := postalCodeRes[strings.ToUpper(fl.Param())]
+		if !ok {
+			re = postalCodeRe
+		}
+		return re.MatchString(fl.Field().String())
+	})
+}
+
+func matches(re *regexp.Regexp) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return re.MatchString(fl.Field().String())
+	}
+}
+
+func mustRegister(v *validator.Validate, tag string, fn validator.Func) {
+	if err := v.RegisterValidation(tag, fn); err != nil {
+		panic("validation: " + err.Error())
+	}
+}
@@ -0,0 +1,291 @@
+// Package validation binds request bodies, query strings and path
+// parameters, checks them against their binding tags and reports every
+// invalid field with a code and a message in the client's language.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+)
+
+var (
+	errValidationFailed = apperror.Validation("validation_failed", "One or more fields are invalid")
+	errEmptyBody        = apperror.Validation("invalid_request", "The request body is empty")
+	errMalformedJSON    = apperror.Validation("invalid_request", "The request body is not valid JSON")
+	errMalformedQuery   = apperror.Validation("invalid_request", "The query string could not be parsed")
+	errMalformedForm    = apperror.Validation("invalid_request", "The form could not be parsed")
+	errMalformedPath    = apperror.NotFound("not_found", "The requested resource does not exist")
+)
+
+var (
+	validate   = newValidator()
+	translator = newTranslator(validate)
+)
+
+// newValidator reads rules from binding tags, as gin does, and names fields
+// after their JSON or form keys so errors match what the client sent.
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, key := range []string{"json", "form", "uri"} {
+			name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+	registerRules(v)
+	return v
+}
+
+// BindJSON decodes the request body into obj, a pointer to a struct, and
+// validates it. Fields the struct doesn't declare and anything after the
+// first JSON value are rejected. Errors are *apperror.Error values ready for
+// c.Error.
+func BindJSON(c *gin.Context, obj any) error {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return errEmptyBody
+	}
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(obj); err != nil {
+		return invalid(c, err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		if err == nil {
+			return errMalformedJSON
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return apperror.InvalidRequest(err)
+		}
+		return errMalformedJSON.Wrap(err)
+	}
+	return Struct(c, obj)
+}
+
+// BindQuery fills obj, a pointer to a struct with form tags, from the query
+// string and validates it.
+func BindQuery(c *gin.Context, obj any) error {
+	if err := binding.MapFormWithTag(obj, c.Request.URL.Query(), "form"); err != nil {
+		return errMalformedQuery.Wrap(err)
+	}
+	return Struct(c, obj)
+}
+
+// maxMultipartMemory is how much of a multipart body is kept in memory;
+// larger files are spooled to temporary files, which net/http removes once
+// the request is done.
+const maxMultipartMemory = 8 << 20
+
+var fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+
+// BindMultipart parses a multipart/form-data body into obj, a pointer to a
+// struct with form tags, and validates it. Fields of type
+// *multipart.FileHeader receive the first file uploaded under their name.
+func BindMultipart(c *gin.Context, obj any) error {
+	if err := c.Request.ParseMultipartForm(maxMultipartMemory); err != nil {
+		return apperror.InvalidRequest(err)
+	}
+	form := c.Request.MultipartForm
+	if err := binding.MapFormWithTag(obj, form.Value, "form"); err != nil {
+		return errMalformedForm.Wrap(err)
+	}
+
+	v := reflect.ValueOf(obj).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Type != fileHeaderType {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("form"), ",")
+		if files := form.File[name]; len(files) > 0 {
+			v.Field(i).Set(reflect.ValueOf(files[0]))
+		}
+	}
+	return Struct(c, obj)
+}
+
+// BindURI fills obj, a pointer to a struct with uri tags, from the path
+// parameters and validates it.
+func BindURI(c *gin.Context, obj any) error {
+	params := make(map[string][]string, len(c.Params))
+	for _, p := range c.Params {
+		params[p.Key] = []string{p.Value}
+	}
+	if err := binding.MapFormWithTag(obj, params, "uri"); err != nil {
+		return errMalformedPath.Wrap(err)
+	}
+	return Struct(c, obj)
+}
+
+// Struct validates obj, which the caller has already filled.
+func Struct(c *gin.Context, obj any) error {
+	if err := validate.Struct(obj); err != nil {
+		return invalid(c, err)
+	}
+	return nil
+}
+
+// Problem is an invalid field found by checks that binding tags can't
+// express. Code is a rule with a stock message, such as required or oneof,
+// or one of unknown_field and invalid_type. Param fills the message's
+// second placeholder, e.g. the allowed values of oneof.
+type Problem struct {
+	Field string
+	Code  string
+	Param string
+}
+
+// Fields reports problems the way binding failures are reported, with
+// messages in the client's language. It returns nil if there are none.
+func Fields(c *gin.Context, problems ...Problem) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	trans := translatorFor(c)
+	fields := make([]apperror.FieldError, len(problems))
+	for i, p := range problems {
+		msg, err := trans.T(p.Code, p.Field, p.Param)
+		if err != nil {
+			msg = p.Field + " is invalid"
+		}
+		fields[i] = apperror.FieldError{Field: p.Field, Code: p.Code, Message: msg}
+	}
+	c.Header("Content-Language", strings.ReplaceAll(trans.Locale(), "_", "-"))
+	e := *errValidationFailed
+	e.Fields = fields
+	return &e
+}
+
+// invalid turns a decoding or validation error into a validation_failed
+// error listing the fields, or an invalid_request error when the body as a
+// whole is unusable.
+func invalid(c *gin.Context, err error) error {
+	trans := translatorFor(c)
+
+	var (
+		fieldErrs validator.ValidationErrors
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+		fields    []apperror.FieldError
+	)
+	switch {
+	case errors.As(err, &fieldErrs):
+		for _, fe := range fieldErrs {
+			fields = append(fields, apperror.FieldError{
+				Field:   fieldPath(fe.Namespace()),
+				Code:    fe.Tag(),
+				Message: fe.Translate(trans),
+			})
+		}
+	case errors.As(err, &typeErr):
+		msg, _ := trans.T(invalidTypeKey, typeErr.Field, jsonType(typeErr.Type))
+		fields = append(fields, apperror.FieldError{Field: typeErr.Field, Code: invalidTypeKey, Message: msg})
+	case strings.HasPrefix(err.Error(), `json: unknown field "`):
+		// encoding/json has no error type for unknown fields.
+		name, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		msg, _ := trans.T(unknownFieldKey, name)
+		fields = append(fields, apperror.FieldError{Field: name, Code: unknownFieldKey, Message: msg})
+	case errors.Is(err, io.EOF):
+		return errEmptyBody
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return errMalformedJSON.Wrap(err)
+	default:
+		return apperror.InvalidRequest(err)
+	}
+
+	c.Header("Content-Language", strings.ReplaceAll(trans.Locale(), "_", "-"))
+	e := errValidationFailed.Wrap(err)
+	e.Fields = fields
+	return e
+}
+
+// fieldPath drops the struct name from a validator namespace, turning
+// "UserCreateRequest.email" into "email".
+func fieldPath(namespace string) string {
+	_, path, ok := strings.Cut(namespace, ".")
+	if !ok {
+		return namespace
+	}
+	return path
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
+
+// translatorFor picks the best supported language from the Accept-Language
+// header, falling back to English.
+func translatorFor(c *gin.Context) ut.Translator {
+	trans, _ := translator.FindTranslator(acceptedLanguages(c.GetHeader("Accept-Language"))...)
+	return trans
+}
+
+// acceptedLanguages lists the locales of an Accept-Language header by
+// preference, each followed by its base language, e.g. "fr-CA" gives fr_CA
+// then fr.
+func acceptedLanguages(header string) []string {
+	type accepted struct {
+		tag string
+		q   float64
+	}
+	var langs []accepted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			langs = append(langs, accepted{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	var locales []string
+	for _, l := range langs {
+		locale := strings.ReplaceAll(strings.ToLower(l.tag), "-", "_")
+		locales = append(locales, locale)
+		if base, _, ok := strings.Cut(locale, "_"); ok {
+			locales = append(locales, base)
+		}
+	}
+	return locales
+}
@@ -0,0 +1,74 @@
+package validation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudhir512kj/ecommerce_backend/internal/apperror"
+)
+
+type request struct {
+	Phone   string `json:"phone" binding:"omitempty,phone"`
+	Zipcode string `json:"zipcode" binding:"omitempty,postal_code=US"`
+	Postal  string `json:"postal" binding:"omitempty,postal_code"`
+	SKU     string `json:"sku" binding:"omitempty,sku"`
+	Slug    string `json:"slug" binding:"omitempty,slug"`
+	Count   int    `json:"count"`
+}
+
+func TestBindJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   string
+		wantFields []string
+	}{
+		{name: "valid", body: `{"phone":"+14155550123","zipcode":"94103-1234","postal":"SW1A 1AA","sku":"TEE-RED_L","slug":"red-tee"}`},
+		{name: "trailing whitespace", body: "{\"count\":1}\n"},
+		{name: "empty", body: "", wantCode: "invalid_request"},
+		{name: "malformed", body: `{"count":`, wantCode: "invalid_request"},
+		{name: "second value", body: `{"count":1}{"count":2}`, wantCode: "invalid_request"},
+		{name: "trailing garbage", body: `{"count":1} x`, wantCode: "invalid_request"},
+		{name: "unknown field", body: `{"count":1,"admin":true}`, wantCode: "validation_failed", wantFields: []string{"admin:unknown_field"}},
+		{name: "wrong type", body: `{"count":"1"}`, wantCode: "validation_failed", wantFields: []string{"count:invalid_type"}},
+		{name: "phone without plus", body: `{"phone":"4155550123"}`, wantCode: "validation_failed", wantFields: []string{"phone:phone"}},
+		{name: "phone too short", body: `{"phone":"+1415555"}`, wantCode: "validation_failed", wantFields: []string{"phone:phone"}},
+		{name: "country postal code", body: `{"zipcode":"SW1A 1AA"}`, wantCode: "validation_failed", wantFields: []string{"zipcode:postal_code"}},
+		{name: "postal code", body: `{"postal":"1"}`, wantCode: "validation_failed", wantFields: []string{"postal:postal_code"}},
+		{name: "lower-case sku", body: `{"sku":"tee-red"}`, wantCode: "validation_failed", wantFields: []string{"sku:sku"}},
+		{name: "sku with doubled separator", body: `{"sku":"TEE--RED"}`, wantCode: "validation_failed", wantFields: []string{"sku:sku"}},
+		{name: "slug with trailing dash", body: `{"slug":"red-tee-"}`, wantCode: "validation_failed", wantFields: []string{"slug:slug"}},
+		{name: "upper-case slug", body: `{"slug":"Red-Tee"}`, wantCode: "validation_failed", wantFields: []string{"slug:slug"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.body == "" {
+				c.Request.Body = http.NoBody
+			}
+
+			err := BindJSON(c, &request{})
+			var code string
+			var fields []string
+			var appErr *apperror.Error
+			if errors.As(err, &appErr) {
+				code = appErr.Code
+				for _, f := range appErr.Fields {
+					fields = append(fields, f.Field+":"+f.Code)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.wantCode || !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("err = %s %v, want %s %v", code, fields, tt.wantCode, tt.wantFields)
+			}
+		})
+	}
+}
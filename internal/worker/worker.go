@@ -0,0 +1,131 @@
+// Package worker runs long-lived background jobs alongside the HTTP server
+// and stops them together on shutdown.
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// Worker is a background job. Run must return once ctx is cancelled.
+type Worker interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// Func adapts a function to the Worker interface.
+type Func struct {
+	WorkerName string
+	Fn         func(ctx context.Context) error
+}
+
+func (f Func) Name() string { return f.WorkerName }
+
+func (f Func) Run(ctx context.Context) error { return f.Fn(ctx) }
+
+// Status describes the state of one worker in a Group.
+type Status struct {
+	Name    string
+	Running bool
+	Err     error
+}
+
+// Group starts a set of workers and waits for them on Stop.
+type Group struct {
+	mu      sync.Mutex
+	workers []Worker
+	status  map[string]*Status
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func NewGroup() *Group {
+	return &Group{status: make(map[string]*Status)}
+}
+
+// Add registers w. Workers added after Start are started immediately.
+func (g *Group) Add(w Worker) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.workers = append(g.workers, w)
+	g.status[w.Name()] = &Status{Name: w.Name()}
+	if g.cancel != nil {
+		g.run(w)
+	}
+}
+
+// Start runs every registered worker in its own goroutine. The workers are
+// cancelled when ctx is done or Stop is called.
+func (g *Group) Start(ctx context.Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.cancel != nil {
+		return
+	}
+	ctx, g.cancel = context.WithCancel(ctx)
+	g.ctx = ctx
+	for _, w := range g.workers {
+		g.run(w)
+	}
+}
+
+func (g *Group) run(w Worker) {
+	status := g.status[w.Name()]
+	status.Running = true
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		err := w.Run(g.ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.Error("worker stopped", slog.String("worker", w.Name()), slog.Any("error", err))
+		} else {
+			err = nil
+		}
+
+		g.mu.Lock()
+		status.Running = false
+		status.Err = err
+		g.mu.Unlock()
+	}()
+}
+
+// Statuses returns a snapshot of every registered worker's state.
+func (g *Group) Statuses() []Status {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	statuses := make([]Status, 0, len(g.workers))
+	for _, w := range g.workers {
+		statuses = append(statuses, *g.status[w.Name()])
+	}
+	return statuses
+}
+
+// Stop cancels all workers and waits for them to return, or for ctx to
+// expire.
+func (g *Group) Stop(ctx context.Context) error {
+	g.mu.Lock()
+	if g.cancel != nil {
+		g.cancel()
+	}
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
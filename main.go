@@ -1,15 +1,91 @@
 package main
 
 import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
 	"github.com/sudhir512kj/ecommerce_backend/config"
-	"github.com/sudhir512kj/ecommerce_backend/database"
-	"github.com/sudhir512kj/ecommerce_backend/server"
+	"github.com/sudhir512kj/ecommerce_backend/internal/app"
+	"github.com/sudhir512kj/ecommerce_backend/internal/logging"
 )
 
-var conf *config.Config
+const defaultShutdownTimeout = 30 * time.Second
 
 func main() {
-	conf = config.GetConfig()
-	db := database.NewPostgresDatabase(conf)
-	server.NewEchoServer(conf, db).Start()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "config":
+			os.Exit(runConfigCommand(os.Args[2:]))
+		case "openapi":
+			os.Exit(runOpenAPICommand(os.Args[2:]))
+		}
+	}
+
+	logLevel := new(slog.LevelVar)
+	slog.SetDefault(logging.New(os.Stdout, logLevel))
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	conf, err := config.Load(config.Options{})
+	if err != nil {
+		fatal("invalid configuration", err)
+	}
+	logLevel.Set(logging.ParseLevel(conf.Log.Level))
+	slog.Info("configuration loaded", slog.String("profile", conf.Profile))
+
+	watcher := config.NewWatcher(conf, config.Options{})
+	watcher.OnChange(func(_, next *config.Config) {
+		logLevel.Set(logging.ParseLevel(next.Log.Level))
+	})
+
+	application, err := app.New(watcher)
+	if err != nil {
+		fatal("building application failed", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	srv := application.Server
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Start(ctx)
+	}()
+	slog.Info("server started", slog.Int("port", conf.Server.Port))
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			fatal("server failed", err)
+		}
+		return
+	case <-ctx.Done():
+		slog.Info("shutdown signal received, draining connections")
+	}
+
+	timeout := conf.Server.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		fatal("graceful shutdown failed", err)
+	}
+	if err := <-errCh; err != nil {
+		fatal("server failed", err)
+	}
+	slog.Info("server stopped")
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenAPISpecUpToDate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	spec, err := buildSpec()
+	if err != nil {
+		t.Fatalf("build spec: %v", err)
+	}
+	committed, err := os.ReadFile(specFile)
+	if err != nil {
+		t.Fatalf("read %s: %v", specFile, err)
+	}
+	if !bytes.Equal(committed, spec) {
+		t.Errorf("%s is out of date with the registered routes; run `ecommerce_backend openapi generate`", specFile)
+	}
+}
@@ -22,9 +22,30 @@ func GenerateToken(config *config.Config, userID int) (string, error) {
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = config.JWT.KeyID
 	return token.SignedString([]byte(config.JWT.SecretKey))
 }
 
+// verificationKey returns the key a token was signed with: the active key,
+// a previous key still accepted during rotation, or the active key for
+// tokens issued before key IDs were introduced.
+func verificationKey(config *config.Config) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" || kid == config.JWT.KeyID {
+			return []byte(config.JWT.SecretKey), nil
+		}
+		if secret, ok := config.JWT.PreviousKeys[kid]; ok {
+			return []byte(secret), nil
+		}
+		return nil, errors.New("unknown signing key")
+	}
+}
+
 func GenerateResetToken(config *config.Config, userID int) (string, error) {
 	expirationTime := time.Now().Add(24 * time.Hour)
 	claims := &Claims{
@@ -39,12 +60,7 @@ func GenerateResetToken(config *config.Config, userID int) (string, error) {
 }
 
 func ParseToken(config *config.Config, tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(config.JWT.SecretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, verificationKey(config))
 
 	if err != nil {
 		return nil, err
@@ -59,12 +75,7 @@ func ParseToken(config *config.Config, tokenString string) (*Claims, error) {
 
 func VerifyToken(config *config.Config, tokenString string) (int, error) {
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(config.JWT.SecretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, verificationKey(config))
 
 	if err != nil {
 		return 0, err
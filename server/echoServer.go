@@ -1,63 +1,165 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sudhir512kj/ecommerce_backend/config"
-	"github.com/sudhir512kj/ecommerce_backend/database"
-	"github.com/sudhir512kj/ecommerce_backend/internal/handlers"
-	"github.com/sudhir512kj/ecommerce_backend/internal/repository"
+	"github.com/sudhir512kj/ecommerce_backend/internal/api"
+	"github.com/sudhir512kj/ecommerce_backend/internal/health"
+	"github.com/sudhir512kj/ecommerce_backend/internal/metrics"
+	"github.com/sudhir512kj/ecommerce_backend/internal/middleware"
+	"github.com/sudhir512kj/ecommerce_backend/internal/openapi"
+	"github.com/sudhir512kj/ecommerce_backend/internal/tracing"
+	"github.com/sudhir512kj/ecommerce_backend/internal/worker"
 )
 
+const (
+	defaultReadTimeout  = 15 * time.Second
+	defaultWriteTimeout = 15 * time.Second
+	defaultIdleTimeout  = 60 * time.Second
+)
+
+// Dependencies are the components the server routes to and shuts down.
+type Dependencies struct {
+	// Config is consulted per request by middleware whose settings can be
+	// reloaded.
+	Config config.Provider
+	// API holds the versioned routes of every module.
+	API     *api.Registry
+	Health  *health.Registry
+	Workers *worker.Group
+	Metrics *metrics.Metrics
+	Tracing *tracing.Tracing
+	// Closers are closed in order once the server and workers have stopped.
+	Closers []io.Closer
+}
+
 type echoServer struct {
 	app  *gin.Engine
-	db   database.Database
+	deps Dependencies
 	conf *config.Config
+	http *http.Server
 }
 
-func NewEchoServer(conf *config.Config, db database.Database) Server {
-	ginApp := gin.Default()
-	// echoApp.Logger.SetLevel(log.DEBUG)
+func NewEchoServer(conf *config.Config, deps Dependencies) (Server, error) {
+	ginApp := gin.New()
+	if err := ginApp.SetTrustedProxies(conf.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
 
-	return &echoServer{
+	s := &echoServer{
 		app:  ginApp,
-		db:   db,
+		deps: deps,
 		conf: conf,
+		http: &http.Server{
+			Addr:         fmt.Sprintf(":%d", conf.Server.Port),
+			Handler:      ginApp,
+			ReadTimeout:  durationOr(conf.Server.ReadTimeout, defaultReadTimeout),
+			WriteTimeout: durationOr(conf.Server.WriteTimeout, defaultWriteTimeout),
+			IdleTimeout:  durationOr(conf.Server.IdleTimeout, defaultIdleTimeout),
+		},
 	}
+	s.routes()
+	return s, nil
+}
+
+func (s *echoServer) routes() {
+	s.app.Use(
+		middleware.RequestID(),
+		s.deps.Tracing.Middleware(),
+		s.deps.Metrics.Middleware(),
+		middleware.AccessLog(),
+		middleware.Recovery(),
+		middleware.ErrorHandler(),
+		middleware.SecurityHeaders(s.deps.Config),
+		middleware.CORS(s.deps.Config),
+		middleware.BodyLimit(s.deps.Config),
+		middleware.CSRF(s.deps.Config),
+	)
+
+	s.deps.API.Mount(s.app)
+
+	// Health check adding; kept for existing probes, prefer /healthz
+	s.app.GET("/v1/health", func(c *gin.Context) {
+		c.String(http.StatusOK, "OK")
+	})
+
+	// Liveness and readiness probes
+	s.app.GET("/healthz", s.deps.Health.Liveness)
+	s.app.GET("/readyz", s.deps.Health.Readiness)
+
+	s.app.GET("/metrics", gin.WrapH(s.deps.Metrics.Handler()))
+
+	s.app.GET("/openapi.json", openapi.Handler(openapi.FromRegistry(s.deps.API)))
+	s.app.GET("/docs/*filepath", openapi.UI("/docs"))
+}
+
+func (s *echoServer) Handler() http.Handler {
+	return s.app
 }
 
-func (s *echoServer) Start() {
-	// s.app.Use(middleware.Recover())
-	// s.app.Use(middleware.Logger())
+func (s *echoServer) Start(ctx context.Context) error {
+	// Requests and workers outlive ctx: cancelling it, e.g. on SIGTERM,
+	// would abort them before Shutdown has drained them.
+	s.deps.Workers.Start(context.WithoutCancel(ctx))
+
+	var err error
+	if tlsConf := s.conf.Server.TLS; tlsConf != nil && tlsConf.CertFile != "" {
+		reloader, rErr := newCertReloader(tlsConf.CertFile, tlsConf.KeyFile)
+		if rErr != nil {
+			return fmt.Errorf("load TLS certificate: %w", rErr)
+		}
+		s.http.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+		err = s.http.ListenAndServeTLS("", "")
+	} else {
+		err = s.http.ListenAndServe()
+	}
 
-	// Create the repositories
-	userRepo := repository.NewUserRepository(s.db.GetDb())
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
 
-	// Create the handlers
-	userHandler := handlers.NewUserHandler(userRepo)
+func (s *echoServer) Shutdown(ctx context.Context) error {
+	var errs []error
 
-	// Define the API routes
-	api := s.app.Group("/api")
-	{
-		users := api.Group("/users")
-		{
-			users.POST("/register", userHandler.Register)
-			users.POST("/login", userHandler.Login)
-			users.POST("/forgot-password", userHandler.ForgotPassword)
-			users.POST("/reset-password", userHandler.AuthMiddleware, userHandler.ChangePassword)
-			// users.GET("/profile", userHandler.GetProfile)
-			users.PUT("/update-profile", userHandler.AuthMiddleware, userHandler.UpdateProfile)
-			users.POST("/verify-otp", userHandler.VerifyOTP)
+	s.deps.Health.SetDraining()
+	if delay := s.conf.Server.DrainDelay; delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+	// Workers are stopped after the requests that may be feeding them.
+	if err := s.http.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("http server: %w", err))
+	}
+	if err := s.deps.Workers.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("background workers: %w", err))
+	}
+	for _, closer := range s.deps.Closers {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
-	// Health check adding
-	s.app.GET("/v1/health", func(c *gin.Context) {
-		c.String(http.StatusOK, "OK")
-	})
+	return errors.Join(errs...)
+}
 
-	serverUrl := fmt.Sprintf(":%d", s.conf.Server.Port)
-	s.app.Run(serverUrl)
+func durationOr(d, fallback time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return fallback
 }
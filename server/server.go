@@ -1,5 +1,18 @@
 package server
 
+import (
+	"context"
+	"net/http"
+)
+
 type Server interface {
-	Start()
+	// Start serves HTTP until Shutdown is called, even if ctx is cancelled
+	// first. It returns nil after a clean shutdown.
+	Start(ctx context.Context) error
+	// Shutdown fails readiness, waits the configured drain delay, drains
+	// in-flight requests, stops background workers and closes the database
+	// pool.
+	Shutdown(ctx context.Context) error
+	// Handler exposes the routed handler, e.g. for httptest servers.
+	Handler() http.Handler
 }